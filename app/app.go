@@ -8,7 +8,6 @@ import (
 	"bytes"
 	"fmt"
 	"strings"
-	"sync"
 )
 
 const (
@@ -42,11 +41,6 @@ var (
 	// per the semantic versioning spec.
 	appBuild = "dev"
 
-	// busy is a flag that indicates that a process is currently running
-	busy = false
-
-	busyLocker sync.Mutex
-
 	// ShutdownOps holds function that must be ran before shutdown
 	ShutdownOps []func()
 )
@@ -105,13 +99,3 @@ func normalizePreRelString(str string) string {
 func normalizeBuildString(str string) string {
 	return normalizeSemString(str, semanticBuildAlphabet)
 }
-
-// MarkBusyIfFree locks the busy locker to ensure sequential execution of callers
-func MarkBusyIfFree() {
-	busyLocker.Lock()
-}
-
-// ReleaseForNewModule unlocks the busy locker to allow other modules to start running
-func ReleaseForNewModule() {
-	busyLocker.Unlock()
-}
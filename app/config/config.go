@@ -37,6 +37,8 @@ const (
 	defaultMempoolInterval     = 60
 	defaultVSPInterval         = 300
 	defaultPowInterval         = 300
+	defaultOrderBookInterval   = 300
+	defaultGapFillInterval     = 21600
 	defaultSyncInterval        = 60
 	defaultSnapshotInterval    = 720
 	defaultRedditInterval      = 60
@@ -60,6 +62,7 @@ const (
 var (
 	defaultHomeDir        = dcrutil.AppDataDir("dcrextdata", false)
 	defaultCacheDir       = filepath.Join(defaultHomeDir, "data")
+	defaultKVStoreDir     = filepath.Join(defaultHomeDir, "kvstore")
 	defaultConfigFilename = filepath.Join(defaultHomeDir, defaultConfigFileName)
 	defaultLogFilename    = filepath.Join(defaultHomeDir, "log", defaultLogFileName)
 
@@ -72,26 +75,29 @@ var (
 
 func defaultFileOptions() ConfigFileOptions {
 	cfg := ConfigFileOptions{
-		LogFile:          defaultLogFilename,
-		CacheDir:         defaultCacheDir,
-		DBHost:           defaultDbHost,
-		DBPort:           defaultDbPort,
-		DBUser:           defaultDbUser,
-		DBPass:           defaultDbPass,
-		DBName:           defaultDbName,
-		LogLevel:         defaultLogLevel,
-		VSPInterval:      defaultVSPInterval,
-		PowInterval:      defaultPowInterval,
-		MempoolInterval:  defaultMempoolInterval,
-		DcrdNetworkType:  defaultDcrdNetworkType,
-		DcrdRpcServer:    defaultDcrdServer,
-		DcrdRpcUser:      defaultDcrdUser,
-		DcrdRpcPassword:  defaultDcrdPassword,
-		HTTPHost:         defaultHttpHost,
-		HTTPPort:         defaultHttpPort,
-		SyncInterval:     defaultSyncInterval,
-		ChartsCacheDump:  defaultChartsCacheDump,
-		EnableChartCache: true,
+		LogFile:           defaultLogFilename,
+		CacheDir:          defaultCacheDir,
+		KVStoreDir:        defaultKVStoreDir,
+		DBHost:            defaultDbHost,
+		DBPort:            defaultDbPort,
+		DBUser:            defaultDbUser,
+		DBPass:            defaultDbPass,
+		DBName:            defaultDbName,
+		LogLevel:          defaultLogLevel,
+		VSPInterval:       defaultVSPInterval,
+		PowInterval:       defaultPowInterval,
+		OrderBookInterval: defaultOrderBookInterval,
+		GapFillInterval:   defaultGapFillInterval,
+		MempoolInterval:   defaultMempoolInterval,
+		DcrdNetworkType:   defaultDcrdNetworkType,
+		DcrdRpcServer:     defaultDcrdServer,
+		DcrdRpcUser:       defaultDcrdUser,
+		DcrdRpcPassword:   defaultDcrdPassword,
+		HTTPHost:          defaultHttpHost,
+		HTTPPort:          defaultHttpPort,
+		SyncInterval:      defaultSyncInterval,
+		ChartsCacheDump:   defaultChartsCacheDump,
+		EnableChartCache:  true,
 	}
 
 	cfg.RedditStatInterval = defaultRedditInterval
@@ -130,9 +136,24 @@ type ConfigFileOptions struct {
 	DBPass string `long:"dbpass" description:"Database password"`
 	DBName string `long:"dbname" description:"Database name"`
 
+	// In memory storage
+	InMemory bool `long:"inmemory" description:"Keep the collected data in memory instead of PostgreSQL. The data is lost on shutdown"`
+
+	// Embedded key-value storage
+	KVStore    bool   `long:"kvstore" description:"Store the collected data in an embedded LevelDB database instead of PostgreSQL. Every record is also kept in memory, so the RAM used grows with the collected history"`
+	KVStoreDir string `long:"kvstoredir" description:"The directory of the embedded database"`
+
 	// Http Server
 	HTTPHost string `long:"httphost" description:"HTTP server host address or IP when running godcr in http mode."`
 	HTTPPort string `long:"httpport" description:"HTTP server port when running godcr in http mode."`
+	AdminKey string `long:"adminkey" description:"Bearer token required by the admin API. The admin API is disabled if it is not set"`
+
+	// Access to the http server
+	RequireAPIKey   bool     `long:"requireapikey" description:"Require an API key, sent in the X-API-Key header or the api_key parameter, for /api/v1, /api/export and /api/events"`
+	IPRateLimit     int      `long:"ratelimit" description:"Number of requests per minute allowed per IP address for the clients without an API key, 0 for no limit"`
+	APIKeyRateLimit int      `long:"apikeyratelimit" description:"Number of requests per minute allowed per API key without a limit of its own, 0 for no limit"`
+	CORSOrigins     []string `long:"corsorigin" description:"Origin allowed to call the http server from a browser, * for any origin"`
+	SyncAllowedKeys []string `long:"syncallowkey" description:"Name of an API key allowed to call the sync endpoints. Only the keys listed may sync if any is set"`
 
 	// pprof
 	Cpuprofile string `long:"cpuprofile" description:"write cpu profile to file"`
@@ -141,6 +162,15 @@ type ConfigFileOptions struct {
 	// Exchange collector
 	DisableExchangeTicks bool     `long:"disablexcticks" description:"Disables collection of ticker data from exchanges"`
 	DisabledExchanges    []string `long:"disableexchange" description:"Disable data collection for this exchange"`
+	EnabledExchanges     []string `long:"enableexchange" description:"Enable data collection for this exchange, that is off by default (huobi, kucoin, bitfinex, upbit, dragonex)"`
+	ExchangeConfigFile   string   `long:"exchangeconfig" description:"Path to a JSON file defining additional exchanges to collect ticks from"`
+	StreamExchanges      []string `long:"streamexchange" description:"Stream trades from this exchange over websocket to build live candles"`
+	GapFillInterval      int64    `long:"gapfillinterval" description:"Number of seconds between the scans for missing exchange ticks to backfill, 0 to disable"`
+
+	// Order book collector
+	DisableOrderBook  bool      `long:"disableorderbook" description:"Disables collection of order book depth snapshots"`
+	OrderBookInterval int64     `long:"orderbookinterval" description:"Number of seconds between order book snapshots"`
+	OrderBookBands    []float64 `long:"orderbookband" description:"Percentage distance from the mid price to measure order book depth at"`
 
 	// PoW collector
 	DisablePow   bool     `long:"disablepow" description:"Disables collection of data for pows"`
@@ -165,6 +195,7 @@ type ConfigFileOptions struct {
 	SyncInterval  int      `long:"syncinterval" description:"The number of minuets between sync operations"`
 	SyncSources   []string `long:"syncsource" description:"Address of remote instance to sync data from"`
 	SyncDatabases []string `long:"syncdatabase" description:"Database to sync remote data to"`
+	SyncAPIKeys   []string `long:"syncapikey" description:"API key sent to the sync source of the same position"`
 
 	// charts
 	EnableChartCache bool `long:"enablechartcache" description:"Enable chart data caching"`
@@ -177,9 +208,19 @@ type ConfigFileOptions struct {
 // CommandLineOptions holds the top-level options/flags that are displayed on the command-line menu
 type CommandLineOptions struct {
 	Reset      bool   `short:"R" long:"reset" description:"Drop all database tables and start over"`
-	ResetCache bool   `short:"E" long:"reset-cache" description:"Drop all database tables used in storing computed cache data"`
+	ResetCache bool   `short:"E" long:"reset-cache" description:"Empty all database tables used in storing computed cache data"`
+	FillGaps   bool   `long:"fill-gaps" description:"Backfill the missing exchange ticks and report the gaps that could not be filled"`
 	ConfigFile string `short:"C" long:"configfile" description:"Path to Configuration file"`
 	HttpMode   string `long:"http" description:"Launch http server"`
+
+	// Export command
+	ExportFormat string `long:"export-format" description:"Format of the export command, csv or ndjson (default csv)"`
+	ExportStart  string `long:"export-start" description:"Only export the rows at or after this time, RFC 3339 or UNIX timestamp"`
+	ExportEnd    string `long:"export-end" description:"Only export the rows before this time, RFC 3339 or UNIX timestamp"`
+	ExportOutput string `long:"export-output" description:"File written by the export command (default <name>.<format>)"`
+	ExportBin    string `long:"export-bin" description:"Bin of the exported chart, default, hour or day"`
+	ExportAxis   string `long:"export-axis" description:"X axis of the exported chart, time or height"`
+	ExportExtras string `long:"export-extras" description:"Sources of the exported chart, separated by |"`
 }
 
 type CommunityStatOptions struct {
@@ -302,6 +343,10 @@ func LoadConfig() (*Config, []string, error) {
 		return nil, nil, errors.New("You must set the same number of sync source and database.")
 	}
 
+	if len(cfg.SyncAPIKeys) > len(cfg.SyncSources) {
+		return nil, nil, errors.New("You cannot set more sync API keys than sync sources.")
+	}
+
 	return &cfg, unknownArg, nil
 }
 
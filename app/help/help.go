@@ -3,10 +3,12 @@ package help
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/planetdecred/dcrextdata/app"
 	"github.com/planetdecred/dcrextdata/app/config"
+	"github.com/planetdecred/dcrextdata/export"
 )
 
 type GeneralHelpData struct {
@@ -23,6 +25,21 @@ func PrintGeneralHelp(output io.Writer, parser *flags.Parser) {
 
 	// print general app options
 	printOptionGroups(tabWriter, parser.Groups())
+
+	exportTables := make([]string, len(export.Tables))
+	for i, table := range export.Tables {
+		exportTables[i] = table.Name
+	}
+
+	// print commands
+	fmt.Fprintln(tabWriter, "Commands:")
+	fmt.Fprintf(tabWriter, "  migrate status \t Show the applied and pending database schema migrations\n")
+	fmt.Fprintf(tabWriter, "  migrate up \t Apply every pending database schema migration\n")
+	fmt.Fprintf(tabWriter, "  migrate down \t Revert the last applied database schema migration\n")
+	fmt.Fprintf(tabWriter, "  migrate to <version> \t Migrate the database schema to the given version\n")
+	fmt.Fprintf(tabWriter, "  export <table> \t Export a table, one of %s\n", strings.Join(exportTables, ", "))
+	fmt.Fprintf(tabWriter, "  export chart <type> [<data type>] \t Export the points of a chart\n")
+	tabWriter.Flush()
 }
 
 func HelpParser() *flags.Parser {
@@ -62,6 +62,29 @@ func GetResponse(ctx context.Context, client *http.Client, url string, destinati
 	return nil
 }
 
+// GetResponseOnce sends a single request to url and decodes the json body into
+// destination. Unlike GetResponse it does not retry and a non 2xx status is
+// returned as an error, leaving the retry policy to the caller.
+func GetResponseOnce(ctx context.Context, client *http.Client, url string, destination interface{}) error {
+	if client.Timeout == time.Duration(0) {
+		client.Timeout = 10 * time.Second
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded with %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(destination)
+}
+
 func AddParams(base string, params map[string]interface{}) (string, error) {
 	var strBuilder strings.Builder
 
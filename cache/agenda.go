@@ -0,0 +1,56 @@
+package cache
+
+import "sort"
+
+// AgendaTally is the number of votes for a choice of an agenda in a bucket,
+// a block or a time bin. Height and Time are the first block and time of the
+// bucket.
+type AgendaTally struct {
+	Bucket uint64
+	Height uint64
+	Time   uint64
+	Choice string
+	Count  uint64
+}
+
+// MakeAgendaChart encodes the number of votes of each choice over the height
+// or the time axis. The tallies must be ordered by bucket.
+func MakeAgendaChart(charts *Manager, tallies []AgendaTally, axis axisType) ([]byte, error) {
+	var heights, times ChartUints
+	var choices []string
+	counts := make(map[string]map[int]uint64)
+	for i, tally := range tallies {
+		if i == 0 || tally.Bucket != tallies[i-1].Bucket {
+			heights = append(heights, tally.Height)
+			times = append(times, tally.Time)
+		}
+		last := len(heights) - 1
+		if tally.Height < heights[last] {
+			heights[last] = tally.Height
+		}
+		if tally.Time < times[last] {
+			times[last] = tally.Time
+		}
+		if _, found := counts[tally.Choice]; !found {
+			counts[tally.Choice] = make(map[int]uint64)
+			choices = append(choices, tally.Choice)
+		}
+		counts[tally.Choice][last] += tally.Count
+	}
+
+	sort.Strings(choices)
+	var keys = []string{"x"}
+	var sets = []Lengther{times}
+	if axis == HeightAxis {
+		sets[0] = heights
+	}
+	for _, choice := range choices {
+		series := make(ChartUints, len(heights))
+		for i := range series {
+			series[i] = counts[choice][i]
+		}
+		keys = append(keys, choice)
+		sets = append(sets, series)
+	}
+	return charts.Encode(keys, sets...)
+}
@@ -7,8 +7,10 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/decred/dcrd/chaincfg"
+	"github.com/planetdecred/dcrextdata/metrics"
 	"github.com/volatiletech/null"
 )
 
@@ -21,6 +23,12 @@ const (
 	VSP         = "vsp"
 	Exchange    = "exchange"
 	Snapshot    = "snapshot"
+	OrderBook   = "orderbook"
+	ExchangeIdx = "exchange-index"
+	Agenda      = "agenda"
+
+	MempoolFeeRate = "mempool-fee-rate"
+	Confirmation   = "confirmation"
 
 	// ADay defines the number of seconds in a day.
 	ADay   = 86400
@@ -46,6 +54,9 @@ const (
 	MempoolFees    = "fees"
 	MempoolTxCount = "tx-count"
 
+	ConfirmationFeeRate = "fee-rate"
+	ConfirmationTxType  = "tx-type"
+
 	BlockPropagation = "block-propagation"
 	BlockTimestamp   = "block-timestamp"
 	VotesReceiveTime = "votes-receive-time"
@@ -83,6 +94,11 @@ func ParseAxis(aType string) axisType {
 		return MempoolTxCount
 	case MempoolFees:
 		return MempoolFees
+		// confirmation
+	case ConfirmationFeeRate:
+		return ConfirmationFeeRate
+	case ConfirmationTxType:
+		return ConfirmationTxType
 		//Propagation
 	case BlockPropagation:
 		return BlockPropagation
@@ -123,6 +139,16 @@ func ParseAxis(aType string) axisType {
 		return ExchangeOpenAxis
 	case ExchangeLowAxis:
 		return ExchangeLowAxis
+		// order book
+	case OrderBookBidAxis:
+		return OrderBookBidAxis
+	case OrderBookAskAxis:
+		return OrderBookAskAxis
+		// exchange index
+	case IndexBTCAxis:
+		return IndexBTCAxis
+	case IndexUSDAxis:
+		return IndexUSDAxis
 		// snapshot
 	case SnapshotNodes:
 		return SnapshotNodes
@@ -711,11 +737,11 @@ func (data ChartUints) Avg(s, e int) uint64 {
 	return sum / uint64(e-s)
 }
 
-// The chart data is cached with the current cacheID of the zoomSet or windowSet.
+// The chart data is cached with the generation of its chart at the time it
+// was retrieved, so that data retrieved before an invalidation is not kept.
 type cachedChart struct {
 	CacheID uint64
 	Data    []byte
-	Version uint64
 }
 
 // A generic structure for JSON encoding keyed data sets.
@@ -743,9 +769,14 @@ type Manager struct {
 	ctx context.Context
 
 	dir       string
-	cache     map[string]*cachedChart
 	retrivers map[string]Retriver
 
+	// cacheMtx protects cache and generations. The generation of a chart
+	// is incremented by every invalidation.
+	cacheMtx    sync.RWMutex
+	cache       map[string]*cachedChart
+	generations map[string]uint64
+
 	syncSource    []string
 	VSPSources    []string
 	PowSources    []string
@@ -926,6 +957,7 @@ func NewChartData(ctx context.Context, enableCache bool, syncSources,
 		EnableCache:   enableCache,
 		dir:           dir,
 		cache:         make(map[string]*cachedChart),
+		generations:   make(map[string]uint64),
 		retrivers:     make(map[string]Retriver),
 		syncSource:    syncSources,
 		PowSources:    poolSources,
@@ -935,6 +967,9 @@ func NewChartData(ctx context.Context, enableCache bool, syncSources,
 	}
 }
 
+var chartCacheLookups = metrics.NewCounterVec("dcrextdata_chart_cache_lookups_total",
+	"Chart requests served by the chart cache, by chart and result (hit or miss).", "chart", "result")
+
 // ChartMaker is a function that accepts a chart type and BinLevel, and returns
 // a JSON-encoded chartResponse.
 type ChartMaker func(ctx context.Context, charts *Manager, dataType, axis axisType, bin binLevel, sources ...string) ([]byte, error)
@@ -949,13 +984,61 @@ func (charts *Manager) Chart(ctx context.Context, chartID, dataType, axisString,
 	if !hasRetriever {
 		return nil, UnknownChartErr
 	}
+	if !charts.EnableCache {
+		return retriever(ctx, charts, string(dataTypeAxis), string(axis), string(bin), extras...)
+	}
+
+	key := cacheKey(chartID, string(dataTypeAxis), string(axis), string(bin), extras)
+	charts.cacheMtx.RLock()
+	cached, found := charts.cache[key]
+	generation := charts.generations[chartID]
+	charts.cacheMtx.RUnlock()
+	if found {
+		chartCacheLookups.Inc(chartID, "hit")
+		return cached.Data, nil
+	}
+
+	chartCacheLookups.Inc(chartID, "miss")
 	data, err := retriever(ctx, charts, string(dataTypeAxis), string(axis), string(bin), extras...)
 	if err != nil {
 		return nil, err
 	}
+	charts.cacheMtx.Lock()
+	if charts.generations[chartID] == generation {
+		charts.cache[key] = &cachedChart{CacheID: generation, Data: data}
+	}
+	charts.cacheMtx.Unlock()
 	return data, nil
 }
 
+// cacheKeySeparator separates the parts of the cache keys. It is not expected
+// in the chart parameters.
+const cacheKeySeparator = "\x1f"
+
+func cacheKey(chartID, dataType, axis, bin string, extras []string) string {
+	parts := append([]string{chartID, dataType, axis, bin}, extras...)
+	return strings.Join(parts, cacheKeySeparator)
+}
+
+// Invalidate drops the cached data of the charts chartIDs. The stores call it
+// once new data is written, it does nothing on a nil Manager.
+func (charts *Manager) Invalidate(chartIDs ...string) {
+	if charts == nil || !charts.EnableCache {
+		return
+	}
+	charts.cacheMtx.Lock()
+	defer charts.cacheMtx.Unlock()
+	for _, chartID := range chartIDs {
+		charts.generations[chartID]++
+		prefix := chartID + cacheKeySeparator
+		for key := range charts.cache {
+			if strings.HasPrefix(key, prefix) {
+				delete(charts.cache, key)
+			}
+		}
+	}
+}
+
 // Keys used for the chartResponse data sets.
 var responseKeys = []string{"x", "y", "z"}
 
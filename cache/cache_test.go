@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// countingManager returns a Manager with a mempool retriever that encodes the
+// number of times it was called.
+func countingManager(dir string) (*Manager, *int) {
+	charts := NewChartData(context.Background(), true, nil, nil, nil, nil, nil, nil, dir)
+	calls := 0
+	charts.AddRetriever(Mempool, func(ctx context.Context, charts *Manager, dataType, axis, bin string, extras ...string) ([]byte, error) {
+		calls++
+		return []byte(fmt.Sprintf("%s %s %s %d", dataType, axis, bin, calls)), nil
+	})
+	return charts, &calls
+}
+
+func chart(t *testing.T, charts *Manager, dataType string) string {
+	data, err := charts.Chart(context.Background(), Mempool, dataType, "time", "day")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestChartMemoization(t *testing.T) {
+	charts, calls := countingManager("")
+
+	first := chart(t, charts, "size")
+	if again := chart(t, charts, "size"); again != first {
+		t.Fatalf("cached chart %q, want %q", again, first)
+	}
+	if *calls != 1 {
+		t.Fatalf("%d retrievals, want 1", *calls)
+	}
+	chart(t, charts, "fees")
+	if *calls != 2 {
+		t.Fatalf("%d retrievals for another data type, want 2", *calls)
+	}
+
+	charts.Invalidate(PowChart)
+	chart(t, charts, "size")
+	if *calls != 2 {
+		t.Fatalf("%d retrievals after invalidating another chart, want 2", *calls)
+	}
+	charts.Invalidate(Mempool)
+	if refreshed := chart(t, charts, "size"); refreshed == first {
+		t.Fatal("the chart was not retrieved again after its invalidation")
+	}
+
+	charts.EnableCache = false
+	chart(t, charts, "size")
+	chart(t, charts, "size")
+	if *calls != 5 {
+		t.Fatalf("%d retrievals with the cache disabled, want 5", *calls)
+	}
+
+	var nilManager *Manager
+	nilManager.Invalidate(Mempool)
+}
+
+func TestDumpLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcrextdata-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	charts, _ := countingManager(dir)
+	saved := chart(t, charts, "size")
+	if err = charts.Dump("charts.glob"); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, calls := countingManager(dir)
+	if err = restarted.Load("charts.glob"); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := chart(t, restarted, "size"); loaded != saved || *calls != 0 {
+		t.Fatalf("loaded chart %q after %d retrievals, want %q", loaded, *calls, saved)
+	}
+	restarted.Invalidate(Mempool)
+	chart(t, restarted, "size")
+	if *calls != 1 {
+		t.Fatalf("%d retrievals after the invalidation of a loaded chart, want 1", *calls)
+	}
+
+	// the dump is removed once loaded
+	if isFileExists(filepath.Join(dir, "charts.glob")) {
+		t.Fatal("the dump was not removed")
+	}
+	if err = restarted.Load("charts.glob"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a reset removes the dump
+	if err = restarted.Dump("charts.glob"); err != nil {
+		t.Fatal(err)
+	}
+	if err = RemoveDump(dir, "charts.glob"); err != nil {
+		t.Fatal(err)
+	}
+	if isFileExists(filepath.Join(dir, "charts.glob")) {
+		t.Fatal("the dump was not removed by RemoveDump")
+	}
+	if err = RemoveDump(dir, "charts.glob"); err != nil {
+		t.Fatalf("expected no error without a dump, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package cache
+
+import "github.com/volatiletech/null"
+
+// ConfirmationTxTypes are the transaction types of the confirmation chart, in
+// the order of its series.
+var ConfirmationTxTypes = []string{"regular", "ticket", "vote", "revocation"}
+
+// ConfirmationLatency is the average wait in the mempool, in seconds, of the
+// transactions of a group, a fee rate bucket or a transaction type, mined in
+// a bucket, a block or a time bin. Height and Time are the first block and
+// time of the bucket.
+type ConfirmationLatency struct {
+	Bucket uint64
+	Height uint64
+	Time   uint64
+	Group  string
+	Wait   float64
+}
+
+// MakeConfirmationChart encodes the average wait of each group over the height
+// or the time axis. The series follow the order of groups, the groups without
+// any transaction are left out. The latencies must be ordered by bucket.
+func MakeConfirmationChart(charts *Manager, groups []string, latencies []ConfirmationLatency, axis axisType) ([]byte, error) {
+	var heights, times ChartUints
+	waits := make(map[string]map[int]float64)
+	for i, latency := range latencies {
+		if i == 0 || latency.Bucket != latencies[i-1].Bucket {
+			heights = append(heights, latency.Height)
+			times = append(times, latency.Time)
+		}
+		last := len(heights) - 1
+		if latency.Height < heights[last] {
+			heights[last] = latency.Height
+		}
+		if latency.Time < times[last] {
+			times[last] = latency.Time
+		}
+		if _, found := waits[latency.Group]; !found {
+			waits[latency.Group] = make(map[int]float64)
+		}
+		waits[latency.Group][last] = latency.Wait
+	}
+
+	var keys = []string{"x"}
+	var sets = []Lengther{times}
+	if axis == HeightAxis {
+		sets[0] = heights
+	}
+	for _, group := range groups {
+		groupWaits, found := waits[group]
+		if !found {
+			continue
+		}
+		series := make(ChartNullFloats, len(heights))
+		for i, wait := range groupWaits {
+			series[i] = &null.Float64{Float64: wait, Valid: true}
+		}
+		keys = append(keys, group)
+		sets = append(sets, series)
+	}
+	return charts.Encode(keys, sets...)
+}
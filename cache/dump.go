@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// chartsDump is the content of the file the chart cache is saved to.
+type chartsDump struct {
+	Version Semver
+	Charts  map[string][]byte
+}
+
+// Dump writes the cached charts to the file filename of the cache directory,
+// to be read back by Load on the next start.
+func (charts *Manager) Dump(filename string) error {
+	if !charts.EnableCache {
+		return nil
+	}
+	dump := chartsDump{Version: cacheVersion, Charts: make(map[string][]byte)}
+	charts.cacheMtx.RLock()
+	for key, cached := range charts.cache {
+		dump.Charts[key] = cached.Data
+	}
+	charts.cacheMtx.RUnlock()
+
+	if err := os.MkdirAll(charts.dir, 0700); err != nil {
+		return err
+	}
+	// the dump is written aside and renamed so that a failure does not leave
+	// a truncated file behind
+	path := filepath.Join(charts.dir, filename)
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err = gob.NewEncoder(file).Encode(dump); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("cannot encode the chart cache, %s", err.Error())
+	}
+	if err = file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err = os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+	log.Infof("Saved %d charts to %s", len(dump.Charts), path)
+	return nil
+}
+
+// Load adds the charts saved by Dump in the file filename of the cache
+// directory to the cache, unless they were retrieved in the meantime. It must
+// be called once the chart data of the stores is up to date. The file is
+// removed, so that a dump is never read after the data has changed, and is
+// ignored if it was written by an incompatible version.
+func (charts *Manager) Load(filename string) error {
+	path := filepath.Join(charts.dir, filename)
+	if !charts.EnableCache || !isFileExists(path) {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	var dump chartsDump
+	err = gob.NewDecoder(file).Decode(&dump)
+	file.Close()
+	if removeErr := os.Remove(path); removeErr != nil {
+		log.Warnf("Cannot remove the chart cache dump, %s", removeErr.Error())
+	}
+	if err != nil {
+		return fmt.Errorf("cannot decode the chart cache, %s", err.Error())
+	}
+	if !Compatible(cacheVersion, dump.Version) {
+		log.Infof("Ignoring the chart cache of version %s, the current version is %s", dump.Version, cacheVersion)
+		return nil
+	}
+
+	charts.cacheMtx.Lock()
+	defer charts.cacheMtx.Unlock()
+	for key, data := range dump.Charts {
+		if _, found := charts.cache[key]; found {
+			continue
+		}
+		chartID := key
+		if i := strings.Index(key, cacheKeySeparator); i >= 0 {
+			chartID = key[:i]
+		}
+		charts.cache[key] = &cachedChart{CacheID: charts.generations[chartID], Data: data}
+	}
+	log.Infof("Loaded %d charts from %s", len(dump.Charts), path)
+	return nil
+}
+
+// RemoveDump removes the charts saved by Dump in the file filename of dir, so
+// that they are not read after the data they were built from is dropped.
+func RemoveDump(dir, filename string) error {
+	err := os.Remove(filepath.Join(dir, filename))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -11,6 +11,12 @@ const (
 	ExchangeHighAxis  axisType = "high"
 	ExchangeOpenAxis  axisType = "open"
 	ExchangeLowAxis   axisType = "low"
+
+	OrderBookBidAxis axisType = "bid-depth"
+	OrderBookAskAxis axisType = "ask-depth"
+
+	IndexBTCAxis axisType = "btc-price"
+	IndexUSDAxis axisType = "usd-price"
 )
 
 // BuildExchangeKey returns exchange name, currency pair and interval joined by -
@@ -33,3 +39,13 @@ func ExtractExchangeKey(setKey string) (exchangeName string, currencyPair string
 	}
 	return
 }
+
+// BuildOrderBookKey returns exchange name and currency pair joined by -
+func BuildOrderBookKey(exchangeName string, currencyPair string) string {
+	return fmt.Sprintf("%s-%s", exchangeName, currencyPair)
+}
+
+func ExtractOrderBookKey(setKey string) (exchangeName string, currencyPair string) {
+	exchangeName, currencyPair, _ = ExtractExchangeKey(setKey)
+	return
+}
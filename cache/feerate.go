@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/volatiletech/null"
+)
+
+// FeeRateRow is the number of transactions of a fee rate bucket, and their
+// size, in a mempool sample or the average of a time bin.
+type FeeRateRow struct {
+	Time    uint64
+	FeeRate int64
+	Count   float64
+	Size    float64
+}
+
+// FeeRatePercentileRow is a fee rate percentile of a mempool sample or its
+// average in a time bin.
+type FeeRatePercentileRow struct {
+	Time       uint64
+	Percentile int
+	FeeRate    float64
+}
+
+// MakeFeeRateChart encodes a heatmap of the transaction count or, with the
+// size data type, of the bytes of every fee rate bucket over time. The
+// buckets are keyed by their lower fee rate in atoms/kB and the percentiles
+// by "p" followed by the percentile.
+func MakeFeeRateChart(charts *Manager, dataType string, rows []FeeRateRow, percentiles []FeeRatePercentileRow) ([]byte, error) {
+	var dates ChartUints
+	index := make(map[uint64]int)
+	addDate := func(date uint64) {
+		if _, found := index[date]; !found {
+			index[date] = len(dates)
+			dates = append(dates, date)
+		}
+	}
+	var feeRates []int64
+	buckets := make(map[int64]map[uint64]float64)
+	for _, row := range rows {
+		addDate(row.Time)
+		if _, found := buckets[row.FeeRate]; !found {
+			buckets[row.FeeRate] = make(map[uint64]float64)
+			feeRates = append(feeRates, row.FeeRate)
+		}
+		if dataType == MempoolSize {
+			buckets[row.FeeRate][row.Time] = row.Size
+		} else {
+			buckets[row.FeeRate][row.Time] = row.Count
+		}
+	}
+	var percentileKeys []int
+	percentileRates := make(map[int]map[uint64]float64)
+	for _, row := range percentiles {
+		addDate(row.Time)
+		if _, found := percentileRates[row.Percentile]; !found {
+			percentileRates[row.Percentile] = make(map[uint64]float64)
+			percentileKeys = append(percentileKeys, row.Percentile)
+		}
+		percentileRates[row.Percentile][row.Time] = row.FeeRate
+	}
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i] < dates[j] })
+	sort.Slice(feeRates, func(i, j int) bool { return feeRates[i] < feeRates[j] })
+	sort.Ints(percentileKeys)
+
+	var keys = []string{"x"}
+	var sets = []Lengther{dates}
+	for _, feeRate := range feeRates {
+		series := make(ChartFloats, len(dates))
+		for i, date := range dates {
+			series[i] = buckets[feeRate][date]
+		}
+		keys = append(keys, strconv.FormatInt(feeRate, 10))
+		sets = append(sets, series)
+	}
+	for _, percentile := range percentileKeys {
+		series := make(ChartNullFloats, len(dates))
+		for i, date := range dates {
+			if feeRate, found := percentileRates[percentile][date]; found {
+				rate := null.Float64From(feeRate)
+				series[i] = &rate
+			}
+		}
+		keys = append(keys, fmt.Sprintf("p%d", percentile))
+		sets = append(sets, series)
+	}
+	return charts.Encode(keys, sets...)
+}
@@ -9,8 +9,8 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/planetdecred/dcrextdata/app"
 	"github.com/planetdecred/dcrextdata/app/config"
+	"github.com/planetdecred/dcrextdata/scheduler"
 )
 
 const (
@@ -26,26 +26,37 @@ func NewCommStatCollector(store DataStore, options *config.CommunityStatOptions)
 	}, nil
 }
 
-func (c *Collector) Run(ctx context.Context) {
-	if ctx.Err() != nil {
-		return
-	}
-
-	// continually check the state of the app until its free to run this module
-	app.MarkBusyIfFree()
-
-	log.Info("Fetching community stats...")
-
-	app.ReleaseForNewModule()
-
-	go c.startTwitterCollector(ctx)
-
-	go c.startYoutubeCollector(ctx)
-
-	// github
-	go c.startGithubCollector(ctx)
+// SetHTTPClient replaces the client used to request the community stats.
+func (c *Collector) SetHTTPClient(client *http.Client) {
+	c.client = *client
+}
 
-	go c.startRedditCollector(ctx)
+// RegisterJobs adds the collection of each community stat to s.
+func (c *Collector) RegisterJobs(s *scheduler.Scheduler) error {
+	jobs := []struct {
+		name     string
+		interval time.Duration
+		collect  func(context.Context) error
+	}{
+		{"commstats-reddit", time.Duration(c.options.RedditStatInterval) * time.Minute, c.collectAndStoreRedditStat},
+		{"commstats-twitter", time.Duration(c.options.TwitterStatInterval) * time.Minute, c.collectAndStoreTwitterStat},
+		{"commstats-github", time.Duration(c.options.GithubStatInterval) * time.Minute, c.collectAndStoreGithubStat},
+		{"commstats-youtube", time.Duration(c.options.YoutubeStatInterval) * time.Minute, c.collectAndStoreYoutubeStat},
+	}
+	for _, job := range jobs {
+		err := s.AddJob(scheduler.Job{
+			Name:     job.name,
+			Module:   "commstats",
+			Interval: job.interval,
+			Jitter:   time.Minute,
+			Timeout:  job.interval,
+			Run:      job.collect,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func SetAccounts(options config.CommunityStatOptions) {
@@ -0,0 +1,132 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package commstats
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/app/config"
+	"github.com/planetdecred/dcrextdata/app/helpers"
+	"github.com/planetdecred/dcrextdata/testutil"
+)
+
+// commStatTestStore records the stats passed to the Store methods.
+type commStatTestStore struct {
+	reddit  []Reddit
+	twitter []Twitter
+	youtube []Youtube
+	github  []Github
+}
+
+func (s *commStatTestStore) StoreRedditStat(_ context.Context, stat Reddit) error {
+	s.reddit = append(s.reddit, stat)
+	return nil
+}
+func (s *commStatTestStore) LastCommStatEntry() time.Time { return time.Time{} }
+func (s *commStatTestStore) StoreTwitterStat(_ context.Context, stat Twitter) error {
+	s.twitter = append(s.twitter, stat)
+	return nil
+}
+func (s *commStatTestStore) StoreYoutubeStat(_ context.Context, stat Youtube) error {
+	s.youtube = append(s.youtube, stat)
+	return nil
+}
+func (s *commStatTestStore) StoreGithubStat(_ context.Context, stat Github) error {
+	s.github = append(s.github, stat)
+	return nil
+}
+func (s *commStatTestStore) LastEntry(context.Context, string, interface{}) error { return nil }
+
+func TestCollectAndStoreStats(t *testing.T) {
+	server := testutil.NewFixtureServer(t,
+		testutil.Route{Host: "www.reddit.com", Path: "/r/decred/about.json", Fixture: "reddit.json"},
+		testutil.Route{Host: "cdn.syndication.twimg.com", Path: "/widgets/followbutton/info.json", Fixture: "twitter.json"},
+		testutil.Route{Host: "api.github.com", Path: "/repos/decred/dcrd", Fixture: "github.json"},
+		testutil.Route{Host: "www.googleapis.com", Path: "/youtube/v3/channels", Fixture: "youtube.json"},
+	)
+
+	store := new(commStatTestStore)
+	collector, err := NewCommStatCollector(store, &config.CommunityStatOptions{
+		Subreddit:          []string{"decred"},
+		TwitterHandles:     []string{"decredproject"},
+		GithubRepositories: []string{"decred/dcrd"},
+		YoutubeChannelName: []string{"Decred"},
+		YoutubeChannelId:   []string{"UCJ2bYDaPYHpSmJPh_M5dNSg"},
+		YoutubeDataApiKey:  "key",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.SetHTTPClient(server.Client())
+
+	ctx := context.Background()
+	start := helpers.NowUTC()
+	if err = collector.collectAndStoreRedditStat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = collector.collectAndStoreTwitterStat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = collector.collectAndStoreGithubStat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = collector.collectAndStoreYoutubeStat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	end := helpers.NowUTC()
+
+	// the stats are dated with the collection time
+	checkDate := func(date *time.Time) {
+		t.Helper()
+		if date.Before(start) || date.After(end) {
+			t.Errorf("expected a date between %s and %s, got %s", start, end, date)
+		}
+		*date = time.Time{}
+	}
+	for i := range store.reddit {
+		checkDate(&store.reddit[i].Date)
+	}
+	for i := range store.twitter {
+		checkDate(&store.twitter[i].Date)
+	}
+	for i := range store.github {
+		checkDate(&store.github[i].Date)
+	}
+	for i := range store.youtube {
+		checkDate(&store.youtube[i].Date)
+	}
+
+	expectedReddit := []Reddit{{Subscribers: 12874, AccountsActive: 53, Subreddit: "decred"}}
+	if !reflect.DeepEqual(store.reddit, expectedReddit) {
+		t.Errorf("expected Reddit stats %+v, got %+v", expectedReddit, store.reddit)
+	}
+	expectedTwitter := []Twitter{{Followers: 45218, Handle: "decredproject"}}
+	if !reflect.DeepEqual(store.twitter, expectedTwitter) {
+		t.Errorf("expected Twitter stats %+v, got %+v", expectedTwitter, store.twitter)
+	}
+	expectedGithub := []Github{{Stars: 583, Folks: 261, Repository: "decred/dcrd"}}
+	if !reflect.DeepEqual(store.github, expectedGithub) {
+		t.Errorf("expected Github stats %+v, got %+v", expectedGithub, store.github)
+	}
+	expectedYoutube := []Youtube{{Subscribers: 4387, ViewCount: 391742, Channel: "Decred"}}
+	if !reflect.DeepEqual(store.youtube, expectedYoutube) {
+		t.Errorf("expected Youtube stats %+v, got %+v", expectedYoutube, store.youtube)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("expected 4 requests, got %d", len(requests))
+	}
+	if handle := requests[1].Query().Get("screen_names"); handle != "decredproject" {
+		t.Errorf("expected the decredproject followers to be requested, got %s", handle)
+	}
+	query := requests[3].Query()
+	if query.Get("id") != "UCJ2bYDaPYHpSmJPh_M5dNSg" || query.Get("key") != "key" || query.Get("part") != "statistics" {
+		t.Errorf("unexpected Youtube request %s", requests[3])
+	}
+}
@@ -2,15 +2,11 @@ package commstats
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"time"
 
-	"github.com/planetdecred/dcrextdata/app"
 	"github.com/planetdecred/dcrextdata/app/helpers"
-	"github.com/planetdecred/dcrextdata/postgres/models"
 )
 
 const (
@@ -23,48 +19,7 @@ func Subreddits() []string {
 	return subreddits
 }
 
-func (c *Collector) startRedditCollector(ctx context.Context) {
-	var lastCollectionDate time.Time
-	err := c.dataStore.LastEntry(ctx, models.TableNames.Reddit, &lastCollectionDate)
-	if err != nil && err != sql.ErrNoRows {
-		log.Errorf("Cannot fetch last Reddit entry time, %s", err.Error())
-		return
-	}
-
-	secondsPassed := time.Since(lastCollectionDate)
-	period := time.Duration(c.options.TwitterStatInterval) * time.Minute
-
-	if secondsPassed < period {
-		timeLeft := period - secondsPassed
-		log.Infof("Fetching Reddit stats every %dm, collected %s ago, will fetch in %s.", period/time.Minute, helpers.DurationToString(secondsPassed),
-			helpers.DurationToString(timeLeft))
-
-		time.Sleep(timeLeft)
-	}
-
-	registerStarter := func() {
-		// continually check the state of the app until its free to run this module
-		app.MarkBusyIfFree()
-	}
-
-	registerStarter()
-	c.collectAndStoreRedditStat(ctx)
-	app.ReleaseForNewModule()
-
-	ticker := time.NewTicker(time.Duration(c.options.RedditStatInterval) * time.Minute)
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			registerStarter()
-			c.collectAndStoreRedditStat(ctx)
-			app.ReleaseForNewModule()
-		}
-	}
-}
-
-func (c *Collector) collectAndStoreRedditStat(ctx context.Context) {
+func (c *Collector) collectAndStoreRedditStat(ctx context.Context) error {
 	log.Info("Starting Reddit stats collection cycle")
 
 	for _, subreddit := range c.options.Subreddit {
@@ -72,8 +27,7 @@ func (c *Collector) collectAndStoreRedditStat(ctx context.Context) {
 		resp, err := c.fetchRedditStat(ctx, subreddit)
 		for retry := 0; err != nil; retry++ {
 			if retry == retryLimit {
-				log.Error(err)
-				return
+				return err
 			}
 			log.Warn(err)
 			resp, err = c.fetchRedditStat(ctx, subreddit)
@@ -86,12 +40,12 @@ func (c *Collector) collectAndStoreRedditStat(ctx context.Context) {
 			Subreddit:      subreddit,
 		})
 		if err != nil {
-			log.Error("Unable to save reddit stat, %s", err.Error())
-			return
+			return fmt.Errorf("unable to save reddit stat: %v", err)
 		}
 		log.Infof("New Reddit stat collected for %s at %s, Subscribers  %d, Active Users %d", subreddit,
 			helpers.NowUTC().Format(dateMiliTemplate), resp.Data.Subscribers, resp.Data.AccountsActive)
 	}
+	return nil
 }
 
 func (c *Collector) fetchRedditStat(ctx context.Context, subreddit string) (response *RedditResponse, err error) {
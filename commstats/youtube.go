@@ -2,17 +2,13 @@ package commstats
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
-	"time"
 
-	"github.com/planetdecred/dcrextdata/app"
 	"github.com/planetdecred/dcrextdata/app/helpers"
-	"github.com/planetdecred/dcrextdata/postgres/models"
 )
 
 var youtubeChannels []string
@@ -21,65 +17,14 @@ func YoutubeChannels() []string {
 	return youtubeChannels
 }
 
-func (c *Collector) startYoutubeCollector(ctx context.Context) {
-	if c.options.YoutubeDataApiKey == "" {
-		log.Error("youtubedataapikey is required for the youtube stat collector to work")
-		return
-	}
-
-	if len(c.options.YoutubeChannelName) != len(c.options.YoutubeChannelId) {
-		log.Error("Both name and ID is required for all youtube channels")
-		return
-	}
-
-	var lastCollectionDate time.Time
-	err := c.dataStore.LastEntry(ctx, models.TableNames.Youtube, &lastCollectionDate)
-	if err != nil && err != sql.ErrNoRows {
-		log.Errorf("Cannot fetch last Youtube entry time, %s", err.Error())
-		return
-	}
-
-	secondsPassed := time.Since(lastCollectionDate)
-	period := time.Duration(c.options.TwitterStatInterval) * time.Minute
-
-	if secondsPassed < period {
-		timeLeft := period - secondsPassed
-		log.Infof("Fetching Youtube stats every %dm, collected %s ago, will fetch in %s.", period/time.Minute, helpers.DurationToString(secondsPassed),
-			helpers.DurationToString(timeLeft))
-
-		time.Sleep(timeLeft)
-	}
-
-	registerStarter := func() {
-		// continually check the state of the app until its free to run this module
-		app.MarkBusyIfFree()
-	}
-
-	registerStarter()
-	c.collectAndStoreYoutubeStat(ctx)
-	app.ReleaseForNewModule()
-
-	ticker := time.NewTicker(time.Duration(c.options.YoutubeStatInterval) * time.Minute)
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			registerStarter()
-			c.collectAndStoreYoutubeStat(ctx)
-			app.ReleaseForNewModule()
-		}
-	}
-}
-
-func (c *Collector) collectAndStoreYoutubeStat(ctx context.Context) {
+func (c *Collector) collectAndStoreYoutubeStat(ctx context.Context) error {
 	log.Info("Starting Github stats collection cycle")
 	// youtube
 	for index, id := range c.options.YoutubeChannelId {
 		youtubeSubscribers, viewCount, err := c.getYoutubeSubscriberCount(ctx, id)
 		for retry := 0; err != nil; retry++ {
 			if retry == retryLimit {
-				return
+				return err
 			}
 			log.Warn(err)
 			youtubeSubscribers, viewCount, err = c.getYoutubeSubscriberCount(ctx, id)
@@ -95,14 +40,13 @@ func (c *Collector) collectAndStoreYoutubeStat(ctx context.Context) {
 		}
 		err = c.dataStore.StoreYoutubeStat(ctx, youtubeStat)
 		if err != nil {
-			log.Error("Unable to save Youtube stat, %s", err.Error())
-			return
+			return fmt.Errorf("unable to save Youtube stat: %v", err)
 		}
 
 		log.Infof("New Youtube stat collected for %s at %s, Subscribers %d", channel,
 			youtubeStat.Date.Format(dateMiliTemplate), youtubeSubscribers)
 	}
-
+	return nil
 }
 
 func (c *Collector) getYoutubeSubscriberCount(ctx context.Context, youtubeChannelId string) (int, int, error) {
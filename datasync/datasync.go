@@ -6,10 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	neturl "net/url"
 	"strings"
 	"time"
 
 	"github.com/planetdecred/dcrextdata/app/helpers"
+	"github.com/planetdecred/dcrextdata/metrics"
 )
 
 var coordinator *SyncCoordinator
@@ -27,11 +29,14 @@ func (s *SyncCoordinator) AddSyncer(tableName string, syncer Syncer) {
 	s.syncersKeys[len(s.syncersKeys)] = tableName
 }
 
-func (s *SyncCoordinator) AddSource(url string, store Store, database string) {
+// AddSource adds an instance to sync the data of into store. apiKey, if set,
+// is sent with the sync requests.
+func (s *SyncCoordinator) AddSource(url string, store Store, database, apiKey string) {
 	s.instances = append(s.instances, instance{
 		store:    store,
 		url:      url,
 		database: database,
+		apiKey:   apiKey,
 	})
 }
 
@@ -58,7 +63,9 @@ func (s *SyncCoordinator) StartSyncing(ctx context.Context) {
 				url := fmt.Sprintf("%s/api/sync/%s", source.url, tableName)
 				log.Infof(format, source.database, tableName, url)
 
+				start := time.Now()
 				err := s.sync(ctx, source, tableName, syncer)
+				metrics.CollectionCycle("datasync", start, err)
 				if err != nil {
 					log.Error(err)
 				}
@@ -100,9 +107,14 @@ func (s *SyncCoordinator) sync(ctx context.Context, source instance, tableName s
 		url := fmt.Sprintf("%s/api/sync/%s?last=%s&skip=%d&take=%d", strings.TrimSuffix(source.url, "/"),
 			tableName, lastEntry, skip, take)
 		log.Infof("Syncing %s data from %s", tableName, url)
+		// the key is added after the url is logged
+		requestURL := url
+		if source.apiKey != "" {
+			requestURL += "&api_key=" + neturl.QueryEscape(source.apiKey)
+		}
 		var result *Result
 		for {
-			result, err = syncer.Collect(ctx, url)
+			result, err = syncer.Collect(ctx, requestURL)
 			retries++
 			if err == nil || retries >= 3 {
 				break
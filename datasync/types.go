@@ -22,6 +22,7 @@ type instance struct {
 	database string
 	store    Store
 	url      string
+	apiKey   string
 }
 
 type Syncer struct {
@@ -39,10 +40,14 @@ type Store interface {
 	SaveExchangeFromSync(ctx context.Context, exchange interface{}) error
 	LastExchangeTickEntryTime() (time time.Time)
 	SaveExchangeTickFromSync(ctx context.Context, tick interface{}) error
+	SaveOrderBookSnapshotFromSync(ctx context.Context, snapshot interface{}) error
 
 	StoreMempoolFromSync(ctx context.Context, mempoolDto interface{}) error
 	SaveBlockFromSync(ctx context.Context, block interface{}) error
 	SaveVoteFromSync(ctx context.Context, vote interface{}) error
+	SaveVoteVersionFromSync(ctx context.Context, vote interface{}) error
+	SaveTxConfirmationFromSync(ctx context.Context, confirmation interface{}) error
+	SaveReorgFromSync(ctx context.Context, reorg interface{}) error
 	UpdatePropagationData(ctx context.Context) error
 
 	AddPowDataFromSync(ctx context.Context, data interface{}) error
@@ -8,12 +8,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/planetdecred/dcrextdata/app"
-	"github.com/planetdecred/dcrextdata/app/helpers"
 	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/scheduler"
 )
 
 const (
@@ -22,6 +22,7 @@ const (
 
 type TickHub struct {
 	collectors []ticks.Collector
+	streamers  []ticks.Streamer
 	client     *http.Client
 	store      ticks.Store
 }
@@ -34,16 +35,38 @@ var (
 		// ticks.Bleutrade,
 		ticks.Poloniex,
 	}
+
+	// optInExchanges are only collected from when they are enabled
+	optInExchanges = []string{
+		ticks.Huobi,
+		ticks.Kucoin,
+		ticks.Bitfinex,
+		ticks.Upbit,
+		ticks.Dragonex,
+	}
 )
 
-func NewTickHub(ctx context.Context, disabledexchanges []string, store ticks.Store) (*TickHub, error) {
-	collectors := make([]ticks.Collector, 0, len(availableExchanges)-len(disabledexchanges))
+// NewTickHub returns a hub of the collectors of the available exchanges that
+// are not disabled, of the opt-in exchanges that are enabled and of the
+// exchanges of exchangeConfigFile.
+func NewTickHub(ctx context.Context, disabledexchanges, enabledexchanges []string, exchangeConfigFile string,
+	store ticks.Store) (*TickHub, error) {
+	exchanges := append([]string{}, availableExchanges...)
+	for _, exchange := range enabledexchanges {
+		for _, optIn := range optInExchanges {
+			if exchange == optIn {
+				exchanges = append(exchanges, exchange)
+			}
+		}
+	}
+
+	collectors := make([]ticks.Collector, 0, len(exchanges))
 	disabledMap := make(map[string]struct{})
 	for _, e := range disabledexchanges {
 		disabledMap[e] = struct{}{}
 	}
 	enabledExchanges := make([]string, 0, cap(collectors))
-	for _, exchange := range availableExchanges {
+	for _, exchange := range exchanges {
 		if _, ok := disabledMap[exchange]; !ok {
 			collector, err := ticks.CollectorConstructors[exchange](ctx, store)
 			if err != nil {
@@ -55,6 +78,25 @@ func NewTickHub(ctx context.Context, disabledexchanges []string, store ticks.Sto
 		}
 	}
 
+	if exchangeConfigFile != "" {
+		configs, err := ticks.LoadExchangeConfigs(exchangeConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, cfg := range configs {
+			if _, ok := disabledMap[cfg.Name]; ok {
+				continue
+			}
+			configured, err := ticks.NewConfiguredCollectors(ctx, store, cfg)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			collectors = append(collectors, configured...)
+			enabledExchanges = append(enabledExchanges, cfg.Name)
+		}
+	}
+
 	if len(collectors) == 0 {
 		return nil, fmt.Errorf("No tick collectors")
 	}
@@ -68,64 +110,117 @@ func NewTickHub(ctx context.Context, disabledexchanges []string, store ticks.Sto
 	}, nil
 }
 
-func (hub *TickHub) CollectShort(ctx context.Context) {
+// EnableStreaming adds a trade stream for each of the given exchanges. The
+// streams are started by StartStreaming.
+func (hub *TickHub) EnableStreaming(ctx context.Context, exchanges []string) {
+	for _, exchange := range exchanges {
+		constructor, ok := ticks.StreamConstructors[exchange]
+		if !ok {
+			log.Errorf("Trade streaming is not supported for %s", exchange)
+			continue
+		}
+		streamer, err := constructor(ctx, hub.store)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		hub.streamers = append(hub.streamers, streamer)
+		log.Infof("Enabled trade streaming for %s", exchange)
+	}
+}
+
+// logCollectionError logs err, leaving the errors of exchanges whose circuit
+// is open to the debug level since the guard already reported the failures.
+func logCollectionError(err error) {
+	if _, open := err.(ticks.ErrCircuitOpen); open {
+		log.Debug(err)
+		return
+	}
+	log.Error(err)
+}
+
+// collectionErrors gathers the errors of a collection cycle, leaving out those
+// of the exchanges whose circuit is open.
+type collectionErrors []string
+
+func (errs *collectionErrors) add(err error) {
+	logCollectionError(err)
+	if _, open := err.(ticks.ErrCircuitOpen); !open {
+		*errs = append(*errs, err.Error())
+	}
+}
+
+func (errs collectionErrors) err(collection string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s collection failed, %s", collection, strings.Join(errs, "; "))
+}
+
+func (hub *TickHub) CollectShort(ctx context.Context) error {
+	var errs collectionErrors
 	wg := new(sync.WaitGroup)
 	for _, collector := range hub.collectors {
 		if ctx.Err() != nil {
 			log.Error(ctx.Err())
-			break
+			return ctx.Err()
 		}
 		wg.Add(1)
 		func(ctx context.Context, wg *sync.WaitGroup, collector ticks.Collector) {
 			err := collector.GetShort(ctx)
 			if err != nil {
-				log.Error(err)
+				errs.add(err)
 			}
 			wg.Done()
 		}(ctx, wg, collector)
 	}
 	wg.Wait()
 	log.Info("Completed short collection")
+	return errs.err("short")
 }
 
-func (hub *TickHub) CollectLong(ctx context.Context) {
+func (hub *TickHub) CollectLong(ctx context.Context) error {
+	var errs collectionErrors
 	wg := new(sync.WaitGroup)
 	for _, collector := range hub.collectors {
 		if ctx.Err() != nil {
 			log.Error(ctx.Err())
-			break
+			return ctx.Err()
 		}
 		wg.Add(1)
 		func(ctx context.Context, wg *sync.WaitGroup, collector ticks.Collector) {
 			err := collector.GetLong(ctx)
 			if err != nil {
-				log.Error(err)
+				errs.add(err)
 			}
 			wg.Done()
 		}(ctx, wg, collector)
 	}
 	wg.Wait()
 	log.Info("Completed long collection")
+	return errs.err("long")
 }
 
-func (hub *TickHub) CollectHistoric(ctx context.Context) {
+func (hub *TickHub) CollectHistoric(ctx context.Context) error {
+	var errs collectionErrors
 	wg := new(sync.WaitGroup)
 	for _, collector := range hub.collectors {
 		if ctx.Err() != nil {
 			log.Error(ctx.Err())
-			break
+			return ctx.Err()
 		}
 		wg.Add(1)
 		func(ctx context.Context, wg *sync.WaitGroup, collector ticks.Collector) {
 			err := collector.GetHistoric(ctx)
 			if err != nil {
-				log.Error(err)
+				errs.add(err)
 			}
 			wg.Done()
 		}(ctx, wg, collector)
 	}
 	wg.Wait()
 	log.Info("Completed historic collection")
+	return errs.err("historic")
 }
 
 func (hub *TickHub) CollectAll(ctx context.Context) {
@@ -137,75 +232,75 @@ func (hub *TickHub) CollectAll(ctx context.Context) {
 
 		err := collector.GetShort(ctx)
 		if err != nil {
-			log.Error(err)
+			logCollectionError(err)
 		}
 
 		err = collector.GetLong(ctx)
 		if err != nil {
-			log.Error(err)
+			logCollectionError(err)
 		}
 
 		err = collector.GetHistoric(ctx)
 		if err != nil {
-			log.Error(err)
+			logCollectionError(err)
 		}
 	}
 }
 
-func (hub *TickHub) Run(ctx context.Context) {
-	shortTicker := time.NewTicker(5 * time.Minute)
-	longTicker := time.NewTicker(time.Hour)
-	dayTicker := time.NewTicker(24 * time.Hour)
-	defer shortTicker.Stop()
-	defer longTicker.Stop()
-	defer dayTicker.Stop()
-
+// updateIndex refreshes the cross exchange index with the newly collected ticks
+func (hub *TickHub) updateIndex(ctx context.Context) error {
 	if ctx.Err() != nil {
-		log.Error(ctx.Err())
-		return
+		return ctx.Err()
 	}
-
-	lastCollectionDate := hub.store.LastExchangeTickEntryTime()
-	secondsPassed := time.Since(lastCollectionDate)
-	period := 5 * time.Minute
-
-	if secondsPassed < period {
-		timeLeft := period - secondsPassed
-		log.Infof("Fetching exchange ticks every %dm, collected %s ago, will fetch in %s.", period/time.Minute, helpers.DurationToString(secondsPassed),
-			helpers.DurationToString(timeLeft))
-
-		time.Sleep(timeLeft)
+	if err := hub.store.UpdateExchangeIndex(ctx); err != nil {
+		return fmt.Errorf("Error in exchange index update, %s", err.Error())
 	}
+	return nil
+}
 
-	registerStarter := func() {
-		// continually check the state of the app until its free to run this module
-		app.MarkBusyIfFree()
-
-		log.Info("Starting exchange tick collection cycle")
+// StartStreaming starts the trade streams enabled with EnableStreaming.
+func (hub *TickHub) StartStreaming(ctx context.Context) {
+	for _, streamer := range hub.streamers {
+		go streamer.Stream(ctx)
 	}
+}
 
-	registerStarter()
-	hub.CollectAll(ctx)
-	app.ReleaseForNewModule()
-
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-shortTicker.C:
-				registerStarter()
-				hub.CollectShort(ctx)
-				app.ReleaseForNewModule()
-			case <-longTicker.C:
-				registerStarter()
-				hub.CollectLong(ctx)
-				app.ReleaseForNewModule()
-			case <-dayTicker.C:
-				registerStarter()
-				hub.CollectHistoric(ctx)
-				app.ReleaseForNewModule()
-			}
+// RegisterJobs adds the collection of the short, long and historic ticks to s.
+// The jobs share the exchange group with the order book collection.
+func (hub *TickHub) RegisterJobs(s *scheduler.Scheduler) error {
+	jobs := []struct {
+		name     string
+		interval time.Duration
+		priority int
+		collect  func(context.Context) error
+	}{
+		{"exchange-ticks-short", 5 * time.Minute, 3, hub.CollectShort},
+		{"exchange-ticks-long", time.Hour, 2, hub.CollectLong},
+		{"exchange-ticks-historic", 24 * time.Hour, 1, hub.CollectHistoric},
+	}
+	for _, job := range jobs {
+		collect := job.collect
+		err := s.AddJob(scheduler.Job{
+			Name:     job.name,
+			Module:   ticks.JobGroup,
+			Interval: job.interval,
+			Timeout:  job.interval,
+			Group:    ticks.JobGroup,
+			Priority: job.priority,
+			Run: func(ctx context.Context) error {
+				log.Info("Starting exchange tick collection cycle")
+				err := collect(ctx)
+				// the index is updated with whatever the exchanges that did not
+				// fail returned
+				if indexErr := hub.updateIndex(ctx); err == nil {
+					err = indexErr
+				}
+				return err
+			},
+		})
+		if err != nil {
+			return err
 		}
-	}()
+	}
+	return nil
 }
@@ -0,0 +1,289 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package orderbook
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/app/helpers"
+	"github.com/planetdecred/dcrextdata/datasync"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/scheduler"
+)
+
+const (
+	btcdcrPair = "BTC/DCR"
+
+	binanceDepthURL  = "https://api.binance.com/api/v3/depth"
+	bittrexDepthURL  = "https://api.bittrex.com/v3/markets/%s/orderbook"
+	poloniexDepthURL = "https://poloniex.com/public"
+)
+
+var (
+	DefaultBands = []float64{1, 2, 5}
+
+	availableSources = []source{
+		{
+			exchange: ticks.Binance,
+			pair:     btcdcrPair,
+			requester: func() (string, error) {
+				return helpers.AddParams(binanceDepthURL, map[string]interface{}{
+					"symbol": "DCRBTC",
+					"limit":  1000,
+				})
+			},
+			response: func() orderBookable { return new(binanceOrderBook) },
+		},
+		{
+			exchange: ticks.Bittrex,
+			pair:     btcdcrPair,
+			requester: func() (string, error) {
+				return helpers.AddParams(fmt.Sprintf(bittrexDepthURL, "DCR-BTC"), map[string]interface{}{
+					"depth": 500,
+				})
+			},
+			response: func() orderBookable { return new(bittrexOrderBook) },
+		},
+		{
+			exchange: ticks.Poloniex,
+			pair:     btcdcrPair,
+			requester: func() (string, error) {
+				return helpers.AddParams(poloniexDepthURL, map[string]interface{}{
+					"command":      "returnOrderBook",
+					"currencyPair": "BTC_DCR",
+					"depth":        1000,
+				})
+			},
+			response: func() orderBookable { return new(poloniexOrderBook) },
+		},
+	}
+)
+
+type source struct {
+	exchange  string
+	pair      string
+	requester func() (string, error)
+	response  func() orderBookable
+}
+
+type Collector struct {
+	sources []source
+	bands   []float64
+	period  int64
+	client  *http.Client
+	store   Store
+}
+
+// NewCollector returns a Collector that takes a depth snapshot of every
+// enabled exchange each period seconds.
+func NewCollector(disabledExchanges []string, bands []float64, period int64, store Store) (*Collector, error) {
+	disabledMap := make(map[string]struct{})
+	for _, exchange := range disabledExchanges {
+		disabledMap[exchange] = struct{}{}
+	}
+
+	var sources []source
+	for _, src := range availableSources {
+		if _, disabled := disabledMap[src.exchange]; !disabled {
+			sources = append(sources, src)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("No order book source")
+	}
+
+	if len(bands) == 0 {
+		bands = DefaultBands
+	}
+	sortedBands := make([]float64, len(bands))
+	copy(sortedBands, bands)
+	sort.Float64s(sortedBands)
+
+	return &Collector{
+		sources: sources,
+		bands:   sortedBands,
+		period:  period,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		store:   store,
+	}, nil
+}
+
+// SetHTTPClient replaces the client used to request the order books.
+func (c *Collector) SetHTTPClient(client *http.Client) {
+	c.client = client
+}
+
+// RegisterJobs adds the order book collection to s, in the exchange group.
+func (c *Collector) RegisterJobs(s *scheduler.Scheduler) error {
+	period := time.Duration(c.period) * time.Second
+	return s.AddJob(scheduler.Job{
+		Name:     "orderbook",
+		Module:   ticks.JobGroup,
+		Interval: period,
+		Timeout:  period,
+		Group:    ticks.JobGroup,
+		Run:      c.Collect,
+	})
+}
+
+// Collect takes and stores a snapshot of each source. A failing source does
+// not stop the collection of the others, their errors are returned together.
+// The sources whose exchange circuit is open are skipped without an error.
+func (c *Collector) Collect(ctx context.Context) error {
+	log.Info("Fetching order books")
+	var failures []string
+	for _, src := range c.sources {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		snapshot, err := c.snapshot(ctx, src)
+		if _, open := err.(ticks.ErrCircuitOpen); open {
+			log.Debugf("Skipping %s %s order book: %v", src.exchange, src.pair, err)
+			continue
+		}
+		if err != nil {
+			log.Errorf("Unable to fetch %s %s order book: %v", src.exchange, src.pair, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", src.exchange, err))
+			continue
+		}
+		if err = c.store.StoreOrderBookSnapshot(ctx, *snapshot); err != nil {
+			log.Errorf("Unable to store %s order book snapshot: %v", src.exchange, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", src.exchange, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("unable to collect the order books, %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func (c *Collector) snapshot(ctx context.Context, src source) (*Snapshot, error) {
+	requestURL, err := src.requester()
+	if err != nil {
+		return nil, err
+	}
+	resp := src.response()
+	// the requests share the rate limit and the circuit breaker of the tick
+	// collectors of the exchange
+	if err = ticks.GetResponse(ctx, c.client, src.exchange, requestURL, resp); err != nil {
+		return nil, err
+	}
+	book, err := resp.toOrderBook()
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := BuildSnapshot(book, c.bands)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Exchange = src.exchange
+	snapshot.CurrencyPair = src.pair
+	snapshot.Time = helpers.NowUTC().Truncate(time.Second)
+	return snapshot, nil
+}
+
+// BuildSnapshot sums the bid and ask quantities within each percentage band
+// around the mid price of book.
+func BuildSnapshot(book *OrderBook, bands []float64) (*Snapshot, error) {
+	if len(book.Bids) == 0 || len(book.Asks) == 0 {
+		return nil, fmt.Errorf("order book has no bid or ask")
+	}
+
+	bestBid, bestAsk := book.Bids[0].Price, book.Asks[0].Price
+	for _, bid := range book.Bids {
+		if bid.Price > bestBid {
+			bestBid = bid.Price
+		}
+	}
+	for _, ask := range book.Asks {
+		if ask.Price < bestAsk {
+			bestAsk = ask.Price
+		}
+	}
+	mid := (bestBid + bestAsk) / 2
+
+	snapshot := &Snapshot{
+		BestBid:  bestBid,
+		BestAsk:  bestAsk,
+		MidPrice: mid,
+		Depths:   make([]Depth, len(bands)),
+	}
+	for i, band := range bands {
+		depth := Depth{Band: band}
+		minBid := mid * (1 - band/100)
+		maxAsk := mid * (1 + band/100)
+		for _, bid := range book.Bids {
+			if bid.Price >= minBid {
+				depth.BidDepth += bid.Quantity
+			}
+		}
+		for _, ask := range book.Asks {
+			if ask.Price <= maxAsk {
+				depth.AskDepth += ask.Quantity
+			}
+		}
+		snapshot.Depths[i] = depth
+	}
+	return snapshot, nil
+}
+
+func (c *Collector) RegisterSyncer(syncCoordinator *datasync.SyncCoordinator) {
+	syncCoordinator.AddSyncer(c.store.OrderBookSnapshotTableName(), datasync.Syncer{
+		LastEntry: func(ctx context.Context, db datasync.Store) (string, error) {
+			var lastTime time.Time
+			err := db.LastEntry(ctx, c.store.OrderBookSnapshotTableName(), &lastTime)
+			if err != nil && err != sql.ErrNoRows {
+				return "0", fmt.Errorf("error in fetching last order book snapshot time, %s", err.Error())
+			}
+			return strconv.FormatInt(lastTime.Unix(), 10), nil
+		},
+		Collect: func(ctx context.Context, url string) (result *datasync.Result, err error) {
+			result = new(datasync.Result)
+			result.Records = []Snapshot{}
+			err = helpers.GetResponse(ctx, &http.Client{Timeout: 10 * time.Second}, url, result)
+			return
+		},
+		Retrieve: func(ctx context.Context, last string, skip, take int) (result *datasync.Result, err error) {
+			unixDate, _ := strconv.ParseInt(last, 10, 64)
+			result = new(datasync.Result)
+			snapshots, totalCount, err := c.store.FetchOrderBookSnapshotsForSync(ctx, helpers.UnixTime(unixDate), skip, take)
+			if err != nil {
+				result.Message = err.Error()
+				return
+			}
+			result.Records = snapshots
+			result.TotalCount = totalCount
+			result.Success = true
+			return
+		},
+		Append: func(ctx context.Context, store datasync.Store, data interface{}) {
+			mappedData := data.([]interface{})
+			var snapshots []Snapshot
+			for _, item := range mappedData {
+				var snapshot Snapshot
+				err := datasync.DecodeSyncObj(item, &snapshot)
+				if err != nil {
+					log.Errorf("Error in decoding the received order book snapshot data, %s", err.Error())
+					return
+				}
+				snapshots = append(snapshots, snapshot)
+			}
+
+			for _, snapshot := range snapshots {
+				err := store.SaveOrderBookSnapshotFromSync(ctx, snapshot)
+				if err != nil {
+					log.Errorf("Error while appending order book snapshot synced data, %s", err.Error())
+				}
+			}
+		},
+	})
+}
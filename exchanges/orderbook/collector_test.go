@@ -0,0 +1,179 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package orderbook
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/app/helpers"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/testutil"
+)
+
+// snapshotTestStore records the snapshots passed to StoreOrderBookSnapshot.
+type snapshotTestStore struct {
+	snapshots []Snapshot
+}
+
+func (s *snapshotTestStore) OrderBookSnapshotTableName() string { return "order_book_snapshot" }
+func (s *snapshotTestStore) StoreOrderBookSnapshot(_ context.Context, snapshot Snapshot) error {
+	s.snapshots = append(s.snapshots, snapshot)
+	return nil
+}
+func (s *snapshotTestStore) LastOrderBookSnapshotTime() time.Time { return time.Time{} }
+func (s *snapshotTestStore) FetchOrderBookSnapshotsForSync(context.Context, time.Time, int, int) ([]Snapshot, int64, error) {
+	return nil, 0, nil
+}
+
+func TestCollect(t *testing.T) {
+	server := testutil.NewFixtureServer(t,
+		testutil.Route{Host: "api.binance.com", Path: "/api/v3/depth", Fixture: "binance.json"},
+		testutil.Route{Host: "api.bittrex.com", Path: "/v3/markets/DCR-BTC/orderbook", Fixture: "bittrex.json"},
+		testutil.Route{Host: "poloniex.com", Path: "/public", Fixture: "poloniex.json"},
+	)
+
+	store := new(snapshotTestStore)
+	collector, err := NewCollector(nil, nil, 300, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.SetHTTPClient(server.Client())
+
+	start := helpers.NowUTC().Truncate(time.Second)
+	if err = collector.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	end := helpers.NowUTC()
+
+	// every fixture holds the same book
+	bestBid, bestAsk := 0.00995, 0.01005
+	depths := []Depth{
+		{Band: 1, BidDepth: 10, AskDepth: 5},
+		{Band: 2, BidDepth: 30, AskDepth: 20},
+		{Band: 5, BidDepth: 30, AskDepth: 50},
+	}
+	var expected []Snapshot
+	for _, exchange := range []string{ticks.Binance, ticks.Bittrex, ticks.Poloniex} {
+		expected = append(expected, Snapshot{
+			Exchange:     exchange,
+			CurrencyPair: btcdcrPair,
+			BestBid:      bestBid,
+			BestAsk:      bestAsk,
+			MidPrice:     (bestBid + bestAsk) / 2,
+			Depths:       depths,
+		})
+	}
+
+	for i := range store.snapshots {
+		if date := store.snapshots[i].Time; date.Before(start) || date.After(end) {
+			t.Errorf("expected a snapshot time between %s and %s, got %s", start, end, date)
+		}
+		store.snapshots[i].Time = time.Time{}
+	}
+	if !reflect.DeepEqual(store.snapshots, expected) {
+		t.Errorf("expected snapshots %+v, got %+v", expected, store.snapshots)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(requests))
+	}
+	if query := requests[0].Query(); query.Get("symbol") != "DCRBTC" || query.Get("limit") != "1000" {
+		t.Errorf("unexpected binance request %s", requests[0])
+	}
+	if depth := requests[1].Query().Get("depth"); depth != "500" {
+		t.Errorf("expected a bittrex depth of 500, got %s", depth)
+	}
+	if query := requests[2].Query(); query.Get("command") != "returnOrderBook" || query.Get("currencyPair") != "BTC_DCR" {
+		t.Errorf("unexpected poloniex request %s", requests[2])
+	}
+}
+
+func TestCollectSkipsOpenCircuit(t *testing.T) {
+	server := testutil.NewFixtureServer(t,
+		testutil.Route{Host: "api.binance.com", Path: "/api/v3/depth", Fixture: "binance.json"},
+		testutil.Route{Host: "api.bittrex.com", Path: "/v3/markets/DCR-BTC/orderbook", Fixture: "bittrex.json"},
+		testutil.Route{Host: "poloniex.com", Path: "/public", Fixture: "poloniex.json"},
+	)
+
+	// the failures of the bittrex tick collector open the shared circuit
+	guard := ticks.GuardOf(ticks.Bittrex)
+	for guard.Status().State != ticks.BreakerOpen {
+		guard.Done(errors.New("unavailable"))
+	}
+	defer guard.Done(nil)
+
+	store := new(snapshotTestStore)
+	collector, err := NewCollector(nil, nil, 300, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.SetHTTPClient(server.Client())
+	if err = collector.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.snapshots) != 2 {
+		t.Fatalf("expected the snapshots of binance and poloniex, got %+v", store.snapshots)
+	}
+	for _, request := range server.Requests() {
+		if request.Host == "api.bittrex.com" {
+			t.Errorf("expected no request to bittrex while its circuit is open, got %s", request)
+		}
+	}
+}
+
+func TestBuildSnapshot(t *testing.T) {
+	book := &OrderBook{
+		Bids: []Order{{Price: 99, Quantity: 1}, {Price: 100, Quantity: 2}, {Price: 97, Quantity: 4}},
+		Asks: []Order{{Price: 104, Quantity: 3}, {Price: 102, Quantity: 5}, {Price: 110, Quantity: 8}},
+	}
+	tests := []struct {
+		name     string
+		book     *OrderBook
+		bands    []float64
+		expected *Snapshot
+	}{
+		{
+			name:  "unsorted book",
+			book:  book,
+			bands: []float64{1, 5},
+			expected: &Snapshot{BestBid: 100, BestAsk: 102, MidPrice: 101, Depths: []Depth{
+				{Band: 1, BidDepth: 2, AskDepth: 5},
+				{Band: 5, BidDepth: 7, AskDepth: 8},
+			}},
+		},
+		{
+			name:     "no band",
+			book:     book,
+			expected: &Snapshot{BestBid: 100, BestAsk: 102, MidPrice: 101, Depths: []Depth{}},
+		},
+		{
+			name:  "no ask",
+			book:  &OrderBook{Bids: book.Bids},
+			bands: []float64{1},
+		},
+	}
+	for _, test := range tests {
+		snapshot, err := BuildSnapshot(test.book, test.bands)
+		if test.expected == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %+v", test.name, snapshot)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(snapshot, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, snapshot)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package orderbook
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type Store interface {
+	OrderBookSnapshotTableName() string
+	StoreOrderBookSnapshot(ctx context.Context, snapshot Snapshot) error
+	LastOrderBookSnapshotTime() (time time.Time)
+	FetchOrderBookSnapshotsForSync(ctx context.Context, date time.Time, skip, take int) ([]Snapshot, int64, error)
+}
+
+// Order is a single price level of an order book
+type Order struct {
+	Price    float64
+	Quantity float64
+}
+
+// OrderBook holds the bids and asks of a market, best price first
+type OrderBook struct {
+	Bids []Order
+	Asks []Order
+}
+
+// Snapshot is the depth of an order book at a point in time
+type Snapshot struct {
+	Exchange     string    `json:"exchange"`
+	CurrencyPair string    `json:"currency_pair"`
+	Time         time.Time `json:"time"`
+	BestBid      float64   `json:"best_bid"`
+	BestAsk      float64   `json:"best_ask"`
+	MidPrice     float64   `json:"mid_price"`
+	Depths       []Depth   `json:"depths"`
+}
+
+// Depth is the total quantity offered within Band percent of the mid price
+type Depth struct {
+	Band     float64 `json:"band"`
+	BidDepth float64 `json:"bid_depth"`
+	AskDepth float64 `json:"ask_depth"`
+}
+
+type orderBookable interface {
+	toOrderBook() (*OrderBook, error)
+}
+
+type binanceOrderBook struct {
+	Bids [][2]string `json:"bids"`
+	Asks [][2]string `json:"asks"`
+}
+
+func (resp *binanceOrderBook) toOrderBook() (*OrderBook, error) {
+	bids, err := parseStringLevels(resp.Bids)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := parseStringLevels(resp.Asks)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderBook{Bids: bids, Asks: asks}, nil
+}
+
+type bittrexOrder struct {
+	Quantity string `json:"quantity"`
+	Rate     string `json:"rate"`
+}
+
+type bittrexOrderBook struct {
+	Bid []bittrexOrder `json:"bid"`
+	Ask []bittrexOrder `json:"ask"`
+}
+
+func (resp *bittrexOrderBook) toOrderBook() (*OrderBook, error) {
+	parse := func(levels []bittrexOrder) ([]Order, error) {
+		orders := make([]Order, 0, len(levels))
+		for _, level := range levels {
+			price, err := strconv.ParseFloat(level.Rate, 64)
+			if err != nil {
+				return nil, err
+			}
+			quantity, err := strconv.ParseFloat(level.Quantity, 64)
+			if err != nil {
+				return nil, err
+			}
+			orders = append(orders, Order{Price: price, Quantity: quantity})
+		}
+		return orders, nil
+	}
+	bids, err := parse(resp.Bid)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := parse(resp.Ask)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderBook{Bids: bids, Asks: asks}, nil
+}
+
+type poloniexOrderBook struct {
+	Bids  [][2]interface{} `json:"bids"`
+	Asks  [][2]interface{} `json:"asks"`
+	Error string           `json:"error"`
+}
+
+func (resp *poloniexOrderBook) toOrderBook() (*OrderBook, error) {
+	if resp.Error != "" {
+		return nil, fmt.Errorf("poloniex: %s", resp.Error)
+	}
+	parse := func(levels [][2]interface{}) ([]Order, error) {
+		orders := make([]Order, 0, len(levels))
+		for _, level := range levels {
+			priceStr, ok := level[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected price %v", level[0])
+			}
+			price, err := strconv.ParseFloat(priceStr, 64)
+			if err != nil {
+				return nil, err
+			}
+			quantity, ok := level[1].(float64)
+			if !ok {
+				return nil, fmt.Errorf("unexpected quantity %v", level[1])
+			}
+			orders = append(orders, Order{Price: price, Quantity: quantity})
+		}
+		return orders, nil
+	}
+	bids, err := parse(resp.Bids)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := parse(resp.Asks)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderBook{Bids: bids, Asks: asks}, nil
+}
+
+func parseStringLevels(levels [][2]string) ([]Order, error) {
+	orders := make([]Order, 0, len(levels))
+	for _, level := range levels {
+		price, err := strconv.ParseFloat(level[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		quantity, err := strconv.ParseFloat(level[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, Order{Price: price, Quantity: quantity})
+	}
+	return orders, nil
+}
@@ -6,6 +6,7 @@ package ticks
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
@@ -21,10 +22,24 @@ const (
 	poloniexAPIURL = "https://poloniex.com/public"
 	Binance        = "binance"
 	binanceAPIURL  = "https://api.binance.com/api/v1/klines"
+	Huobi          = "huobi"
+	huobiAPIURL    = "https://api.huobi.pro/market/history/kline"
+	Kucoin         = "kucoin"
+	kucoinAPIURL   = "https://api.kucoin.com/api/v1/market/candles"
+	Bitfinex       = "bitfinex"
+	bitfinexAPIURL = "https://api-pub.bitfinex.com/v2/candles/trade:%s:%s/hist"
+	Upbit          = "upbit"
+	upbitAPIURL    = "https://api.upbit.com/v1/candles/%s"
+	Dragonex       = "dragonex"
+	dragonexAPIURL = "https://openapi.dragonex.io/api/v1/market/kline/"
 
 	btcdcrPair = "BTC/DCR"
 	usdbtcPair = "USD/BTC"
 
+	// JobGroup is the scheduler group of the jobs that request the
+	// exchanges, so that they do not request them at once.
+	JobGroup = "exchange"
+
 	fiveMin = time.Minute * 5
 	oneDay  = time.Hour * 24
 
@@ -34,6 +49,22 @@ const (
 	apprxPoloniexStart  int64 = 1463364000
 	poloniexVolumeLimit int64 = 20000
 
+	// huobiVolumeLimit is the number of latest candles served by Huobi, the
+	// only ones it can collect
+	huobiVolumeLimit int64 = 2000
+
+	apprxKucoinStart  int64 = 1546300800
+	kucoinVolumeLimit int64 = 1500
+
+	apprxBitfinexStart  int64 = 1546300800
+	bitfinexVolumeLimit int64 = 10000
+
+	apprxUpbitStart  int64 = 1509494400
+	upbitVolumeLimit int64 = 200
+
+	apprxDragonexStart  int64 = 1527811200
+	dragonexVolumeLimit int64 = 100
+
 	clientTimeout = time.Minute
 
 	IntervalShort    = "short"
@@ -49,6 +80,11 @@ var (
 		Bittrexusd: NewBittrexUSDCollector,
 		Poloniex:   NewPoloniexCollector,
 		Binance:    NewBinanceCollector,
+		Huobi:      NewHuobiCollector,
+		Kucoin:     NewKucoinCollector,
+		Bitfinex:   NewBitfinexCollector,
+		Upbit:      NewUpbitCollector,
+		Dragonex:   NewDragonexCollector,
 	}
 
 	bittrexIntervals = map[float64]string{
@@ -70,6 +106,36 @@ var (
 		86400: "1d",
 	}
 
+	huobiIntervals = map[float64]string{
+		300:   "5min",
+		3600:  "60min",
+		86400: "1day",
+	}
+
+	kucoinIntervals = map[float64]string{
+		300:   "5min",
+		3600:  "1hour",
+		86400: "1day",
+	}
+
+	bitfinexIntervals = map[float64]string{
+		300:   "5m",
+		3600:  "1h",
+		86400: "1D",
+	}
+
+	upbitIntervals = map[float64]string{
+		300:   "minutes/5",
+		3600:  "minutes/60",
+		86400: "days",
+	}
+
+	dragonexIntervals = map[float64]int{
+		300:   2,
+		3600:  5,
+		86400: 6,
+	}
+
 	poloniexData = ExchangeData{
 		Name:       Poloniex,
 		WebsiteURL: "https://poloniex.com",
@@ -80,6 +146,8 @@ var (
 		ShortInterval:    fiveMin,
 		LongInterval:     2 * time.Hour,
 		HistoricInterval: oneDay,
+		requestRate:      2,
+		requestBurst:     4,
 		requester: func(last time.Time, interval time.Duration, cpair string) (string, error) {
 			return helpers.AddParams(poloniexAPIURL, map[string]interface{}{
 				"command":      "returnChartData",
@@ -101,6 +169,8 @@ var (
 		ShortInterval:    fiveMin,
 		LongInterval:     time.Hour,
 		HistoricInterval: oneDay,
+		requestRate:      5,
+		requestBurst:     10,
 		requester: func(last time.Time, interval time.Duration, cpair string) (string, error) {
 			start := last.Unix() * 1000
 			end := start + binanceVolumeLimit*int64(interval.Seconds())*1000
@@ -125,6 +195,8 @@ var (
 		ShortInterval:    fiveMin,
 		LongInterval:     time.Hour,
 		HistoricInterval: oneDay,
+		requestRate:      1,
+		requestBurst:     2,
 		requester: func(last time.Time, interval time.Duration, cpair string) (string, error) {
 			return helpers.AddParams(bittrexAPIURL, map[string]interface{}{
 				"marketName":   cpair,
@@ -132,13 +204,161 @@ var (
 			})
 		},
 	}
+
+	huobiData = ExchangeData{
+		Name:       Huobi,
+		WebsiteURL: "https://www.huobi.com",
+		availableCPairs: map[string]string{
+			btcdcrPair: "dcrbtc",
+		},
+		apiLimited:       false,
+		ShortInterval:    fiveMin,
+		LongInterval:     time.Hour,
+		HistoricInterval: oneDay,
+		requester:        huobiRequester(huobiAPIURL),
+		requestRate:      5,
+		requestBurst:     5,
+	}
+
+	kucoinData = ExchangeData{
+		Name:       Kucoin,
+		WebsiteURL: "https://www.kucoin.com",
+		availableCPairs: map[string]string{
+			btcdcrPair: "DCR-BTC",
+		},
+		apiLimited:       true,
+		ShortInterval:    fiveMin,
+		LongInterval:     time.Hour,
+		HistoricInterval: oneDay,
+		requester:        kucoinRequester(kucoinAPIURL),
+		requestRate:      3,
+		requestBurst:     5,
+	}
+
+	bitfinexData = ExchangeData{
+		Name:       Bitfinex,
+		WebsiteURL: "https://www.bitfinex.com",
+		availableCPairs: map[string]string{
+			btcdcrPair: "tDCRBTC",
+		},
+		apiLimited:       true,
+		ShortInterval:    fiveMin,
+		LongInterval:     time.Hour,
+		HistoricInterval: oneDay,
+		requester:        bitfinexRequester(bitfinexAPIURL),
+		requestRate:      1,
+		requestBurst:     3,
+	}
+
+	upbitData = ExchangeData{
+		Name:       Upbit,
+		WebsiteURL: "https://upbit.com",
+		availableCPairs: map[string]string{
+			btcdcrPair: "BTC-DCR",
+		},
+		apiLimited:       true,
+		ShortInterval:    fiveMin,
+		LongInterval:     time.Hour,
+		HistoricInterval: oneDay,
+		requester:        upbitRequester(upbitAPIURL),
+		requestRate:      5,
+		requestBurst:     10,
+	}
+
+	dragonexData = ExchangeData{
+		Name:       Dragonex,
+		WebsiteURL: "https://dragonex.io",
+		availableCPairs: map[string]string{
+			btcdcrPair: "1520101",
+		},
+		apiLimited:       true,
+		ShortInterval:    fiveMin,
+		LongInterval:     time.Hour,
+		HistoricInterval: oneDay,
+		requester:        dragonexRequester(dragonexAPIURL),
+		requestRate:      1,
+		requestBurst:     2,
+	}
+
+	// builtinExchanges are the exchanges with a built-in collector by name.
+	builtinExchanges = map[string]*ExchangeData{
+		Poloniex: &poloniexData,
+		Binance:  &binanceData,
+		Bittrex:  &bittrexData,
+		Huobi:    &huobiData,
+		Kucoin:   &kucoinData,
+		Bitfinex: &bitfinexData,
+		Upbit:    &upbitData,
+		Dragonex: &dragonexData,
+	}
 )
 
+func huobiRequester(apiURL string) urlRequester {
+	return func(last time.Time, interval time.Duration, cpair string) (string, error) {
+		// the kline history has no start parameter, the most recent candles
+		// are returned: the ticks older than them cannot be backfilled
+		return helpers.AddParams(apiURL, map[string]interface{}{
+			"symbol": cpair,
+			"period": huobiIntervals[interval.Seconds()],
+			"size":   huobiVolumeLimit,
+		})
+	}
+}
+
+func kucoinRequester(apiURL string) urlRequester {
+	return func(last time.Time, interval time.Duration, cpair string) (string, error) {
+		start := last.Unix()
+		return helpers.AddParams(apiURL, map[string]interface{}{
+			"symbol":  cpair,
+			"type":    kucoinIntervals[interval.Seconds()],
+			"startAt": start,
+			"endAt":   start + kucoinVolumeLimit*int64(interval.Seconds()),
+		})
+	}
+}
+
+func bitfinexRequester(apiURL string) urlRequester {
+	return func(last time.Time, interval time.Duration, cpair string) (string, error) {
+		start := last.Unix() * 1000
+		return helpers.AddParams(fmt.Sprintf(apiURL, bitfinexIntervals[interval.Seconds()], cpair), map[string]interface{}{
+			"start": start,
+			"end":   start + bitfinexVolumeLimit*int64(interval.Seconds())*1000,
+			"limit": bitfinexVolumeLimit,
+			"sort":  1,
+		})
+	}
+}
+
+func upbitRequester(apiURL string) urlRequester {
+	return func(last time.Time, interval time.Duration, cpair string) (string, error) {
+		// candles are returned backwards from the exclusive to parameter
+		to := last.Add(time.Duration(upbitVolumeLimit) * interval)
+		return helpers.AddParams(fmt.Sprintf(apiURL, upbitIntervals[interval.Seconds()]), map[string]interface{}{
+			"market": cpair,
+			"to":     to.UTC().Format("2006-01-02T15:04:05Z"),
+			"count":  upbitVolumeLimit,
+		})
+	}
+}
+
+func dragonexRequester(apiURL string) urlRequester {
+	return func(last time.Time, interval time.Duration, cpair string) (string, error) {
+		return helpers.AddParams(apiURL, map[string]interface{}{
+			"symbol_id":  cpair,
+			"st":         last.Unix(),
+			"direction":  2,
+			"count":      dragonexVolumeLimit,
+			"kline_type": dragonexIntervals[interval.Seconds()],
+		})
+	}
+}
+
 type commonExchange struct {
 	*ExchangeData
 	currencyPair string
 	store        Store
 	client       *http.Client
+	guard        *ExchangeGuard
 	lastShort    time.Time
 	lastLong     time.Time
 	lastHistoric time.Time
@@ -146,6 +366,13 @@ type commonExchange struct {
 	apiResp      tickable
 }
 
+// SetHTTPClient replaces the client used to request the exchange API.
+func (xc *commonExchange) SetHTTPClient(client *http.Client) {
+	xc.respLock.Lock()
+	xc.client = client
+	xc.respLock.Unlock()
+}
+
 func (xc *commonExchange) GetShort(ctx context.Context) error {
 	return xc.Get(ctx, &xc.lastShort, xc.ShortInterval, IntervalShort)
 }
@@ -170,7 +397,7 @@ func (xc *commonExchange) Get(ctx context.Context, last *time.Time, interval tim
 			return err
 		}
 		// fmt.Printf("Debug: %s\n", requestURL)
-		err = helpers.GetResponse(ctx, xc.client, requestURL, xc.apiResp)
+		err = xc.guard.GetResponse(ctx, xc.client, requestURL, xc.apiResp)
 		if err != nil {
 			return err
 		}
@@ -181,9 +408,11 @@ func (xc *commonExchange) Get(ctx context.Context, last *time.Time, interval tim
 		if err != nil {
 			return err
 		}
-		if newLast != zeroTime {
-			*last = newLast
+		if !newLast.After(*last) {
+			// the exchange has nothing newer, requesting again would return the same ticks
+			break
 		}
+		*last = newLast
 		if !xc.apiLimited || len(ticks) == 1 {
 			break
 		}
@@ -211,6 +440,7 @@ func newCollector(ctx context.Context, store Store, exchange ExchangeData, curre
 	return &commonExchange{
 		ExchangeData: &exchange,
 		client:       &http.Client{Timeout: 10 * time.Second},
+		guard:        guardFor(&exchange),
 		store:        store,
 		lastShort:    lastShort,
 		lastLong:     lastLong,
@@ -235,3 +465,26 @@ func NewBittrexUSDCollector(ctx context.Context, store Store) (Collector, error)
 func NewBinanceCollector(ctx context.Context, store Store) (Collector, error) {
 	return newCollector(ctx, store, binanceData, btcdcrPair, helpers.UnixTime(apprxBinanceStart), new(binanceAPIResponse))
 }
+
+// NewHuobiCollector returns a collector of the Huobi candles. Huobi only serves
+// the latest huobiVolumeLimit candles of an interval, the history before them
+// is not collected nor backfilled.
+func NewHuobiCollector(ctx context.Context, store Store) (Collector, error) {
+	return newCollector(ctx, store, huobiData, btcdcrPair, zeroTime, new(huobiAPIResponse))
+}
+
+func NewKucoinCollector(ctx context.Context, store Store) (Collector, error) {
+	return newCollector(ctx, store, kucoinData, btcdcrPair, helpers.UnixTime(apprxKucoinStart), new(kucoinAPIResponse))
+}
+
+func NewBitfinexCollector(ctx context.Context, store Store) (Collector, error) {
+	return newCollector(ctx, store, bitfinexData, btcdcrPair, helpers.UnixTime(apprxBitfinexStart), new(bitfinexAPIResponse))
+}
+
+func NewUpbitCollector(ctx context.Context, store Store) (Collector, error) {
+	return newCollector(ctx, store, upbitData, btcdcrPair, helpers.UnixTime(apprxUpbitStart), new(upbitAPIResponse))
+}
+
+func NewDragonexCollector(ctx context.Context, store Store) (Collector, error) {
+	return newCollector(ctx, store, dragonexData, btcdcrPair, helpers.UnixTime(apprxDragonexStart), new(dragonexAPIResponse))
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ticks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/app/helpers"
+	"github.com/planetdecred/dcrextdata/testutil"
+)
+
+// tickTestStore keeps the stored ticks of each series by time, ignoring
+// duplicates like the unique index of the exchange_tick table.
+type tickTestStore struct {
+	mtx   sync.Mutex
+	ticks map[string]map[int64]Tick
+}
+
+func (s *tickTestStore) ExchangeTickTableName() string { return "exchange_tick" }
+func (s *tickTestStore) ExchangeTableName() string     { return "exchange" }
+func (s *tickTestStore) RegisterExchange(context.Context, ExchangeData) (time.Time, time.Time, time.Time, error) {
+	return zeroTime, zeroTime, zeroTime, nil
+}
+func (s *tickTestStore) FetchExchangeForSync(context.Context, int, int, int) ([]ExchangeData, int64, error) {
+	return nil, 0, nil
+}
+func (s *tickTestStore) LastExchangeTickEntryTime() time.Time      { return zeroTime }
+func (s *tickTestStore) UpdateExchangeIndex(context.Context) error { return nil }
+
+func (s *tickTestStore) StoreExchangeTicks(_ context.Context, exchange string, interval int, pair string, data []Tick) (time.Time, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	key := TickSeries{exchange, pair, interval}.String()
+	if s.ticks == nil {
+		s.ticks = make(map[string]map[int64]Tick)
+	}
+	if s.ticks[key] == nil {
+		s.ticks[key] = make(map[int64]Tick)
+	}
+	var last time.Time
+	for _, tick := range data {
+		s.ticks[key][tick.Time.Unix()] = tick
+		last = tick.Time
+	}
+	return last, nil
+}
+
+func (s *tickTestStore) stored(series TickSeries) []Tick {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	var ticks []Tick
+	for _, tick := range s.ticks[series.String()] {
+		ticks = append(ticks, tick)
+	}
+	sort.Slice(ticks, func(i, j int) bool { return ticks[i].Time.Before(ticks[j].Time) })
+	return ticks
+}
+
+// fixtureTicks are the candles recorded in the testdata responses.
+var fixtureTicks = []Tick{
+	{Open: 0.002295, High: 0.002302, Low: 0.00229, Close: 0.0023, Volume: 98.5, Time: helpers.UnixTime(1577836800)},
+	{Open: 0.0023, High: 0.002304, Low: 0.002298, Close: 0.002301, Volume: 41.25, Time: helpers.UnixTime(1577837100)},
+	{Open: 0.002301, High: 0.002308, Low: 0.0023, Close: 0.002305, Volume: 152.31, Time: helpers.UnixTime(1577837400)},
+}
+
+func TestCollectors(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		exchange ExchangeData
+		response tickable
+		host     string
+		path     string
+		query    []string
+	}{
+		{
+			fixture:  "bittrex.json",
+			exchange: bittrexData,
+			response: new(bittrexAPIResponse),
+			host:     "bittrex.com",
+			path:     "/Api/v2.0/pub/market/GetTicks",
+			query:    []string{"marketName=BTC-DCR", "tickInterval=fiveMin"},
+		},
+		{
+			fixture:  "poloniex.json",
+			exchange: poloniexData,
+			response: new(poloniexAPIResponse),
+			host:     "poloniex.com",
+			path:     "/public",
+			query:    []string{"command=returnChartData", "currencyPair=BTC_DCR", "start=1577836800", "period=300"},
+		},
+		{
+			fixture:  "binance.json",
+			exchange: binanceData,
+			response: new(binanceAPIResponse),
+			host:     "api.binance.com",
+			path:     "/api/v1/klines",
+			query:    []string{"symbol=DCRBTC", "startTime=1577836800000", "endTime=1578136800000", "interval=5m", "limit=1000"},
+		},
+		{
+			fixture:  "huobi.json",
+			exchange: huobiData,
+			response: new(huobiAPIResponse),
+			host:     "api.huobi.pro",
+			path:     "/market/history/kline",
+			query:    []string{"symbol=dcrbtc", "period=5min", "size=2000"},
+		},
+		{
+			fixture:  "kucoin.json",
+			exchange: kucoinData,
+			response: new(kucoinAPIResponse),
+			host:     "api.kucoin.com",
+			path:     "/api/v1/market/candles",
+			query:    []string{"symbol=DCR-BTC", "type=5min", "startAt=1577836800", "endAt=1578286800"},
+		},
+		{
+			fixture:  "bitfinex.json",
+			exchange: bitfinexData,
+			response: new(bitfinexAPIResponse),
+			host:     "api-pub.bitfinex.com",
+			path:     "/v2/candles/trade:5m:tDCRBTC/hist",
+			query:    []string{"start=1577836800000", "sort=1"},
+		},
+		{
+			fixture:  "upbit.json",
+			exchange: upbitData,
+			response: new(upbitAPIResponse),
+			host:     "api.upbit.com",
+			path:     "/v1/candles/minutes/5",
+			query:    []string{"market=BTC-DCR", "to=2020-01-01T16:40:00Z", "count=200"},
+		},
+		{
+			fixture:  "dragonex.json",
+			exchange: dragonexData,
+			response: new(dragonexAPIResponse),
+			host:     "openapi.dragonex.io",
+			path:     "/api/v1/market/kline/",
+			query:    []string{"symbol_id=1520101", "st=1577836800", "direction=2", "kline_type=2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.exchange.Name, func(t *testing.T) {
+			server := testutil.NewFixtureServer(t, testutil.Route{Host: test.host, Path: test.path, Fixture: test.fixture})
+
+			store := new(tickTestStore)
+			collector, err := newCollector(context.Background(), store, test.exchange, btcdcrPair, zeroTime, test.response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			collector.SetHTTPClient(server.Client())
+			collector.(*commonExchange).lastShort = fixtureTicks[0].Time
+			if err = collector.GetShort(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			stored := store.stored(TickSeries{test.exchange.Name, btcdcrPair, 5})
+			if !reflect.DeepEqual(stored, fixtureTicks) {
+				t.Fatalf("expected ticks %+v, got %+v", fixtureTicks, stored)
+			}
+
+			requests := server.Requests()
+			if len(requests) == 0 {
+				t.Fatal("no request was sent")
+			}
+			if requests[0].Host != test.host || requests[0].Path != test.path {
+				t.Errorf("expected a request to %s%s, got %s", test.host, test.path, requests[0])
+			}
+			for _, param := range test.query {
+				if !strings.Contains(requests[0].RawQuery, param) {
+					t.Errorf("expected %s in the request %s", param, requests[0])
+				}
+			}
+			if !test.exchange.apiLimited && len(requests) != 1 {
+				t.Errorf("expected a single request, got %d", len(requests))
+			}
+		})
+	}
+}
+
+func TestCollectorsSkipOldTicks(t *testing.T) {
+	responses := map[string]tickable{
+		"bittrex.json":  new(bittrexAPIResponse),
+		"poloniex.json": new(poloniexAPIResponse),
+		"binance.json":  new(binanceAPIResponse),
+		"huobi.json":    new(huobiAPIResponse),
+		"kucoin.json":   new(kucoinAPIResponse),
+		"bitfinex.json": new(bitfinexAPIResponse),
+		"upbit.json":    new(upbitAPIResponse),
+		"dragonex.json": new(dragonexAPIResponse),
+	}
+	for fixture, response := range responses {
+		body, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = json.Unmarshal(body, response); err != nil {
+			t.Fatalf("%s: %v", fixture, err)
+		}
+		ticks := response.toTicks(fixtureTicks[1].Time.Unix())
+		if !reflect.DeepEqual(ticks, fixtureTicks[1:]) {
+			t.Errorf("%s: expected %+v, got %+v", fixture, fixtureTicks[1:], ticks)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ticks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/app/helpers"
+)
+
+const (
+	timeFormatUnix   = "unix"
+	timeFormatUnixMs = "unixms"
+)
+
+// ExchangeConfig describes an exchange whose candle API can be requested and
+// decoded without exchange specific code. A list of ExchangeConfig is read
+// from the exchange config file at startup.
+type ExchangeConfig struct {
+	Name       string `json:"name"`
+	WebsiteURL string `json:"website"`
+	// URL is a text/template for the candle request. The template receives
+	// the exchange symbol as .Pair, the interval name as .Interval, the
+	// window as .Start/.End (seconds) or .StartMs/.EndMs (milliseconds)
+	// and the page size as .Limit.
+	URL string `json:"url"`
+	// Pairs maps the currency pairs used in dcrextdata (BTC/DCR, USD/BTC) to
+	// the exchange symbol.
+	Pairs     map[string]string         `json:"pairs"`
+	Intervals map[string]IntervalConfig `json:"intervals"`
+	Fields    FieldsConfig              `json:"fields"`
+	// Limit is the maximum number of candles returned per request. A zero
+	// limit means that the API returns all candles in a single response.
+	Limit int64 `json:"limit"`
+	// HistoricStart is the unix time of the first historic candle.
+	HistoricStart int64 `json:"historicstart"`
+	// RateLimit is the number of requests per second allowed by the API and
+	// RateBurst the number of requests that may be sent at once. Both
+	// default to 1.
+	RateLimit float64 `json:"ratelimit"`
+	RateBurst int     `json:"rateburst"`
+}
+
+// IntervalConfig holds the length of a candle interval in seconds and the
+// name the exchange uses for it.
+type IntervalConfig struct {
+	Seconds int64  `json:"seconds"`
+	Name    string `json:"name"`
+}
+
+// FieldsConfig holds the paths to the OHLCV values in an API response. A path
+// is a dot separated list of object keys and array indices. Data is the path
+// of the candle list from the response root, the other paths are relative to
+// a single candle.
+type FieldsConfig struct {
+	Data   string `json:"data"`
+	Time   string `json:"time"`
+	Open   string `json:"open"`
+	High   string `json:"high"`
+	Low    string `json:"low"`
+	Close  string `json:"close"`
+	Volume string `json:"volume"`
+	// TimeFormat is one of unix, unixms or a Go time layout. Defaults to unix.
+	TimeFormat string `json:"timeformat"`
+}
+
+type urlTemplateData struct {
+	Pair     string
+	Interval string
+	Start    int64
+	End      int64
+	StartMs  int64
+	EndMs    int64
+	Limit    int64
+}
+
+// LoadExchangeConfigs reads and validates the exchange definitions in the
+// JSON file at path.
+func LoadExchangeConfigs(path string) ([]ExchangeConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs []ExchangeConfig
+	if err = json.Unmarshal(content, &configs); err != nil {
+		return nil, fmt.Errorf("error in parsing exchange config %s - %s", path, err.Error())
+	}
+	for i := range configs {
+		if err = configs[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	return configs, nil
+}
+
+func (cfg *ExchangeConfig) validate() error {
+	if cfg.Name == "" {
+		return fmt.Errorf("exchange config: missing name")
+	}
+	if _, found := CollectorConstructors[cfg.Name]; found {
+		return fmt.Errorf("exchange config: %s is a built-in exchange", cfg.Name)
+	}
+	if cfg.URL == "" {
+		return fmt.Errorf("exchange config %s: missing url", cfg.Name)
+	}
+	if len(cfg.Pairs) == 0 {
+		return fmt.Errorf("exchange config %s: no currency pair", cfg.Name)
+	}
+	for _, name := range []string{IntervalShort, IntervalLong, IntervalHistoric} {
+		if interval, ok := cfg.Intervals[name]; !ok || interval.Seconds <= 0 {
+			return fmt.Errorf("exchange config %s: missing %s interval", cfg.Name, name)
+		}
+	}
+	fields := map[string]string{
+		"time":   cfg.Fields.Time,
+		"open":   cfg.Fields.Open,
+		"high":   cfg.Fields.High,
+		"low":    cfg.Fields.Low,
+		"close":  cfg.Fields.Close,
+		"volume": cfg.Fields.Volume,
+	}
+	for name, path := range fields {
+		if path == "" {
+			return fmt.Errorf("exchange config %s: missing %s field path", cfg.Name, name)
+		}
+	}
+	return nil
+}
+
+// exchangeData builds the ExchangeData of the configured exchange with a
+// requester that executes the URL template.
+func (cfg *ExchangeConfig) exchangeData() (ExchangeData, error) {
+	urlTemplate, err := template.New(cfg.Name).Parse(cfg.URL)
+	if err != nil {
+		return ExchangeData{}, fmt.Errorf("exchange config %s: invalid url template - %s", cfg.Name, err.Error())
+	}
+
+	intervalNames := make(map[float64]string, len(cfg.Intervals))
+	for _, interval := range cfg.Intervals {
+		intervalNames[float64(interval.Seconds)] = interval.Name
+	}
+
+	limit := cfg.Limit
+	return ExchangeData{
+		Name:             cfg.Name,
+		WebsiteURL:       cfg.WebsiteURL,
+		availableCPairs:  cfg.Pairs,
+		apiLimited:       limit > 0,
+		ShortInterval:    time.Duration(cfg.Intervals[IntervalShort].Seconds) * time.Second,
+		LongInterval:     time.Duration(cfg.Intervals[IntervalLong].Seconds) * time.Second,
+		HistoricInterval: time.Duration(cfg.Intervals[IntervalHistoric].Seconds) * time.Second,
+		requestRate:      cfg.RateLimit,
+		requestBurst:     cfg.RateBurst,
+		requester: func(last time.Time, interval time.Duration, cpair string) (string, error) {
+			start := last.Unix()
+			end := helpers.NowUTC().Unix()
+			if limit > 0 {
+				end = start + limit*int64(interval.Seconds())
+			}
+			data := urlTemplateData{
+				Pair:     cpair,
+				Interval: intervalNames[interval.Seconds()],
+				Start:    start,
+				End:      end,
+				StartMs:  start * 1000,
+				EndMs:    end * 1000,
+				Limit:    limit,
+			}
+			var buf bytes.Buffer
+			if err := urlTemplate.Execute(&buf, data); err != nil {
+				return "", err
+			}
+			return buf.String(), nil
+		},
+	}, nil
+}
+
+// NewConfiguredCollectors returns a collector for each currency pair of the
+// configured exchange.
+func NewConfiguredCollectors(ctx context.Context, store Store, cfg ExchangeConfig) ([]Collector, error) {
+	exchange, err := cfg.exchangeData()
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]string, 0, len(cfg.Pairs))
+	for pair := range cfg.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	var historicStart time.Time
+	if cfg.HistoricStart > 0 {
+		historicStart = helpers.UnixTime(cfg.HistoricStart)
+	}
+
+	collectors := make([]Collector, 0, len(pairs))
+	for _, pair := range pairs {
+		collector, err := newCollector(ctx, store, exchange, pair, historicStart, &configuredResponse{name: cfg.Name, fields: cfg.Fields})
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, collector)
+	}
+	return collectors, nil
+}
+
+// configuredResponse is a tickable that reads candles from an arbitrary JSON
+// document using the field paths of an ExchangeConfig. A response whose data
+// path does not lead to a list, or none of whose candles can be read, fails to
+// decode so that a misconfigured exchange reports an error.
+type configuredResponse struct {
+	name   string
+	fields FieldsConfig
+	ticks  []Tick
+}
+
+func (resp *configuredResponse) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var body interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return err
+	}
+	rows, ok := lookupPath(body, resp.fields.Data).([]interface{})
+	if !ok {
+		return fmt.Errorf("%s response: no candle list at the data path %q", resp.name, resp.fields.Data)
+	}
+
+	resp.ticks = make([]Tick, 0, len(rows))
+	var rowErr error
+	for i, row := range rows {
+		tick, err := resp.fields.parseTick(row)
+		if err != nil {
+			rowErr = fmt.Errorf("%s response: candle %d: %s", resp.name, i, err.Error())
+			continue
+		}
+		resp.ticks = append(resp.ticks, tick)
+	}
+	if len(resp.ticks) == 0 && rowErr != nil {
+		return rowErr
+	}
+	if rowErr != nil {
+		log.Warnf("Skipped %d of the %d candles of the %s response, %s", len(rows)-len(resp.ticks), len(rows),
+			resp.name, rowErr.Error())
+	}
+	return nil
+}
+
+// parseTick reads the candle of row at the field paths.
+func (fields FieldsConfig) parseTick(row interface{}) (Tick, error) {
+	t, err := parseTickTime(lookupPath(row, fields.Time), fields.TimeFormat)
+	if err != nil {
+		return Tick{}, fmt.Errorf("time at %q: %s", fields.Time, err.Error())
+	}
+	values := make([]float64, 5)
+	names := []string{"open", "high", "low", "close", "volume"}
+	paths := []string{fields.Open, fields.High, fields.Low, fields.Close, fields.Volume}
+	for i, path := range paths {
+		if values[i], err = toFloat(lookupPath(row, path)); err != nil {
+			return Tick{}, fmt.Errorf("%s at %q: %s", names[i], path, err.Error())
+		}
+	}
+	return Tick{
+		Open:   values[0],
+		High:   values[1],
+		Low:    values[2],
+		Close:  values[3],
+		Volume: values[4],
+		Time:   t.UTC(),
+	}, nil
+}
+
+func (resp *configuredResponse) toTicks(start int64) []Tick {
+	dataTicks := make([]Tick, 0, len(resp.ticks))
+	for _, tick := range resp.ticks {
+		if tick.Time.Unix() >= start {
+			dataTicks = append(dataTicks, tick)
+		}
+	}
+
+	// some exchanges return the newest candle first
+	sort.Slice(dataTicks, func(i, j int) bool {
+		return dataTicks[i].Time.Before(dataTicks[j].Time)
+	})
+	return dataTicks
+}
+
+// lookupPath walks the dot separated path through nested JSON objects and
+// arrays. It returns nil if any part of the path is missing.
+func lookupPath(value interface{}, path string) interface{} {
+	if path == "" {
+		return value
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			value = node[key]
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil
+			}
+			value = node[index]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case nil:
+		return 0, fmt.Errorf("missing value")
+	default:
+		return 0, fmt.Errorf("unexpected value %v", value)
+	}
+}
+
+func parseTickTime(value interface{}, format string) (time.Time, error) {
+	switch format {
+	case "", timeFormatUnix, timeFormatUnixMs:
+		secs, err := toFloat(value)
+		if err != nil {
+			return zeroTime, err
+		}
+		if format == timeFormatUnixMs {
+			secs /= 1000
+		}
+		return helpers.UnixTime(int64(secs)), nil
+	default:
+		str, ok := value.(string)
+		if !ok {
+			return zeroTime, fmt.Errorf("unexpected time value %v", value)
+		}
+		return time.Parse(format, str)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ticks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadExchangeConfigs(t *testing.T) {
+	configs, err := LoadExchangeConfigs(filepath.Join("..", "..", "sample-exchanges.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 || configs[0].Name != "kraken" || configs[0].Fields.Data != "result.DCRXBT" {
+		t.Fatalf("unexpected sample exchange configs %+v", configs)
+	}
+
+	dir, err := ioutil.TempDir("", "dcrextdata-exchanges")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"malformed": `[{"name": "kraken",`,
+		"invalid":   `[{"name": "kraken"}]`,
+	} {
+		path := filepath.Join(dir, name+".json")
+		if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = LoadExchangeConfigs(path); err == nil {
+			t.Errorf("expected an error for the %s config", name)
+		}
+	}
+	if _, err = LoadExchangeConfigs(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected an error for a missing config file")
+	}
+}
+
+// testExchangeConfig returns a valid config, in the format of the sample file.
+func testExchangeConfig() ExchangeConfig {
+	return ExchangeConfig{
+		Name:  "kraken",
+		URL:   "https://api.kraken.com/0/public/OHLC?pair={{.Pair}}&interval={{.Interval}}&since={{.Start}}",
+		Pairs: map[string]string{btcdcrPair: "DCRXBT"},
+		Intervals: map[string]IntervalConfig{
+			IntervalShort:    {Seconds: 300, Name: "5"},
+			IntervalLong:     {Seconds: 3600, Name: "60"},
+			IntervalHistoric: {Seconds: 86400, Name: "1440"},
+		},
+		Fields: FieldsConfig{Data: "result.DCRXBT", Time: "0", Open: "1", High: "2", Low: "3", Close: "4", Volume: "6"},
+	}
+}
+
+func TestExchangeConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*ExchangeConfig)
+		err    string
+	}{
+		{"valid", func(*ExchangeConfig) {}, ""},
+		{"no name", func(cfg *ExchangeConfig) { cfg.Name = "" }, "missing name"},
+		{"built-in exchange", func(cfg *ExchangeConfig) { cfg.Name = Binance }, "built-in exchange"},
+		{"no url", func(cfg *ExchangeConfig) { cfg.URL = "" }, "missing url"},
+		{"no pair", func(cfg *ExchangeConfig) { cfg.Pairs = nil }, "no currency pair"},
+		{"missing interval", func(cfg *ExchangeConfig) { delete(cfg.Intervals, IntervalLong) }, "missing long interval"},
+		{"zero interval", func(cfg *ExchangeConfig) {
+			cfg.Intervals[IntervalHistoric] = IntervalConfig{Name: "1440"}
+		}, "missing historic interval"},
+		{"missing field", func(cfg *ExchangeConfig) { cfg.Fields.Volume = "" }, "missing volume field path"},
+	}
+	for _, test := range tests {
+		cfg := testExchangeConfig()
+		test.change(&cfg)
+		err := cfg.validate()
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected an error with %q, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestConfiguredResponse(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	krakenFields := testExchangeConfig().Fields
+	objectFields := FieldsConfig{Data: "data", Time: "ts", Open: "o", High: "h", Low: "l", Close: "c",
+		Volume: "v", TimeFormat: timeFormatUnixMs}
+
+	tests := []struct {
+		name     string
+		fields   FieldsConfig
+		body     string
+		start    time.Time
+		expected []Tick
+		err      string
+	}{
+		{
+			name:   "array indexed fields",
+			fields: krakenFields,
+			body: `{"error":[],"result":{"DCRXBT":[
+				[1577836800,"0.0020","0.0022","0.0019","0.0021","0.0020","12.5",3],
+				[1577837100,"0.0021","0.0023","0.0020","0.0022","0.0021","7",2]
+			],"last":1577837100}}`,
+			expected: []Tick{
+				{Open: 0.0020, High: 0.0022, Low: 0.0019, Close: 0.0021, Volume: 12.5, Time: at(0)},
+				{Open: 0.0021, High: 0.0023, Low: 0.0020, Close: 0.0022, Volume: 7, Time: at(5)},
+			},
+		},
+		{
+			name:   "unixms times, newest first",
+			fields: objectFields,
+			body: `{"data":[
+				{"ts":1577837100000,"o":2,"h":4,"l":1,"c":3,"v":10},
+				{"ts":1577836800000,"o":1,"h":2,"l":0.5,"c":2,"v":5}
+			]}`,
+			expected: []Tick{
+				{Open: 1, High: 2, Low: 0.5, Close: 2, Volume: 5, Time: at(0)},
+				{Open: 2, High: 4, Low: 1, Close: 3, Volume: 10, Time: at(5)},
+			},
+		},
+		{
+			name:   "candles before the start",
+			fields: objectFields,
+			body: `{"data":[
+				{"ts":1577836800000,"o":1,"h":2,"l":0.5,"c":2,"v":5},
+				{"ts":1577837100000,"o":2,"h":4,"l":1,"c":3,"v":10}
+			]}`,
+			start:    at(5),
+			expected: []Tick{{Open: 2, High: 4, Low: 1, Close: 3, Volume: 10, Time: at(5)}},
+		},
+		{
+			name: "time layout",
+			fields: FieldsConfig{Data: "", Time: "time", Open: "0", High: "1", Low: "2", Close: "3", Volume: "4",
+				TimeFormat: time.RFC3339},
+			body:     `[{"time":"2020-01-01T00:05:00Z","0":1,"1":2,"2":0.5,"3":1.5,"4":"3"}]`,
+			expected: []Tick{{Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 3, Time: at(5)}},
+		},
+		{
+			name:   "missing field in a candle",
+			fields: objectFields,
+			body: `{"data":[
+				{"ts":1577836800000,"o":1,"h":2,"l":0.5,"c":2},
+				{"ts":1577837100000,"o":2,"h":4,"l":1,"c":3,"v":10}
+			]}`,
+			expected: []Tick{{Open: 2, High: 4, Low: 1, Close: 3, Volume: 10, Time: at(5)}},
+		},
+		{
+			name:     "no candle",
+			fields:   objectFields,
+			body:     `{"data":[]}`,
+			expected: []Tick{},
+		},
+		{
+			name:   "missing field in every candle",
+			fields: objectFields,
+			body:   `{"data":[{"ts":1577836800000,"o":1,"h":2,"l":0.5,"c":2}]}`,
+			err:    `volume at "v": missing value`,
+		},
+		{
+			name:   "unparsable time",
+			fields: objectFields,
+			body:   `{"data":[{"ts":"yesterday","o":1,"h":2,"l":0.5,"c":2,"v":1}]}`,
+			err:    `time at "ts"`,
+		},
+		{
+			name:   "wrong data path",
+			fields: krakenFields,
+			body:   `{"error":[],"result":{"DCRBTC":[]}}`,
+			err:    `no candle list at the data path "result.DCRXBT"`,
+		},
+		{
+			name:   "data path to an object",
+			fields: FieldsConfig{Data: "result", Time: "0", Open: "1", High: "2", Low: "3", Close: "4", Volume: "6"},
+			body:   `{"result":{"DCRXBT":[]}}`,
+			err:    "no candle list",
+		},
+	}
+	for _, test := range tests {
+		resp := &configuredResponse{name: "test", fields: test.fields}
+		err := json.Unmarshal([]byte(test.body), resp)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected an error with %q, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if ticks := resp.toTicks(test.start.Unix()); !reflect.DeepEqual(ticks, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, ticks)
+		}
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{"a":{"b":[10,{"c":"x"}]}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path     string
+		expected interface{}
+	}{
+		{"a.b.0", 10.0},
+		{"a.b.1.c", "x"},
+		{"a.b.2", nil},
+		{"a.b.-1", nil},
+		{"a.b.c", nil},
+		{"a.x.c", nil},
+		{"a.b.0.c", nil},
+	}
+	for _, test := range tests {
+		if value := lookupPath(doc, test.path); value != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.path, test.expected, value)
+		}
+	}
+	if value := lookupPath(doc, ""); !reflect.DeepEqual(value, doc) {
+		t.Errorf("expected the empty path to return the document, got %v", value)
+	}
+}
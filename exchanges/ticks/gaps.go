@@ -0,0 +1,263 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ticks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/scheduler"
+)
+
+// DefaultGapFillInterval is the time between two scans for exchange tick gaps.
+const DefaultGapFillInterval = 6 * time.Hour
+
+// GapStore is a Store that can list the stored candle times of each series.
+type GapStore interface {
+	Store
+	ExchangeTickSeries(ctx context.Context) ([]TickSeries, error)
+	ExchangeTickTimes(ctx context.Context, series TickSeries) ([]time.Time, error)
+}
+
+// TickSeries identifies the candles of an exchange currency pair at a given
+// interval in minutes.
+type TickSeries struct {
+	Exchange     string
+	CurrencyPair string
+	Interval     int
+}
+
+func (s TickSeries) String() string {
+	return fmt.Sprintf("%s %s (%dm)", s.Exchange, s.CurrencyPair, s.Interval)
+}
+
+// Gap is a run of consecutive missing candles. From and To are the times of
+// the first and last missing candle.
+type Gap struct {
+	TickSeries
+	From    time.Time
+	To      time.Time
+	Missing int
+}
+
+// GapReport summarises a gap filling run. Missing and Filled are counted in
+// candles.
+type GapReport struct {
+	Series   int
+	Gaps     int
+	Missing  int
+	Filled   int
+	Unfilled []Gap
+}
+
+// FindGaps returns the gaps between the candle times of series, which may be
+// in any order. The candles before the first time and after the last one are
+// not gaps.
+func FindGaps(series TickSeries, times []time.Time) []Gap {
+	interval := time.Duration(series.Interval) * time.Minute
+	if interval <= 0 {
+		return nil
+	}
+	if !sort.SliceIsSorted(times, func(i, j int) bool { return times[i].Before(times[j]) }) {
+		times = append([]time.Time(nil), times...)
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	}
+
+	var gaps []Gap
+	for i := 1; i < len(times); i++ {
+		missing := int(times[i].Sub(times[i-1])/interval) - 1
+		if missing < 1 {
+			continue
+		}
+		gaps = append(gaps, Gap{
+			TickSeries: series,
+			From:       times[i-1].Add(interval),
+			To:         times[i-1].Add(time.Duration(missing) * interval),
+			Missing:    missing,
+		})
+	}
+	return gaps
+}
+
+type gapSource struct {
+	exchange ExchangeData
+	response func() tickable
+}
+
+// GapFiller finds the missing candles of the stored exchange ticks and
+// requests them again from the exchanges.
+type GapFiller struct {
+	store    GapStore
+	client   *http.Client
+	sources  map[string]gapSource
+	interval time.Duration
+}
+
+// NewGapFiller returns a GapFiller for the built-in exchanges and the
+// configured ones, leaving out the disabled exchanges.
+func NewGapFiller(store GapStore, disabledExchanges []string, configs []ExchangeConfig) (*GapFiller, error) {
+	sources := map[string]gapSource{
+		Bittrex:  {bittrexData, func() tickable { return new(bittrexAPIResponse) }},
+		Poloniex: {poloniexData, func() tickable { return new(poloniexAPIResponse) }},
+		Binance:  {binanceData, func() tickable { return new(binanceAPIResponse) }},
+		Huobi:    {huobiData, func() tickable { return new(huobiAPIResponse) }},
+		Kucoin:   {kucoinData, func() tickable { return new(kucoinAPIResponse) }},
+		Bitfinex: {bitfinexData, func() tickable { return new(bitfinexAPIResponse) }},
+		Upbit:    {upbitData, func() tickable { return new(upbitAPIResponse) }},
+		Dragonex: {dragonexData, func() tickable { return new(dragonexAPIResponse) }},
+	}
+	for _, cfg := range configs {
+		exchange, err := cfg.exchangeData()
+		if err != nil {
+			return nil, err
+		}
+		name, fields := cfg.Name, cfg.Fields
+		sources[cfg.Name] = gapSource{exchange, func() tickable { return &configuredResponse{name: name, fields: fields} }}
+	}
+	for _, name := range disabledExchanges {
+		delete(sources, name)
+	}
+
+	return &GapFiller{
+		store:    store,
+		client:   &http.Client{Timeout: clientTimeout},
+		sources:  sources,
+		interval: DefaultGapFillInterval,
+	}, nil
+}
+
+// SetInterval sets the time between the gap filling runs of the job added by
+// RegisterJobs.
+func (f *GapFiller) SetInterval(interval time.Duration) {
+	f.interval = interval
+}
+
+// RegisterJobs adds the periodic gap filling to s, in the exchange group and
+// after the tick collection jobs.
+func (f *GapFiller) RegisterJobs(s *scheduler.Scheduler) error {
+	return s.AddJob(scheduler.Job{
+		Name:     "exchange-tick-gaps",
+		Module:   JobGroup,
+		Interval: f.interval,
+		Timeout:  f.interval,
+		Group:    JobGroup,
+		Run: func(ctx context.Context) error {
+			report, err := f.Run(ctx)
+			if err != nil {
+				return fmt.Errorf("error in filling exchange tick gaps, %s", err.Error())
+			}
+			log.Infof("Found %d exchange tick gaps with %d missing ticks in %d series, filled %d ticks",
+				report.Gaps, report.Missing, report.Series, report.Filled)
+			return nil
+		},
+	})
+}
+
+func (f *GapFiller) scanSeries(ctx context.Context, series TickSeries) ([]Gap, error) {
+	times, err := f.store.ExchangeTickTimes(ctx, series)
+	if err != nil {
+		return nil, fmt.Errorf("error in fetching %s tick times, %s", series, err.Error())
+	}
+	return FindGaps(series, times), nil
+}
+
+// Fill requests the candles of gap from its exchange and stores the ones that
+// fall within the gap.
+func (f *GapFiller) Fill(ctx context.Context, gap Gap) error {
+	src, found := f.sources[gap.Exchange]
+	if !found {
+		return fmt.Errorf("no backfill source for %s", gap.Exchange)
+	}
+	cpair, found := src.exchange.availableCPairs[gap.CurrencyPair]
+	if !found {
+		return fmt.Errorf("%s does not provide %s", gap.Exchange, gap.CurrencyPair)
+	}
+
+	interval := time.Duration(gap.Interval) * time.Minute
+	last := gap.From
+	for !last.After(gap.To) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		requestURL, err := src.exchange.requester(last, interval, cpair)
+		if err != nil {
+			return err
+		}
+		resp := src.response()
+		if err = guardFor(&src.exchange).GetResponse(ctx, f.client, requestURL, resp); err != nil {
+			return err
+		}
+
+		var missing []Tick
+		for _, tick := range resp.toTicks(last.Unix()) {
+			if !tick.Time.After(gap.To) {
+				missing = append(missing, tick)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+
+		newLast, err := f.store.StoreExchangeTicks(ctx, gap.Exchange, gap.Interval, gap.CurrencyPair, missing)
+		if err != nil {
+			return err
+		}
+		if !src.exchange.apiLimited || !newLast.After(last) {
+			return nil
+		}
+		last = newLast.Add(interval)
+	}
+	return nil
+}
+
+// Run fills every gap it can find and reports the gaps that remain after the
+// backfill. A gap may remain when the exchange no longer serves candles that
+// old or had no trade within the gap.
+func (f *GapFiller) Run(ctx context.Context) (*GapReport, error) {
+	seriesList, err := f.store.ExchangeTickSeries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &GapReport{Series: len(seriesList)}
+	for _, series := range seriesList {
+		gaps, err := f.scanSeries(ctx, series)
+		if err != nil {
+			return nil, err
+		}
+		if len(gaps) == 0 {
+			continue
+		}
+
+		var missingBefore int
+		for _, gap := range gaps {
+			missingBefore += gap.Missing
+			if err = f.Fill(ctx, gap); err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				log.Errorf("Unable to backfill %s from %s to %s, %s", series, gap.From.Format(time.RFC3339),
+					gap.To.Format(time.RFC3339), err.Error())
+			}
+		}
+		report.Gaps += len(gaps)
+		report.Missing += missingBefore
+
+		remaining, err := f.scanSeries(ctx, series)
+		if err != nil {
+			return nil, err
+		}
+		var missingAfter int
+		for _, gap := range remaining {
+			missingAfter += gap.Missing
+		}
+		report.Filled += missingBefore - missingAfter
+		report.Unfilled = append(report.Unfilled, remaining...)
+	}
+	return report, nil
+}
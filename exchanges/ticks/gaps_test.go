@@ -0,0 +1,62 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ticks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindGaps(t *testing.T) {
+	series := TickSeries{Exchange: Binance, CurrencyPair: btcdcrPair, Interval: 5}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(candles ...int) []time.Time {
+		times := make([]time.Time, len(candles))
+		for i, candle := range candles {
+			times[i] = start.Add(time.Duration(candle) * 5 * time.Minute)
+		}
+		return times
+	}
+	gap := func(from, to int) Gap {
+		return Gap{
+			TickSeries: series,
+			From:       start.Add(time.Duration(from) * 5 * time.Minute),
+			To:         start.Add(time.Duration(to) * 5 * time.Minute),
+			Missing:    to - from + 1,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		series   TickSeries
+		times    []time.Time
+		expected []Gap
+	}{
+		{"no candle", series, nil, nil},
+		{"one candle", series, at(3), nil},
+		{"no gap", series, at(0, 1, 2, 3), nil},
+		{"leading gap after the first candle", series, at(0, 3, 4, 5), []Gap{gap(1, 2)}},
+		{"interior gaps", series, at(0, 1, 3, 4, 8), []Gap{gap(2, 2), gap(5, 7)}},
+		{"trailing gap before the last candle", series, at(0, 1, 2, 6), []Gap{gap(3, 5)}},
+		{"unsorted", series, at(6, 0, 2, 1), []Gap{gap(3, 5)}},
+		{"duplicate candles", series, at(0, 0, 1, 3, 3), []Gap{gap(2, 2)}},
+		{"unaligned candle", series, []time.Time{start, start.Add(12 * time.Minute)}, []Gap{gap(1, 1)}},
+		{"no interval", TickSeries{Exchange: Binance}, at(0, 4), nil},
+	}
+	for _, test := range tests {
+		gaps := FindGaps(test.series, test.times)
+		if !reflect.DeepEqual(gaps, test.expected) {
+			t.Errorf("%s: expected gaps %+v, got %+v", test.name, test.expected, gaps)
+		}
+	}
+
+	// the times passed are left in their order
+	times := at(2, 0)
+	FindGaps(series, times)
+	if !times[0].Equal(at(2)[0]) {
+		t.Error("FindGaps reordered the times passed")
+	}
+}
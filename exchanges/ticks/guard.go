@@ -0,0 +1,245 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ticks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/app/helpers"
+)
+
+const (
+	defaultRequestRate  = 1.0
+	defaultRequestBurst = 1
+
+	// breakerThreshold is the number of consecutive failed requests that
+	// opens the circuit of an exchange.
+	breakerThreshold = 5
+	breakerCooldown  = 10 * time.Minute
+)
+
+// BreakerState is the state of the circuit breaker of an exchange.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// ErrCircuitOpen is returned for requests to an exchange whose circuit is open.
+type ErrCircuitOpen struct {
+	Exchange string
+	RetryAt  time.Time
+}
+
+func (e ErrCircuitOpen) Error() string {
+	return fmt.Sprintf("%s circuit is open after repeated failures, requests resume at %s",
+		e.Exchange, e.RetryAt.Format(time.RFC3339))
+}
+
+// GuardStatus is a snapshot of the state of an ExchangeGuard.
+type GuardStatus struct {
+	Exchange    string       `json:"exchange"`
+	State       BreakerState `json:"state"`
+	Failures    int          `json:"failures"`
+	LastError   string       `json:"last_error"`
+	LastFailure time.Time    `json:"last_failure"`
+	RetryAt     time.Time    `json:"retry_at"`
+}
+
+// ExchangeGuard spaces the requests made to an exchange with a token bucket
+// and stops requesting an exchange that keeps failing. Every collector of an
+// exchange shares the same guard.
+type ExchangeGuard struct {
+	name string
+	now  func() time.Time
+
+	mtx         sync.Mutex
+	rate        float64
+	burst       float64
+	tokens      float64
+	lastRefill  time.Time
+	state       BreakerState
+	failures    int
+	openedAt    time.Time
+	lastError   string
+	lastFailure time.Time
+}
+
+var (
+	guardsMtx sync.Mutex
+	guards    = make(map[string]*ExchangeGuard)
+)
+
+// guardFor returns the guard of the exchange, creating it from the rate limit
+// of exchange on first use.
+func guardFor(exchange *ExchangeData) *ExchangeGuard {
+	guardsMtx.Lock()
+	defer guardsMtx.Unlock()
+	if guard, found := guards[exchange.Name]; found {
+		return guard
+	}
+	guard := newExchangeGuard(exchange.Name, exchange.requestRate, exchange.requestBurst)
+	guards[exchange.Name] = guard
+	return guard
+}
+
+// GuardOf returns the guard of the named exchange, shared with its tick
+// collectors. The guard of an exchange without a built-in collector has the
+// default rate limit until a configured collector of the exchange is created.
+func GuardOf(name string) *ExchangeGuard {
+	if exchange, found := builtinExchanges[name]; found {
+		return guardFor(exchange)
+	}
+	return guardFor(&ExchangeData{Name: name})
+}
+
+// GetResponse requests url once through the guard of the named exchange.
+func GetResponse(ctx context.Context, client *http.Client, exchange, url string, destination interface{}) error {
+	return GuardOf(exchange).GetResponse(ctx, client, url, destination)
+}
+
+func newExchangeGuard(name string, rate float64, burst int) *ExchangeGuard {
+	if rate <= 0 {
+		rate = defaultRequestRate
+	}
+	if burst <= 0 {
+		burst = defaultRequestBurst
+	}
+	return &ExchangeGuard{
+		name:   name,
+		now:    time.Now,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		state:  BreakerClosed,
+	}
+}
+
+// ExchangeStatuses returns the state of the guard of every exchange that has
+// been requested, sorted by exchange name.
+func ExchangeStatuses() []GuardStatus {
+	guardsMtx.Lock()
+	statuses := make([]GuardStatus, 0, len(guards))
+	for _, guard := range guards {
+		statuses = append(statuses, guard.Status())
+	}
+	guardsMtx.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Exchange < statuses[j].Exchange })
+	return statuses
+}
+
+// Wait blocks until a request may be sent to the exchange. It returns
+// ErrCircuitOpen without waiting if the circuit is open.
+func (g *ExchangeGuard) Wait(ctx context.Context) error {
+	for {
+		g.mtx.Lock()
+		now := g.now()
+		retryAt := g.openedAt.Add(breakerCooldown)
+		if g.state == BreakerHalfOpen || (g.state == BreakerOpen && now.Before(retryAt)) {
+			// only the trial request is sent while the circuit is half-open
+			g.mtx.Unlock()
+			return ErrCircuitOpen{Exchange: g.name, RetryAt: retryAt}
+		}
+
+		if !g.lastRefill.IsZero() {
+			g.tokens += now.Sub(g.lastRefill).Seconds() * g.rate
+			if g.tokens > g.burst {
+				g.tokens = g.burst
+			}
+		}
+		g.lastRefill = now
+		if g.tokens >= 1 {
+			g.tokens--
+			if g.state == BreakerOpen {
+				g.state = BreakerHalfOpen
+				log.Infof("%s circuit is half-open, sending a trial request", g.name)
+			}
+			g.mtx.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - g.tokens) / g.rate * float64(time.Second))
+		g.mtx.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Done records the outcome of a request sent after Wait. Callers pass the
+// context error instead of the request error when the context is done.
+func (g *ExchangeGuard) Done(err error) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		// an abandoned request says nothing about the exchange
+		if g.state == BreakerHalfOpen {
+			g.state = BreakerOpen
+		}
+		return
+	}
+
+	if err == nil {
+		if g.state != BreakerClosed {
+			log.Infof("%s circuit is closed, requests succeed again", g.name)
+		}
+		g.state = BreakerClosed
+		g.failures = 0
+		return
+	}
+
+	g.failures++
+	g.lastError = err.Error()
+	g.lastFailure = g.now()
+	if g.state == BreakerHalfOpen || (g.state == BreakerClosed && g.failures >= breakerThreshold) {
+		g.state = BreakerOpen
+		g.openedAt = g.lastFailure
+		log.Warnf("%s circuit is open after %d failed requests, pausing requests for %s. Last error: %s",
+			g.name, g.failures, breakerCooldown, g.lastError)
+	}
+}
+
+// Status returns the current state of the guard.
+func (g *ExchangeGuard) Status() GuardStatus {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	status := GuardStatus{
+		Exchange:    g.name,
+		State:       g.state,
+		Failures:    g.failures,
+		LastError:   g.lastError,
+		LastFailure: g.lastFailure,
+	}
+	if g.state == BreakerOpen {
+		status.RetryAt = g.openedAt.Add(breakerCooldown)
+	}
+	return status
+}
+
+// GetResponse waits for the guard, requests url once and records the outcome.
+func (g *ExchangeGuard) GetResponse(ctx context.Context, client *http.Client, url string, destination interface{}) error {
+	if err := g.Wait(ctx); err != nil {
+		return err
+	}
+	err := helpers.GetResponseOnce(ctx, client, url, destination)
+	if ctx.Err() != nil {
+		g.Done(ctx.Err())
+		return ctx.Err()
+	}
+	g.Done(err)
+	return err
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ticks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testClock is the time of a guard under test, moved by the test only.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time                { return c.now }
+func (c *testClock) advance(elapsed time.Duration) { c.now = c.now.Add(elapsed) }
+
+func newTestGuard(rate float64, burst int) (*ExchangeGuard, *testClock) {
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	guard := newExchangeGuard("test", rate, burst)
+	guard.now = clock.Now
+	return guard, clock
+}
+
+// tryWait calls Wait with a context that is already done, so that it returns
+// the context error instead of sleeping when no token is available.
+func tryWait(guard *ExchangeGuard) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return guard.Wait(ctx)
+}
+
+func TestExchangeGuardTokenBucket(t *testing.T) {
+	guard, clock := newTestGuard(2, 3)
+
+	// the bucket starts full
+	for i := 0; i < 3; i++ {
+		if err := tryWait(guard); err != nil {
+			t.Fatalf("request %d: expected a token, got %v", i, err)
+		}
+	}
+	if err := tryWait(guard); err != context.Canceled {
+		t.Fatalf("expected to wait for a token, got %v", err)
+	}
+
+	// two tokens are added per second
+	clock.advance(500 * time.Millisecond)
+	if err := tryWait(guard); err != nil {
+		t.Fatalf("expected a token after half a second, got %v", err)
+	}
+	if err := tryWait(guard); err != context.Canceled {
+		t.Fatalf("expected the refilled token to be used, got %v", err)
+	}
+
+	// the bucket does not hold more than the burst
+	clock.advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		if err := tryWait(guard); err != nil {
+			t.Fatalf("request %d: expected a token, got %v", i, err)
+		}
+	}
+	if err := tryWait(guard); err != context.Canceled {
+		t.Fatalf("expected the burst to limit the tokens, got %v", err)
+	}
+}
+
+func TestExchangeGuardWaitCancelled(t *testing.T) {
+	guard, _ := newTestGuard(0.001, 1)
+	if err := guard.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the next token is 1000 seconds away
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := guard.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to end the wait, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected Wait to return on cancellation, waited %s", elapsed)
+	}
+}
+
+func TestExchangeGuardBreaker(t *testing.T) {
+	guard, clock := newTestGuard(1000, 1000)
+	failure := errors.New("bad gateway")
+
+	// closed: the failures below the threshold let the requests through
+	for i := 0; i < breakerThreshold-1; i++ {
+		if err := tryWait(guard); err != nil {
+			t.Fatal(err)
+		}
+		guard.Done(failure)
+	}
+	if status := guard.Status(); status.State != BreakerClosed || status.Failures != breakerThreshold-1 {
+		t.Fatalf("expected a closed circuit with %d failures, got %+v", breakerThreshold-1, status)
+	}
+	// a success resets the failure count
+	guard.Done(nil)
+	for i := 0; i < breakerThreshold-1; i++ {
+		guard.Done(failure)
+	}
+	if status := guard.Status(); status.State != BreakerClosed {
+		t.Fatalf("expected the circuit to stay closed after a success, got %+v", status)
+	}
+
+	// closed -> open
+	guard.Done(failure)
+	status := guard.Status()
+	openedAt := clock.now
+	if status.State != BreakerOpen || !status.RetryAt.Equal(openedAt.Add(breakerCooldown)) || status.LastError != failure.Error() {
+		t.Fatalf("expected an open circuit retried at %s, got %+v", openedAt.Add(breakerCooldown), status)
+	}
+	clock.advance(breakerCooldown - time.Second)
+	if err, open := tryWait(guard).(ErrCircuitOpen); !open || !err.RetryAt.Equal(openedAt.Add(breakerCooldown)) {
+		t.Fatalf("expected ErrCircuitOpen during the cooldown, got %v", err)
+	}
+
+	// open -> half-open: a single trial request is let through
+	clock.advance(time.Second)
+	if err := tryWait(guard); err != nil {
+		t.Fatalf("expected the trial request after the cooldown, got %v", err)
+	}
+	if status := guard.Status(); status.State != BreakerHalfOpen {
+		t.Fatalf("expected a half-open circuit, got %+v", status)
+	}
+	if _, open := tryWait(guard).(ErrCircuitOpen); !open {
+		t.Fatal("expected a single request while the circuit is half-open")
+	}
+
+	// half-open -> open when the trial fails
+	clock.advance(time.Minute)
+	guard.Done(failure)
+	if status := guard.Status(); status.State != BreakerOpen || !status.RetryAt.Equal(clock.now.Add(breakerCooldown)) {
+		t.Fatalf("expected the failed trial to open the circuit again, got %+v", status)
+	}
+
+	// half-open -> open when the trial is abandoned, without a new cooldown
+	clock.advance(breakerCooldown)
+	if err := tryWait(guard); err != nil {
+		t.Fatal(err)
+	}
+	guard.Done(context.Canceled)
+	if status := guard.Status(); status.State != BreakerOpen {
+		t.Fatalf("expected a cancelled trial to reopen the circuit, got %+v", status)
+	}
+	if err := tryWait(guard); err != nil {
+		t.Fatalf("expected a new trial to be allowed right away, got %v", err)
+	}
+
+	// half-open -> closed when the trial succeeds
+	guard.Done(nil)
+	if status := guard.Status(); status.State != BreakerClosed || status.Failures != 0 {
+		t.Fatalf("expected a closed circuit after a successful trial, got %+v", status)
+	}
+	if err := tryWait(guard); err != nil {
+		t.Fatalf("expected the requests to resume, got %v", err)
+	}
+}
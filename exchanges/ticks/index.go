@@ -0,0 +1,96 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ticks
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// IndexBTCPair and IndexUSDPair are the currency pairs of the cross
+	// exchange index, following the notation of the exchange ticks.
+	IndexBTCPair = btcdcrPair
+	IndexUSDPair = "USD/DCR"
+
+	// IndexMaxDeviation is the fraction by which an exchange price may differ
+	// from the median of all exchanges before it is left out of the index.
+	IndexMaxDeviation = 0.1
+)
+
+// IndexUSDSource returns the exchange and currency pair of the USD/BTC series
+// used to convert the BTC index to USD.
+func IndexUSDSource() (exchange, currencyPair string) {
+	return bittrexData.Name, usdbtcPair
+}
+
+// ExchangePrice is the volume weighted price of one exchange over an index
+// interval.
+type ExchangePrice struct {
+	Exchange string
+	Price    float64
+	Volume   float64
+}
+
+// IndexPrice is a single point of the cross exchange index
+type IndexPrice struct {
+	Time          time.Time `json:"time"`
+	CurrencyPair  string    `json:"currency_pair"`
+	Price         float64   `json:"price"`
+	Volume        float64   `json:"volume"`
+	ExchangeCount int       `json:"exchange_count"`
+}
+
+// VolumeWeightedIndex combines the prices of several exchanges into a single
+// volume weighted price. Prices that deviate from the median by more than
+// maxDeviation are excluded. The exchanges without volume are left out too,
+// unless none of the others reports any, in which case the prices get an equal
+// weight. count is the number of exchanges that weigh in the price. ok is false
+// if prices is empty.
+func VolumeWeightedIndex(prices []ExchangePrice, maxDeviation float64) (price, volume float64, count int, ok bool) {
+	var valid []ExchangePrice
+	for _, p := range prices {
+		if p.Price > 0 {
+			valid = append(valid, p)
+		}
+	}
+	if len(valid) == 0 {
+		return 0, 0, 0, false
+	}
+
+	sorted := make([]float64, len(valid))
+	for i, p := range valid {
+		sorted[i] = p.Price
+	}
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+
+	var weighted, sum float64
+	var inBand int
+	for _, p := range valid {
+		if p.Price < median*(1-maxDeviation) || p.Price > median*(1+maxDeviation) {
+			continue
+		}
+		sum += p.Price
+		inBand++
+		if p.Volume > 0 {
+			weighted += p.Price * p.Volume
+			volume += p.Volume
+			count++
+		}
+	}
+	if inBand == 0 {
+		return 0, 0, 0, false
+	}
+
+	// exchanges without reported volume get an equal weight
+	if volume == 0 {
+		return sum / float64(inBand), 0, inBand, true
+	}
+	return weighted / volume, volume, count, true
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ticks
+
+import (
+	"math"
+	"testing"
+)
+
+func TestVolumeWeightedIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		prices []ExchangePrice
+		price  float64
+		volume float64
+		count  int
+		ok     bool
+	}{
+		{name: "no price"},
+		{name: "no positive price", prices: []ExchangePrice{{"a", 0, 5}, {"b", -1, 2}}},
+		{
+			name:   "single exchange",
+			prices: []ExchangePrice{{"a", 0.002, 10}},
+			price:  0.002, volume: 10, count: 1, ok: true,
+		},
+		{
+			name:   "volume weighted",
+			prices: []ExchangePrice{{"a", 1.00, 30}, {"b", 1.04, 10}},
+			price:  1.01, volume: 40, count: 2, ok: true,
+		},
+		{
+			name:   "odd median cuts the outlier",
+			prices: []ExchangePrice{{"a", 1.00, 10}, {"b", 1.02, 10}, {"c", 1.50, 100}},
+			price:  1.01, volume: 20, count: 2, ok: true,
+		},
+		{
+			name:   "even median cuts the outliers",
+			prices: []ExchangePrice{{"a", 0.5, 100}, {"b", 1.00, 10}, {"c", 1.02, 10}, {"d", 2.00, 100}},
+			price:  1.01, volume: 20, count: 2, ok: true,
+		},
+		{
+			name:   "price at the deviation limit",
+			prices: []ExchangePrice{{"a", 0.9, 10}, {"b", 1.0, 10}, {"c", 1.1, 10}},
+			price:  1.0, volume: 30, count: 3, ok: true,
+		},
+		{
+			name:   "no volume gives an equal weight",
+			prices: []ExchangePrice{{"a", 1.00, 0}, {"b", 1.02, 0}, {"c", 1.50, 0}},
+			price:  1.01, volume: 0, count: 2, ok: true,
+		},
+		{
+			name:   "exchanges without volume are not counted",
+			prices: []ExchangePrice{{"a", 1.00, 10}, {"b", 1.02, 0}, {"c", 1.04, 30}},
+			price:  1.03, volume: 40, count: 2, ok: true,
+		},
+		{
+			name:   "both middle prices are outliers",
+			prices: []ExchangePrice{{"a", 1, 10}, {"b", 2, 10}},
+		},
+	}
+	for _, test := range tests {
+		price, volume, count, ok := VolumeWeightedIndex(test.prices, IndexMaxDeviation)
+		if ok != test.ok || count != test.count || math.Abs(price-test.price) > 1e-9 || math.Abs(volume-test.volume) > 1e-9 {
+			t.Errorf("%s: expected %v, %v, %d, %t, got %v, %v, %d, %t", test.name, test.price, test.volume, test.count,
+				test.ok, price, volume, count, ok)
+		}
+	}
+}
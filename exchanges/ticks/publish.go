@@ -0,0 +1,78 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ticks
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TickTopic is the topic of the exchange ticks published by PublishingStore.
+const TickTopic = "exchange-tick"
+
+// Publisher receives the exchange ticks as they are stored.
+type Publisher interface {
+	Publish(topic string, data interface{})
+}
+
+// publishingStore publishes the newest tick of each series stored through
+// it.
+type publishingStore struct {
+	Store
+	publisher Publisher
+
+	mtx  sync.Mutex
+	last map[TickSeries]time.Time
+}
+
+// PublishingStore returns a Store that stores the ticks in store and
+// publishes, as a TickSyncDto, the newest tick of every successful call
+// unless a later tick of the series was already published. The backfill of
+// old ticks is therefore not published.
+func PublishingStore(store Store, publisher Publisher) Store {
+	return &publishingStore{
+		Store:     store,
+		publisher: publisher,
+		last:      make(map[TickSeries]time.Time),
+	}
+}
+
+func (s *publishingStore) StoreExchangeTicks(ctx context.Context, exchange string, interval int, pair string,
+	data []Tick) (time.Time, error) {
+	lastTime, err := s.Store.StoreExchangeTicks(ctx, exchange, interval, pair, data)
+	if err != nil || len(data) == 0 {
+		return lastTime, err
+	}
+
+	newest := data[0]
+	for _, tick := range data[1:] {
+		if tick.Time.After(newest.Time) {
+			newest = tick
+		}
+	}
+	series := TickSeries{Exchange: exchange, CurrencyPair: pair, Interval: interval}
+	s.mtx.Lock()
+	published, found := s.last[series]
+	if found && !newest.Time.After(published) {
+		s.mtx.Unlock()
+		return lastTime, nil
+	}
+	s.last[series] = newest.Time
+	s.mtx.Unlock()
+
+	s.publisher.Publish(TickTopic, TickSyncDto{
+		ExchangeName: exchange,
+		High:         newest.High,
+		Low:          newest.Low,
+		Open:         newest.Open,
+		Close:        newest.Close,
+		Volume:       newest.Volume,
+		Time:         newest.Time,
+		Interval:     interval,
+		CurrencyPair: pair,
+	})
+	return lastTime, nil
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ticks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/planetdecred/dcrextdata/app/helpers"
+)
+
+type testPublisher struct {
+	published []TickSyncDto
+}
+
+func (p *testPublisher) Publish(topic string, data interface{}) {
+	if topic == TickTopic {
+		p.published = append(p.published, data.(TickSyncDto))
+	}
+}
+
+func TestPublishingStore(t *testing.T) {
+	publisher := new(testPublisher)
+	store := PublishingStore(new(tickTestStore), publisher)
+	ctx := context.Background()
+
+	store.StoreExchangeTicks(ctx, "binance", 5, "BTC/DCR", fixtureTicks)
+	// the backfill of older ticks and the ticks of other series
+	store.StoreExchangeTicks(ctx, "binance", 5, "BTC/DCR", fixtureTicks[:2])
+	store.StoreExchangeTicks(ctx, "binance", 60, "BTC/DCR", fixtureTicks[:1])
+	store.StoreExchangeTicks(ctx, "binance", 5, "BTC/DCR", nil)
+
+	if len(publisher.published) != 2 {
+		t.Fatalf("expected 2 published ticks, got %d", len(publisher.published))
+	}
+	first := publisher.published[0]
+	if !first.Time.Equal(fixtureTicks[2].Time) || first.Close != fixtureTicks[2].Close || first.Interval != 5 ||
+		first.ExchangeName != "binance" || first.CurrencyPair != "BTC/DCR" {
+		t.Errorf("unexpected published tick, %+v", first)
+	}
+	if second := publisher.published[1]; second.Interval != 60 || !second.Time.Equal(helpers.UnixTime(1577836800)) {
+		t.Errorf("unexpected published tick, %+v", second)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ticks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/planetdecred/dcrextdata/app/helpers"
+)
+
+const (
+	binanceStreamURL = "wss://stream.binance.com:9443/ws/%s@trade"
+
+	minStreamBackoff = time.Second
+	maxStreamBackoff = 2 * time.Minute
+	streamPingPeriod = 30 * time.Second
+	// streamReadTimeout is the time without a message or a pong after which
+	// the connection is taken as dead, in ping periods
+	streamReadTimeout = 2
+)
+
+var (
+	StreamConstructors = map[string]func(context.Context, Store) (Streamer, error){
+		Binance: NewBinanceStreamer,
+	}
+
+	binanceStream = StreamExchange{
+		ExchangeData:   binanceData,
+		URL:            binanceStreamURL,
+		CandleInterval: fiveMin,
+		ParseTrades:    parseBinanceTrades,
+	}
+)
+
+// Streamer is implemented by collectors that receive trades over a long lived
+// connection instead of polling candles.
+type Streamer interface {
+	Stream(ctx context.Context)
+}
+
+// Trade is a single trade received from an exchange stream
+type Trade struct {
+	Price    float64
+	Quantity float64
+	Time     time.Time
+}
+
+// StreamExchange describes a websocket trade feed of an exchange
+type StreamExchange struct {
+	ExchangeData
+	// URL is the websocket endpoint. A %s verb is replaced by the exchange
+	// symbol of the currency pair.
+	URL            string
+	CandleInterval time.Duration
+	// SubscribeMessage returns the message to send after connecting. It is
+	// nil when the URL itself selects the stream.
+	SubscribeMessage func(symbol string) interface{}
+	// ParseTrades extracts the trades from a stream message. Messages that
+	// carry no trade return an empty slice.
+	ParseTrades func(message []byte) ([]Trade, error)
+}
+
+type streamingCollector struct {
+	*StreamExchange
+	currencyPair string
+	store        Store
+	dialer       *websocket.Dialer
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	pingPeriod   time.Duration
+
+	candleLock sync.Mutex
+	candle     *Tick
+	// partial is set from a connection until its first candle closes. That
+	// candle lacks the trades of its interval made before the connection and
+	// is not stored, since it would take the place of the polled candle.
+	partial bool
+}
+
+// NewStreamingCollector returns a Streamer that builds candles of
+// exchange.CandleInterval from the trades of currencyPair.
+func NewStreamingCollector(ctx context.Context, store Store, exchange StreamExchange, currencyPair string) (Streamer, error) {
+	if _, ok := exchange.availableCPairs[currencyPair]; !ok {
+		return nil, fmt.Errorf("%s does not support %s", exchange.Name, currencyPair)
+	}
+	if _, _, _, err := store.RegisterExchange(ctx, exchange.ExchangeData); err != nil {
+		return nil, err
+	}
+	if exchange.CandleInterval == 0 {
+		exchange.CandleInterval = fiveMin
+	}
+	return &streamingCollector{
+		StreamExchange: &exchange,
+		currencyPair:   currencyPair,
+		store:          store,
+		dialer:         &websocket.Dialer{HandshakeTimeout: 10 * time.Second},
+		minBackoff:     minStreamBackoff,
+		maxBackoff:     maxStreamBackoff,
+		pingPeriod:     streamPingPeriod,
+	}, nil
+}
+
+func NewBinanceStreamer(ctx context.Context, store Store) (Streamer, error) {
+	return NewStreamingCollector(ctx, store, binanceStream, btcdcrPair)
+}
+
+// Stream keeps a connection to the exchange open until ctx is cancelled,
+// reconnecting with an exponential backoff whenever the connection drops.
+func (sc *streamingCollector) Stream(ctx context.Context) {
+	backoff := sc.minBackoff
+	for ctx.Err() == nil {
+		connected, err := sc.connectAndRead(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		if connected {
+			backoff = sc.minBackoff
+		}
+		log.Errorf("%s trade stream disconnected, reconnecting in %s: %v", sc.Name, backoff, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > sc.maxBackoff {
+			backoff = sc.maxBackoff
+		}
+	}
+}
+
+func (sc *streamingCollector) connectAndRead(ctx context.Context) (bool, error) {
+	symbol := sc.availableCPairs[sc.currencyPair]
+	url := sc.URL
+	if strings.Contains(url, "%s") {
+		url = fmt.Sprintf(url, strings.ToLower(symbol))
+	}
+
+	conn, _, err := sc.dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if sc.SubscribeMessage != nil {
+		if err = conn.WriteJSON(sc.SubscribeMessage(symbol)); err != nil {
+			return false, err
+		}
+	}
+	log.Infof("Streaming %s trades from %s", sc.currencyPair, sc.Name)
+
+	// a half-open connection never delivers an error, ReadMessage fails
+	// instead when neither a message nor a pong arrives in time
+	readTimeout := streamReadTimeout * sc.pingPeriod
+	if err = conn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
+		return true, err
+	}
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+
+	// the trades made while disconnected are missing from the open candle
+	sc.candleLock.Lock()
+	sc.candle = nil
+	sc.partial = true
+	sc.candleLock.Unlock()
+
+	// close the connection on shutdown to unblock ReadMessage and flush
+	// candles that ended without a subsequent trade
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(sc.pingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-ticker.C:
+				sc.flushClosed(ctx, helpers.NowUTC())
+				_ = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+			}
+		}
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		if err = conn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
+			return true, err
+		}
+		trades, err := sc.ParseTrades(message)
+		if err != nil {
+			log.Errorf("Unable to parse %s stream message: %v", sc.Name, err)
+			continue
+		}
+		for _, trade := range trades {
+			sc.addTrade(ctx, trade)
+		}
+	}
+}
+
+// addTrade updates the open candle with trade. The open candle is stored
+// and replaced when the trade falls in a later interval.
+func (sc *streamingCollector) addTrade(ctx context.Context, trade Trade) {
+	start := trade.Time.UTC().Truncate(sc.CandleInterval)
+
+	sc.candleLock.Lock()
+	var closed *Tick
+	var partial bool
+	if sc.candle != nil && start.After(sc.candle.Time) {
+		closed, partial = sc.candle, sc.partial
+		sc.candle, sc.partial = nil, false
+	}
+	if sc.candle == nil {
+		sc.candle = &Tick{
+			Open: trade.Price,
+			High: trade.Price,
+			Low:  trade.Price,
+			Time: start,
+		}
+	}
+	if !start.Before(sc.candle.Time) {
+		if trade.Price > sc.candle.High {
+			sc.candle.High = trade.Price
+		}
+		if trade.Price < sc.candle.Low {
+			sc.candle.Low = trade.Price
+		}
+		sc.candle.Close = trade.Price
+		sc.candle.Volume += trade.Quantity
+	}
+	sc.candleLock.Unlock()
+
+	if closed != nil && !partial {
+		sc.storeCandle(ctx, *closed)
+	}
+}
+
+// flushClosed stores the open candle if its interval ended before now.
+func (sc *streamingCollector) flushClosed(ctx context.Context, now time.Time) {
+	sc.candleLock.Lock()
+	candle := sc.candle
+	if candle == nil || now.Before(candle.Time.Add(sc.CandleInterval)) {
+		sc.candleLock.Unlock()
+		return
+	}
+	partial := sc.partial
+	sc.candle, sc.partial = nil, false
+	sc.candleLock.Unlock()
+
+	if !partial {
+		sc.storeCandle(ctx, *candle)
+	}
+}
+
+func (sc *streamingCollector) storeCandle(ctx context.Context, candle Tick) {
+	_, err := sc.store.StoreExchangeTicks(ctx, sc.Name, int(sc.CandleInterval.Minutes()), sc.currencyPair, []Tick{candle})
+	if err != nil {
+		log.Errorf("Unable to store %s streamed candle: %v", sc.Name, err)
+	}
+}
+
+type binanceTradeMessage struct {
+	Price    string `json:"p"`
+	Quantity string `json:"q"`
+	Time     int64  `json:"T"`
+}
+
+func parseBinanceTrades(message []byte) ([]Trade, error) {
+	var msg binanceTradeMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Time == 0 {
+		return []Trade{}, nil
+	}
+	price, err := strconv.ParseFloat(msg.Price, 64)
+	if err != nil {
+		return nil, err
+	}
+	quantity, err := strconv.ParseFloat(msg.Quantity, 64)
+	if err != nil {
+		return nil, err
+	}
+	return []Trade{{
+		Price:    price,
+		Quantity: quantity,
+		Time:     time.Unix(0, msg.Time*int64(time.Millisecond)).UTC(),
+	}}, nil
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ticks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type storedTicks struct {
+	exchange string
+	interval int
+	pair     string
+	ticks    []Tick
+}
+
+type streamTestStore struct {
+	mtx    sync.Mutex
+	stored []storedTicks
+	added  chan struct{}
+}
+
+func (s *streamTestStore) ExchangeTickTableName() string { return "exchange_tick" }
+func (s *streamTestStore) ExchangeTableName() string     { return "exchange" }
+func (s *streamTestStore) RegisterExchange(context.Context, ExchangeData) (time.Time, time.Time, time.Time, error) {
+	return zeroTime, zeroTime, zeroTime, nil
+}
+func (s *streamTestStore) FetchExchangeForSync(context.Context, int, int, int) ([]ExchangeData, int64, error) {
+	return nil, 0, nil
+}
+func (s *streamTestStore) LastExchangeTickEntryTime() time.Time      { return zeroTime }
+func (s *streamTestStore) UpdateExchangeIndex(context.Context) error { return nil }
+
+func (s *streamTestStore) StoreExchangeTicks(_ context.Context, exchange string, interval int, pair string, data []Tick) (time.Time, error) {
+	s.mtx.Lock()
+	s.stored = append(s.stored, storedTicks{exchange, interval, pair, data})
+	s.mtx.Unlock()
+	s.added <- struct{}{}
+	return data[len(data)-1].Time, nil
+}
+
+func binanceTradeJSON(price, quantity string, t time.Time) string {
+	return fmt.Sprintf(`{"e":"trade","s":"DCRBTC","p":"%s","q":"%s","T":%d}`, price, quantity, t.UnixNano()/int64(time.Millisecond))
+}
+
+// newTradeServer starts a websocket server that sends each batch of messages
+// on a separate connection and then drops the connection.
+func newTradeServer(t *testing.T, batches [][]string) (*httptest.Server, func() int) {
+	var connections int
+	var mtx sync.Mutex
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		mtx.Lock()
+		batch := connections
+		connections++
+		mtx.Unlock()
+
+		if batch >= len(batches) {
+			// keep the last connection open until the client leaves
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}
+		for _, msg := range batches[batch] {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+				return
+			}
+		}
+	}))
+	connectionCount := func() int {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return connections
+	}
+	return server, connectionCount
+}
+
+func TestStreamingCollectorBuildsCandles(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	batches := [][]string{
+		{
+			`{"result":null,"id":1}`,
+			// the first candle of a connection is partial and dropped
+			binanceTradeJSON("0.0019", "3", start.Add(4*time.Minute)),
+			binanceTradeJSON("0.0020", "10", start.Add(5*time.Minute+30*time.Second)),
+			binanceTradeJSON("0.0025", "5", start.Add(6*time.Minute)),
+			binanceTradeJSON("0.0018", "1", start.Add(7*time.Minute)),
+			binanceTradeJSON("0.0021", "4", start.Add(9*time.Minute)),
+			binanceTradeJSON("0.0022", "1", start.Add(10*time.Minute+30*time.Second)),
+		},
+		{
+			// the connection is dropped and the stream resumes, the open
+			// candle missed trades and so does the first one after it
+			binanceTradeJSON("0.0023", "2", start.Add(12*time.Minute)),
+			binanceTradeJSON("0.0030", "2", start.Add(16*time.Minute)),
+			binanceTradeJSON("0.0031", "6", start.Add(19*time.Minute)),
+			binanceTradeJSON("0.0032", "1", start.Add(21*time.Minute)),
+		},
+	}
+	server, connectionCount := newTradeServer(t, batches)
+	defer server.Close()
+
+	store := &streamTestStore{added: make(chan struct{}, 2)}
+	exchange := binanceStream
+	exchange.URL = "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/%s@trade"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	streamer, err := NewStreamingCollector(ctx, store, exchange, btcdcrPair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := streamer.(*streamingCollector)
+	sc.minBackoff = 10 * time.Millisecond
+	sc.maxBackoff = 20 * time.Millisecond
+	go sc.Stream(ctx)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-store.added:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a closed candle")
+		}
+	}
+	cancel()
+
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	if len(store.stored) != 2 {
+		t.Fatalf("expected 2 stored candles, got %d", len(store.stored))
+	}
+	got := store.stored[0]
+	if got.exchange != Binance || got.pair != btcdcrPair || got.interval != 5 {
+		t.Fatalf("candle stored as %s %s %dm", got.exchange, got.pair, got.interval)
+	}
+	expected := []Tick{
+		{Open: 0.0020, High: 0.0025, Low: 0.0018, Close: 0.0021, Volume: 20, Time: start.Add(5 * time.Minute)},
+		{Open: 0.0030, High: 0.0031, Low: 0.0030, Close: 0.0031, Volume: 8, Time: start.Add(15 * time.Minute)},
+	}
+	for i, stored := range store.stored {
+		if len(stored.ticks) != 1 || stored.ticks[0] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], stored.ticks)
+		}
+	}
+	if count := connectionCount(); count < 2 {
+		t.Fatalf("expected the collector to reconnect, got %d connection(s)", count)
+	}
+}
+
+func TestStreamingCollectorReadTimeout(t *testing.T) {
+	// a peer that answers the pings keeps the connection open
+	server, connectionCount := newTradeServer(t, nil)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sc := newTestStreamer(t, ctx, server)
+	go sc.Stream(ctx)
+	time.Sleep(20 * sc.pingPeriod)
+	if count := connectionCount(); count != 1 {
+		t.Fatalf("expected the answered pings to keep the connection, got %d connection(s)", count)
+	}
+	cancel()
+
+	// a half-open connection, that does not answer the pings, is dropped
+	var mtx sync.Mutex
+	var connections int
+	release := make(chan struct{})
+	defer close(release)
+	upgrader := websocket.Upgrader{}
+	silent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		mtx.Lock()
+		connections++
+		mtx.Unlock()
+		<-release
+	}))
+	defer silent.Close()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	sc = newTestStreamer(t, ctx, silent)
+	go sc.Stream(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mtx.Lock()
+		count := connections
+		mtx.Unlock()
+		if count >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the collector to reconnect after the read timeout, got %d connection(s)", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newTestStreamer returns a collector of the trades streamed by server, with
+// short backoffs and ping period.
+func newTestStreamer(t *testing.T, ctx context.Context, server *httptest.Server) *streamingCollector {
+	exchange := binanceStream
+	exchange.URL = "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/%s@trade"
+	streamer, err := NewStreamingCollector(ctx, &streamTestStore{added: make(chan struct{}, 1)}, exchange, btcdcrPair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := streamer.(*streamingCollector)
+	sc.minBackoff = 10 * time.Millisecond
+	sc.maxBackoff = 20 * time.Millisecond
+	sc.pingPeriod = 20 * time.Millisecond
+	return sc
+}
+
+func TestStreamingCollectorDropsPartialCandle(t *testing.T) {
+	store := &streamTestStore{added: make(chan struct{}, 1)}
+	streamer, err := NewStreamingCollector(context.Background(), store, binanceStream, btcdcrPair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := streamer.(*streamingCollector)
+	sc.partial = true
+
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	sc.addTrade(context.Background(), Trade{Price: 1, Quantity: 2, Time: start.Add(3 * time.Minute)})
+	sc.flushClosed(context.Background(), start.Add(5*time.Minute))
+	if len(store.stored) != 0 {
+		t.Fatalf("expected the partial candle to be dropped, got %+v", store.stored)
+	}
+
+	sc.addTrade(context.Background(), Trade{Price: 2, Quantity: 1, Time: start.Add(6 * time.Minute)})
+	sc.flushClosed(context.Background(), start.Add(10*time.Minute))
+	if len(store.stored) != 1 || store.stored[0].ticks[0].Time != start.Add(5*time.Minute) {
+		t.Fatalf("expected the next candle to be stored, got %+v", store.stored)
+	}
+}
+
+func TestStreamingCollectorFlushesIdleCandle(t *testing.T) {
+	store := &streamTestStore{added: make(chan struct{}, 1)}
+	streamer, err := NewStreamingCollector(context.Background(), store, binanceStream, btcdcrPair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := streamer.(*streamingCollector)
+
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	sc.addTrade(context.Background(), Trade{Price: 1, Quantity: 2, Time: start.Add(time.Minute)})
+
+	sc.flushClosed(context.Background(), start.Add(4*time.Minute))
+	if len(store.stored) != 0 {
+		t.Fatal("open candle was flushed before its interval ended")
+	}
+
+	sc.flushClosed(context.Background(), start.Add(5*time.Minute))
+	if len(store.stored) != 1 || store.stored[0].ticks[0].Time != start {
+		t.Fatalf("expected the candle at %s to be flushed, got %+v", start, store.stored)
+	}
+}
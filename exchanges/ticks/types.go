@@ -6,6 +6,8 @@ package ticks
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"strconv"
 	"time"
 
@@ -16,6 +18,7 @@ type Collector interface {
 	GetShort(context.Context) error
 	GetLong(context.Context) error
 	GetHistoric(context.Context) error
+	SetHTTPClient(*http.Client)
 }
 
 type Store interface {
@@ -25,6 +28,7 @@ type Store interface {
 	FetchExchangeForSync(ctx context.Context, lastID int, skip, take int) ([]ExchangeData, int64, error)
 	StoreExchangeTicks(ctx context.Context, exchange string, interval int, pair string, data []Tick) (time.Time, error)
 	LastExchangeTickEntryTime() (time time.Time)
+	UpdateExchangeIndex(ctx context.Context) error
 }
 
 type urlRequester func(time.Time, time.Duration, string) (string, error)
@@ -39,6 +43,10 @@ type ExchangeData struct {
 	LongInterval     time.Duration
 	HistoricInterval time.Duration
 	requester        urlRequester
+	// requestRate is the number of requests per second that may be sent to
+	// the exchange, with bursts of up to requestBurst requests.
+	requestRate  float64
+	requestBurst int
 }
 
 type tickable interface {
@@ -242,3 +250,182 @@ func (resp binanceAPIResponse) toTicks(start int64) []Tick {
 	}
 	return dataTicks
 }
+
+type huobiAPIResponse struct {
+	Status string          `json:"status"`
+	Data   []huobiDataTick `json:"data"`
+}
+
+type huobiDataTick struct {
+	ID     int64   `json:"id"`
+	Open   float64 `json:"open"`
+	Close  float64 `json:"close"`
+	Low    float64 `json:"low"`
+	High   float64 `json:"high"`
+	Amount float64 `json:"amount"`
+}
+
+func (resp huobiAPIResponse) toTicks(start int64) []Tick {
+	res := resp.Data
+	dataTicks := make([]Tick, 0, len(res))
+	// the most recent candle comes first
+	for i := len(res) - 1; i >= 0; i-- {
+		v := res[i]
+		if v.ID < start {
+			continue
+		}
+		dataTicks = append(dataTicks, Tick{
+			High:   v.High,
+			Low:    v.Low,
+			Open:   v.Open,
+			Close:  v.Close,
+			Volume: v.Amount,
+			Time:   helpers.UnixTime(v.ID),
+		})
+	}
+	return dataTicks
+}
+
+// kucoinAPIResponse holds the candles as [time, open, close, high, low,
+// volume, turnover] strings, the most recent candle first.
+type kucoinAPIResponse struct {
+	Code string     `json:"code"`
+	Data [][]string `json:"data"`
+}
+
+func (resp kucoinAPIResponse) toTicks(start int64) []Tick {
+	res := resp.Data
+	dataTicks := make([]Tick, 0, len(res))
+	for i := len(res) - 1; i >= 0; i-- {
+		v := res[i]
+		if len(v) < 6 {
+			continue
+		}
+		var values [6]float64
+		var err error
+		for j := range values {
+			if values[j], err = strconv.ParseFloat(v[j], 64); err != nil {
+				break
+			}
+		}
+		if err != nil || int64(values[0]) < start {
+			continue
+		}
+		dataTicks = append(dataTicks, Tick{
+			High:   values[3],
+			Low:    values[4],
+			Open:   values[1],
+			Close:  values[2],
+			Volume: values[5],
+			Time:   helpers.UnixTime(int64(values[0])),
+		})
+	}
+	return dataTicks
+}
+
+// bitfinexAPIResponse holds the candles as [time (ms), open, close, high,
+// low, volume], the oldest candle first.
+type bitfinexAPIResponse [][]float64
+
+func (resp bitfinexAPIResponse) toTicks(start int64) []Tick {
+	res := [][]float64(resp)
+	dataTicks := make([]Tick, 0, len(res))
+	for _, v := range res {
+		if len(v) < 6 {
+			continue
+		}
+		secs := int64(v[0] / 1000)
+		if secs < start {
+			continue
+		}
+		dataTicks = append(dataTicks, Tick{
+			High:   v[3],
+			Low:    v[4],
+			Open:   v[1],
+			Close:  v[2],
+			Volume: v[5],
+			Time:   helpers.UnixTime(secs),
+		})
+	}
+	return dataTicks
+}
+
+type upbitAPIResponse []upbitDataTick
+
+type upbitDataTick struct {
+	Time   string  `json:"candle_date_time_utc"`
+	Open   float64 `json:"opening_price"`
+	High   float64 `json:"high_price"`
+	Low    float64 `json:"low_price"`
+	Close  float64 `json:"trade_price"`
+	Volume float64 `json:"candle_acc_trade_volume"`
+}
+
+func (resp upbitAPIResponse) toTicks(start int64) []Tick {
+	res := []upbitDataTick(resp)
+	dataTicks := make([]Tick, 0, len(res))
+	// the most recent candle comes first
+	for i := len(res) - 1; i >= 0; i-- {
+		v := res[i]
+		t, err := time.Parse("2006-01-02T15:04:05", v.Time)
+		if err != nil || t.Unix() < start {
+			continue
+		}
+		dataTicks = append(dataTicks, Tick{
+			High:   v.High,
+			Low:    v.Low,
+			Open:   v.Open,
+			Close:  v.Close,
+			Volume: v.Volume,
+			Time:   t.UTC(),
+		})
+	}
+	return dataTicks
+}
+
+// dragonexAPIResponse holds the candles as rows whose values are ordered by
+// the names in Columns.
+type dragonexAPIResponse struct {
+	Ok   bool `json:"ok"`
+	Data struct {
+		Columns []string        `json:"columns"`
+		Lists   [][]interface{} `json:"lists"`
+	} `json:"data"`
+}
+
+func (resp dragonexAPIResponse) toTicks(start int64) []Tick {
+	columns := make(map[string]int, len(resp.Data.Columns))
+	for i, column := range resp.Data.Columns {
+		columns[column] = i
+	}
+	value := func(row []interface{}, column string) (float64, error) {
+		i, found := columns[column]
+		if !found || i >= len(row) {
+			return 0, fmt.Errorf("missing %s column", column)
+		}
+		return toFloat(row[i])
+	}
+
+	dataTicks := make([]Tick, 0, len(resp.Data.Lists))
+	for _, row := range resp.Data.Lists {
+		var values [6]float64
+		var err error
+		for i, column := range []string{"timestamp", "open_price", "max_price", "min_price", "close_price", "volume"} {
+			if values[i], err = value(row, column); err != nil {
+				break
+			}
+		}
+		if err != nil || int64(values[0]) < start {
+			continue
+		}
+		dataTicks = append(dataTicks, Tick{
+			High:   values[2],
+			Low:    values[3],
+			Open:   values[1],
+			Close:  values[4],
+			Volume: values[5],
+			Time:   helpers.UnixTime(int64(values[0])),
+		})
+	}
+	return dataTicks
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// chartAxisKey is the key of the x axis in the encoded charts.
+const chartAxisKey = "x"
+
+// seriesRank orders the keys of the encoded charts, x, y, z, x1, y1, z1, ...
+// as they were passed to cache.Manager.Encode. ok is false for the other keys.
+func seriesRank(key string) (rank int, ok bool) {
+	if key == "" {
+		return 0, false
+	}
+	letter := map[byte]int{'x': 0, 'y': 1, 'z': 2}
+	position, ok := letter[key[0]]
+	if !ok {
+		return 0, false
+	}
+	n := 0
+	if len(key) > 1 {
+		var err error
+		if n, err = strconv.Atoi(key[1:]); err != nil {
+			return 0, false
+		}
+	}
+	return n*len(letter) + position, true
+}
+
+// seriesLess orders the keys of an encoded chart. The x axis comes first, the
+// other keys follow the encoding order, or their numeric or lexical order if
+// they are named after the series, such as the bands of the order book chart.
+func seriesLess(a, b string) bool {
+	rankA, okA := seriesRank(a)
+	rankB, okB := seriesRank(b)
+	if okA && okB {
+		return rankA < rankB
+	}
+	if a == chartAxisKey || b == chartAxisKey {
+		return a == chartAxisKey
+	}
+	if okA != okB {
+		return okA
+	}
+	x, errX := strconv.ParseFloat(a, 64)
+	y, errY := strconv.ParseFloat(b, 64)
+	if errX == nil && errY == nil {
+		return x < y
+	}
+	return a < b
+}
+
+// WriteChart writes a chart encoded by cache.Manager as rows, a row per point
+// of the x axis. If timeAxis is set the x axis holds UNIX timestamps, it is
+// written as a time column and only the points in timeRange are kept.
+func WriteChart(data []byte, timeAxis bool, timeRange Range, rows RowWriter) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var chart map[string][]interface{}
+	if err := decoder.Decode(&chart); err != nil {
+		return fmt.Errorf("cannot decode the chart, %s", err.Error())
+	}
+
+	keys := make([]string, 0, len(chart))
+	length := 0
+	for key, series := range chart {
+		keys = append(keys, key)
+		if len(series) > length {
+			length = len(series)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return seriesLess(keys[i], keys[j]) })
+
+	timeColumn := -1
+	names := make([]string, len(keys))
+	copy(names, keys)
+	if timeAxis {
+		for i, key := range keys {
+			if key == chartAxisKey {
+				timeColumn = i
+				names[i] = "time"
+			}
+		}
+	}
+	if err := rows.Columns(names); err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(keys))
+	for row := 0; row < length; row++ {
+		for i, key := range keys {
+			values[i] = nil
+			if row < len(chart[key]) {
+				values[i] = chart[key][row]
+			}
+		}
+		if timeColumn >= 0 {
+			number, ok := values[timeColumn].(json.Number)
+			if !ok {
+				return fmt.Errorf("unexpected value on the time axis, %v", values[timeColumn])
+			}
+			timestamp, err := number.Int64()
+			if err != nil {
+				return fmt.Errorf("unexpected value on the time axis, %s", err.Error())
+			}
+			t := time.Unix(timestamp, 0).UTC()
+			if !timeRange.Contains(t) {
+				continue
+			}
+			values[timeColumn] = t
+		}
+		if err := rows.Row(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package export writes the collected data and the charts as CSV or NDJSON.
+// The rows of a table are streamed from the store to the output, so that an
+// export does not need to hold the table in memory.
+package export
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/postgres/models"
+)
+
+// Table is a table that can be exported. Its rows are filtered and ordered by
+// TimeColumn, which holds UNIX timestamps if UnixTime is set.
+type Table struct {
+	Name       string
+	TimeColumn string
+	UnixTime   bool
+}
+
+// Tables are the exportable tables.
+var Tables = []Table{
+	{Name: models.TableNames.ExchangeTick, TimeColumn: models.ExchangeTickColumns.Time},
+	{Name: models.TableNames.VSPTick, TimeColumn: models.VSPTickColumns.Time},
+	{Name: models.TableNames.PowData, TimeColumn: models.PowDatumColumns.Time, UnixTime: true},
+	{Name: models.TableNames.Mempool, TimeColumn: models.MempoolColumns.Time},
+	{Name: models.TableNames.Block, TimeColumn: models.BlockColumns.ReceiveTime},
+	{Name: models.TableNames.Vote, TimeColumn: models.VoteColumns.ReceiveTime},
+	{Name: models.TableNames.Reddit, TimeColumn: models.RedditColumns.Date},
+	{Name: models.TableNames.Twitter, TimeColumn: models.TwitterColumns.Date},
+	{Name: models.TableNames.Github, TimeColumn: models.GithubColumns.Date},
+	{Name: models.TableNames.Youtube, TimeColumn: models.YoutubeColumns.Date},
+	{Name: models.TableNames.NetworkSnapshot, TimeColumn: models.NetworkSnapshotColumns.Timestamp, UnixTime: true},
+	{Name: models.TableNames.Node, TimeColumn: models.NodeColumns.LastSeen, UnixTime: true},
+	{Name: models.TableNames.Heartbeat, TimeColumn: models.HeartbeatColumns.Timestamp, UnixTime: true},
+}
+
+// TableByName returns the exportable table called name.
+func TableByName(name string) (Table, error) {
+	for _, table := range Tables {
+		if table.Name == name {
+			return table, nil
+		}
+	}
+	return Table{}, fmt.Errorf("unknown table, %s", name)
+}
+
+// Range selects the rows whose time is at or after Start and before End. A
+// zero bound is not checked.
+type Range struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Contains reports whether t is in the range.
+func (r Range) Contains(t time.Time) bool {
+	return (r.Start.IsZero() || !t.Before(r.Start)) && (r.End.IsZero() || t.Before(r.End))
+}
+
+// ParseTime parses the bound of a time range, an RFC 3339 time or a UNIX
+// timestamp. An empty value is the zero time.
+func ParseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if timestamp, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(timestamp, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// Source is implemented by the stores whose tables can be exported.
+type Source interface {
+	// ExportTable writes the columns then the rows of table that are in
+	// timeRange to rows, in time order.
+	ExportTable(ctx context.Context, table Table, timeRange Range, rows RowWriter) error
+}
+
+// RowWriter receives the rows of an export.
+type RowWriter interface {
+	// Columns is called with the column names before any row.
+	Columns(names []string) error
+	// Row is called with the values of each row, in column order.
+	Row(values []interface{}) error
+}
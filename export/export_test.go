@@ -0,0 +1,82 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriters(t *testing.T) {
+	date := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := [][]interface{}{
+		{date, 1.5, int64(7), "a, \"b\""},
+		{date.Add(time.Hour), nil, []byte("12.30"), ""},
+	}
+	expected := map[string]string{
+		CSV: "time,rate,count,name\n" +
+			"2020-01-01T00:00:00Z,1.5,7,\"a, \"\"b\"\"\"\n" +
+			"2020-01-01T01:00:00Z,,12.30,\n",
+		NDJSON: `{"time":"2020-01-01T00:00:00Z","rate":1.5,"count":7,"name":"a, \"b\""}` + "\n" +
+			`{"time":"2020-01-01T01:00:00Z","rate":null,"count":"12.30","name":""}` + "\n",
+	}
+
+	for format, output := range expected {
+		var buf bytes.Buffer
+		writer, err := NewWriter(format, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = writer.Columns([]string{"time", "rate", "count", "name"}); err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range rows {
+			if err = writer.Row(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != output {
+			t.Errorf("unexpected %s output, got\n%s\nexpected\n%s", format, buf.String(), output)
+		}
+	}
+
+	if _, err := NewWriter("xml", &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestWriteChart(t *testing.T) {
+	chart := []byte(`{"y1":[4,5,6],"x":[1577836800,1577840400,1577844000],"z":[7,null,9],"y":[1,2,3]}`)
+	timeRange := Range{Start: time.Unix(1577840400, 0)}
+
+	var buf bytes.Buffer
+	writer, _ := NewWriter(CSV, &buf)
+	if err := WriteChart(chart, true, timeRange, writer); err != nil {
+		t.Fatal(err)
+	}
+	writer.Flush()
+	expected := "time,y,z,y1\n" +
+		"2020-01-01T01:00:00Z,2,,5\n" +
+		"2020-01-01T02:00:00Z,3,9,6\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected chart export, got\n%s\nexpected\n%s", buf.String(), expected)
+	}
+
+	// the bands of the order book chart follow the x axis in numeric order
+	buf.Reset()
+	writer, _ = NewWriter(CSV, &buf)
+	chart = []byte(`{"10":[3],"2.5":[2],"x":[100]}`)
+	if err := WriteChart(chart, false, Range{}, writer); err != nil {
+		t.Fatal(err)
+	}
+	writer.Flush()
+	if expected = "x,2.5,10\n100,2,3\n"; buf.String() != expected {
+		t.Errorf("unexpected chart export, got\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
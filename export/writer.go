@@ -0,0 +1,137 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// The export formats.
+const (
+	CSV    = "csv"
+	NDJSON = "ndjson"
+)
+
+// Writer is a RowWriter that encodes the rows in a format. Flush must be
+// called once the last row is written.
+type Writer interface {
+	RowWriter
+	Flush() error
+	// ContentType is the media type of the format.
+	ContentType() string
+}
+
+// NewWriter returns a Writer that encodes the rows to w in format.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case NDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown format, %s, expected %s or %s", format, CSV, NDJSON)
+}
+
+// normalize converts the values that have no natural encoding, times are
+// written in RFC 3339 and byte slices as text.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case []byte:
+		return string(v)
+	}
+	return value
+}
+
+// csvWriter writes a header line then a line per row. NULL values are written
+// as empty fields.
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (c *csvWriter) Columns(names []string) error {
+	c.record = make([]string, len(names))
+	return c.w.Write(names)
+}
+
+func (c *csvWriter) Row(values []interface{}) error {
+	for i, value := range values {
+		switch v := normalize(value).(type) {
+		case nil:
+			c.record[i] = ""
+		case string:
+			c.record[i] = v
+		case float64:
+			c.record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case float32:
+			c.record[i] = strconv.FormatFloat(float64(v), 'f', -1, 32)
+		default:
+			c.record[i] = fmt.Sprint(v)
+		}
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) ContentType() string {
+	return "text/csv"
+}
+
+// ndjsonWriter writes a JSON object per row, keyed by column name in column
+// order.
+type ndjsonWriter struct {
+	w     *bufio.Writer
+	names [][]byte
+}
+
+func (n *ndjsonWriter) Columns(names []string) error {
+	n.names = make([][]byte, len(names))
+	for i, name := range names {
+		encoded, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		n.names[i] = encoded
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) Row(values []interface{}) error {
+	n.w.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			n.w.WriteByte(',')
+		}
+		encoded, err := json.Marshal(normalize(value))
+		if err != nil {
+			return fmt.Errorf("cannot encode the %s column, %s", n.names[i], err.Error())
+		}
+		n.w.Write(n.names[i])
+		n.w.WriteByte(':')
+		n.w.Write(encoded)
+	}
+	n.w.WriteByte('}')
+	return n.w.WriteByte('\n')
+}
+
+func (n *ndjsonWriter) Flush() error {
+	return n.w.Flush()
+}
+
+func (n *ndjsonWriter) ContentType() string {
+	return "application/x-ndjson"
+}
@@ -18,6 +18,7 @@ require (
 	github.com/dgraph-io/badger v1.6.1
 	github.com/friendsofgo/errors v0.9.2
 	github.com/go-chi/chi v4.0.2+incompatible
+	github.com/gorilla/websocket v1.4.0
 	github.com/jessevdk/go-flags v1.4.0
 	github.com/jrick/logrotate v1.0.0
 	github.com/kat-co/vala v0.0.0-20170210184112-42e1d8b61f12
@@ -0,0 +1,92 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package kvstore persists a memstore.Store in an embedded LevelDB database so
+// that dcrextdata can keep its data between runs without PostgreSQL. Every
+// record is written under a "table/key" key as it is stored and the whole
+// database is read back into memory when it is opened. The memory used
+// therefore grows with the stored history, the reads are never served from
+// LevelDB.
+package kvstore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/goleveldb/leveldb"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+	"github.com/planetdecred/dcrextdata/memstore"
+)
+
+var _ memstore.Journal = (*Store)(nil)
+
+// Store is a memstore.Store backed by a LevelDB database.
+type Store struct {
+	*memstore.Store
+	db *leveldb.DB
+}
+
+// Open opens or creates the database in dir and loads its records.
+func Open(dir string) (*Store, error) {
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open the database in %s, %s", dir, err.Error())
+	}
+
+	s := &Store{
+		Store: memstore.NewStore(),
+		db:    db,
+	}
+	if err = s.load(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	s.SetJournal(s)
+	return s, nil
+}
+
+// load restores the records of every table, in key order.
+func (s *Store) load() error {
+	start := time.Now()
+	var count int
+	for _, table := range memstore.JournalTables() {
+		iter := s.db.NewIterator(util.BytesPrefix(tablePrefix(table)), nil)
+		for iter.Next() {
+			if err := s.Restore(table, iter.Value()); err != nil {
+				iter.Release()
+				return err
+			}
+			count++
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return fmt.Errorf("cannot read the %s records, %s", table, err.Error())
+		}
+	}
+	log.Infof("Loaded %d records in %v", count, time.Since(start))
+	return nil
+}
+
+func tablePrefix(table string) []byte {
+	return []byte(table + "/")
+}
+
+func recordKey(table, key string) []byte {
+	return append(tablePrefix(table), key...)
+}
+
+// Put writes a record to the database.
+func (s *Store) Put(table, key string, record []byte) error {
+	return s.db.Put(recordKey(table, key), record, nil)
+}
+
+// Delete removes a record from the database.
+func (s *Store) Delete(table, key string) error {
+	return s.db.Delete(recordKey(table, key), nil)
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package kvstore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/netsnapshot"
+	"github.com/planetdecred/dcrextdata/postgres/models"
+	"github.com/planetdecred/dcrextdata/pow"
+)
+
+func TestReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err = store.RegisterExchange(ctx, ticks.ExchangeData{Name: "bittrex", WebsiteURL: "https://bittrex.com"}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := []ticks.Tick{
+		{Close: 1, Time: start},
+		{Close: 2, Time: start.Add(5 * time.Minute)},
+	}
+	if _, err = store.StoreExchangeTicks(ctx, "bittrex", 5, "BTC/DCR", data); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.AddPowData(ctx, []pow.PowData{{Time: start.Unix(), Source: "f2pool", Workers: 10}}); err != nil {
+		t.Fatal(err)
+	}
+	peer := netsnapshot.NetworkPeer{Address: "10.0.0.1", UserAgent: "/dcrd:1.5.1/"}
+	if err = store.SaveNode(ctx, peer); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := netsnapshot.SnapShot{Timestamp: start.Unix(), Height: 100}
+	if err = store.SaveSnapshot(ctx, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.SaveHeartbeat(ctx, netsnapshot.Heartbeat{Timestamp: start.Unix(), Address: peer.Address}); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	tickDtos, total, err := store.FetchExchangeTicks(ctx, "BTC/DCR", "bittrex", 5, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || tickDtos[0].Close != 2 {
+		t.Errorf("expected the 2 stored ticks, most recent first, got %+v", tickDtos)
+	}
+	if count, _ := store.PowCount(ctx); count != 1 {
+		t.Errorf("expected 1 PoW entry, got %d", count)
+	}
+	if exists, _ := store.NodeExists(ctx, peer.Address); !exists {
+		t.Errorf("expected node %s to be restored", peer.Address)
+	}
+	if count, _ := store.TotalPeerCount(ctx, snapshot.Timestamp); count != 1 {
+		t.Errorf("expected 1 peer in the restored snapshot, got %d", count)
+	}
+
+	// new records must not reuse the IDs of the restored ones
+	data = []ticks.Tick{{Close: 3, Time: start.Add(10 * time.Minute)}}
+	if _, err = store.StoreExchangeTicks(ctx, "bittrex", 5, "BTC/DCR", data); err != nil {
+		t.Fatal(err)
+	}
+	var lastID int64
+	if err = store.LastEntry(ctx, models.TableNames.ExchangeTick, &lastID); err != nil {
+		t.Fatal(err)
+	}
+	if lastID != 3 {
+		t.Errorf("expected the last exchange tick ID to be 3, got %d", lastID)
+	}
+}
@@ -17,10 +17,14 @@ import (
 	"github.com/planetdecred/dcrextdata/commstats"
 	"github.com/planetdecred/dcrextdata/datasync"
 	"github.com/planetdecred/dcrextdata/exchanges"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/kvstore"
 	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/planetdecred/dcrextdata/memstore"
 	"github.com/planetdecred/dcrextdata/netsnapshot"
 	"github.com/planetdecred/dcrextdata/postgres"
 	"github.com/planetdecred/dcrextdata/pow"
+	"github.com/planetdecred/dcrextdata/scheduler"
 	"github.com/planetdecred/dcrextdata/vsp"
 	"github.com/planetdecred/dcrextdata/web"
 )
@@ -56,6 +60,9 @@ var (
 	syncLog     = backendLog.Logger("SYNC")
 	snapshotLog = backendLog.Logger("NETS")
 	cacheLog    = backendLog.Logger("CACH")
+	memStoreLog = backendLog.Logger("MSTR")
+	kvStoreLog  = backendLog.Logger("KVST")
+	schedLog    = backendLog.Logger("SCHD")
 )
 
 // subsystemLoggers maps each subsystem identifier to its associated logger.
@@ -71,11 +78,15 @@ var subsystemLoggers = map[string]slog.Logger{
 	"SYNC": syncLog,
 	"NETS": snapshotLog,
 	"CACH": cacheLog,
+	"MSTR": memStoreLog,
+	"KVST": kvStoreLog,
+	"SCHD": schedLog,
 }
 
 func init() {
 	pow.UseLogger(powLog)
 	exchanges.UseLogger(excLog)
+	ticks.UseLogger(excLog)
 	postgres.UseLogger(pqLog)
 	vsp.UseLogger(vspLog)
 	mempool.UseLogger(mempoolLog)
@@ -84,6 +95,9 @@ func init() {
 	datasync.UseLogger(syncLog)
 	netsnapshot.UseLogger(snapshotLog)
 	cache.UseLogger(cacheLog)
+	memstore.UseLogger(memStoreLog)
+	kvstore.UseLogger(kvStoreLog)
+	scheduler.UseLogger(schedLog)
 }
 
 // initLogRotator initializes the logging rotater to write logs to logFile and
@@ -13,7 +13,9 @@ import (
 	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/decred/dcrd/chaincfg"
 	"github.com/decred/dcrd/dcrutil"
@@ -27,10 +29,16 @@ import (
 	"github.com/planetdecred/dcrextdata/commstats"
 	"github.com/planetdecred/dcrextdata/datasync"
 	"github.com/planetdecred/dcrextdata/exchanges"
+	"github.com/planetdecred/dcrextdata/exchanges/orderbook"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/export"
+	"github.com/planetdecred/dcrextdata/kvstore"
 	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/planetdecred/dcrextdata/memstore"
 	"github.com/planetdecred/dcrextdata/netsnapshot"
 	"github.com/planetdecred/dcrextdata/postgres"
 	"github.com/planetdecred/dcrextdata/pow"
+	"github.com/planetdecred/dcrextdata/scheduler"
 	"github.com/planetdecred/dcrextdata/vsp"
 	"github.com/planetdecred/dcrextdata/web"
 )
@@ -107,7 +115,9 @@ func _main(ctx context.Context) error {
 	}
 
 	// if len(args) == 0, then there's nothing to execute as all command-line args were parsed as app options
-	if len(args) > 0 {
+	isMigrateCommand := len(args) > 0 && args[0] == migrateCommand
+	isExportCommand := len(args) > 0 && args[0] == exportCommand
+	if len(args) > 0 && !isMigrateCommand && !isExportCommand {
 		err := executeHelpCommand()
 		if err != nil {
 			return fmt.Errorf("%s: %s", err, config.Hint)
@@ -115,6 +125,13 @@ func _main(ctx context.Context) error {
 		return nil
 	}
 
+	if cfg.InMemory || cfg.KVStore {
+		if isMigrateCommand {
+			return fmt.Errorf("the %s command is only supported by the PostgreSQL storage", migrateCommand)
+		}
+		return runEmbedded(ctx, cfg, isExportCommand, args)
+	}
+
 	db, err := postgres.NewPgDb(cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPass, cfg.DBName, cfg.LogLevel == config.DebugLogLevel)
 
 	if err != nil {
@@ -141,6 +158,9 @@ func _main(ctx context.Context) error {
 				log.Error("Could not drop tables: ", err)
 				return err
 			}
+			if err = cache.RemoveDump(cfg.CacheDir, chartsCacheDump(cfg)); err != nil {
+				log.Errorf("Could not remove the chart cache, %s", err.Error())
+			}
 
 			fmt.Println("Done. You can restart the server now.")
 			return nil
@@ -162,6 +182,9 @@ func _main(ctx context.Context) error {
 				log.Error("Could not drop tables: ", err)
 				return err
 			}
+			if err = cache.RemoveDump(cfg.CacheDir, chartsCacheDump(cfg)); err != nil {
+				log.Errorf("Could not remove the chart cache, %s", err.Error())
+			}
 
 			fmt.Println("Done. You can restart the server now.")
 			return nil
@@ -170,13 +193,24 @@ func _main(ctx context.Context) error {
 		return nil
 	}
 
+	if isMigrateCommand {
+		return runMigrateCommand(ctx, db, args[1:])
+	}
+
 	// Display app version.
 	log.Infof("%s version %v (Go version %s)", app.AppName, app.Version(), runtime.Version())
 
-	if err = createTablesAndIndex(db); err != nil {
+	if err = db.CheckSchemaVersion(ctx); err != nil {
+		return err
+	}
+	if err = db.MigrateUp(ctx); err != nil {
 		return err
 	}
 
+	if cfg.FillGaps {
+		return fillExchangeTickGaps(ctx, db, cfg)
+	}
+
 	syncCoordinator := datasync.NewCoordinator(!cfg.DisableSync, cfg.SyncInterval)
 
 	var syncDbs = map[string]*postgres.PgDb{}
@@ -190,51 +224,16 @@ func _main(ctx context.Context) error {
 			continue
 		}
 
-		if !db.BlockTableExits() {
-			if err := db.CreateBlockTable(); err != nil {
-				log.Error("Error creating block table for sync source, %s: ", source, err)
-				return err
-			}
-			log.Info("Blocks table created successfully.")
-
-		}
-
-		if !db.VoteTableExits() {
-			if err := db.CreateVoteTable(); err != nil {
-				log.Error("Error creating vote table for sync source, %s: ", source, err)
-				return err
-			}
-			log.Info("Votes table created successfully.")
+		if err = db.MigrateUp(ctx); err != nil {
+			log.Errorf("Error migrating the database of the sync source, %s, %s", source, err.Error())
+			return err
 		}
 		syncDbs[databaseName] = db
-		syncCoordinator.AddSource(source, db, databaseName)
-	}
-
-	pools, _ := db.FetchPowSourceData(ctx)
-	var poolSources = make([]string, len(pools))
-	for i, pool := range pools {
-		poolSources[i] = pool.Source
-	}
-
-	allVspData, _ := db.FetchVSPs(ctx)
-	var vsps = make([]string, len(allVspData))
-	for i, vspSource := range allVspData {
-		vsps[i] = vspSource.Name
-	}
-
-	noveVersions, err := db.AllNodeVersions(ctx)
-	if err != nil {
-		log.Error(err)
-	}
-
-	nodeCountries, err := db.AllNodeContries(ctx)
-	if err != nil {
-		log.Error(err)
+		syncCoordinator.AddSource(source, db, databaseName, syncAPIKey(cfg, i))
 	}
 
 	commstats.SetAccounts(cfg.CommunityStatOptions)
-	cacheManager := cache.NewChartData(ctx, cfg.EnableChartCache, cfg.SyncDatabases, poolSources, vsps,
-		nodeCountries, noveVersions, netParams(cfg.DcrdNetworkType), cfg.CacheDir)
+	cacheManager := newChartManager(ctx, cfg, db)
 	db.RegisterCharts(cacheManager, cfg.SyncDatabases, func(name string) (*postgres.PgDb, error) {
 		db, found := syncDbs[name]
 		if !found {
@@ -242,27 +241,12 @@ func _main(ctx context.Context) error {
 		}
 		return db, nil
 	})
-	if err = db.UpdateMempoolAggregateData(ctx); err != nil {
-		return fmt.Errorf("Error in initial mempool bin update, %s", err.Error())
-	}
-	if err = db.UpdatePropagationData(ctx); err != nil {
-		return fmt.Errorf("Error in initial propagation data update, %s", err.Error())
-	}
-	if err = db.UpdateBlockBinData(ctx); err != nil {
-		return fmt.Errorf("Error in initial block data update, %s", err.Error())
-	}
-	if err = db.UpdateVoteTimeDeviationData(ctx); err != nil {
-		return fmt.Errorf("Error in initial vote receive time deviation data update, %s", err.Error())
-	}
-	if err = db.UpdatePowChart(ctx); err != nil {
-		return fmt.Errorf("Error in initial PoW bin update, %s", err.Error())
-	}
-	if err = db.UpdateVspChart(ctx); err != nil {
-		return fmt.Errorf("Error in initial VSP bin update, %s", err.Error())
-	}
-	if err = db.UpdateSnapshotNodesBin(ctx); err != nil {
-		return fmt.Errorf("Error in initial network snapshot bin update, %s", err.Error())
+	if isExportCommand {
+		return runExportCommand(ctx, cfg, db, cacheManager, args[1:])
 	}
+	jobScheduler := scheduler.NewScheduler(db)
+	health := web.NewHealth(db.Ping)
+	hub := web.NewHub()
 
 	// http server method
 	if strings.ToLower(cfg.HttpMode) == "true" || cfg.HttpMode == "1" {
@@ -273,122 +257,431 @@ func _main(ctx context.Context) error {
 			}
 			return db, nil
 		}
-		go web.StartHttpServer(cfg.HTTPHost, cfg.HTTPPort, cacheManager, db, netParams(cfg.DcrdNetworkType), extDbFactory)
+		go web.StartHttpServer(cfg.HTTPHost, cfg.HTTPPort, cacheManager, db, netParams(cfg.DcrdNetworkType), extDbFactory,
+			jobScheduler, cfg.AdminKey, health, hub, accessPolicy(cfg))
 	}
 
-	var dcrClient *rpcclient.Client
-	var collector *mempool.Collector
+	// the web server reports the instance as not ready until the bins are up to date
+	if err = updateChartData(ctx, db); err != nil {
+		return err
+	}
+	loadChartCache(cfg, cacheManager)
+	health.SetChartsReady()
 
-	// if mempool is not disable, check that a dcrclient can be created before showing app version
-	if !cfg.DisableMempool {
-		connCfg := &rpcclient.ConnConfig{
-			Host:       cfg.DcrdRpcServer,
-			Endpoint:   "ws",
-			User:       cfg.DcrdRpcUser,
-			Pass:       cfg.DcrdRpcPassword,
-			DisableTLS: cfg.DisableTLS,
-		}
+	stores := collectorStores{
+		ticks:     db,
+		orderBook: db,
+		pow:       db,
+		vsp:       db,
+		mempool:   db,
+		commStats: db,
+		snapshot:  db,
+	}
+	if !startCollectors(ctx, cfg, stores, jobScheduler, health, hub, cacheManager, syncCoordinator) {
+		return nil
+	}
 
-		if !cfg.DisableTLS {
-			dcrdHomeDir := dcrutil.AppDataDir("dcrd", false)
-			certs, err := ioutil.ReadFile(filepath.Join(dcrdHomeDir, "rpc.cert"))
-			if err != nil {
-				log.Error("Error in reading dcrd cert: ", err)
-				return nil
-			}
-			connCfg.Certificates = certs
-		}
+	go syncCoordinator.StartSyncing(ctx)
 
-		collector = mempool.NewCollector(cfg.MempoolInterval, netParams(cfg.DcrdNetworkType), db)
-		collector.RegisterSyncer(syncCoordinator)
+	return waitForShutdown(ctx, cfg, cacheManager)
+}
 
-		dcrClient, err = rpcclient.New(connCfg, collector.DcrdHandlers(ctx, cacheManager))
-		if err != nil {
-			dcrNotRunningErr := "No connection could be made because the target machine actively refused it"
-			if strings.Contains(err.Error(), dcrNotRunningErr) {
-				log.Errorf(fmt.Sprintf("Unable to connect to dcrd at %s. Is it running?", cfg.DcrdRpcServer))
-				return nil
-			} //running on port
-			fmt.Printf("Error in opening a dcrd connection: %s\n", err.Error())
-			return nil
-		}
+// accessPolicy returns the restrictions of the access to the http server set
+// by cfg.
+func accessPolicy(cfg *config.Config) web.AccessPolicy {
+	return web.AccessPolicy{
+		RequireAPIKey: cfg.RequireAPIKey,
+		IPRateLimit:   cfg.IPRateLimit,
+		KeyRateLimit:  cfg.APIKeyRateLimit,
+		CORSOrigins:   cfg.CORSOrigins,
+		SyncKeys:      cfg.SyncAllowedKeys,
+	}
+}
 
-		err = collector.SetExplorerBestBlock(ctx)
-		if err != nil {
-			log.Errorf("Unable to retrieve explorer best block height. Dcrextdata will not be able to filter out staled blocks, %s", err.Error())
-		}
+// syncAPIKey returns the API key of the i-th sync source, if any.
+func syncAPIKey(cfg *config.Config, i int) string {
+	if i < len(cfg.SyncAPIKeys) {
+		return cfg.SyncAPIKeys[i]
 	}
+	return ""
+}
 
-	if !cfg.DisableMempool {
-		// register the close function to be run before shutdown
-		app.ShutdownOps = append(app.ShutdownOps, func() {
-			log.Info("Shutting down dcrd dcrClient")
-			dcrClient.Shutdown()
-		})
+// collectorStores holds the data store of each collector.
+type collectorStores struct {
+	ticks     ticks.Store
+	orderBook orderbook.Store
+	pow       pow.PowDataStore
+	vsp       vsp.DataStore
+	mempool   mempool.DataStore
+	commStats commstats.DataStore
+	snapshot  netsnapshot.DataStore
+}
 
-		if err := dcrClient.NotifyNewTransactions(true); err != nil {
-			log.Error(err)
-		}
+// startCollectors starts the enabled collectors, the periodic ones as jobs of
+// jobScheduler, and adds the connection to dcrd to health. The new records are
+// published on hub. It returns false if the mempool collector could not
+// connect to dcrd.
+func startCollectors(ctx context.Context, cfg *config.Config, stores collectorStores, jobScheduler *scheduler.Scheduler,
+	health *web.Health, hub *web.Hub, cacheManager *cache.Manager, syncCoordinator *datasync.SyncCoordinator) bool {
 
-		if err := dcrClient.NotifyBlocks(); err != nil {
-			log.Errorf("Unable to register block notification for dcrClient: %s", err.Error())
+	registerJobs := func(name string, collector interface {
+		RegisterJobs(*scheduler.Scheduler) error
+	}) {
+		if err := collector.RegisterJobs(jobScheduler); err != nil {
+			log.Errorf("Cannot schedule the %s collection, %s", name, err.Error())
 		}
+	}
 
-		collector.SetClient(dcrClient)
-
-		go collector.StartMonitoring(ctx)
+	if !cfg.DisableMempool {
+		collector, started := startMempoolCollector(ctx, cfg, stores.mempool, hub, cacheManager, syncCoordinator)
+		if !started {
+			return false
+		}
+		health.SetDcrd(collector)
+		registerJobs("mempool", collector)
 	}
 
 	if !cfg.DisableVSP {
-		vspCollector, err := vsp.NewVspCollector(cfg.VSPInterval, db)
+		vspCollector, err := vsp.NewVspCollector(cfg.VSPInterval, stores.vsp)
 		if err == nil {
-			go vspCollector.Run(ctx, cacheManager)
+			registerJobs("VSP", vspCollector)
 		} else {
 			log.Error(err)
 		}
 	}
 
 	if !cfg.DisableExchangeTicks {
-		go func() {
-			ticksHub, err := exchanges.NewTickHub(ctx, cfg.DisabledExchanges, db)
-			if err != nil {
+		ticksHub, err := exchanges.NewTickHub(ctx, cfg.DisabledExchanges, cfg.EnabledExchanges, cfg.ExchangeConfigFile,
+			ticks.PublishingStore(stores.ticks, hub))
+		if err == nil {
+			ticksHub.EnableStreaming(ctx, cfg.StreamExchanges)
+			ticksHub.StartStreaming(ctx)
+			registerJobs("exchange tick", ticksHub)
+		} else {
+			log.Error(err)
+		}
+
+		if gapStore, ok := stores.ticks.(ticks.GapStore); ok && cfg.GapFillInterval > 0 {
+			if filler, err := newGapFiller(gapStore, cfg); err == nil {
+				filler.SetInterval(time.Duration(cfg.GapFillInterval) * time.Second)
+				registerJobs("exchange tick gap", filler)
+			} else {
 				log.Error(err)
-				return
 			}
-			ticksHub.Run(ctx)
-		}()
+		}
+	}
+
+	if !cfg.DisableOrderBook {
+		orderBookCollector, err := orderbook.NewCollector(cfg.DisabledExchanges, cfg.OrderBookBands, cfg.OrderBookInterval, stores.orderBook)
+		if err == nil {
+			orderBookCollector.RegisterSyncer(syncCoordinator)
+			registerJobs("order book", orderBookCollector)
+		} else {
+			log.Error(err)
+		}
 	}
 
 	if !cfg.DisablePow {
-		go func() {
-			powCollector, err := pow.NewCollector(cfg.DisabledPows, cfg.PowInterval, db)
-			if err != nil {
-				log.Error(err)
-				return
-			}
-			powCollector.Run(ctx)
-		}()
+		powCollector, err := pow.NewCollector(cfg.DisabledPows, cfg.PowInterval, stores.pow)
+		if err == nil {
+			registerJobs("PoW", powCollector)
+		} else {
+			log.Error(err)
+		}
 	}
 
 	if !cfg.DisableCommunityStat {
-		redditCollector, err := commstats.NewCommStatCollector(db, &cfg.CommunityStatOptions)
+		commStatCollector, err := commstats.NewCommStatCollector(stores.commStats, &cfg.CommunityStatOptions)
 		if err == nil {
-			go redditCollector.Run(ctx)
+			registerJobs("community stat", commStatCollector)
 		} else {
 			log.Error(err)
 		}
 	}
 
 	if !cfg.DisableNetworkSnapshot {
-		snapshotTaker := netsnapshot.NewTaker(db, cfg.NetworkSnapshotOptions)
+		snapshotTaker := netsnapshot.NewTaker(stores.snapshot, cfg.NetworkSnapshotOptions)
+		snapshotTaker.SetPublisher(hub)
+		registerJobs("network snapshot", snapshotTaker)
 		go snapshotTaker.Start(ctx)
 	}
 
+	go jobScheduler.Start(ctx)
+	return true
+}
+
+// startMempoolCollector connects the mempool collector to dcrd. It returns
+// false if the connection could not be made.
+func startMempoolCollector(ctx context.Context, cfg *config.Config, store mempool.DataStore, publisher mempool.Publisher,
+	cacheManager *cache.Manager, syncCoordinator *datasync.SyncCoordinator) (*mempool.Collector, bool) {
+
+	connCfg := &rpcclient.ConnConfig{
+		Host:       cfg.DcrdRpcServer,
+		Endpoint:   "ws",
+		User:       cfg.DcrdRpcUser,
+		Pass:       cfg.DcrdRpcPassword,
+		DisableTLS: cfg.DisableTLS,
+	}
+
+	if !cfg.DisableTLS {
+		dcrdHomeDir := dcrutil.AppDataDir("dcrd", false)
+		certs, err := ioutil.ReadFile(filepath.Join(dcrdHomeDir, "rpc.cert"))
+		if err != nil {
+			log.Error("Error in reading dcrd cert: ", err)
+			return nil, false
+		}
+		connCfg.Certificates = certs
+	}
+
+	collector := mempool.NewCollector(cfg.MempoolInterval, netParams(cfg.DcrdNetworkType), store)
+	collector.SetPublisher(publisher)
+	collector.RegisterSyncer(syncCoordinator)
+
+	dcrClient, err := rpcclient.New(connCfg, collector.DcrdHandlers(ctx, cacheManager))
+	if err != nil {
+		dcrNotRunningErr := "No connection could be made because the target machine actively refused it"
+		if strings.Contains(err.Error(), dcrNotRunningErr) {
+			log.Errorf(fmt.Sprintf("Unable to connect to dcrd at %s. Is it running?", cfg.DcrdRpcServer))
+			return nil, false
+		} //running on port
+		fmt.Printf("Error in opening a dcrd connection: %s\n", err.Error())
+		return nil, false
+	}
+
+	err = collector.SetExplorerBestBlock(ctx)
+	if err != nil {
+		log.Errorf("Unable to retrieve explorer best block height. Dcrextdata will not be able to filter out staled blocks, %s", err.Error())
+	}
+
+	// register the close function to be run before shutdown
+	app.ShutdownOps = append(app.ShutdownOps, func() {
+		log.Info("Shutting down dcrd dcrClient")
+		dcrClient.Shutdown()
+	})
+
+	if err := dcrClient.NotifyNewTransactions(true); err != nil {
+		log.Error(err)
+	}
+
+	if err := dcrClient.NotifyBlocks(); err != nil {
+		log.Errorf("Unable to register block notification for dcrClient: %s", err.Error())
+	}
+
+	collector.SetClient(dcrClient)
+	return collector, true
+}
+
+// runEmbedded runs the collectors, the data sync and the web server on
+// memory stores. The stores are persisted in LevelDB databases when the
+// kvstore option is set, else the collected data is lost on shutdown. If
+// isExportCommand is set, the export command of args is run instead.
+func runEmbedded(ctx context.Context, cfg *config.Config, isExportCommand bool, args []string) error {
+	log.Infof("%s version %v (Go version %s)", app.AppName, app.Version(), runtime.Version())
+
+	openStore := func(name string) (*memstore.Store, error) {
+		return memstore.NewStore(), nil
+	}
+	if cfg.KVStore {
+		openStore = func(name string) (*memstore.Store, error) {
+			store, err := kvstore.Open(filepath.Join(cfg.KVStoreDir, name))
+			if err != nil {
+				return nil, err
+			}
+			app.ShutdownOps = append(app.ShutdownOps, func() {
+				if err := store.Close(); err != nil {
+					log.Errorf("Could not close the %s database, %s", name, err.Error())
+				}
+			})
+			return store.Store, nil
+		}
+	} else {
+		log.Warn("Running with an in-memory store, the collected data will be lost on shutdown")
+	}
+
+	db, err := openStore(cfg.DBName)
+	if err != nil {
+		return err
+	}
+
+	syncCoordinator := datasync.NewCoordinator(!cfg.DisableSync, cfg.SyncInterval)
+	var syncDbs = map[string]*memstore.Store{}
+	for i := 0; i < len(cfg.SyncSources); i++ {
+		source := cfg.SyncSources[i]
+		databaseName := cfg.SyncDatabases[i]
+		syncDb, err := openStore(databaseName)
+		if err != nil {
+			log.Errorf("Error in opening the store for the sync instance, %s, %s", source, err.Error())
+			continue
+		}
+		syncDbs[databaseName] = syncDb
+		syncCoordinator.AddSource(source, syncDb, databaseName, syncAPIKey(cfg, i))
+	}
+
+	commstats.SetAccounts(cfg.CommunityStatOptions)
+	cacheManager := newChartManager(ctx, cfg, db)
+	db.RegisterCharts(cacheManager, cfg.SyncDatabases, func(name string) (*memstore.Store, error) {
+		db, found := syncDbs[name]
+		if !found {
+			return nil, fmt.Errorf("no db is registered for the source, %s", name)
+		}
+		return db, nil
+	})
+	if isExportCommand {
+		return runExportCommand(ctx, cfg, db, cacheManager, args[1:])
+	}
+	jobScheduler := scheduler.NewScheduler(db)
+	health := web.NewHealth(nil)
+	hub := web.NewHub()
+	if strings.ToLower(cfg.HttpMode) == "true" || cfg.HttpMode == "1" {
+		extDbFactory := func(name string) (query web.DataQuery, e error) {
+			db, found := syncDbs[name]
+			if !found {
+				return nil, fmt.Errorf("no db is registered for the source, %s", name)
+			}
+			return db, nil
+		}
+		go web.StartHttpServer(cfg.HTTPHost, cfg.HTTPPort, cacheManager, db, netParams(cfg.DcrdNetworkType), extDbFactory,
+			jobScheduler, cfg.AdminKey, health, hub, accessPolicy(cfg))
+	}
+
+	if err = updateChartData(ctx, db); err != nil {
+		return err
+	}
+	loadChartCache(cfg, cacheManager)
+	health.SetChartsReady()
+
+	stores := collectorStores{
+		ticks:     db,
+		orderBook: db,
+		pow:       db,
+		vsp:       db,
+		mempool:   db,
+		commStats: db,
+		snapshot:  db,
+	}
+	if !startCollectors(ctx, cfg, stores, jobScheduler, health, hub, cacheManager, syncCoordinator) {
+		return nil
+	}
+
 	go syncCoordinator.StartSyncing(ctx)
 
-	// wait for shutdown signal
+	return waitForShutdown(ctx, cfg, cacheManager)
+}
+
+// chartStore is implemented by the stores that back the charts.
+type chartStore interface {
+	FetchPowSourceData(ctx context.Context) ([]pow.PowDataSource, error)
+	FetchVSPs(ctx context.Context) ([]vsp.VSPDto, error)
+	AllNodeVersions(ctx context.Context) ([]string, error)
+	AllNodeContries(ctx context.Context) ([]string, error)
+
+	UpdateMempoolAggregateData(ctx context.Context) error
+	UpdatePropagationData(ctx context.Context) error
+	UpdateBlockBinData(ctx context.Context) error
+	UpdateVoteTimeDeviationData(ctx context.Context) error
+	UpdatePowChart(ctx context.Context) error
+	UpdateVspChart(ctx context.Context) error
+	UpdateSnapshotNodesBin(ctx context.Context) error
+	UpdateExchangeIndex(ctx context.Context) error
+}
+
+// newChartManager creates the chart manager for the PoW sources, VSPs, node
+// versions and countries found in db.
+func newChartManager(ctx context.Context, cfg *config.Config, db chartStore) *cache.Manager {
+	pools, _ := db.FetchPowSourceData(ctx)
+	var poolSources = make([]string, len(pools))
+	for i, pool := range pools {
+		poolSources[i] = pool.Source
+	}
+
+	allVspData, _ := db.FetchVSPs(ctx)
+	var vsps = make([]string, len(allVspData))
+	for i, vspSource := range allVspData {
+		vsps[i] = vspSource.Name
+	}
+
+	noveVersions, err := db.AllNodeVersions(ctx)
+	if err != nil {
+		log.Error(err)
+	}
+
+	nodeCountries, err := db.AllNodeContries(ctx)
+	if err != nil {
+		log.Error(err)
+	}
+
+	return cache.NewChartData(ctx, cfg.EnableChartCache, cfg.SyncDatabases, poolSources, vsps,
+		nodeCountries, noveVersions, netParams(cfg.DcrdNetworkType), cfg.CacheDir)
+}
+
+// updateChartData brings the chart bins of db up to date.
+func updateChartData(ctx context.Context, db chartStore) error {
+	if err := db.UpdateMempoolAggregateData(ctx); err != nil {
+		return fmt.Errorf("Error in initial mempool bin update, %s", err.Error())
+	}
+	if err := db.UpdatePropagationData(ctx); err != nil {
+		return fmt.Errorf("Error in initial propagation data update, %s", err.Error())
+	}
+	if err := db.UpdateBlockBinData(ctx); err != nil {
+		return fmt.Errorf("Error in initial block data update, %s", err.Error())
+	}
+	if err := db.UpdateVoteTimeDeviationData(ctx); err != nil {
+		return fmt.Errorf("Error in initial vote receive time deviation data update, %s", err.Error())
+	}
+	if err := db.UpdatePowChart(ctx); err != nil {
+		return fmt.Errorf("Error in initial PoW bin update, %s", err.Error())
+	}
+	if err := db.UpdateVspChart(ctx); err != nil {
+		return fmt.Errorf("Error in initial VSP bin update, %s", err.Error())
+	}
+	if err := db.UpdateSnapshotNodesBin(ctx); err != nil {
+		return fmt.Errorf("Error in initial network snapshot bin update, %s", err.Error())
+	}
+	if err := db.UpdateExchangeIndex(ctx); err != nil {
+		return fmt.Errorf("Error in initial exchange index bin update, %s", err.Error())
+	}
+	return nil
+}
+
+// chartsCacheDump is the file the chart cache is saved to, named after the
+// storage and the database so that instances sharing the cache directory
+// never read each other's charts. It is empty for the in-memory store, whose
+// data does not outlive the process.
+func chartsCacheDump(cfg *config.Config) string {
+	storage := "postgres"
+	if cfg.KVStore {
+		storage = "kvstore"
+	} else if cfg.InMemory {
+		return ""
+	}
+	ext := filepath.Ext(cfg.ChartsCacheDump)
+	return fmt.Sprintf("%s-%s-%s%s", strings.TrimSuffix(cfg.ChartsCacheDump, ext), storage, cfg.DBName, ext)
+}
+
+// loadChartCache reads the charts cached by the previous run. It must be
+// called once the chart data is up to date.
+func loadChartCache(cfg *config.Config, charts *cache.Manager) {
+	dump := chartsCacheDump(cfg)
+	if dump == "" {
+		return
+	}
+	if err := charts.Load(dump); err != nil {
+		log.Errorf("Cannot load the chart cache, %s", err.Error())
+	}
+}
+
+// waitForShutdown blocks until a shutdown is requested, then saves the chart
+// cache, unless the data is kept in memory only, and writes the memory profile
+// if one was requested.
+func waitForShutdown(ctx context.Context, cfg *config.Config, charts *cache.Manager) error {
 	<-ctx.Done()
 
+	if dump := chartsCacheDump(cfg); dump != "" {
+		if err := charts.Dump(dump); err != nil {
+			log.Errorf("Cannot save the chart cache, %s", err.Error())
+		}
+	}
+
 	if cfg.Memprofile != "" {
 		f, err := os.Create(cfg.Memprofile)
 		if err != nil {
@@ -434,210 +727,204 @@ func executeHelpCommand() (err error) {
 	return fmt.Errorf(config.Hint)
 }
 
-func createTablesAndIndex(db *postgres.PgDb) error {
-	if !db.MempoolDataTableExits() {
-		if err := db.CreateMempoolDataTable(); err != nil {
-			log.Error("Error creating mempool table: ", err)
-			return err
+// newGapFiller returns a GapFiller for the enabled built-in and configured exchanges.
+func newGapFiller(store ticks.GapStore, cfg *config.Config) (*ticks.GapFiller, error) {
+	var exchangeConfigs []ticks.ExchangeConfig
+	if cfg.ExchangeConfigFile != "" {
+		var err error
+		if exchangeConfigs, err = ticks.LoadExchangeConfigs(cfg.ExchangeConfigFile); err != nil {
+			return nil, err
 		}
-		log.Info("Mempool table created successfully.")
 	}
+	return ticks.NewGapFiller(store, cfg.DisabledExchanges, exchangeConfigs)
+}
 
-	if !db.MempoolBinDataTableExits() {
-		if err := db.CreateMempoolDayBinTable(); err != nil {
-			log.Error("Error creating mempool_bin table: ", err)
-			return err
-		}
-		log.Info("Mempool bin table created successfully.")
+// fillExchangeTickGaps backfills the missing exchange ticks and prints the gaps that could not be filled.
+func fillExchangeTickGaps(ctx context.Context, db *postgres.PgDb, cfg *config.Config) error {
+	filler, err := newGapFiller(db, cfg)
+	if err != nil {
+		return err
 	}
-
-	if !db.PropagationTableExists() {
-		if err := db.CreatePropagationTable(); err != nil {
-			log.Error("Error creating propagation table: ", err)
-			return err
-		}
-		log.Info("Propagation table created successfully.")
+	report, err := filler.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("error in filling exchange tick gaps, %s", err.Error())
 	}
 
-	if !db.BlockTableExits() {
-		if err := db.CreateBlockTable(); err != nil {
-			log.Error("Error creating block table: ", err)
-			return err
-		}
-		log.Info("Blocks table created successfully.")
-
+	fmt.Printf("Scanned %d tick series, found %d gaps with %d missing ticks, filled %d ticks.\n",
+		report.Series, report.Gaps, report.Missing, report.Filled)
+	if len(report.Unfilled) == 0 {
+		return nil
+	}
+	fmt.Printf("%d gaps could not be filled:\n", len(report.Unfilled))
+	for _, gap := range report.Unfilled {
+		fmt.Printf("  %-30s %s to %s, %d missing\n", gap.TickSeries, gap.From.Format(time.RFC3339),
+			gap.To.Format(time.RFC3339), gap.Missing)
 	}
+	return nil
+}
 
-	if !db.BlockBinTableExits() {
-		if err := db.CreateBlockBinTable(); err != nil {
-			log.Error("Error creating block bin table: ", err)
-			return err
-		}
-		log.Info("Blocks bin table created successfully.")
+// migrateCommand is the command that shows or changes the schema version of the database.
+const migrateCommand = "migrate"
 
+// runMigrateCommand executes the status, up, down or to <version> sub command of migrate.
+func runMigrateCommand(ctx context.Context, db *postgres.PgDb, args []string) error {
+	usage := fmt.Errorf("usage: %s %s status|up|down|to <version>", app.AppName, migrateCommand)
+	if len(args) == 0 {
+		return usage
 	}
 
-	if !db.VoteTableExits() {
-		if err := db.CreateVoteTable(); err != nil {
-			log.Error("Error creating vote table: ", err)
-			return err
+	var err error
+	switch args[0] {
+	case "status":
+		if len(args) != 1 {
+			return usage
 		}
-		log.Info("Votes table created successfully.")
-	}
-
-	if !db.VoteReceiveTimeDeviationTableExits() {
-		if err := db.CreateVoteReceiveTimeDeviationTable(); err != nil {
-			log.Error("Error creating vote receive time deviation table: ", err)
+		var statuses []postgres.MigrationStatus
+		if statuses, err = db.MigrationStatuses(ctx); err != nil {
 			return err
 		}
-		log.Info("Vote receive time deviation table created successfully.")
-	}
-
-	if exists := db.VSPInfoTableExits(); !exists {
-		if err := db.CreateVSPInfoTables(); err != nil {
-			log.Error("Error creating vsp info table: ", err)
-			return err
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			if status.Unknown {
+				state += ", unknown to this version of " + app.AppName
+			}
+			fmt.Printf("  %4d  %-40s %s\n", status.Version, status.Description, state)
 		}
-
-		log.Info("VSP table created successfully.")
-	}
-
-	if exists := db.VSPTickTableExits(); !exists {
-		if err := db.CreateVSPTickTables(); err != nil {
-			log.Error("Error creating vsp data table: ", err)
-			return err
+		return nil
+	case "up":
+		if len(args) != 1 {
+			return usage
 		}
-		log.Info("VSPTicks table created successfully.")
-
-		if err := db.CreateVSPTickIndex(); err != nil {
-			log.Error("Error creating vsp data index: ", err)
-			return err
+		err = db.MigrateUp(ctx)
+	case "down":
+		if len(args) != 1 {
+			return usage
 		}
-	}
-
-	if exists := db.VSPTickBinTableExits(); !exists {
-		if err := db.CreateVSPTickBinTable(); err != nil {
-			log.Error("Error creating vsp tick bin table: ", err)
-			return err
+		err = db.MigrateDown(ctx)
+	case "to":
+		if len(args) != 2 {
+			return usage
 		}
-		log.Info("VSPTicks bin table created successfully.")
+		var target int
+		if target, err = strconv.Atoi(args[1]); err != nil {
+			return fmt.Errorf("invalid schema version, %s", args[1])
+		}
+		err = db.MigrateTo(ctx, target)
+	default:
+		return usage
+	}
+	if err != nil {
+		return err
 	}
 
-	if exists := db.ExchangeTableExits(); !exists {
-		if err := db.CreateExchangeTable(); err != nil {
-			log.Error("Error creating exchange table: ", err)
-			return err
-		}
-		log.Info("Exchange table created successfully.")
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return err
 	}
+	fmt.Printf("The database schema is at version %d, the latest version is %d\n", version, postgres.LatestSchemaVersion())
+	return nil
+}
 
-	if exists := db.ExchangeTickTableExits(); !exists {
-		if err := db.CreateExchangeTickTable(); err != nil {
-			log.Error("Error creating exchange tick table: ", err)
-			return err
-		}
-		log.Info("ExchangeTicks table created successfully.")
+// exportCommand is the command that writes a table or a chart to a file.
+const exportCommand = "export"
 
-		if err := db.CreateExchangeTickIndex(); err != nil {
-			log.Error("Error creating exchange tick index: ", err)
-			return err
-		}
-	}
+// exportStore is implemented by the stores whose tables and charts can be
+// exported.
+type exportStore interface {
+	chartStore
+	export.Source
+}
 
-	if exists := db.PowDataTableExits(); !exists {
-		if err := db.CreatePowDataTable(); err != nil {
-			log.Error("Error creating PoW data table: ", err)
-			return err
-		}
-		log.Info("Pow table created successfully.")
+// runExportCommand writes the table, or the chart, named by args to the
+// export output, in the export format and time range of cfg.
+func runExportCommand(ctx context.Context, cfg *config.Config, db exportStore, charts *cache.Manager, args []string) error {
+	usage := fmt.Errorf("usage: %s %s <table>|chart <type> [<data type>]", app.AppName, exportCommand)
+	if len(args) == 0 {
+		return usage
 	}
 
-	if exists := db.PowBInTableExits(); !exists {
-		if err := db.CreatePowBinTable(); err != nil {
-			log.Error("Error creating PoW bin table: ", err)
-			return err
-		}
-		log.Info("Pow bin table created successfully.")
+	format := cfg.ExportFormat
+	if format == "" {
+		format = export.CSV
 	}
-
-	if exists := db.RedditTableExits(); !exists {
-		if err := db.CreateRedditTable(); err != nil {
-			log.Error("Error creating reddit table: ", err)
-			return err
-		}
-		log.Info("reddit table created successfully.")
+	if format != export.CSV && format != export.NDJSON {
+		return fmt.Errorf("invalid export format, %s, expected %s or %s", format, export.CSV, export.NDJSON)
 	}
-
-	if exists := db.TwitterTableExits(); !exists {
-		if err := db.CreateTwitterTable(); err != nil {
-			log.Error("Error creating twitter table: ", err)
-			return err
-		}
-		log.Info("twitter table created successfully.")
+	var timeRange export.Range
+	var err error
+	if timeRange.Start, err = export.ParseTime(cfg.ExportStart); err != nil {
+		return fmt.Errorf("invalid export start, %s", err.Error())
 	}
-
-	if exists := db.YoutubeTableExits(); !exists {
-		if err := db.CreateYoutubeTable(); err != nil {
-			log.Error("Error creating youtube table: ", err)
-			return err
-		}
-		log.Info("youtube table created successfully.")
+	if timeRange.End, err = export.ParseTime(cfg.ExportEnd); err != nil {
+		return fmt.Errorf("invalid export end, %s", err.Error())
 	}
 
-	if exists := db.GithubTableExits(); !exists {
-		if err := db.CreateGithubTable(); err != nil {
-			log.Error("Error creating github table: ", err)
+	write := func(name string, writeRows func(writer export.Writer) error) error {
+		output := cfg.ExportOutput
+		if output == "" {
+			output = name + "." + format
+		}
+		file, err := os.Create(output)
+		if err != nil {
 			return err
 		}
-		log.Info("github table created successfully.")
-	}
+		defer file.Close()
 
-	if exists := db.NetworkSnapshotTableExists(); !exists {
-		if err := db.CreateNetworkSnapshotTable(); err != nil {
-			log.Error("Error creating network snapshot table: ", err)
+		writer, err := export.NewWriter(format, file)
+		if err != nil {
 			return err
 		}
-		log.Info("snapshot table created successfully.")
-	}
-
-	if exists := db.NetworkSnapshotBinTableExists(); !exists {
-		if err := db.CreateNetworkSnapshotBinTable(); err != nil {
-			log.Error("Error creating network snapshot bin table: ", err)
+		if err = writeRows(writer); err != nil {
 			return err
 		}
-		log.Info("snapshot bin table created successfully.")
-	}
-
-	if exists := db.NodeVersionTableExists(); !exists {
-		if err := db.CreateNodeVersoinTable(); err != nil {
-			log.Error("Error creating node version table: ", err)
+		if err = writer.Flush(); err != nil {
 			return err
 		}
-		log.Info("node version table created successfully.")
-	}
-
-	if exists := db.NodeLocationTableExists(); !exists {
-		if err := db.CreateNodeLocationTable(); err != nil {
-			log.Error("Error creating node location table: ", err)
+		if err = file.Close(); err != nil {
 			return err
 		}
-		log.Info("node location table created successfully.")
+		fmt.Printf("Exported %s to %s\n", name, output)
+		return nil
 	}
 
-	if exists := db.NetworkNodeTableExists(); !exists {
-		if err := db.CreateNetworkNodeTable(); err != nil {
-			log.Error("Error creating node table: ", err)
+	if args[0] != "chart" {
+		if len(args) != 1 {
+			return usage
+		}
+		table, err := export.TableByName(args[0])
+		if err != nil {
 			return err
 		}
-		log.Info("node table created successfully.")
+		return write(table.Name, func(writer export.Writer) error {
+			return db.ExportTable(ctx, table, timeRange, writer)
+		})
 	}
 
-	if exists := db.HeartbeatTableExists(); !exists {
-		if err := db.CreateHeartbeatTable(); err != nil {
-			log.Error("Error creating heartbeat table: ", err)
-			return err
-		}
-		log.Info("heartbeat table created successfully.")
+	if len(args) < 2 || len(args) > 3 {
+		return usage
 	}
-	return nil
+	chartType, dataType, name := args[1], "", args[1]
+	if len(args) == 3 {
+		dataType = args[2]
+		name += "-" + dataType
+	}
+	// the memory stores compute their bins on startup
+	if err = updateChartData(ctx, db); err != nil {
+		return err
+	}
+	var extras []string
+	if cfg.ExportExtras != "" {
+		extras = strings.Split(cfg.ExportExtras, "|")
+	}
+	data, err := charts.Chart(ctx, chartType, dataType, cfg.ExportAxis, cfg.ExportBin, extras...)
+	if err != nil {
+		return err
+	}
+	timeAxis := cache.ParseAxis(cfg.ExportAxis) != cache.HeightAxis
+	return write(name, func(writer export.Writer) error {
+		return export.WriteChart(data, timeAxis, timeRange, writer)
+	})
 }
@@ -0,0 +1,96 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"math"
+	"sort"
+)
+
+// FeeRateBuckets are the lower bounds, in atoms/kB, of the fee rate buckets
+// of the mempool samples. The last bucket has no upper bound.
+var FeeRateBuckets = []int64{0, 10000, 20000, 50000, 100000, 200000, 500000, 1000000}
+
+// FeeRatePercentiles are the percentiles of the transaction fee rates
+// recorded with the mempool samples.
+var FeeRatePercentiles = []int{10, 25, 50, 75, 90}
+
+// FeeRateBucket is the number of transactions of a mempool sample, and their
+// size in bytes, whose fee rate is at least FeeRate atoms/kB and below the
+// fee rate of the next bucket.
+type FeeRateBucket struct {
+	FeeRate int64 `json:"fee_rate"`
+	Count   int   `json:"count"`
+	Size    int64 `json:"size"`
+}
+
+// FeeRatePercentile is the fee rate, in atoms/kB, below which Percentile
+// percent of the transactions of a mempool sample are.
+type FeeRatePercentile struct {
+	Percentile int   `json:"percentile"`
+	FeeRate    int64 `json:"fee_rate"`
+}
+
+type txFeeRate struct {
+	feeRate int64
+	size    int64
+}
+
+// newTxFeeRate returns the fee rate of a transaction of size bytes paying fee
+// DCR.
+func newTxFeeRate(fee float64, size int32) txFeeRate {
+	rate := txFeeRate{size: int64(size)}
+	if size > 0 {
+		rate.feeRate = int64(math.Round(fee*1e8)) * 1000 / int64(size)
+	}
+	return rate
+}
+
+// feeRateBucketIndex returns the index of the bucket of feeRate in
+// FeeRateBuckets.
+func feeRateBucketIndex(feeRate int64) int {
+	bucket := sort.Search(len(FeeRateBuckets), func(i int) bool { return FeeRateBuckets[i] > feeRate }) - 1
+	if bucket < 0 {
+		return 0
+	}
+	return bucket
+}
+
+// BucketFeeRate returns the lower fee rate of the bucket of feeRate.
+func BucketFeeRate(feeRate int64) int64 {
+	return FeeRateBuckets[feeRateBucketIndex(feeRate)]
+}
+
+// feeRateDistribution returns the transactions of txs in every bucket of
+// FeeRateBuckets and the FeeRatePercentiles of their fee rates.
+func feeRateDistribution(txs []txFeeRate) ([]FeeRateBucket, []FeeRatePercentile) {
+	buckets := make([]FeeRateBucket, len(FeeRateBuckets))
+	for i, feeRate := range FeeRateBuckets {
+		buckets[i].FeeRate = feeRate
+	}
+	if len(txs) == 0 {
+		return buckets, nil
+	}
+
+	sorted := make([]int64, len(txs))
+	for i, tx := range txs {
+		sorted[i] = tx.feeRate
+		bucket := feeRateBucketIndex(tx.feeRate)
+		buckets[bucket].Count++
+		buckets[bucket].Size += tx.size
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	percentiles := make([]FeeRatePercentile, len(FeeRatePercentiles))
+	for i, percentile := range FeeRatePercentiles {
+		// nearest rank
+		rank := int(math.Ceil(float64(percentile) / 100 * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		}
+		percentiles[i] = FeeRatePercentile{Percentile: percentile, FeeRate: sorted[rank-1]}
+	}
+	return buckets, percentiles
+}
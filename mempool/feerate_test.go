@@ -0,0 +1,51 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import "testing"
+
+func TestFeeRateDistribution(t *testing.T) {
+	if rate := newTxFeeRate(0.0001, 250); rate.feeRate != 40000 || rate.size != 250 {
+		t.Errorf("expected a fee rate of 40000 atoms/kB, got %+v", rate)
+	}
+
+	var txs []txFeeRate
+	for i := 1; i <= 10; i++ {
+		txs = append(txs, txFeeRate{feeRate: int64(i) * 10000, size: 100})
+	}
+	buckets, percentiles := feeRateDistribution(txs)
+	if len(buckets) != len(FeeRateBuckets) {
+		t.Fatalf("expected %d buckets, got %d", len(FeeRateBuckets), len(buckets))
+	}
+	expected := []FeeRateBucket{
+		{FeeRate: 0},
+		{FeeRate: 10000, Count: 1, Size: 100},
+		{FeeRate: 20000, Count: 3, Size: 300},
+		{FeeRate: 50000, Count: 5, Size: 500},
+		{FeeRate: 100000, Count: 1, Size: 100},
+		{FeeRate: 200000},
+		{FeeRate: 500000},
+		{FeeRate: 1000000},
+	}
+	for i, bucket := range buckets {
+		if bucket != expected[i] {
+			t.Errorf("expected the bucket %+v, got %+v", expected[i], bucket)
+		}
+	}
+
+	expectedRates := map[int]int64{10: 10000, 25: 30000, 50: 50000, 75: 80000, 90: 90000}
+	if len(percentiles) != len(FeeRatePercentiles) {
+		t.Fatalf("expected %d percentiles, got %d", len(FeeRatePercentiles), len(percentiles))
+	}
+	for _, p := range percentiles {
+		if p.FeeRate != expectedRates[p.Percentile] {
+			t.Errorf("expected the fee rate %d at the %dth percentile, got %d", expectedRates[p.Percentile], p.Percentile, p.FeeRate)
+		}
+	}
+
+	if _, percentiles = feeRateDistribution(nil); percentiles != nil {
+		t.Errorf("expected no percentiles without transactions, got %+v", percentiles)
+	}
+}
@@ -8,9 +8,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,13 +24,19 @@ import (
 	"github.com/planetdecred/dcrextdata/app/helpers"
 	"github.com/planetdecred/dcrextdata/cache"
 	"github.com/planetdecred/dcrextdata/datasync"
+	"github.com/planetdecred/dcrextdata/metrics"
+	"github.com/planetdecred/dcrextdata/scheduler"
 )
 
+var rpcNotifications = metrics.NewCounterVec("dcrextdata_rpc_notifications_total",
+	"Notifications received from dcrd, by type.", "notification")
+
 func NewCollector(interval float64, activeChain *chaincfg.Params, dataStore DataStore) *Collector {
 	c := &Collector{
 		collectionInterval: interval,
 		dataStore:          dataStore,
 		activeChain:        activeChain,
+		view:               newMempoolView(),
 	}
 	return c
 }
@@ -39,6 +45,23 @@ func (c *Collector) SetClient(client *rpcclient.Client) {
 	c.dcrClient = client
 }
 
+// SetPublisher sets the publisher of the blocks, votes and mempool samples
+// that are stored.
+func (c *Collector) SetPublisher(publisher Publisher) {
+	c.publisher = publisher
+}
+
+func (c *Collector) publish(topic string, data interface{}) {
+	if c.publisher != nil {
+		c.publisher.Publish(topic, data)
+	}
+}
+
+// Connected tells whether the RPC connection to dcrd is up.
+func (c *Collector) Connected() bool {
+	return c.dcrClient != nil && !c.dcrClient.Disconnected()
+}
+
 func (c *Collector) SetExplorerBestBlock(ctx context.Context) error {
 	var explorerUrl string
 	switch c.activeChain.Name {
@@ -68,22 +91,26 @@ func (c *Collector) DcrdHandlers(ctx context.Context, cacheManager *cache.Manage
 
 	return &rpcclient.NotificationHandlers{
 		OnTxAcceptedVerbose: func(txDetails *dcrjson.TxRawResult) {
+			rpcNotifications.Inc("txacceptedverbose")
 			go func() {
 				if ctx.Err() != nil {
 					return
 				}
-				if !c.syncIsDone {
-					return
-				}
 				receiveTime := helpers.NowUTC()
 
-				msgTx, err := txhelpers.MsgTxFromHex(txDetails.Hex)
+				msgTx, tx, err := newViewTx(txDetails, receiveTime)
 				if err != nil {
 					log.Errorf("Failed to decode transaction hex: %v", err)
 					return
 				}
+				tx.notified = true
+				c.view.add(txDetails.Txid, tx)
 
-				if txType := txhelpers.DetermineTxTypeString(msgTx); txType != "Vote" {
+				if !c.syncIsDone {
+					return
+				}
+
+				if tx.txType != "Vote" {
 					return
 				}
 
@@ -143,6 +170,25 @@ func (c *Collector) DcrdHandlers(ctx context.Context, cacheManager *cache.Manage
 
 				if err = c.dataStore.SaveVote(ctx, vote); err != nil {
 					log.Error(err)
+				} else {
+					c.publish(VoteTopic, vote)
+				}
+
+				voteVersion := VoteVersion{
+					Hash:        vote.Hash,
+					ReceiveTime: receiveTime,
+					VotingOn:    validation.Height,
+					Version:     version,
+					Bits:        bits,
+				}
+				for _, choice := range choices {
+					voteVersion.Choices = append(voteVersion.Choices, AgendaChoice{
+						AgendaID: choice.ID,
+						Choice:   choice.Choice.Id,
+					})
+				}
+				if err = c.dataStore.SaveVoteVersion(ctx, voteVersion); err != nil {
+					log.Errorf("Error in saving the vote choices, %s", err.Error())
 				}
 
 				if err = c.dataStore.UpdateVoteTimeDeviationData(ctx); err != nil {
@@ -152,6 +198,7 @@ func (c *Collector) DcrdHandlers(ctx context.Context, cacheManager *cache.Manage
 		},
 
 		OnBlockConnected: func(blockHeaderSerialized []byte, transactions [][]byte) {
+			rpcNotifications.Inc("blockconnected")
 			if ctx.Err() != nil {
 				return
 			}
@@ -178,127 +225,173 @@ func (c *Collector) DcrdHandlers(ctx context.Context, cacheManager *cache.Manage
 				BlockHash:         blockHeader.BlockHash().String(),
 				BlockHeight:       blockHeader.Height,
 			}
+			go c.confirmBlockTransactions(ctx, blockHeader.BlockHash(), block.BlockReceiveTime)
 			if err = c.dataStore.SaveBlock(ctx, block); err != nil {
 				log.Error(err)
+			} else {
+				c.publish(BlockTopic, block)
 			}
 			if err = c.dataStore.UpdateBlockBinData(ctx); err != nil {
 				log.Errorf("Error in block bin data update, %s", err.Error())
 			}
 		},
-	}
-}
-
-func (c *Collector) StartMonitoring(ctx context.Context) {
-	var mu sync.Mutex
 
-	collectMempool := func() {
-		if !c.syncIsDone {
-			return
-		}
+		OnReorganization: func(oldHash *chainhash.Hash, oldHeight int32, newHash *chainhash.Hash, newHeight int32) {
+			rpcNotifications.Inc("reorganization")
+			if ctx.Err() != nil {
+				return
+			}
+			log.Infof("Chain reorganization from %s at %d to %s at %d", oldHash, oldHeight, newHash, newHeight)
+			c.saveReorg(ctx, Reorg{
+				OldTipHash:   oldHash.String(),
+				OldTipHeight: uint32(oldHeight),
+				NewTipHash:   newHash.String(),
+				NewTipHeight: uint32(newHeight),
+			})
+		},
 
-		mu.Lock()
-		defer mu.Unlock()
+		OnBlockDisconnected: func(blockHeaderSerialized []byte) {
+			rpcNotifications.Inc("blockdisconnected")
+			if ctx.Err() != nil {
+				return
+			}
 
-		mempoolTransactionMap, err := c.dcrClient.GetRawMempoolVerbose(dcrjson.GRMAll)
-		if err != nil {
-			log.Error(err)
-			return
-		}
+			blockHeader := new(wire.BlockHeader)
+			if err := blockHeader.FromBytes(blockHeaderSerialized); err != nil {
+				log.Errorf("Failed to deserialize blockHeader in block disconnected notification: %v", err)
+				return
+			}
+			hash := blockHeader.BlockHash().String()
+			c.orphanedBlocks = append(c.orphanedBlocks, hash)
 
-		if len(mempoolTransactionMap) == 0 {
-			return
-		}
+			// the block is orphaned before the blocks of the new chain,
+			// that can have the same heights, are saved
+			if err := c.dataStore.OrphanBlock(ctx, hash); err != nil {
+				log.Errorf("Error in orphaning block %s, %s", hash, err.Error())
+			}
+		},
+	}
+}
 
-		mempoolDto := Mempool{
-			NumberOfTransactions: len(mempoolTransactionMap),
-			Time:                 helpers.NowUTC(),
-			FirstSeenTime:        helpers.NowUTC(), //todo: use the time of the first tx in the mempool
-		}
+// saveReorg saves reorg with the blocks disconnected since the previous
+// reorganization. dcrd notifies the reorganization once all the blocks of the
+// old chain are disconnected and those of the new chain are connected.
+func (c *Collector) saveReorg(ctx context.Context, reorg Reorg) {
+	reorg.OrphanedBlocks = c.orphanedBlocks
+	reorg.Depth = len(c.orphanedBlocks)
+	reorg.Time = helpers.NowUTC()
+	c.orphanedBlocks = nil
+	if err := c.dataStore.SaveReorg(ctx, reorg); err != nil {
+		log.Errorf("Error in saving the chain reorganization, %s", err.Error())
+	}
+}
 
-		for hashString, tx := range mempoolTransactionMap {
-			hash, err := chainhash.NewHashFromStr(hashString)
-			if err != nil {
-				log.Error(err)
-				continue
-			}
-			rawTx, err := c.dcrClient.GetRawTransactionVerbose(hash)
-			if err != nil {
-				log.Error(err)
-				continue
-			}
+// RegisterJobs adds the periodic collection of the mempool to s.
+func (c *Collector) RegisterJobs(s *scheduler.Scheduler) error {
+	interval := time.Duration(c.collectionInterval * float64(time.Second))
+	return s.AddJob(scheduler.Job{
+		Name:     "mempool",
+		Interval: interval,
+		Timeout:  interval,
+		Run:      c.collectMempool,
+	})
+}
 
-			totalOut := 0.0
-			for _, v := range rawTx.Vout {
-				totalOut += v.Value
-			}
+func (c *Collector) collectMempool(ctx context.Context) error {
+	if !c.syncIsDone {
+		return nil
+	}
 
-			mempoolDto.Total += totalOut
-			mempoolDto.TotalFee += tx.Fee
-			mempoolDto.Size += tx.Size
-			if mempoolDto.FirstSeenTime.Unix() > tx.Time {
-				mempoolDto.FirstSeenTime = helpers.UnixTime(tx.Time)
-			}
+	mempoolHashes, err := c.dcrClient.GetRawMempool(dcrjson.GRMAll)
+	if err != nil {
+		return err
+	}
 
-		}
+	if len(mempoolHashes) == 0 {
+		c.view.reconcile(nil)
+		return nil
+	}
 
-		votes, err := c.dcrClient.GetRawMempool(dcrjson.GRMVotes)
+	// only the transactions that were not notified are fetched
+	hashes := make([]string, len(mempoolHashes))
+	for i, hash := range mempoolHashes {
+		hashes[i] = hash.String()
+	}
+	for _, hashString := range c.view.reconcile(hashes) {
+		hash, err := chainhash.NewHashFromStr(hashString)
 		if err != nil {
 			log.Error(err)
-			return
+			continue
 		}
-		mempoolDto.Voters = len(votes)
-
-		tickets, err := c.dcrClient.GetRawMempool(dcrjson.GRMTickets)
+		rawTx, err := c.dcrClient.GetRawTransactionVerbose(hash)
 		if err != nil {
 			log.Error(err)
-			return
+			continue
 		}
-		mempoolDto.Tickets = len(tickets)
-
-		revocations, err := c.dcrClient.GetRawMempool(dcrjson.GRMRevocations)
+		_, tx, err := newViewTx(rawTx, helpers.NowUTC())
 		if err != nil {
 			log.Error(err)
-			return
+			continue
 		}
-		mempoolDto.Revocations = len(revocations)
+		c.view.add(hashString, tx)
+	}
 
-		if err = c.dataStore.StoreMempool(ctx, mempoolDto); err != nil {
-			log.Error(err)
-		}
+	mempoolDto := c.view.sample(helpers.NowUTC())
+	if err = c.dataStore.StoreMempool(ctx, mempoolDto); err != nil {
+		return err
 	}
+	c.publish(MempoolTopic, mempoolDto)
+	return nil
+}
 
-	lastMempoolTime, err := c.dataStore.LastMempoolTime()
+// confirmBlockTransactions removes the transactions of a block connected at
+// receiveTime from the mempool view and saves how long the ones that were
+// notified waited in the mempool.
+func (c *Collector) confirmBlockTransactions(ctx context.Context, blockHash chainhash.Hash, receiveTime time.Time) {
+	block, err := c.dcrClient.GetBlock(&blockHash)
 	if err != nil {
-		if err != sql.ErrNoRows {
-			log.Errorf("Unable to get last mempool entry time: %s", err.Error())
-		}
-	} else {
-		sencodsPassed := math.Abs(time.Since(lastMempoolTime).Seconds())
-		if sencodsPassed < c.collectionInterval {
-			timeLeft := c.collectionInterval - sencodsPassed
-			log.Infof("Fetching mempool every %dm, collected %0.2f ago, will fetch in %0.2f.", 1, sencodsPassed,
-				timeLeft)
-			time.Sleep(time.Duration(timeLeft) * time.Second)
-		}
+		log.Errorf("Unable to fetch block %s to update the mempool, %s", blockHash, err.Error())
+		return
 	}
-	collectMempool()
-	ticker := time.NewTicker(time.Duration(c.collectionInterval) * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			collectMempool()
-			break
-		case <-ctx.Done():
-			return
+	var hashes []string
+	for _, tx := range block.Transactions {
+		hashes = append(hashes, tx.TxHash().String())
+	}
+	for _, tx := range block.STransactions {
+		hashes = append(hashes, tx.TxHash().String())
+	}
+
+	var confirmations []TxConfirmation
+	for hash, tx := range c.view.mined(hashes...) {
+		if !tx.notified {
+			continue
 		}
+		confirmations = append(confirmations, TxConfirmation{
+			Hash:        hash,
+			TxType:      strings.ToLower(tx.txType),
+			FeeRate:     newTxFeeRate(tx.fee, tx.size).feeRate,
+			Size:        tx.size,
+			SeenTime:    tx.seen,
+			BlockHeight: block.Header.Height,
+			BlockHash:   blockHash.String(),
+			MinedTime:   receiveTime,
+			WaitTime:    receiveTime.Sub(tx.seen).Seconds(),
+		})
+	}
+	if len(confirmations) == 0 {
+		return
+	}
+	if err = c.dataStore.SaveTxConfirmations(ctx, confirmations); err != nil {
+		log.Errorf("Error in saving the transaction confirmations, %s", err.Error())
 	}
 }
 
 func (c *Collector) RegisterSyncer(syncCoordinator *datasync.SyncCoordinator) {
 	c.registerBlockSyncer(syncCoordinator)
 	c.registerVoteSyncer(syncCoordinator)
+	c.registerVoteVersionSyncer(syncCoordinator)
+	c.registerTxConfirmationSyncer(syncCoordinator)
+	c.registerReorgSyncer(syncCoordinator)
 }
 
 func (c *Collector) registerBlockSyncer(syncCoordinator *datasync.SyncCoordinator) {
@@ -409,3 +502,159 @@ func (c *Collector) registerVoteSyncer(syncCoordinator *datasync.SyncCoordinator
 		},
 	})
 }
+
+func (c *Collector) registerVoteVersionSyncer(syncCoordinator *datasync.SyncCoordinator) {
+	syncCoordinator.AddSyncer(c.dataStore.VoteVersionTableName(), datasync.Syncer{
+		LastEntry: func(ctx context.Context, db datasync.Store) (string, error) {
+			var receiveTime time.Time
+			err := db.LastEntry(ctx, c.dataStore.VoteVersionTableName(), &receiveTime)
+			if err != nil && err != sql.ErrNoRows {
+				return "0", fmt.Errorf("error in fetching last vote version receive time, %s", err.Error())
+			}
+			return strconv.FormatInt(receiveTime.Unix(), 10), nil
+		},
+		Collect: func(ctx context.Context, url string) (result *datasync.Result, err error) {
+			result = new(datasync.Result)
+			result.Records = []VoteVersion{}
+			err = helpers.GetResponse(ctx, &http.Client{Timeout: 10 * time.Second}, url, result)
+			return
+		},
+		Retrieve: func(ctx context.Context, last string, skip, take int) (result *datasync.Result, err error) {
+			unixDate, _ := strconv.ParseInt(last, 10, 64)
+			result = new(datasync.Result)
+			votes, totalCount, err := c.dataStore.FetchVoteVersionsForSync(ctx, helpers.UnixTime(unixDate), skip, take)
+			if err != nil {
+				result.Message = err.Error()
+				return
+			}
+			result.Records = votes
+			result.TotalCount = totalCount
+			result.Success = true
+			return
+		},
+		Append: func(ctx context.Context, store datasync.Store, data interface{}) {
+			mappedData := data.([]interface{})
+			var votes []VoteVersion
+			for _, item := range mappedData {
+				var vote VoteVersion
+				err := datasync.DecodeSyncObj(item, &vote)
+				if err != nil {
+					log.Errorf("Error in decoding the received vote version data, %s", err.Error())
+					return
+				}
+				votes = append(votes, vote)
+			}
+
+			for _, vote := range votes {
+				err := store.SaveVoteVersionFromSync(ctx, vote)
+				if err != nil {
+					log.Errorf("Error while appending vote version synced data, %s", err.Error())
+				}
+			}
+		},
+	})
+}
+
+func (c *Collector) registerTxConfirmationSyncer(syncCoordinator *datasync.SyncCoordinator) {
+	syncCoordinator.AddSyncer(c.dataStore.TxConfirmationTableName(), datasync.Syncer{
+		LastEntry: func(ctx context.Context, db datasync.Store) (string, error) {
+			var minedTime time.Time
+			err := db.LastEntry(ctx, c.dataStore.TxConfirmationTableName(), &minedTime)
+			if err != nil && err != sql.ErrNoRows {
+				return "0", fmt.Errorf("error in fetching last transaction confirmation time, %s", err.Error())
+			}
+			return strconv.FormatInt(minedTime.Unix(), 10), nil
+		},
+		Collect: func(ctx context.Context, url string) (result *datasync.Result, err error) {
+			result = new(datasync.Result)
+			result.Records = []TxConfirmation{}
+			err = helpers.GetResponse(ctx, &http.Client{Timeout: 10 * time.Second}, url, result)
+			return
+		},
+		Retrieve: func(ctx context.Context, last string, skip, take int) (result *datasync.Result, err error) {
+			unixDate, _ := strconv.ParseInt(last, 10, 64)
+			result = new(datasync.Result)
+			confirmations, totalCount, err := c.dataStore.FetchTxConfirmationsForSync(ctx, helpers.UnixTime(unixDate), skip, take)
+			if err != nil {
+				result.Message = err.Error()
+				return
+			}
+			result.Records = confirmations
+			result.TotalCount = totalCount
+			result.Success = true
+			return
+		},
+		Append: func(ctx context.Context, store datasync.Store, data interface{}) {
+			mappedData := data.([]interface{})
+			var confirmations []TxConfirmation
+			for _, item := range mappedData {
+				var confirmation TxConfirmation
+				err := datasync.DecodeSyncObj(item, &confirmation)
+				if err != nil {
+					log.Errorf("Error in decoding the received transaction confirmation data, %s", err.Error())
+					return
+				}
+				confirmations = append(confirmations, confirmation)
+			}
+
+			for _, confirmation := range confirmations {
+				err := store.SaveTxConfirmationFromSync(ctx, confirmation)
+				if err != nil {
+					log.Errorf("Error while appending transaction confirmation synced data, %s", err.Error())
+				}
+			}
+		},
+	})
+}
+
+func (c *Collector) registerReorgSyncer(syncCoordinator *datasync.SyncCoordinator) {
+	syncCoordinator.AddSyncer(c.dataStore.ReorgTableName(), datasync.Syncer{
+		LastEntry: func(ctx context.Context, db datasync.Store) (string, error) {
+			var reorgTime time.Time
+			err := db.LastEntry(ctx, c.dataStore.ReorgTableName(), &reorgTime)
+			if err != nil && err != sql.ErrNoRows {
+				return "0", fmt.Errorf("error in fetching last reorg time, %s", err.Error())
+			}
+			return strconv.FormatInt(reorgTime.Unix(), 10), nil
+		},
+		Collect: func(ctx context.Context, url string) (result *datasync.Result, err error) {
+			result = new(datasync.Result)
+			result.Records = []Reorg{}
+			err = helpers.GetResponse(ctx, &http.Client{Timeout: 10 * time.Second}, url, result)
+			return
+		},
+		Retrieve: func(ctx context.Context, last string, skip, take int) (result *datasync.Result, err error) {
+			unixDate, _ := strconv.ParseInt(last, 10, 64)
+			result = new(datasync.Result)
+			reorgs, totalCount, err := c.dataStore.FetchReorgsForSync(ctx, helpers.UnixTime(unixDate), skip, take)
+			if err != nil {
+				result.Message = err.Error()
+				return
+			}
+			result.Records = reorgs
+			result.TotalCount = totalCount
+			result.Success = true
+			return
+		},
+		Append: func(ctx context.Context, store datasync.Store, data interface{}) {
+			mappedData := data.([]interface{})
+			var reorgs []Reorg
+			for _, item := range mappedData {
+				var reorg Reorg
+				err := datasync.DecodeSyncObj(item, &reorg)
+				if err != nil {
+					log.Errorf("Error in decoding the received reorg data, %s", err.Error())
+					return
+				}
+				reorgs = append(reorgs, reorg)
+			}
+
+			for _, reorg := range reorgs {
+				err := store.SaveReorgFromSync(ctx, reorg)
+				if err != nil {
+					log.Errorf("Error while appending reorg synced data, %s", err.Error())
+				}
+			}
+		},
+	})
+}
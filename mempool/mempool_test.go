@@ -0,0 +1,110 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/decred/dcrd/wire"
+)
+
+// reorgTestStore records the orphaned blocks and the saved reorganizations.
+type reorgTestStore struct {
+	DataStore
+	orphaned []string
+	reorgs   []Reorg
+}
+
+func (s *reorgTestStore) OrphanBlock(_ context.Context, hash string) error {
+	s.orphaned = append(s.orphaned, hash)
+	return nil
+}
+
+func (s *reorgTestStore) SaveReorg(_ context.Context, reorg Reorg) error {
+	s.reorgs = append(s.reorgs, reorg)
+	return nil
+}
+
+func TestReorganization(t *testing.T) {
+	store := new(reorgTestStore)
+	collector := NewCollector(0, nil, store)
+	// the new chain does not pass the best block, its blocks are not saved
+	collector.bestBlockHeight = 101
+	handlers := collector.DcrdHandlers(context.Background(), nil)
+
+	header := func(height uint32, nonce uint32) *wire.BlockHeader {
+		return &wire.BlockHeader{Height: height, Nonce: nonce}
+	}
+	serialize := func(header *wire.BlockHeader) []byte {
+		b, err := header.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	// reorganize replays the notifications of dcrd for a reorganization from
+	// the old blocks to the new ones: the old blocks are disconnected from
+	// the tip, the new ones connected, then the reorganization is notified.
+	reorganize := func(old, new []*wire.BlockHeader) {
+		for i := len(old) - 1; i >= 0; i-- {
+			handlers.OnBlockDisconnected(serialize(old[i]))
+		}
+		for _, header := range new {
+			handlers.OnBlockConnected(serialize(header), nil)
+		}
+		oldTip, newTip := old[len(old)-1].BlockHash(), new[len(new)-1].BlockHash()
+		handlers.OnReorganization(&oldTip, int32(old[len(old)-1].Height), &newTip, int32(new[len(new)-1].Height))
+	}
+	hash := func(header *wire.BlockHeader) string { return header.BlockHash().String() }
+
+	old := []*wire.BlockHeader{header(100, 1), header(101, 1)}
+	first := []*wire.BlockHeader{header(100, 2), header(101, 2)}
+	second := []*wire.BlockHeader{header(101, 3)}
+	reorganize(old, first)
+	reorganize(first[1:], second)
+
+	expectedOrphans := []string{hash(old[1]), hash(old[0]), hash(first[1])}
+	if !reflect.DeepEqual(store.orphaned, expectedOrphans) {
+		t.Errorf("expected the orphaned blocks %v, got %v", expectedOrphans, store.orphaned)
+	}
+
+	if len(store.reorgs) != 2 {
+		t.Fatalf("expected 2 reorganizations, got %+v", store.reorgs)
+	}
+	for i := range store.reorgs {
+		if store.reorgs[i].Time.IsZero() {
+			t.Errorf("reorganization %d has no time", i)
+		}
+	}
+	expected := []Reorg{
+		{
+			Depth:          2,
+			OldTipHash:     hash(old[1]),
+			OldTipHeight:   101,
+			NewTipHash:     hash(first[1]),
+			NewTipHeight:   101,
+			OrphanedBlocks: []string{hash(old[1]), hash(old[0])},
+		},
+		{
+			Depth:          1,
+			OldTipHash:     hash(first[1]),
+			OldTipHeight:   101,
+			NewTipHash:     hash(second[0]),
+			NewTipHeight:   101,
+			OrphanedBlocks: []string{hash(first[1])},
+		},
+	}
+	for i := range expected {
+		expected[i].Time = store.reorgs[i].Time
+	}
+	if !reflect.DeepEqual(store.reorgs, expected) {
+		t.Errorf("expected the reorganizations %+v, got %+v", expected, store.reorgs)
+	}
+	if collector.orphanedBlocks != nil {
+		t.Errorf("expected no disconnected blocks left, got %v", collector.orphanedBlocks)
+	}
+}
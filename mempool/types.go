@@ -23,6 +23,9 @@ type Mempool struct {
 	Size                 int32     `json:"size"`
 	TotalFee             float64   `json:"total_fee"`
 	Total                float64   `json:"total"`
+
+	FeeRates    []FeeRateBucket     `json:"fee_rates,omitempty"`
+	Percentiles []FeeRatePercentile `json:"fee_rate_percentiles,omitempty"`
 }
 
 type Dto struct {
@@ -64,6 +67,53 @@ type Vote struct {
 	Validity          string
 }
 
+// VoteVersion is the version, the vote bits and the agenda choices of a vote.
+type VoteVersion struct {
+	Hash        string
+	ReceiveTime time.Time
+	VotingOn    int64
+	Version     uint32
+	Bits        uint16
+	Choices     []AgendaChoice
+}
+
+// AgendaChoice is the choice of a vote on a consensus agenda, usually
+// abstain, yes or no.
+type AgendaChoice struct {
+	AgendaID string
+	Choice   string
+}
+
+// TxConfirmation is the wait of a transaction between its entry in the
+// mempool and the connection of the block that mined it.
+type TxConfirmation struct {
+	Hash string
+	// TxType is regular, ticket, vote or revocation.
+	TxType string
+	// FeeRate is in atoms/kB.
+	FeeRate     int64
+	Size        int32
+	SeenTime    time.Time
+	BlockHeight uint32
+	BlockHash   string
+	MinedTime   time.Time
+	// WaitTime is in seconds.
+	WaitTime float64
+}
+
+// Reorg is a chain reorganisation. The blocks from the old tip down to the
+// fork point are disconnected, their hashes are in OrphanedBlocks, and the
+// ones of the new chain are connected up to the new tip.
+type Reorg struct {
+	Time           time.Time
+	Depth          int
+	OldTipHash     string
+	OldTipHeight   uint32
+	NewTipHash     string
+	NewTipHeight   uint32
+	OrphanedBlocks []string
+}
+
 type VoteDto struct {
 	Hash                  string `json:"hash"`
 	ReceiveTime           string `json:"receive_time"`
@@ -100,6 +150,16 @@ type DataStore interface {
 	SaveVote(ctx context.Context, vote Vote) error
 	UpdateVoteTimeDeviationData(context.Context) error
 	FetchVoteForSync(ctx context.Context, date time.Time, offtset int, limit int) ([]Vote, int64, error)
+	VoteVersionTableName() string
+	SaveVoteVersion(ctx context.Context, vote VoteVersion) error
+	FetchVoteVersionsForSync(ctx context.Context, date time.Time, offset int, limit int) ([]VoteVersion, int64, error)
+	TxConfirmationTableName() string
+	SaveTxConfirmations(ctx context.Context, confirmations []TxConfirmation) error
+	FetchTxConfirmationsForSync(ctx context.Context, date time.Time, offset int, limit int) ([]TxConfirmation, int64, error)
+	OrphanBlock(ctx context.Context, hash string) error
+	ReorgTableName() string
+	SaveReorg(ctx context.Context, reorg Reorg) error
+	FetchReorgsForSync(ctx context.Context, date time.Time, offset int, limit int) ([]Reorg, int64, error)
 
 	datasync.Store
 }
@@ -111,4 +171,21 @@ type Collector struct {
 	activeChain        *chaincfg.Params
 	syncIsDone         bool
 	bestBlockHeight    uint32
+	publisher          Publisher
+	view               *mempoolView
+	// orphanedBlocks are the blocks disconnected since the last chain
+	// reorganisation. The notifications are handled one at a time, in order.
+	orphanedBlocks []string
+}
+
+// The topics of the records published by the collector.
+const (
+	BlockTopic   = "block"
+	VoteTopic    = "vote"
+	MempoolTopic = "mempool"
+)
+
+// Publisher receives the records as they are collected.
+type Publisher interface {
+	Publish(topic string, data interface{})
 }
@@ -0,0 +1,145 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrjson"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrdata/txhelpers/v2"
+)
+
+// viewTx is what the mempool samples need of a transaction.
+type viewTx struct {
+	txType   string
+	fee      float64
+	size     int32
+	totalOut float64
+	seen     time.Time
+	// notified is set for the transactions added from the notifications,
+	// whose seen time is their entry in the mempool.
+	notified bool
+}
+
+// newViewTx decodes a transaction received at seen and returns it with its
+// summary.
+func newViewTx(tx *dcrjson.TxRawResult, seen time.Time) (*wire.MsgTx, viewTx, error) {
+	msgTx, err := txhelpers.MsgTxFromHex(tx.Hex)
+	if err != nil {
+		return nil, viewTx{}, err
+	}
+
+	summary := viewTx{
+		txType: txhelpers.DetermineTxTypeString(msgTx),
+		size:   int32(len(tx.Hex) / 2),
+		seen:   seen,
+	}
+	var totalIn float64
+	for _, in := range tx.Vin {
+		totalIn += in.AmountIn
+	}
+	for _, out := range tx.Vout {
+		summary.totalOut += out.Value
+	}
+	if totalIn > summary.totalOut {
+		// the amounts are rounded to atoms
+		summary.fee = math.Round((totalIn-summary.totalOut)*1e8) / 1e8
+	}
+	return msgTx, summary, nil
+}
+
+// mempoolView is an index of the transactions of the mempool of dcrd. The
+// accepted transactions are added from the notifications and the mined ones
+// removed on block connections. The view is reconciled with the mempool
+// before every sample to drop the expired or double spent transactions and to
+// add the ones that were missed.
+type mempoolView struct {
+	mtx sync.Mutex
+	txs map[string]viewTx
+}
+
+func newMempoolView() *mempoolView {
+	return &mempoolView{txs: make(map[string]viewTx)}
+}
+
+func (v *mempoolView) add(hash string, tx viewTx) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if _, found := v.txs[hash]; !found {
+		v.txs[hash] = tx
+	}
+}
+
+// mined removes the transactions of a block and returns the ones that were in
+// the view.
+func (v *mempoolView) mined(hashes ...string) map[string]viewTx {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	removed := make(map[string]viewTx)
+	for _, hash := range hashes {
+		if tx, found := v.txs[hash]; found {
+			removed[hash] = tx
+			delete(v.txs, hash)
+		}
+	}
+	return removed
+}
+
+// reconcile removes the transactions that are not in the mempool, whose
+// hashes are passed, and returns the hashes that are missing from the view.
+func (v *mempoolView) reconcile(mempoolHashes []string) []string {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	inMempool := make(map[string]struct{}, len(mempoolHashes))
+	var missing []string
+	for _, hash := range mempoolHashes {
+		inMempool[hash] = struct{}{}
+		if _, found := v.txs[hash]; !found {
+			missing = append(missing, hash)
+		}
+	}
+	for hash := range v.txs {
+		if _, found := inMempool[hash]; !found {
+			delete(v.txs, hash)
+		}
+	}
+	return missing
+}
+
+// sample returns the mempool sample of the transactions of the view at now.
+func (v *mempoolView) sample(now time.Time) Mempool {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	sample := Mempool{
+		Time:                 now,
+		FirstSeenTime:        now,
+		NumberOfTransactions: len(v.txs),
+	}
+	feeRates := make([]txFeeRate, 0, len(v.txs))
+	for _, tx := range v.txs {
+		switch tx.txType {
+		case "Vote":
+			sample.Voters++
+		case "Ticket":
+			sample.Tickets++
+		case "Revocation":
+			sample.Revocations++
+		}
+		sample.Total += tx.totalOut
+		sample.TotalFee += tx.fee
+		sample.Size += tx.size
+		if tx.seen.Before(sample.FirstSeenTime) {
+			sample.FirstSeenTime = tx.seen
+		}
+		feeRates = append(feeRates, newTxFeeRate(tx.fee, tx.size))
+	}
+	sample.FeeRates, sample.Percentiles = feeRateDistribution(feeRates)
+	return sample
+}
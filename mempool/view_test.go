@@ -0,0 +1,84 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrjson"
+	"github.com/decred/dcrd/wire"
+)
+
+func TestNewViewTx(t *testing.T) {
+	msgTx := wire.NewMsgTx()
+	msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0, wire.TxTreeRegular), 300000000, nil))
+	msgTx.AddTxOut(wire.NewTxOut(200000000, []byte{0x51}))
+	msgTx.AddTxOut(wire.NewTxOut(99990000, []byte{0x51}))
+	var buf bytes.Buffer
+	if err := msgTx.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, tx, err := newViewTx(&dcrjson.TxRawResult{
+		Hex:  hex.EncodeToString(buf.Bytes()),
+		Vin:  []dcrjson.Vin{{AmountIn: 3}},
+		Vout: []dcrjson.Vout{{Value: 2}, {Value: 0.9999}},
+	}, seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.txType != "Regular" || tx.size != int32(buf.Len()) || math.Abs(tx.totalOut-2.9999) > 1e-9 || !tx.seen.Equal(seen) {
+		t.Errorf("unexpected transaction summary %+v", tx)
+	}
+	if rate := newTxFeeRate(tx.fee, tx.size); rate.feeRate != 10000*1000/int64(buf.Len()) {
+		t.Errorf("expected a fee of 10000 atoms, got %f DCR", tx.fee)
+	}
+}
+
+func TestMempoolView(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	view := newMempoolView()
+	view.add("a", viewTx{txType: "Regular", fee: 0.001, size: 250, totalOut: 10, seen: start.Add(time.Minute)})
+	view.add("b", viewTx{txType: "Vote", size: 300, totalOut: 2, seen: start})
+	view.add("c", viewTx{txType: "Ticket", fee: 0.0003, size: 300, totalOut: 100, seen: start.Add(time.Minute)})
+	// a transaction that is notified again keeps its first receive time
+	view.add("a", viewTx{txType: "Regular", fee: 0.001, size: 250, totalOut: 10, seen: start.Add(time.Hour)})
+
+	// c was mined
+	if mined := view.mined("c", "e"); len(mined) != 1 || mined["c"].totalOut != 100 {
+		t.Errorf("expected c to be mined, got %+v", mined)
+	}
+	// d is in the mempool but was not notified, b expired
+	if missing := view.reconcile([]string{"a", "d"}); len(missing) != 1 || missing[0] != "d" {
+		t.Fatalf("expected d to be missing from the view, got %v", missing)
+	}
+	view.add("d", viewTx{txType: "Revocation", size: 200, totalOut: 5, seen: start.Add(2 * time.Minute)})
+
+	now := start.Add(3 * time.Minute)
+	sample := view.sample(now)
+	if sample.NumberOfTransactions != 2 || sample.Voters != 0 || sample.Tickets != 0 || sample.Revocations != 1 {
+		t.Errorf("unexpected transaction counts %+v", sample)
+	}
+	if sample.Size != 450 || sample.Total != 15 || sample.TotalFee != 0.001 {
+		t.Errorf("unexpected totals %+v", sample)
+	}
+	if !sample.Time.Equal(now) || !sample.FirstSeenTime.Equal(start.Add(time.Minute)) {
+		t.Errorf("unexpected sample times %s and %s", sample.Time, sample.FirstSeenTime)
+	}
+	if len(sample.FeeRates) != len(FeeRateBuckets) || len(sample.Percentiles) != len(FeeRatePercentiles) {
+		t.Errorf("expected the fee rate distribution, got %+v and %+v", sample.FeeRates, sample.Percentiles)
+	}
+
+	view.reconcile(nil)
+	if sample = view.sample(now); sample.NumberOfTransactions != 0 {
+		t.Errorf("expected an empty mempool, got %d transactions", sample.NumberOfTransactions)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/commstats"
+	"github.com/planetdecred/dcrextdata/datasync"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/planetdecred/dcrextdata/netsnapshot"
+	"github.com/planetdecred/dcrextdata/pow"
+	"github.com/planetdecred/dcrextdata/web"
+)
+
+// apiPosition is the sort key of a record served by the v1 API. Records are
+// ordered by time, then by key if the times are not distinct.
+type apiPosition struct {
+	time time.Time
+	key  string
+}
+
+// keyLess reports whether key a sorts before key b. Keys are compared as
+// integers if both are, so that they are ordered as the postgres columns.
+func keyLess(a, b string) bool {
+	x, errX := strconv.ParseInt(a, 10, 64)
+	y, errY := strconv.ParseInt(b, 10, 64)
+	if errX == nil && errY == nil {
+		return x < y
+	}
+	return a < b
+}
+
+func (p apiPosition) before(q apiPosition) bool {
+	if !p.time.Equal(q.time) {
+		return p.time.Before(q.time)
+	}
+	return keyLess(p.key, q.key)
+}
+
+// apiPage returns the indexes of the n records, whose positions are given by
+// position, that are kept by keep and fall in page, in the order of the API.
+func apiPage(n int, position func(i int) apiPosition, keep func(i int) bool, page web.APIPage) []int {
+	var after apiPosition
+	if page.After != nil {
+		after = apiPosition{time: page.After.Time, key: page.After.Key}
+	}
+
+	var indexes []int
+	for i := 0; i < n; i++ {
+		if keep != nil && !keep(i) {
+			continue
+		}
+		pos := position(i)
+		if !page.Start.IsZero() && pos.time.Before(page.Start) {
+			continue
+		}
+		if !page.End.IsZero() && !pos.time.Before(page.End) {
+			continue
+		}
+		if page.After != nil && !after.before(pos) {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return position(indexes[i]).before(position(indexes[j]))
+	})
+	if page.Limit >= 0 && len(indexes) > page.Limit {
+		indexes = indexes[:page.Limit]
+	}
+	return indexes
+}
+
+func (s *Store) APIExchangeTicks(ctx context.Context, filter web.APITickFilter, page web.APIPage) ([]ticks.TickSyncDto, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	exchangeID := -1
+	if filter.Exchange != "" {
+		xch, found := s.exchangeByName(filter.Exchange)
+		if !found {
+			return []ticks.TickSyncDto{}, nil
+		}
+		exchangeID = xch.ID
+	}
+	keep := func(i int) bool {
+		tick := s.exchangeTicks[i]
+		return (exchangeID < 0 || tick.ExchangeID == exchangeID) &&
+			(filter.CurrencyPair == "" || tick.CurrencyPair == filter.CurrencyPair) &&
+			(filter.Interval <= 0 || tick.Interval == filter.Interval)
+	}
+	position := func(i int) apiPosition {
+		tick := s.exchangeTicks[i]
+		return apiPosition{time: tick.Time, key: strconv.Itoa(tick.ID)}
+	}
+
+	indexes := apiPage(len(s.exchangeTicks), position, keep, page)
+	result := make([]ticks.TickSyncDto, len(indexes))
+	for i, index := range indexes {
+		tick := s.exchangeTicks[index]
+		result[i] = ticks.TickSyncDto{
+			ID:           tick.ID,
+			ExchangeID:   tick.ExchangeID,
+			ExchangeName: s.exchangeName(tick.ExchangeID),
+			Interval:     tick.Interval,
+			CurrencyPair: tick.CurrencyPair,
+			Time:         tick.Time,
+			High:         tick.High,
+			Low:          tick.Low,
+			Open:         tick.Open,
+			Close:        tick.Close,
+			Volume:       tick.Volume,
+		}
+	}
+	return result, nil
+}
+
+func (s *Store) APIVSPTicks(ctx context.Context, vspName string, page web.APIPage) ([]datasync.VSPTickSyncDto, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var keep func(i int) bool
+	if vspName != "" {
+		pool, found := s.vspByName(vspName)
+		if !found {
+			return []datasync.VSPTickSyncDto{}, nil
+		}
+		keep = func(i int) bool { return s.vspTicks[i].VSPID == pool.ID }
+	}
+	position := func(i int) apiPosition {
+		tick := s.vspTicks[i]
+		return apiPosition{time: tick.Time, key: strconv.Itoa(tick.ID)}
+	}
+
+	indexes := apiPage(len(s.vspTicks), position, keep, page)
+	result := make([]datasync.VSPTickSyncDto, len(indexes))
+	for i, index := range indexes {
+		result[i] = s.vspTicks[index]
+		result[i].VSP = s.vspName(result[i].VSPID)
+	}
+	return result, nil
+}
+
+func (s *Store) APIPowData(ctx context.Context, source string, page web.APIPage) ([]pow.PowData, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var keep func(i int) bool
+	if source != "" {
+		keep = func(i int) bool { return s.powData[i].Source == source }
+	}
+	position := func(i int) apiPosition {
+		item := s.powData[i]
+		return apiPosition{time: time.Unix(item.Time, 0), key: item.Source}
+	}
+
+	indexes := apiPage(len(s.powData), position, keep, page)
+	result := make([]pow.PowData, len(indexes))
+	for i, index := range indexes {
+		result[i] = s.powData[index]
+	}
+	return result, nil
+}
+
+func (s *Store) APIMempool(ctx context.Context, page web.APIPage) ([]mempool.Mempool, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	position := func(i int) apiPosition { return apiPosition{time: s.mempools[i].Time} }
+	indexes := apiPage(len(s.mempools), position, nil, page)
+	result := make([]mempool.Mempool, len(indexes))
+	for i, index := range indexes {
+		result[i] = s.mempools[index]
+	}
+	return result, nil
+}
+
+func (s *Store) APIBlocks(ctx context.Context, page web.APIPage) ([]mempool.Block, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	position := func(i int) apiPosition {
+		block := s.blocks[i]
+		return apiPosition{time: block.BlockReceiveTime, key: strconv.FormatUint(uint64(block.BlockHeight), 10)}
+	}
+	indexes := apiPage(len(s.blocks), position, nil, page)
+	result := make([]mempool.Block, len(indexes))
+	for i, index := range indexes {
+		result[i] = s.blocks[index]
+	}
+	return result, nil
+}
+
+func (s *Store) APIVotes(ctx context.Context, page web.APIPage) ([]mempool.Vote, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	position := func(i int) apiPosition {
+		vote := s.votes[i]
+		return apiPosition{time: vote.ReceiveTime, key: vote.Hash}
+	}
+	indexes := apiPage(len(s.votes), position, nil, page)
+	result := make([]mempool.Vote, len(indexes))
+	for i, index := range indexes {
+		result[i] = s.votes[index]
+	}
+	return result, nil
+}
+
+func (s *Store) APIRedditStats(ctx context.Context, subreddit string, page web.APIPage) ([]commstats.Reddit, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	keep := func(i int) bool { return s.reddit[i].Subreddit == subreddit }
+	position := func(i int) apiPosition { return apiPosition{time: s.reddit[i].Date} }
+	indexes := apiPage(len(s.reddit), position, keep, page)
+	result := make([]commstats.Reddit, len(indexes))
+	for i, index := range indexes {
+		result[i] = s.reddit[index]
+	}
+	return result, nil
+}
+
+func (s *Store) APITwitterStats(ctx context.Context, handle string, page web.APIPage) ([]commstats.Twitter, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	keep := func(i int) bool { return s.twitter[i].Handle == handle }
+	position := func(i int) apiPosition { return apiPosition{time: s.twitter[i].Date} }
+	indexes := apiPage(len(s.twitter), position, keep, page)
+	result := make([]commstats.Twitter, len(indexes))
+	for i, index := range indexes {
+		result[i] = s.twitter[index]
+	}
+	return result, nil
+}
+
+func (s *Store) APIGithubStats(ctx context.Context, repository string, page web.APIPage) ([]commstats.Github, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	keep := func(i int) bool { return s.github[i].Repository == repository }
+	position := func(i int) apiPosition { return apiPosition{time: s.github[i].Date} }
+	indexes := apiPage(len(s.github), position, keep, page)
+	result := make([]commstats.Github, len(indexes))
+	for i, index := range indexes {
+		result[i] = s.github[index]
+	}
+	return result, nil
+}
+
+func (s *Store) APIYoutubeStats(ctx context.Context, channel string, page web.APIPage) ([]commstats.Youtube, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	keep := func(i int) bool { return s.youtube[i].Channel == channel }
+	position := func(i int) apiPosition { return apiPosition{time: s.youtube[i].Date} }
+	indexes := apiPage(len(s.youtube), position, keep, page)
+	result := make([]commstats.Youtube, len(indexes))
+	for i, index := range indexes {
+		result[i] = s.youtube[index]
+	}
+	return result, nil
+}
+
+// APISnapshots returns the completed network snapshots of page.
+func (s *Store) APISnapshots(ctx context.Context, page web.APIPage) ([]netsnapshot.SnapShot, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	keep := func(i int) bool { return s.snapshots[i].Height > 0 }
+	position := func(i int) apiPosition { return apiPosition{time: time.Unix(s.snapshots[i].Timestamp, 0)} }
+	indexes := apiPage(len(s.snapshots), position, keep, page)
+	result := make([]netsnapshot.SnapShot, len(indexes))
+	for i, index := range indexes {
+		result[i] = s.snapshots[index]
+	}
+	return result, nil
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/planetdecred/dcrextdata/web"
+)
+
+// SaveAPIKey stores a new API key.
+func (s *Store) SaveAPIKey(ctx context.Context, key web.APIKey) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, stored := range s.apiKeys {
+		if stored.Name == key.Name || stored.Hash == key.Hash {
+			return fmt.Errorf("an API key called %s already exists", stored.Name)
+		}
+	}
+	key.CreatedAt = key.CreatedAt.UTC()
+	s.apiKeys[key.Name] = key
+	return s.putAPIKey(key)
+}
+
+// APIKeyByHash returns the API key whose hash is hash.
+func (s *Store) APIKeyByHash(ctx context.Context, hash string) (web.APIKey, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	for _, key := range s.apiKeys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return web.APIKey{}, web.ErrUnknownAPIKey
+}
+
+// APIKeys returns the API keys ordered by name.
+func (s *Store) APIKeys(ctx context.Context) ([]web.APIKey, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	keys := make([]web.APIKey, 0, len(s.apiKeys))
+	for _, key := range s.apiKeys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys, nil
+}
+
+// DeleteAPIKey removes the API key called name.
+func (s *Store) DeleteAPIKey(ctx context.Context, name string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, found := s.apiKeys[name]; !found {
+		return web.ErrUnknownAPIKey
+	}
+	delete(s.apiKeys, name)
+	return s.remove(apiKeyTableName, name)
+}
@@ -0,0 +1,385 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/mempool"
+)
+
+// Names of the binned series. The series of a PoW source, a VSP, a user agent,
+// a country or a propagation sync source are suffixed with its name.
+const (
+	mempoolSeries     = "mempool"
+	feeRateSeries     = "fee-rate"
+	blockSeries       = "block"
+	voteSeries        = "vote"
+	snapshotSeries    = "snapshot"
+	powSeries         = "pow/"
+	vspSeries         = "vsp/"
+	versionSeries     = "version/"
+	locationSeries    = "location/"
+	propagationSeries = "propagation/"
+)
+
+// binPoint is a point of a chart series. values holds a value for every column
+// of the series.
+type binPoint struct {
+	time   uint64
+	height uint64
+	values []float64
+}
+
+// binSet holds the points of a series at each bin level.
+type binSet map[string][]binPoint
+
+type binGenerator func(dates, heights cache.ChartUints) (cache.ChartUints, cache.ChartUints, [][2]int)
+
+// updateBins adds the hourly and daily averages of points, sorted by time, to
+// the bins of series. Only the intervals that start after the last bin of each
+// level are added. The caller must hold the lock.
+func (s *Store) updateBins(series string, points []binPoint) {
+	bins, found := s.bins[series]
+	if !found {
+		bins = make(binSet)
+		s.bins[series] = bins
+	}
+	bins[string(cache.HourBin)] = appendBins(bins[string(cache.HourBin)], points, cache.AnHour, cache.GenerateHourBin)
+	bins[string(cache.DayBin)] = appendBins(bins[string(cache.DayBin)], points, cache.ADay, cache.GenerateDayBin)
+}
+
+// appendBins appends the average of every complete interval of points that
+// starts after the last of bins.
+func appendBins(bins, points []binPoint, width uint64, generate binGenerator) []binPoint {
+	var next uint64
+	if len(bins) > 0 {
+		next = bins[len(bins)-1].time + width
+	}
+	start := sort.Search(len(points), func(i int) bool { return points[i].time >= next })
+	points = points[start:]
+
+	dates, heights := make(cache.ChartUints, len(points)), make(cache.ChartUints, len(points))
+	for i, point := range points {
+		dates[i], heights[i] = point.time, point.height
+	}
+	times, binHeights, intervals := generate(dates, heights)
+	for i, interval := range intervals {
+		values := make([]float64, len(points[interval[0]].values))
+		for _, point := range points[interval[0]:interval[1]] {
+			for j, value := range point.values {
+				values[j] += value
+			}
+		}
+		count := float64(interval[1] - interval[0])
+		for j := range values {
+			values[j] /= count
+		}
+		bins = append(bins, binPoint{time: times[i], height: binHeights[i], values: values})
+	}
+	return bins
+}
+
+// binnedPoints returns the points of series at bin. The caller must hold the
+// lock.
+func (s *Store) binnedPoints(series, bin string) []binPoint {
+	return s.bins[series][bin]
+}
+
+// roundDelay rounds a delay in seconds the way it is shown on the propagation
+// charts.
+func roundDelay(seconds float64) float64 {
+	rounded, _ := strconv.ParseFloat(fmt.Sprintf("%04.2f", seconds), 64)
+	return rounded
+}
+
+func (s *Store) mempoolPoints() []binPoint {
+	var points []binPoint
+	for _, m := range s.sortedMempools(false) {
+		points = append(points, binPoint{
+			time:   uint64(m.Time.Unix()),
+			values: []float64{float64(m.Size), m.TotalFee, float64(m.NumberOfTransactions)},
+		})
+	}
+	return points
+}
+
+// feeRatePoints returns the fee rate distribution of the mempool samples that
+// have one. The values are the transaction count of every bucket of
+// mempool.FeeRateBuckets, then their sizes, then the fee rate at every
+// percentile of mempool.FeeRatePercentiles.
+func (s *Store) feeRatePoints() []binPoint {
+	buckets, percentiles := len(mempool.FeeRateBuckets), len(mempool.FeeRatePercentiles)
+	var points []binPoint
+	for _, m := range s.sortedMempools(false) {
+		if len(m.FeeRates) == 0 || len(m.Percentiles) == 0 {
+			continue
+		}
+		values := make([]float64, 2*buckets+percentiles)
+		for _, bucket := range m.FeeRates {
+			for i, feeRate := range mempool.FeeRateBuckets {
+				if feeRate == bucket.FeeRate {
+					values[i] = float64(bucket.Count)
+					values[buckets+i] = float64(bucket.Size)
+				}
+			}
+		}
+		for _, percentile := range m.Percentiles {
+			for i, p := range mempool.FeeRatePercentiles {
+				if p == percentile.Percentile {
+					values[2*buckets+i] = float64(percentile.FeeRate)
+				}
+			}
+		}
+		points = append(points, binPoint{time: uint64(m.Time.Unix()), values: values})
+	}
+	return points
+}
+
+// blockPoints returns the receive delay of every block, in seconds, ordered by
+// height.
+func (s *Store) blockPoints() []binPoint {
+	var points []binPoint
+	for _, block := range s.blocks {
+		points = append(points, binPoint{
+			time:   uint64(block.BlockInternalTime.Unix()),
+			height: uint64(block.BlockHeight),
+			values: []float64{block.BlockReceiveTime.Sub(block.BlockInternalTime).Seconds()},
+		})
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].height < points[j].height })
+	return points
+}
+
+// votePoints returns the delay between the receipt of every vote and of the
+// block it votes on, in seconds, ordered by the targeted block time.
+func (s *Store) votePoints() []binPoint {
+	var points []binPoint
+	for _, vote := range s.votes {
+		points = append(points, binPoint{
+			time:   uint64(vote.TargetedBlockTime.Unix()),
+			height: uint64(vote.VotingOn),
+			values: []float64{vote.ReceiveTime.Sub(vote.BlockReceiveTime).Seconds()},
+		})
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].time < points[j].time })
+	return points
+}
+
+// powPoints returns the workers and hashrate of a PoW source.
+func (s *Store) powPoints(source string) []binPoint {
+	var points []binPoint
+	for _, data := range s.filterPowData(source, false) {
+		points = append(points, binPoint{
+			time:   uint64(data.Time),
+			values: []float64{float64(data.Workers), float64(uint64(data.PoolHashrate))},
+		})
+	}
+	return points
+}
+
+// The columns of the VSP series.
+const (
+	vspImmature = iota
+	vspLive
+	vspVoted
+	vspMissed
+	vspPoolFees
+	vspProportionLive
+	vspProportionMissed
+	vspUserCount
+	vspUsersActive
+)
+
+func (s *Store) vspPoints(name string) []binPoint {
+	pool, found := s.vspByName(name)
+	if !found {
+		return nil
+	}
+	var points []binPoint
+	for _, tick := range s.vspTicks {
+		if tick.VSPID != pool.ID {
+			continue
+		}
+		points = append(points, binPoint{
+			time: uint64(tick.Time.Unix()),
+			values: []float64{
+				vspImmature:         float64(tick.Immature),
+				vspLive:             float64(tick.Live),
+				vspVoted:            float64(tick.Voted),
+				vspMissed:           float64(tick.Missed),
+				vspPoolFees:         tick.PoolFees,
+				vspProportionLive:   tick.ProportionLive,
+				vspProportionMissed: tick.ProportionMissed,
+				vspUserCount:        float64(tick.UserCount),
+				vspUsersActive:      float64(tick.UsersActive),
+			},
+		})
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].time < points[j].time })
+	return points
+}
+
+func (s *Store) snapshotPoints() []binPoint {
+	var points []binPoint
+	for _, snapshot := range s.snapshots {
+		if snapshot.Height == 0 {
+			continue
+		}
+		points = append(points, binPoint{
+			time:   uint64(snapshot.Timestamp),
+			height: uint64(snapshot.Height),
+			values: []float64{float64(snapshot.NodeCount), float64(snapshot.ReachableNodeCount)},
+		})
+	}
+	return points
+}
+
+// nodeGroupPoints returns the node count of every key of keyOf in each
+// snapshot, zero for the snapshots without a node of that key.
+func (s *Store) nodeGroupPoints(keyOf func(*node) string) map[string][]binPoint {
+	var snapshots []binPoint
+	counts := make(map[uint64]map[string]float64)
+	for _, group := range s.groupSnapshotNodes("", keyOf) {
+		timestamp := uint64(group.timestamp)
+		if _, found := counts[timestamp]; !found {
+			counts[timestamp] = make(map[string]float64)
+			snapshots = append(snapshots, binPoint{time: timestamp, height: uint64(group.height)})
+		}
+		counts[timestamp][group.key] = float64(group.nodes)
+	}
+
+	keys := make(map[string]bool)
+	for _, keyCounts := range counts {
+		for key := range keyCounts {
+			keys[key] = true
+		}
+	}
+	points := make(map[string][]binPoint)
+	for key := range keys {
+		for _, snapshot := range snapshots {
+			points[key] = append(points[key], binPoint{
+				time:   snapshot.time,
+				height: snapshot.height,
+				values: []float64{counts[snapshot.time][key]},
+			})
+		}
+	}
+	return points
+}
+
+func (s *Store) UpdateMempoolAggregateData(ctx context.Context) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.updateBins(mempoolSeries, s.mempoolPoints())
+	s.updateBins(feeRateSeries, s.feeRatePoints())
+	s.charts.Invalidate(cache.Mempool, cache.MempoolFeeRate)
+	return nil
+}
+
+func (s *Store) UpdateBlockBinData(ctx context.Context) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.updateBins(blockSeries, s.blockPoints())
+	s.charts.Invalidate(cache.Propagation)
+	return nil
+}
+
+func (s *Store) UpdateVoteTimeDeviationData(ctx context.Context) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.updateBins(voteSeries, s.votePoints())
+	s.charts.Invalidate(cache.Propagation)
+	return nil
+}
+
+// UpdatePropagationData computes the deviation of the block receive delay of
+// every sync source from the local one and bins it.
+func (s *Store) UpdatePropagationData(ctx context.Context) error {
+	if len(s.syncSources) == 0 {
+		log.Info("Please add one or more propagation sources")
+		return nil
+	}
+
+	for _, source := range s.syncSources {
+		db, err := s.syncSourceDbProvider(source)
+		if err != nil {
+			return err
+		}
+		db.mtx.RLock()
+		sourceDelays := make(map[uint64]float64)
+		for _, point := range db.blockPoints() {
+			sourceDelays[point.height] = roundDelay(point.values[0])
+		}
+		db.mtx.RUnlock()
+
+		s.mtx.Lock()
+		series := propagationSeries + source
+		deviations := s.binnedPoints(series, string(cache.DefaultBin))
+		var lastHeight uint64
+		if len(deviations) > 0 {
+			lastHeight = deviations[len(deviations)-1].height
+		}
+		for _, point := range s.blockPoints() {
+			if point.height <= lastHeight {
+				continue
+			}
+			var deviation float64
+			if sourceDelay, found := sourceDelays[point.height]; found {
+				deviation = roundDelay(point.values[0]) - sourceDelay
+			}
+			deviations = append(deviations, binPoint{time: point.time, height: point.height, values: []float64{deviation}})
+		}
+		s.updateBins(series, deviations)
+		s.bins[series][string(cache.DefaultBin)] = deviations
+		s.mtx.Unlock()
+	}
+	s.charts.Invalidate(cache.Propagation)
+	return nil
+}
+
+func (s *Store) UpdatePowChart(ctx context.Context) error {
+	sources, err := s.FetchPowSourceData(ctx)
+	if err != nil {
+		return err
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, source := range sources {
+		s.updateBins(powSeries+source.Source, s.powPoints(source.Source))
+	}
+	s.charts.Invalidate(cache.PowChart)
+	return nil
+}
+
+func (s *Store) UpdateVspChart(ctx context.Context) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, pool := range s.vsps {
+		s.updateBins(vspSeries+pool.Name, s.vspPoints(pool.Name))
+	}
+	s.charts.Invalidate(cache.VSP)
+	return nil
+}
+
+// UpdateSnapshotNodesBin bins the node counts of the snapshots, by user agent
+// and by country as well.
+func (s *Store) UpdateSnapshotNodesBin(ctx context.Context) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.updateBins(snapshotSeries, s.snapshotPoints())
+	for userAgent, points := range s.nodeGroupPoints(userAgentOf) {
+		s.updateBins(versionSeries+userAgent, points)
+	}
+	for country, points := range s.nodeGroupPoints(countryOf) {
+		s.updateBins(locationSeries+country, points)
+	}
+	s.charts.Invalidate(cache.Snapshot)
+	return nil
+}
@@ -0,0 +1,649 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/volatiletech/null"
+)
+
+// RegisterCharts adds the chart retrievers of the store to charts.
+func (s *Store) RegisterCharts(charts *cache.Manager, syncSources []string, syncSourceDbProvider func(source string) (*Store, error)) {
+	s.syncSources = syncSources
+	s.syncSourceDbProvider = syncSourceDbProvider
+	s.charts = charts
+	// the propagation chart reads the sync stores, their writes must
+	// invalidate it too
+	for _, source := range syncSources {
+		if db, err := syncSourceDbProvider(source); err == nil {
+			db.charts = charts
+		}
+	}
+
+	charts.AddRetriever(cache.Mempool, s.fetchEncodeMempoolChart)
+	charts.AddRetriever(cache.Propagation, s.fetchEncodePropagationChart)
+	charts.AddRetriever(cache.PowChart, s.fetchEncodePowChart)
+	charts.AddRetriever(cache.VSP, s.fetchEncodeVspChart)
+	charts.AddRetriever(cache.Exchange, s.fetchEncodeExchangeChart)
+	charts.AddRetriever(cache.OrderBook, s.fetchEncodeOrderBookChart)
+	charts.AddRetriever(cache.ExchangeIdx, s.fetchEncodeExchangeIndexChart)
+	charts.AddRetriever(cache.Snapshot, s.fetchEncodeSnapshotChart)
+	charts.AddRetriever(cache.Agenda, s.fetchEncodeAgendaChart)
+	charts.AddRetriever(cache.MempoolFeeRate, s.fetchEncodeMempoolFeeRateChart)
+	charts.AddRetriever(cache.Confirmation, s.fetchEncodeConfirmationChart)
+}
+
+// points returns the raw points of a series for the default bin, else its
+// bins. The caller must hold the lock.
+func (s *Store) points(series, binString string, raw func() []binPoint) []binPoint {
+	if binString == string(cache.DefaultBin) {
+		return raw()
+	}
+	return s.binnedPoints(series, binString)
+}
+
+// xAxis returns the heights of points on the height axis, else their times.
+func xAxis(points []binPoint, axis string) cache.ChartUints {
+	values := make(cache.ChartUints, len(points))
+	for i, point := range points {
+		if axis == string(cache.HeightAxis) {
+			values[i] = point.height
+		} else {
+			values[i] = point.time
+		}
+	}
+	return values
+}
+
+func uintColumn(points []binPoint, column int) cache.ChartUints {
+	values := make(cache.ChartUints, len(points))
+	for i, point := range points {
+		values[i] = uint64(point.values[column])
+	}
+	return values
+}
+
+func floatColumn(points []binPoint, column int) cache.ChartFloats {
+	values := make(cache.ChartFloats, len(points))
+	for i, point := range points {
+		values[i] = point.values[column]
+	}
+	return values
+}
+
+// alignSeries returns the union of the times of every series and the value of
+// column of each series at these times. A value is nil before the first point
+// of its series and invalid where the series has no point.
+func alignSeries(series [][]binPoint, column int) (cache.ChartUints, [][]*null.Float64) {
+	seen := make(map[uint64]bool)
+	var dates cache.ChartUints
+	for _, points := range series {
+		for _, point := range points {
+			if !seen[point.time] {
+				seen[point.time] = true
+				dates = append(dates, point.time)
+			}
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i] < dates[j] })
+
+	aligned := make([][]*null.Float64, len(series))
+	for i, points := range series {
+		values := make(map[uint64]float64, len(points))
+		for _, point := range points {
+			values[point.time] = point.values[column]
+		}
+		var hasFoundOne bool
+		for _, date := range dates {
+			if value, found := values[date]; found {
+				aligned[i] = append(aligned[i], &null.Float64{Float64: value, Valid: true})
+				hasFoundOne = true
+			} else if hasFoundOne {
+				aligned[i] = append(aligned[i], &null.Float64{})
+			} else {
+				aligned[i] = append(aligned[i], nil)
+			}
+		}
+	}
+	return dates, aligned
+}
+
+func nullUints(values []*null.Float64) cache.ChartNullUints {
+	result := make(cache.ChartNullUints, len(values))
+	for i, value := range values {
+		if value != nil {
+			result[i] = &null.Uint64{Uint64: uint64(value.Float64), Valid: value.Valid}
+		}
+	}
+	return result
+}
+
+func nullFloats(values []*null.Float64) cache.ChartNullFloats {
+	return cache.ChartNullFloats(values)
+}
+
+func (s *Store) fetchEncodeMempoolChart(ctx context.Context, charts *cache.Manager, dataType, _ string, binString string, _ ...string) ([]byte, error) {
+	s.mtx.RLock()
+	points := s.points(mempoolSeries, binString, s.mempoolPoints)
+	s.mtx.RUnlock()
+
+	dates := xAxis(points, string(cache.TimeAxis))
+	switch dataType {
+	case cache.MempoolSize:
+		return charts.Encode(nil, dates, uintColumn(points, 0))
+	case cache.MempoolFees:
+		return charts.Encode(nil, dates, floatColumn(points, 1))
+	case cache.MempoolTxCount:
+		return charts.Encode(nil, dates, uintColumn(points, 2))
+	}
+	return nil, cache.UnknownChartErr
+}
+
+// fetchEncodeMempoolFeeRateChart returns the number of transactions, or their
+// size for the size data type, in each fee rate bucket over time with the fee
+// rate percentiles.
+func (s *Store) fetchEncodeMempoolFeeRateChart(ctx context.Context, charts *cache.Manager, dataType, _ string, binString string, _ ...string) ([]byte, error) {
+	s.mtx.RLock()
+	points := s.points(feeRateSeries, binString, s.feeRatePoints)
+	s.mtx.RUnlock()
+
+	buckets := len(mempool.FeeRateBuckets)
+	var feeRates []cache.FeeRateRow
+	var percentiles []cache.FeeRatePercentileRow
+	for _, point := range points {
+		for i, feeRate := range mempool.FeeRateBuckets {
+			feeRates = append(feeRates, cache.FeeRateRow{Time: point.time, FeeRate: feeRate,
+				Count: point.values[i], Size: point.values[buckets+i]})
+		}
+		for i, percentile := range mempool.FeeRatePercentiles {
+			percentiles = append(percentiles, cache.FeeRatePercentileRow{Time: point.time, Percentile: percentile,
+				FeeRate: point.values[2*buckets+i]})
+		}
+	}
+	return cache.MakeFeeRateChart(charts, dataType, feeRates, percentiles)
+}
+
+func (s *Store) fetchEncodePropagationChart(ctx context.Context, charts *cache.Manager, dataType, axis string, binString string, _ ...string) ([]byte, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	switch dataType {
+	case cache.BlockPropagation:
+		var series [][]binPoint
+		for _, source := range s.syncSources {
+			series = append(series, s.binnedPoints(propagationSeries+source, binString))
+		}
+		dates, deviations := alignSeries(series, 0)
+		if axis == string(cache.HeightAxis) {
+			heights := make(map[uint64]uint64)
+			for _, points := range series {
+				for _, point := range points {
+					heights[point.time] = point.height
+				}
+			}
+			for i, date := range dates {
+				dates[i] = heights[date]
+			}
+		}
+		var data = []cache.Lengther{dates}
+		for _, d := range deviations {
+			data = append(data, nullFloats(d))
+		}
+		return charts.Encode(nil, data...)
+
+	case cache.BlockTimestamp:
+		points := s.points(blockSeries, binString, s.blockPoints)
+		delays := floatColumn(points, 0)
+		if binString == string(cache.DefaultBin) {
+			for i := range delays {
+				delays[i] = roundDelay(delays[i])
+			}
+		}
+		return charts.Encode(nil, xAxis(points, axis), delays)
+
+	case cache.VotesReceiveTime:
+		if binString != string(cache.DefaultBin) {
+			points := s.binnedPoints(voteSeries, binString)
+			return charts.Encode(nil, xAxis(points, axis), floatColumn(points, 0))
+		}
+
+		voteDelays := make(map[uint64][]float64)
+		for _, point := range s.votePoints() {
+			voteDelays[point.height] = append(voteDelays[point.height], point.values[0])
+		}
+		blocks := s.blockPoints()
+		var deviations cache.ChartFloats
+		for _, block := range blocks {
+			delays, found := voteDelays[block.height]
+			if !found {
+				deviations = append(deviations, 0)
+				continue
+			}
+			var total float64
+			for _, delay := range delays {
+				total += delay
+			}
+			deviations = append(deviations, roundDelay(total/float64(len(delays))*1000))
+		}
+		return charts.Encode(nil, xAxis(blocks, axis), deviations)
+	}
+	return nil, cache.UnknownChartErr
+}
+
+func (s *Store) fetchEncodePowChart(ctx context.Context, charts *cache.Manager, dataType, _ string, binString string, pools ...string) ([]byte, error) {
+	var column int
+	switch strings.ToLower(dataType) {
+	case string(cache.WorkerAxis):
+		column = 0
+	case string(cache.HashrateAxis):
+		column = 1
+	default:
+		return nil, cache.UnknownChartErr
+	}
+
+	s.mtx.RLock()
+	var series [][]binPoint
+	for _, pool := range pools {
+		pool := pool
+		series = append(series, s.points(powSeries+pool, binString, func() []binPoint { return s.powPoints(pool) }))
+	}
+	s.mtx.RUnlock()
+
+	dates, values := alignSeries(series, column)
+	var deviations []cache.ChartNullUints
+	for _, v := range values {
+		deviations = append(deviations, nullUints(v))
+	}
+	return cache.MakePowChart(charts, dates, deviations, pools)
+}
+
+func (s *Store) fetchEncodeVspChart(ctx context.Context, charts *cache.Manager, dataType, _ string, binString string, vspSources ...string) ([]byte, error) {
+	var column int
+	var isFloat bool
+	switch strings.ToLower(dataType) {
+	case string(cache.ImmatureAxis):
+		column = vspImmature
+	case string(cache.LiveAxis):
+		column = vspLive
+	case string(cache.VotedAxis):
+		column = vspVoted
+	case string(cache.MissedAxis):
+		column = vspMissed
+	case string(cache.PoolFeesAxis):
+		column, isFloat = vspPoolFees, true
+	case string(cache.ProportionLiveAxis):
+		column, isFloat = vspProportionLive, true
+	case string(cache.ProportionMissedAxis):
+		column, isFloat = vspProportionMissed, true
+	case string(cache.UserCountAxis):
+		column = vspUserCount
+	case string(cache.UsersActiveAxis):
+		column = vspUsersActive
+	default:
+		return nil, cache.UnknownChartErr
+	}
+
+	s.mtx.RLock()
+	var series [][]binPoint
+	for _, source := range vspSources {
+		source := source
+		series = append(series, s.points(vspSeries+source, binString, func() []binPoint { return s.vspPoints(source) }))
+	}
+	s.mtx.RUnlock()
+
+	dates, values := alignSeries(series, column)
+	var deviations []cache.ChartNullData
+	for _, v := range values {
+		if isFloat {
+			deviations = append(deviations, nullFloats(v))
+		} else {
+			deviations = append(deviations, nullUints(v))
+		}
+	}
+	return cache.MakeVspChart(charts, dates, deviations, vspSources)
+}
+
+func (s *Store) fetchEncodeExchangeChart(ctx context.Context, charts *cache.Manager, dataType, _ string, binString string, setKey ...string) ([]byte, error) {
+	if len(setKey) < 1 {
+		return nil, errors.New("exchange set key is required for exchange chart")
+	}
+	exchangeName, currencyPair, interval := cache.ExtractExchangeKey(setKey[0])
+
+	s.mtx.RLock()
+	xch, found := s.exchangeByName(exchangeName)
+	var tickSlice []exchangeTick
+	if found {
+		tickSlice = s.filterExchangeTicks(func(tick exchangeTick) bool {
+			return tick.ExchangeID == xch.ID && tick.CurrencyPair == currencyPair && tick.Interval == interval
+		})
+	}
+	s.mtx.RUnlock()
+	sort.SliceStable(tickSlice, func(i, j int) bool { return tickSlice[i].Time.Before(tickSlice[j].Time) })
+
+	var dates cache.ChartUints
+	var yAxis cache.ChartFloats
+	for _, t := range tickSlice {
+		dates = append(dates, uint64(t.Time.Unix()))
+		switch strings.ToLower(dataType) {
+		case string(cache.ExchangeOpenAxis):
+			yAxis = append(yAxis, t.Open)
+		case string(cache.ExchangeCloseAxis):
+			yAxis = append(yAxis, t.Close)
+		case string(cache.ExchangeHighAxis):
+			yAxis = append(yAxis, t.High)
+		case string(cache.ExchangeLowAxis):
+			yAxis = append(yAxis, t.Low)
+		}
+	}
+
+	return charts.Encode(nil, dates, yAxis)
+}
+
+// fetchEncodeOrderBookChart returns the average bid or ask depth of each band
+// per snapshot, hour or day. The order book key of the exchange and pair is
+// passed as the first extra.
+func (s *Store) fetchEncodeOrderBookChart(ctx context.Context, charts *cache.Manager, dataType, _ string, binString string, setKey ...string) ([]byte, error) {
+	if len(setKey) < 1 || setKey[0] == "" {
+		return nil, errors.New("order book key is required for order book chart")
+	}
+	exchangeName, currencyPair := cache.ExtractOrderBookKey(setKey[0])
+
+	var bucketSize int64
+	switch cache.ParseBin(binString) {
+	case cache.HourBin:
+		bucketSize = cache.AnHour
+	case cache.DayBin:
+		bucketSize = cache.ADay
+	}
+
+	type depthSum struct {
+		total float64
+		count int
+	}
+	var dates cache.ChartUints
+	var bands []float64
+	bandDepths := make(map[float64]map[uint64]*depthSum)
+	isAsk := strings.ToLower(dataType) == string(cache.OrderBookAskAxis)
+
+	s.mtx.RLock()
+	for _, snapshot := range s.orderBookSnapshots {
+		if snapshot.Exchange != exchangeName || snapshot.CurrencyPair != currencyPair {
+			continue
+		}
+		date := snapshot.Time.Unix()
+		if bucketSize > 0 {
+			date -= date % bucketSize
+		}
+		if len(dates) == 0 || dates[len(dates)-1] != uint64(date) {
+			dates = append(dates, uint64(date))
+		}
+		for _, depth := range snapshot.Depths {
+			if _, found := bandDepths[depth.Band]; !found {
+				bandDepths[depth.Band] = make(map[uint64]*depthSum)
+				bands = append(bands, depth.Band)
+			}
+			sum, found := bandDepths[depth.Band][uint64(date)]
+			if !found {
+				sum = new(depthSum)
+				bandDepths[depth.Band][uint64(date)] = sum
+			}
+			if isAsk {
+				sum.total += depth.AskDepth
+			} else {
+				sum.total += depth.BidDepth
+			}
+			sum.count++
+		}
+	}
+	s.mtx.RUnlock()
+
+	sort.Float64s(bands)
+	var keys = []string{"x"}
+	var sets = []cache.Lengther{dates}
+	for _, band := range bands {
+		var series cache.ChartNullFloats
+		for _, date := range dates {
+			if sum, found := bandDepths[band][date]; found {
+				series = append(series, &null.Float64{Float64: sum.total / float64(sum.count), Valid: true})
+			} else {
+				series = append(series, &null.Float64{})
+			}
+		}
+		keys = append(keys, strconv.FormatFloat(band, 'f', -1, 64))
+		sets = append(sets, series)
+	}
+
+	return charts.Encode(keys, sets...)
+}
+
+func (s *Store) fetchEncodeExchangeIndexChart(ctx context.Context, charts *cache.Manager, dataType, _ string, binString string, _ ...string) ([]byte, error) {
+	currencyPair := ticks.IndexBTCPair
+	if strings.ToLower(dataType) == string(cache.IndexUSDAxis) {
+		currencyPair = ticks.IndexUSDPair
+	}
+	index, _, err := s.ExchangeIndex(ctx, currencyPair, binString, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	var dates cache.ChartUints
+	var prices, volumes cache.ChartFloats
+	for i := len(index) - 1; i >= 0; i-- {
+		dates = append(dates, uint64(index[i].Time.Unix()))
+		prices = append(prices, index[i].Price)
+		volumes = append(volumes, index[i].Volume)
+	}
+	return charts.Encode(nil, dates, prices, volumes)
+}
+
+func (s *Store) fetchEncodeSnapshotChart(ctx context.Context, charts *cache.Manager, dataType, axis, binString string, extras ...string) ([]byte, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	switch dataType {
+	case string(cache.SnapshotNodes):
+		points := s.points(snapshotSeries, binString, s.snapshotPoints)
+		return charts.Encode(nil, xAxis(points, axis), uintColumn(points, 0), uintColumn(points, 1))
+	case string(cache.SnapshotNodeVersions):
+		return s.encodeNodeGroupChart(charts, versionSeries, userAgentOf, axis, binString, extras...)
+	case string(cache.SnapshotLocations):
+		return s.encodeNodeGroupChart(charts, locationSeries, countryOf, axis, binString, extras...)
+	default:
+		return nil, cache.UnknownChartErr
+	}
+}
+
+// encodeNodeGroupChart encodes the node count of each of keys, user agents or
+// countries. The caller must hold the lock.
+func (s *Store) encodeNodeGroupChart(charts *cache.Manager, series string, keyOf func(*node) string, axis, binString string, keys ...string) ([]byte, error) {
+	var raw map[string][]binPoint
+	if binString == string(cache.DefaultBin) {
+		raw = s.nodeGroupPoints(keyOf)
+	}
+
+	var groups [][]binPoint
+	heights := make(map[uint64]uint64)
+	for _, key := range keys {
+		var points []binPoint
+		if raw != nil {
+			points = raw[key]
+		} else {
+			points = s.binnedPoints(series+key, binString)
+		}
+		for _, point := range points {
+			heights[point.time] = point.height
+		}
+		groups = append(groups, points)
+	}
+
+	dates, counts := alignSeries(groups, 0)
+	if axis == string(cache.HeightAxis) {
+		for i, date := range dates {
+			dates[i] = heights[date]
+		}
+	}
+	recs := []cache.Lengther{dates}
+	for _, c := range counts {
+		nodeCounts := make(cache.ChartUints, len(c))
+		for i, count := range c {
+			if count != nil {
+				nodeCounts[i] = uint64(count.Float64)
+			}
+		}
+		recs = append(recs, nodeCounts)
+	}
+	return charts.Encode(nil, recs...)
+}
+
+// fetchEncodeAgendaChart returns the number of votes for each choice of an
+// agenda, per block for the default bin. The agenda ID is passed as the first
+// extra.
+func (s *Store) fetchEncodeAgendaChart(ctx context.Context, charts *cache.Manager, _, axisString string, binString string, agendaID ...string) ([]byte, error) {
+	if len(agendaID) < 1 || agendaID[0] == "" {
+		return nil, errors.New("agenda ID is required for agenda chart")
+	}
+
+	var bucketSize int64
+	switch cache.ParseBin(binString) {
+	case cache.HourBin:
+		bucketSize = cache.AnHour
+	case cache.DayBin:
+		bucketSize = cache.ADay
+	}
+
+	type tallyKey struct {
+		bucket uint64
+		choice string
+	}
+	tallies := make(map[tallyKey]*cache.AgendaTally)
+	s.mtx.RLock()
+	for _, vote := range s.voteVersions {
+		for _, choice := range vote.Choices {
+			if choice.AgendaID != agendaID[0] {
+				continue
+			}
+			receiveTime := vote.ReceiveTime.Unix()
+			bucket := uint64(vote.VotingOn)
+			if bucketSize > 0 {
+				bucket = uint64(receiveTime - receiveTime%bucketSize)
+			}
+			key := tallyKey{bucket: bucket, choice: choice.Choice}
+			tally, found := tallies[key]
+			if !found {
+				tally = &cache.AgendaTally{Bucket: bucket, Height: uint64(vote.VotingOn), Time: uint64(receiveTime),
+					Choice: choice.Choice}
+				if bucketSize > 0 {
+					tally.Time = bucket
+				}
+				tallies[key] = tally
+			}
+			if uint64(vote.VotingOn) < tally.Height {
+				tally.Height = uint64(vote.VotingOn)
+			}
+			if bucketSize == 0 && uint64(receiveTime) < tally.Time {
+				tally.Time = uint64(receiveTime)
+			}
+			tally.Count++
+		}
+	}
+	s.mtx.RUnlock()
+
+	sorted := make([]cache.AgendaTally, 0, len(tallies))
+	for _, tally := range tallies {
+		sorted = append(sorted, *tally)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Bucket != sorted[j].Bucket {
+			return sorted[i].Bucket < sorted[j].Bucket
+		}
+		return sorted[i].Choice < sorted[j].Choice
+	})
+	return cache.MakeAgendaChart(charts, sorted, cache.ParseAxis(axisString))
+}
+
+// fetchEncodeConfirmationChart returns the average wait in the mempool of the
+// transactions of each fee rate bucket or transaction type, per block for the
+// default bin.
+func (s *Store) fetchEncodeConfirmationChart(ctx context.Context, charts *cache.Manager, dataType, axisString string, binString string, _ ...string) ([]byte, error) {
+	var groups []string
+	var groupOf func(confirmation mempool.TxConfirmation) string
+	switch dataType {
+	case cache.ConfirmationFeeRate:
+		for _, feeRate := range mempool.FeeRateBuckets {
+			groups = append(groups, strconv.FormatInt(feeRate, 10))
+		}
+		groupOf = func(confirmation mempool.TxConfirmation) string {
+			return strconv.FormatInt(mempool.BucketFeeRate(confirmation.FeeRate), 10)
+		}
+	case cache.ConfirmationTxType:
+		groups = cache.ConfirmationTxTypes
+		groupOf = func(confirmation mempool.TxConfirmation) string { return confirmation.TxType }
+	default:
+		return nil, cache.UnknownChartErr
+	}
+
+	var bucketSize int64
+	switch cache.ParseBin(binString) {
+	case cache.HourBin:
+		bucketSize = cache.AnHour
+	case cache.DayBin:
+		bucketSize = cache.ADay
+	}
+
+	type latencyKey struct {
+		bucket uint64
+		group  string
+	}
+	latencies := make(map[latencyKey]*cache.ConfirmationLatency)
+	counts := make(map[latencyKey]float64)
+	s.mtx.RLock()
+	for _, confirmation := range s.confirmations {
+		minedTime := confirmation.MinedTime.Unix()
+		bucket := uint64(confirmation.BlockHeight)
+		if bucketSize > 0 {
+			bucket = uint64(minedTime - minedTime%bucketSize)
+		}
+		key := latencyKey{bucket: bucket, group: groupOf(confirmation)}
+		latency, found := latencies[key]
+		if !found {
+			latency = &cache.ConfirmationLatency{Bucket: bucket, Height: uint64(confirmation.BlockHeight),
+				Time: uint64(minedTime), Group: key.group}
+			if bucketSize > 0 {
+				latency.Time = bucket
+			}
+			latencies[key] = latency
+		}
+		if uint64(confirmation.BlockHeight) < latency.Height {
+			latency.Height = uint64(confirmation.BlockHeight)
+		}
+		if bucketSize == 0 && uint64(minedTime) < latency.Time {
+			latency.Time = uint64(minedTime)
+		}
+		latency.Wait += confirmation.WaitTime
+		counts[key]++
+	}
+	s.mtx.RUnlock()
+
+	sorted := make([]cache.ConfirmationLatency, 0, len(latencies))
+	for key, latency := range latencies {
+		latency.Wait /= counts[key]
+		sorted = append(sorted, *latency)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Bucket != sorted[j].Bucket {
+			return sorted[i].Bucket < sorted[j].Bucket
+		}
+		return sorted[i].Group < sorted[j].Group
+	})
+	return cache.MakeConfirmationChart(charts, groups, sorted, cache.ParseAxis(axisString))
+}
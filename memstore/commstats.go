@@ -0,0 +1,265 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/commstats"
+	"github.com/planetdecred/dcrextdata/postgres/models"
+)
+
+// commStatRow is a community stat as a row of its postgres table
+type commStatRow struct {
+	date    time.Time
+	columns map[string]interface{}
+}
+
+func (s *Store) StoreRedditStat(ctx context.Context, stat commstats.Reddit) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, existing := range s.reddit {
+		if existing.Date.Equal(stat.Date) && existing.Subreddit == stat.Subreddit {
+			return nil
+		}
+	}
+	s.reddit = append(s.reddit, stat)
+	return s.putCommStat(models.TableNames.Reddit, stat.Date, stat.Subreddit, stat)
+}
+
+func (s *Store) LastCommStatEntry() (last time.Time) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	for _, stat := range s.reddit {
+		if stat.Date.After(last) {
+			last = stat.Date
+		}
+	}
+	return
+}
+
+func (s *Store) CountRedditStat(ctx context.Context, subreddit string) (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	var count int64
+	for _, stat := range s.reddit {
+		if stat.Subreddit == subreddit {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) RedditStats(ctx context.Context, subreddit string, offtset int, limit int) ([]commstats.Reddit, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var stats []commstats.Reddit
+	for _, stat := range s.reddit {
+		if stat.Subreddit == subreddit {
+			stats = append(stats, stat)
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Date.After(stats[j].Date) })
+	start, end := page(len(stats), offtset, limit)
+	return stats[start:end], nil
+}
+
+func (s *Store) StoreTwitterStat(ctx context.Context, twitter commstats.Twitter) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, existing := range s.twitter {
+		if existing.Date.Equal(twitter.Date) && existing.Handle == twitter.Handle {
+			return nil
+		}
+	}
+	s.twitter = append(s.twitter, twitter)
+	return s.putCommStat(models.TableNames.Twitter, twitter.Date, twitter.Handle, twitter)
+}
+
+func (s *Store) CountTwitterStat(ctx context.Context, handle string) (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	var count int64
+	for _, stat := range s.twitter {
+		if stat.Handle == handle {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) TwitterStats(ctx context.Context, handle string, offtset int, limit int) ([]commstats.Twitter, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var stats []commstats.Twitter
+	for _, stat := range s.twitter {
+		if stat.Handle == handle {
+			stats = append(stats, stat)
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Date.After(stats[j].Date) })
+	start, end := page(len(stats), offtset, limit)
+	return stats[start:end], nil
+}
+
+func (s *Store) StoreYoutubeStat(ctx context.Context, youtube commstats.Youtube) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, existing := range s.youtube {
+		if existing.Date.Equal(youtube.Date) && existing.Channel == youtube.Channel {
+			return nil
+		}
+	}
+	s.youtube = append(s.youtube, youtube)
+	return s.putCommStat(models.TableNames.Youtube, youtube.Date, youtube.Channel, youtube)
+}
+
+func (s *Store) CountYoutubeStat(ctx context.Context, channel string) (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	var count int64
+	for _, stat := range s.youtube {
+		if stat.Channel == channel {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) YoutubeStat(ctx context.Context, channel string, offtset int, limit int) ([]commstats.Youtube, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var stats []commstats.Youtube
+	for _, stat := range s.youtube {
+		if stat.Channel == channel {
+			stats = append(stats, stat)
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Date.After(stats[j].Date) })
+	start, end := page(len(stats), offtset, limit)
+	return stats[start:end], nil
+}
+
+func (s *Store) StoreGithubStat(ctx context.Context, github commstats.Github) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, existing := range s.github {
+		if existing.Date.Equal(github.Date) && existing.Repository == github.Repository {
+			return nil
+		}
+	}
+	s.github = append(s.github, github)
+	return s.putCommStat(models.TableNames.Github, github.Date, github.Repository, github)
+}
+
+func (s *Store) CountGithubStat(ctx context.Context, repository string) (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	var count int64
+	for _, stat := range s.github {
+		if stat.Repository == repository {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) GithubStat(ctx context.Context, repository string, offtset int, limit int) ([]commstats.Github, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var stats []commstats.Github
+	for _, stat := range s.github {
+		if stat.Repository == repository {
+			stats = append(stats, stat)
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Date.After(stats[j].Date) })
+	start, end := page(len(stats), offtset, limit)
+	return stats[start:end], nil
+}
+
+// commStatRows returns the stats of platform, a postgres table name, as rows
+// keyed by column name.
+func (s *Store) commStatRows(platform string) ([]commStatRow, error) {
+	var rows []commStatRow
+	switch platform {
+	case models.TableNames.Reddit:
+		for _, stat := range s.reddit {
+			rows = append(rows, commStatRow{date: stat.Date, columns: map[string]interface{}{
+				models.RedditColumns.Subscribers:    stat.Subscribers,
+				models.RedditColumns.ActiveAccounts: stat.AccountsActive,
+				models.RedditColumns.Subreddit:      stat.Subreddit,
+			}})
+		}
+	case models.TableNames.Twitter:
+		for _, stat := range s.twitter {
+			rows = append(rows, commStatRow{date: stat.Date, columns: map[string]interface{}{
+				models.TwitterColumns.Followers: stat.Followers,
+				models.TwitterColumns.Handle:    stat.Handle,
+			}})
+		}
+	case models.TableNames.Youtube:
+		for _, stat := range s.youtube {
+			rows = append(rows, commStatRow{date: stat.Date, columns: map[string]interface{}{
+				models.YoutubeColumns.Subscribers: stat.Subscribers,
+				models.YoutubeColumns.ViewCount:   stat.ViewCount,
+				models.YoutubeColumns.Channel:     stat.Channel,
+			}})
+		}
+	case models.TableNames.Github:
+		for _, stat := range s.github {
+			rows = append(rows, commStatRow{date: stat.Date, columns: map[string]interface{}{
+				models.GithubColumns.Stars:      stat.Stars,
+				models.GithubColumns.Folks:      stat.Folks,
+				models.GithubColumns.Repository: stat.Repository,
+			}})
+		}
+	default:
+		return nil, fmt.Errorf("unknown platform, %s", platform)
+	}
+	return rows, nil
+}
+
+// CommunityChart returns the dataType column of the platform table over time.
+// The filter values may be quoted the way the postgres store expects them.
+func (s *Store) CommunityChart(ctx context.Context, platform string, dataType string, filters map[string]string) ([]commstats.ChartData, error) {
+	dataType = strings.ToLower(dataType)
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	rows, err := s.commStatRows(platform)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].date.Before(rows[j].date) })
+
+	var stats []commstats.ChartData
+	for _, row := range rows {
+		record, found := row.columns[dataType].(int)
+		if !found {
+			return nil, fmt.Errorf("unknown %s data type, %s", platform, dataType)
+		}
+		matches := true
+		for attribute, value := range filters {
+			if fmt.Sprint(row.columns[attribute]) != strings.Trim(value, "'") {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			stats = append(stats, commstats.ChartData{Date: row.date, Record: int64(record)})
+		}
+	}
+	return stats, nil
+}
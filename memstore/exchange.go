@@ -0,0 +1,599 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/postgres/models"
+)
+
+type exchange struct {
+	ID   int
+	Name string
+	URL  string
+}
+
+type exchangeTick struct {
+	ticks.Tick
+	ID           int
+	ExchangeID   int
+	Interval     int
+	CurrencyPair string
+}
+
+// exchangeTickKey identifies the tick of a series at a time, in nanoseconds.
+type exchangeTickKey struct {
+	exchangeID   int
+	interval     int
+	currencyPair string
+	time         int64
+}
+
+func (tick exchangeTick) key() exchangeTickKey {
+	return exchangeTickKey{tick.ExchangeID, tick.Interval, tick.CurrencyPair, tick.Time.UnixNano()}
+}
+
+func (s *Store) ExchangeTableName() string {
+	return models.TableNames.Exchange
+}
+
+func (s *Store) ExchangeTickTableName() string {
+	return models.TableNames.ExchangeTick
+}
+
+func (s *Store) exchangeByName(name string) (exchange, bool) {
+	for _, xch := range s.exchanges {
+		if xch.Name == name {
+			return xch, true
+		}
+	}
+	return exchange{}, false
+}
+
+func (s *Store) exchangeName(id int) string {
+	for _, xch := range s.exchanges {
+		if xch.ID == id {
+			return xch.Name
+		}
+	}
+	return ""
+}
+
+func (s *Store) nextExchangeID() int {
+	if len(s.exchanges) == 0 {
+		return 1
+	}
+	return s.exchanges[len(s.exchanges)-1].ID + 1
+}
+
+func (s *Store) RegisterExchange(ctx context.Context, exchangeData ticks.ExchangeData) (time.Time, time.Time, time.Time, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	xch, found := s.exchangeByName(exchangeData.Name)
+	if !found {
+		xch = exchange{
+			ID:   s.nextExchangeID(),
+			Name: exchangeData.Name,
+			URL:  exchangeData.WebsiteURL,
+		}
+		s.exchanges = append(s.exchanges, xch)
+		return time.Time{}, time.Time{}, time.Time{}, s.putExchange(xch)
+	}
+
+	lastTime := func(interval time.Duration) (last time.Time) {
+		for _, tick := range s.exchangeTicks {
+			if tick.ExchangeID == xch.ID && tick.Interval == int(interval.Minutes()) && tick.Time.After(last) {
+				last = tick.Time
+			}
+		}
+		return
+	}
+	return lastTime(exchangeData.ShortInterval), lastTime(exchangeData.LongInterval),
+		lastTime(exchangeData.HistoricInterval), nil
+}
+
+func (s *Store) StoreExchangeTicks(ctx context.Context, name string, interval int, pair string, data []ticks.Tick) (time.Time, error) {
+	if len(data) == 0 {
+		return time.Time{}, fmt.Errorf("No ticks received for %s", name)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	xch, found := s.exchangeByName(name)
+	if !found {
+		return time.Time{}, fmt.Errorf("exchange %s is not registered", name)
+	}
+
+	var lastTime time.Time
+	added := 0
+	for _, tick := range data {
+		tick.Time = tick.Time.UTC()
+		lastTime = tick.Time
+		if s.hasExchangeTick(xch.ID, interval, pair, tick.Time) {
+			continue
+		}
+		err := s.addExchangeTick(exchangeTick{
+			Tick:         tick,
+			ExchangeID:   xch.ID,
+			Interval:     interval,
+			CurrencyPair: pair,
+		})
+		if err != nil {
+			return lastTime, err
+		}
+		added++
+	}
+
+	if added > 0 {
+		// the exchange index is computed from the ticks
+		s.charts.Invalidate(cache.Exchange, cache.ExchangeIdx)
+		log.Infof("%-9s %7s, received %6dm ticks, storing %6v entries %s to %s", name, pair,
+			interval, added, data[0].Time.Format(dateTemplate), lastTime.Format(dateTemplate))
+	}
+	return lastTime, nil
+}
+
+func (s *Store) hasExchangeTick(exchangeID, interval int, pair string, tickTime time.Time) bool {
+	_, found := s.exchangeTickKeys[exchangeTickKey{exchangeID, interval, pair, tickTime.UnixNano()}]
+	return found
+}
+
+// indexExchangeTick appends tick to the stored ticks and their index.
+func (s *Store) indexExchangeTick(tick exchangeTick) {
+	s.exchangeTicks = append(s.exchangeTicks, tick)
+	s.exchangeTickKeys[tick.key()] = struct{}{}
+}
+
+func (s *Store) addExchangeTick(tick exchangeTick) error {
+	if tick.ID == 0 {
+		tick.ID = 1
+		if n := len(s.exchangeTicks); n > 0 {
+			tick.ID = s.exchangeTicks[n-1].ID + 1
+		}
+	}
+	s.indexExchangeTick(tick)
+	return s.putExchangeTick(tick)
+}
+
+func (s *Store) SaveExchangeFromSync(ctx context.Context, exchangeData interface{}) error {
+	xch := exchangeData.(ticks.ExchangeData)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, found := s.exchangeByName(xch.Name); found {
+		return nil
+	}
+	newExchange := exchange{
+		ID:   xch.ID,
+		Name: xch.Name,
+		URL:  xch.WebsiteURL,
+	}
+	s.exchanges = append(s.exchanges, newExchange)
+	sort.Slice(s.exchanges, func(i, j int) bool { return s.exchanges[i].ID < s.exchanges[j].ID })
+	return s.putExchange(newExchange)
+}
+
+func (s *Store) SaveExchangeTickFromSync(ctx context.Context, tickData interface{}) error {
+	tick := tickData.(ticks.TickSyncDto)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.hasExchangeTick(tick.ExchangeID, tick.Interval, tick.CurrencyPair, tick.Time) {
+		return nil
+	}
+	s.charts.Invalidate(cache.Exchange, cache.ExchangeIdx)
+	return s.addExchangeTick(exchangeTick{
+		Tick: ticks.Tick{
+			High:   tick.High,
+			Low:    tick.Low,
+			Open:   tick.Open,
+			Close:  tick.Close,
+			Volume: tick.Volume,
+			Time:   tick.Time.UTC(),
+		},
+		ID:           tick.ID,
+		ExchangeID:   tick.ExchangeID,
+		Interval:     tick.Interval,
+		CurrencyPair: tick.CurrencyPair,
+	})
+}
+
+// AllExchange returns every exchange but bluetrade
+func (s *Store) AllExchange(ctx context.Context) (models.ExchangeSlice, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var exchanges models.ExchangeSlice
+	for _, xch := range s.exchanges {
+		if xch.Name == "bluetrade" {
+			continue
+		}
+		exchanges = append(exchanges, &models.Exchange{ID: xch.ID, Name: xch.Name, URL: xch.URL})
+	}
+	return exchanges, nil
+}
+
+func (s *Store) FetchExchangeForSync(ctx context.Context, lastID int, skip, take int) ([]ticks.ExchangeData, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var exchanges []ticks.ExchangeData
+	for _, xch := range s.exchanges {
+		if xch.ID > lastID {
+			exchanges = append(exchanges, ticks.ExchangeData{ID: xch.ID, Name: xch.Name, WebsiteURL: xch.URL})
+		}
+	}
+	start, end := page(len(exchanges), skip, take)
+	return exchanges[start:end], int64(len(exchanges)), nil
+}
+
+func (s *Store) LastExchangeTickEntryTime() (last time.Time) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	for _, tick := range s.exchangeTicks {
+		if tick.Time.After(last) {
+			last = tick.Time
+		}
+	}
+	return
+}
+
+func (s *Store) LastExchangeEntryID() (id int64) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if len(s.exchanges) > 0 {
+		id = int64(s.exchanges[len(s.exchanges)-1].ID)
+	}
+	return
+}
+
+func (s *Store) ExchangeTickCount(ctx context.Context) (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return int64(len(s.exchangeTicks)), nil
+}
+
+// filterExchangeTicks returns the ticks matching keep, most recent first.
+func (s *Store) filterExchangeTicks(keep func(exchangeTick) bool) []exchangeTick {
+	var result []exchangeTick
+	for _, tick := range s.exchangeTicks {
+		if keep(tick) {
+			result = append(result, tick)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Time.After(result[j].Time) })
+	return result
+}
+
+func (s *Store) tickDtoPage(tickSlice []exchangeTick, offset, limit int) []ticks.TickDto {
+	start, end := page(len(tickSlice), offset, limit)
+	tickDtos := []ticks.TickDto{}
+	for _, tick := range tickSlice[start:end] {
+		tickDtos = append(tickDtos, ticks.TickDto{
+			ExchangeID:   tick.ExchangeID,
+			Interval:     tick.Interval,
+			CurrencyPair: tick.CurrencyPair,
+			Time:         tick.Time.Format(dateTemplate),
+			Close:        tick.Close,
+			ExchangeName: s.exchangeName(tick.ExchangeID),
+			High:         tick.High,
+			Low:          tick.Low,
+			Open:         tick.Open,
+			Volume:       tick.Volume,
+		})
+	}
+	return tickDtos
+}
+
+// exchangeFilter returns the ID of the named exchange, 0 for all exchanges.
+func (s *Store) exchangeFilter(name string) (int, error) {
+	if name == "All" || name == "" {
+		return 0, nil
+	}
+	xch, found := s.exchangeByName(name)
+	if !found {
+		return 0, fmt.Errorf("exchange %s not found", name)
+	}
+	return xch.ID, nil
+}
+
+func (s *Store) FetchExchangeTicks(ctx context.Context, currencyPair, name string, interval, offset, limit int) ([]ticks.TickDto, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	exchangeID, err := s.exchangeFilter(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	tickSlice := s.filterExchangeTicks(func(tick exchangeTick) bool {
+		return (exchangeID == 0 || tick.ExchangeID == exchangeID) &&
+			(currencyPair == "" || currencyPair == "All" || tick.CurrencyPair == currencyPair) &&
+			(interval <= 0 || tick.Interval == interval)
+	})
+	return s.tickDtoPage(tickSlice, offset, limit), int64(len(tickSlice)), nil
+}
+
+func (s *Store) AllExchangeTicks(ctx context.Context, currencyPair string, interval, offset, limit int) ([]ticks.TickDto, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	tickSlice := s.filterExchangeTicks(func(tick exchangeTick) bool {
+		return (currencyPair == "" || tick.CurrencyPair == currencyPair) &&
+			(interval == -1 || tick.Interval == interval)
+	})
+	return s.tickDtoPage(tickSlice, offset, limit), int64(len(tickSlice)), nil
+}
+
+func (s *Store) currencyPairs(exchangeID int) []ticks.TickDtoCP {
+	seen := make(map[string]bool)
+	currencyPairs := []ticks.TickDtoCP{}
+	for _, tick := range s.exchangeTicks {
+		if (exchangeID == 0 || tick.ExchangeID == exchangeID) && !seen[tick.CurrencyPair] {
+			seen[tick.CurrencyPair] = true
+			currencyPairs = append(currencyPairs, ticks.TickDtoCP{CurrencyPair: tick.CurrencyPair})
+		}
+	}
+	sort.Slice(currencyPairs, func(i, j int) bool { return currencyPairs[i].CurrencyPair < currencyPairs[j].CurrencyPair })
+	return currencyPairs
+}
+
+func (s *Store) AllExchangeTicksCurrencyPair(ctx context.Context) ([]ticks.TickDtoCP, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.currencyPairs(0), nil
+}
+
+func (s *Store) CurrencyPairByExchange(ctx context.Context, exchangeName string) ([]ticks.TickDtoCP, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	exchangeID, err := s.exchangeFilter(exchangeName)
+	if err != nil {
+		return nil, err
+	}
+	return s.currencyPairs(exchangeID), nil
+}
+
+func (s *Store) intervals(keep func(exchangeTick) bool) []ticks.TickDtoInterval {
+	seen := make(map[int]bool)
+	intervals := []ticks.TickDtoInterval{}
+	for _, tick := range s.exchangeTicks {
+		if keep(tick) && !seen[tick.Interval] {
+			seen[tick.Interval] = true
+			intervals = append(intervals, ticks.TickDtoInterval{Interval: tick.Interval})
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Interval < intervals[j].Interval })
+	return intervals
+}
+
+func (s *Store) AllExchangeTicksInterval(ctx context.Context) ([]ticks.TickDtoInterval, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.intervals(func(exchangeTick) bool { return true }), nil
+}
+
+func (s *Store) TickIntervalsByExchangeAndPair(ctx context.Context, exchangeName string, currencyPair string) ([]ticks.TickDtoInterval, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	exchangeID, err := s.exchangeFilter(exchangeName)
+	if err != nil {
+		return nil, err
+	}
+	return s.intervals(func(tick exchangeTick) bool {
+		return (exchangeID == 0 || tick.ExchangeID == exchangeID) && tick.CurrencyPair == currencyPair
+	}), nil
+}
+
+func (s *Store) ExchangeTicksChartData(ctx context.Context, selectedTick string, currencyPair string, selectedInterval int, source string) ([]ticks.TickChartData, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	xch, found := s.exchangeByName(source)
+	if !found {
+		return nil, fmt.Errorf("The selected exchange, %s does not exist", source)
+	}
+
+	var tickSlice []exchangeTick
+	for _, tick := range s.exchangeTicks {
+		if tick.ExchangeID == xch.ID && tick.CurrencyPair == currencyPair &&
+			(selectedInterval == -1 || tick.Interval == selectedInterval) {
+			tickSlice = append(tickSlice, tick)
+		}
+	}
+	sort.SliceStable(tickSlice, func(i, j int) bool { return tickSlice[i].Time.Before(tickSlice[j].Time) })
+
+	tickChart := []ticks.TickChartData{}
+	for _, tick := range tickSlice {
+		var filter float64
+		switch selectedTick {
+		case "high":
+			filter = tick.High
+		case "low":
+			filter = tick.Low
+		case "open":
+			filter = tick.Open
+		case "Volume":
+			filter = tick.Volume
+		default:
+			filter = tick.Close
+		}
+		tickChart = append(tickChart, ticks.TickChartData{Time: tick.Time, Filter: filter})
+	}
+	return tickChart, nil
+}
+
+// ExchangeTickSeries returns every stored exchange, currency pair and interval
+// combination
+func (s *Store) ExchangeTickSeries(ctx context.Context) ([]ticks.TickSeries, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	seen := make(map[ticks.TickSeries]bool)
+	var series []ticks.TickSeries
+	for _, tick := range s.exchangeTicks {
+		ts := ticks.TickSeries{
+			Exchange:     s.exchangeName(tick.ExchangeID),
+			CurrencyPair: tick.CurrencyPair,
+			Interval:     tick.Interval,
+		}
+		if !seen[ts] {
+			seen[ts] = true
+			series = append(series, ts)
+		}
+	}
+	return series, nil
+}
+
+// ExchangeTickTimes returns the sorted candle times of series
+func (s *Store) ExchangeTickTimes(ctx context.Context, series ticks.TickSeries) ([]time.Time, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	xch, found := s.exchangeByName(series.Exchange)
+	if !found {
+		return nil, nil
+	}
+	var times []time.Time
+	for _, tick := range s.exchangeTicks {
+		if tick.ExchangeID == xch.ID && tick.CurrencyPair == series.CurrencyPair && tick.Interval == series.Interval {
+			times = append(times, tick.Time)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times, nil
+}
+
+// UpdateExchangeIndex does nothing, the index is computed from the ticks when
+// it is requested.
+func (s *Store) UpdateExchangeIndex(ctx context.Context) error {
+	return nil
+}
+
+// intervalPrice accumulates the ticks of an exchange within an index bin,
+// keeping only the ticks of the smallest candle interval.
+type intervalPrice struct {
+	interval int
+	weighted float64
+	volume   float64
+	sum      float64
+	count    int
+}
+
+func (p *intervalPrice) add(interval int, close, volume float64) {
+	if p.count > 0 && interval > p.interval {
+		return
+	}
+	if p.count > 0 && interval < p.interval {
+		*p = intervalPrice{}
+	}
+	p.interval = interval
+	p.weighted += close * volume
+	p.volume += volume
+	p.sum += close
+	p.count++
+}
+
+func (p *intervalPrice) price() float64 {
+	if p.volume == 0 {
+		return p.sum / float64(p.count)
+	}
+	return p.weighted / p.volume
+}
+
+// ExchangeIndex returns a page of the index of the given currency pair, most
+// recent first.
+func (s *Store) ExchangeIndex(ctx context.Context, currencyPair, binString string, offset, limit int) ([]ticks.IndexPrice, int64, error) {
+	if currencyPair != ticks.IndexBTCPair && currencyPair != ticks.IndexUSDPair {
+		return []ticks.IndexPrice{}, 0, nil
+	}
+	var binSize int64 = cache.AnHour
+	if cache.ParseBin(binString) == cache.DayBin {
+		binSize = cache.ADay
+	}
+	maxInterval := int(binSize / 60)
+	usdExchange, usdPair := ticks.IndexUSDSource()
+
+	s.mtx.RLock()
+	btcPrices := make(map[int64]map[int]*intervalPrice)
+	usdPrices := make(map[int64]*intervalPrice)
+	var usdExchangeID int
+	if xch, found := s.exchangeByName(usdExchange); found {
+		usdExchangeID = xch.ID
+	}
+	for _, tick := range s.exchangeTicks {
+		if tick.Interval > maxInterval {
+			continue
+		}
+		binTime := tick.Time.Unix() - tick.Time.Unix()%binSize
+		switch {
+		case tick.CurrencyPair == usdPair && tick.ExchangeID == usdExchangeID:
+			if usdPrices[binTime] == nil {
+				usdPrices[binTime] = new(intervalPrice)
+			}
+			usdPrices[binTime].add(tick.Interval, tick.Close, tick.Volume)
+		case tick.CurrencyPair == ticks.IndexBTCPair:
+			if btcPrices[binTime] == nil {
+				btcPrices[binTime] = make(map[int]*intervalPrice)
+			}
+			if btcPrices[binTime][tick.ExchangeID] == nil {
+				btcPrices[binTime][tick.ExchangeID] = new(intervalPrice)
+			}
+			btcPrices[binTime][tick.ExchangeID].add(tick.Interval, tick.Close, tick.Volume)
+		}
+	}
+	s.mtx.RUnlock()
+
+	var binTimes []int64
+	for binTime := range btcPrices {
+		binTimes = append(binTimes, binTime)
+	}
+	sort.Slice(binTimes, func(i, j int) bool { return binTimes[i] < binTimes[j] })
+
+	var index []ticks.IndexPrice
+	var usdRate float64
+	for _, binTime := range binTimes {
+		var prices []ticks.ExchangePrice
+		for exchangeID, p := range btcPrices[binTime] {
+			prices = append(prices, ticks.ExchangePrice{Exchange: fmt.Sprint(exchangeID), Price: p.price(), Volume: p.volume})
+		}
+		price, volume, count, ok := ticks.VolumeWeightedIndex(prices, ticks.IndexMaxDeviation)
+		if !ok {
+			continue
+		}
+		if p, found := usdPrices[binTime]; found {
+			usdRate = p.price()
+		}
+		if currencyPair == ticks.IndexUSDPair {
+			if usdRate == 0 {
+				continue
+			}
+			price *= usdRate
+		}
+		index = append(index, ticks.IndexPrice{
+			Time:          time.Unix(binTime, 0).UTC(),
+			CurrencyPair:  currencyPair,
+			Price:         price,
+			Volume:        volume,
+			ExchangeCount: count,
+		})
+	}
+
+	// most recent first
+	for i, j := 0, len(index)-1; i < j; i, j = i+1, j-1 {
+		index[i], index[j] = index[j], index[i]
+	}
+	start, end := page(len(index), offset, limit)
+	return index[start:end], int64(len(index)), nil
+}
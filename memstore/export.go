@@ -0,0 +1,161 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/export"
+	"github.com/planetdecred/dcrextdata/postgres/models"
+)
+
+var _ export.Source = (*Store)(nil)
+
+// exportRow is a row of an exported table and the time it is ordered by.
+type exportRow struct {
+	time   time.Time
+	values []interface{}
+}
+
+// ExportTable writes the rows of table in timeRange to rows, with the columns
+// of the postgres table. The rows are copied under the lock and written once
+// it is released, so that a slow reader does not hold up the collectors.
+func (s *Store) ExportTable(ctx context.Context, table export.Table, timeRange export.Range, rows export.RowWriter) error {
+	s.mtx.RLock()
+	columns, records, err := s.exportRows(table.Name, timeRange)
+	s.mtx.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].time.Before(records[j].time) })
+	if err = rows.Columns(columns); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = rows.Row(record.values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportRows returns the columns and the rows of table in timeRange. The
+// caller must hold the lock.
+func (s *Store) exportRows(table string, timeRange export.Range) ([]string, []exportRow, error) {
+	var columns []string
+	var records []exportRow
+	add := func(t time.Time, values ...interface{}) {
+		if timeRange.Contains(t) {
+			records = append(records, exportRow{time: t, values: values})
+		}
+	}
+
+	switch table {
+	case models.TableNames.ExchangeTick:
+		c := models.ExchangeTickColumns
+		columns = []string{c.ID, c.ExchangeID, c.Interval, c.High, c.Low, c.Open, c.Close, c.Volume, c.CurrencyPair, c.Time}
+		for _, tick := range s.exchangeTicks {
+			add(tick.Time, tick.ID, tick.ExchangeID, tick.Interval, tick.High, tick.Low, tick.Open, tick.Close,
+				tick.Volume, tick.CurrencyPair, tick.Time)
+		}
+	case models.TableNames.VSPTick:
+		c := models.VSPTickColumns
+		columns = []string{c.ID, c.VSPID, c.Immature, c.Live, c.Voted, c.Missed, c.PoolFees, c.ProportionLive,
+			c.ProportionMissed, c.UserCount, c.UsersActive, c.Time}
+		for _, tick := range s.vspTicks {
+			add(tick.Time, tick.ID, tick.VSPID, tick.Immature, tick.Live, tick.Voted, tick.Missed, tick.PoolFees,
+				tick.ProportionLive, tick.ProportionMissed, tick.UserCount, tick.UsersActive, tick.Time)
+		}
+	case models.TableNames.PowData:
+		c := models.PowDatumColumns
+		columns = []string{c.Time, c.PoolHashrate, c.Workers, c.CoinPrice, c.BTCPrice, c.Source}
+		for _, data := range s.powData {
+			add(time.Unix(data.Time, 0), data.Time, data.PoolHashrate, data.Workers, data.CoinPrice, data.BtcPrice,
+				data.Source)
+		}
+	case models.TableNames.Mempool:
+		c := models.MempoolColumns
+		columns = []string{c.Time, c.FirstSeenTime, c.NumberOfTransactions, c.Voters, c.Tickets, c.Revocations,
+			c.Size, c.TotalFee, c.Total}
+		for _, m := range s.mempools {
+			add(m.Time, m.Time, m.FirstSeenTime, m.NumberOfTransactions, m.Voters, m.Tickets, m.Revocations, m.Size,
+				m.TotalFee, m.Total)
+		}
+	case models.TableNames.Block:
+		c := models.BlockColumns
+		columns = []string{c.Height, c.ReceiveTime, c.InternalTimestamp, c.Hash}
+		for _, block := range s.blocks {
+			add(block.BlockReceiveTime, block.BlockHeight, block.BlockReceiveTime, block.BlockInternalTime,
+				block.BlockHash)
+		}
+	case models.TableNames.Vote:
+		c := models.VoteColumns
+		columns = []string{c.Hash, c.VotingOn, c.BlockHash, c.ReceiveTime, c.BlockReceiveTime, c.TargetedBlockTime,
+			c.ValidatorID, c.Validity}
+		for _, vote := range s.votes {
+			add(vote.ReceiveTime, vote.Hash, vote.VotingOn, vote.BlockHash, vote.ReceiveTime, vote.BlockReceiveTime,
+				vote.TargetedBlockTime, vote.ValidatorId, vote.Validity)
+		}
+	case models.TableNames.Reddit:
+		c := models.RedditColumns
+		columns = []string{c.Date, c.Subscribers, c.ActiveAccounts, c.Subreddit}
+		for _, stat := range s.reddit {
+			add(stat.Date, stat.Date, stat.Subscribers, stat.AccountsActive, stat.Subreddit)
+		}
+	case models.TableNames.Twitter:
+		c := models.TwitterColumns
+		columns = []string{c.Date, c.Followers, c.Handle}
+		for _, stat := range s.twitter {
+			add(stat.Date, stat.Date, stat.Followers, stat.Handle)
+		}
+	case models.TableNames.Github:
+		c := models.GithubColumns
+		columns = []string{c.Date, c.Stars, c.Folks, c.Repository}
+		for _, stat := range s.github {
+			add(stat.Date, stat.Date, stat.Stars, stat.Folks, stat.Repository)
+		}
+	case models.TableNames.Youtube:
+		c := models.YoutubeColumns
+		columns = []string{c.Date, c.Subscribers, c.ViewCount, c.Channel}
+		for _, stat := range s.youtube {
+			add(stat.Date, stat.Date, stat.Subscribers, stat.ViewCount, stat.Channel)
+		}
+	case models.TableNames.NetworkSnapshot:
+		c := models.NetworkSnapshotColumns
+		columns = []string{c.Timestamp, c.Height, c.NodeCount, c.ReachableNodes, c.OldestNode, c.OldestNodeTimestamp,
+			c.Latency}
+		for _, snapshot := range s.snapshots {
+			add(time.Unix(snapshot.Timestamp, 0), snapshot.Timestamp, snapshot.Height, snapshot.NodeCount,
+				snapshot.ReachableNodeCount, snapshot.OldestNode, snapshot.OldestNodeTimestamp, snapshot.Latency)
+		}
+	case models.TableNames.Node:
+		c := models.NodeColumns
+		columns = []string{c.Address, c.IPVersion, c.Country, c.Region, c.City, c.Zip, c.LastAttempt, c.LastSeen,
+			c.LastSuccess, c.FailureCount, c.IsDead, c.ConnectionTime, c.ProtocolVersion, c.UserAgent, c.Services,
+			c.StartingHeight, c.CurrentHeight}
+		for _, n := range s.nodes {
+			add(time.Unix(n.LastSeen, 0), n.Address, n.IPVersion, n.CountryName, n.RegionName, n.City, n.Zip,
+				n.LastAttempt, n.LastSeen, n.LastSuccess, n.failureCount, n.IsDead, n.ConnectionTime,
+				n.ProtocolVersion, n.UserAgent, n.Services, n.StartingHeight, n.CurrentHeight)
+		}
+	case models.TableNames.Heartbeat:
+		c := models.HeartbeatColumns
+		columns = []string{c.Timestamp, c.NodeID, c.LastSeen, c.Latency, c.CurrentHeight}
+		for _, heartbeat := range s.heartbeats {
+			add(time.Unix(heartbeat.Timestamp, 0), heartbeat.Timestamp, heartbeat.Address, heartbeat.LastSeen,
+				heartbeat.Latency, heartbeat.CurrentHeight)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown table, %s", table)
+	}
+	return columns, records, nil
+}
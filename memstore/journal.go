@@ -0,0 +1,328 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/commstats"
+	"github.com/planetdecred/dcrextdata/datasync"
+	"github.com/planetdecred/dcrextdata/exchanges/orderbook"
+	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/planetdecred/dcrextdata/netsnapshot"
+	"github.com/planetdecred/dcrextdata/postgres/models"
+	"github.com/planetdecred/dcrextdata/pow"
+	"github.com/planetdecred/dcrextdata/vsp"
+	"github.com/planetdecred/dcrextdata/web"
+)
+
+// Journal persists the changes made to a Store. Put receives the JSON encoding
+// of every record that is added or updated and Delete the key of every record
+// that is removed. The keys of a table sort in the order the records are kept
+// in, so that replaying them with Restore in key order rebuilds the Store.
+type Journal interface {
+	Put(table, key string, record []byte) error
+	Delete(table, key string) error
+}
+
+// journalNode is a node as written to the journal.
+type journalNode struct {
+	netsnapshot.NetworkPeer
+	FailureCount int
+}
+
+// journalJobRun is the last run of a scheduled job as written to the journal.
+type journalJobRun struct {
+	Name    string
+	LastRun time.Time
+}
+
+// SetJournal makes the store write its changes to journal. It should be called
+// after the existing records have been restored.
+func (s *Store) SetJournal(journal Journal) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.journal = journal
+}
+
+func numberKey(n int64) string {
+	return fmt.Sprintf("%020d", n)
+}
+
+func timeKey(t time.Time, parts ...string) string {
+	key := numberKey(t.UnixNano())
+	for _, part := range parts {
+		key += "/" + part
+	}
+	return key
+}
+
+// put writes record to the journal, if any. The caller must hold the lock.
+func (s *Store) put(table, key string, record interface{}) error {
+	if s.journal == nil {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("cannot encode %s record %s, %s", table, key, err.Error())
+	}
+	return s.journal.Put(table, key, data)
+}
+
+func (s *Store) remove(table, key string) error {
+	if s.journal == nil {
+		return nil
+	}
+	return s.journal.Delete(table, key)
+}
+
+func (s *Store) putExchange(xch exchange) error {
+	return s.put(models.TableNames.Exchange, numberKey(int64(xch.ID)), xch)
+}
+
+func (s *Store) putExchangeTick(tick exchangeTick) error {
+	return s.put(models.TableNames.ExchangeTick, numberKey(int64(tick.ID)), tick)
+}
+
+func (s *Store) putOrderBookSnapshot(snapshot orderbook.Snapshot) error {
+	return s.put(orderBookSnapshotTableName, timeKey(snapshot.Time, snapshot.Exchange, snapshot.CurrencyPair), snapshot)
+}
+
+func (s *Store) putPowData(data pow.PowData) error {
+	return s.put(models.TableNames.PowData, numberKey(data.Time)+"/"+data.Source, data)
+}
+
+func (s *Store) putVsp(pool vsp.VSPDto) error {
+	return s.put(models.TableNames.VSP, numberKey(int64(pool.ID)), pool)
+}
+
+func (s *Store) putVspTick(tick datasync.VSPTickSyncDto) error {
+	return s.put(models.TableNames.VSPTick, numberKey(int64(tick.ID)), tick)
+}
+
+func (s *Store) putMempool(m mempool.Mempool) error {
+	return s.put(models.TableNames.Mempool, timeKey(m.Time), m)
+}
+
+func (s *Store) putBlock(block mempool.Block) error {
+	return s.put(models.TableNames.Block, numberKey(int64(block.BlockHeight)), block)
+}
+
+func (s *Store) putVote(vote mempool.Vote) error {
+	return s.put(models.TableNames.Vote, timeKey(vote.ReceiveTime, vote.Hash), vote)
+}
+
+func (s *Store) putVoteVersion(vote mempool.VoteVersion) error {
+	return s.put(voteVersionTableName, timeKey(vote.ReceiveTime, vote.Hash), vote)
+}
+
+func (s *Store) putTxConfirmation(confirmation mempool.TxConfirmation) error {
+	return s.put(txConfirmationTableName, timeKey(confirmation.MinedTime, confirmation.Hash), confirmation)
+}
+
+func (s *Store) putOrphanedBlock(block orphanedBlock) error {
+	return s.put(orphanedBlockTableName, timeKey(block.OrphanedAt, block.BlockHash), block)
+}
+
+func (s *Store) putOrphanedVote(vote orphanedVote) error {
+	return s.put(orphanedVoteTableName, timeKey(vote.OrphanedAt, vote.Hash), vote)
+}
+
+func (s *Store) putReorg(reorg mempool.Reorg) error {
+	return s.put(reorgTableName, timeKey(reorg.Time, reorg.OldTipHash), reorg)
+}
+
+func (s *Store) putCommStat(table string, date time.Time, name string, stat interface{}) error {
+	return s.put(table, timeKey(date, name), stat)
+}
+
+func (s *Store) putSnapshot(snapshot netsnapshot.SnapShot) error {
+	return s.put(models.TableNames.NetworkSnapshot, numberKey(snapshot.Timestamp), snapshot)
+}
+
+func heartbeatKey(heartbeat netsnapshot.Heartbeat) string {
+	return numberKey(heartbeat.Timestamp) + "/" + heartbeat.Address
+}
+
+func (s *Store) putHeartbeat(heartbeat netsnapshot.Heartbeat) error {
+	return s.put(models.TableNames.Heartbeat, heartbeatKey(heartbeat), heartbeat)
+}
+
+func (s *Store) putNode(n *node) error {
+	return s.put(models.TableNames.Node, n.Address, journalNode{NetworkPeer: n.NetworkPeer, FailureCount: n.failureCount})
+}
+
+func (s *Store) putJobRun(name string, lastRun time.Time) error {
+	return s.put(jobRunTableName, name, journalJobRun{Name: name, LastRun: lastRun})
+}
+
+func (s *Store) putAPIKey(key web.APIKey) error {
+	return s.put(apiKeyTableName, key.Name, key)
+}
+
+// Restore adds a record read back from a Journal to table, without any of the
+// checks made when the record was first stored. The records of a table must be
+// restored in key order.
+func (s *Store) Restore(table string, record []byte) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var err error
+	switch table {
+	case models.TableNames.Exchange:
+		var xch exchange
+		if err = json.Unmarshal(record, &xch); err == nil {
+			s.exchanges = append(s.exchanges, xch)
+		}
+	case models.TableNames.ExchangeTick:
+		var tick exchangeTick
+		if err = json.Unmarshal(record, &tick); err == nil {
+			s.indexExchangeTick(tick)
+		}
+	case orderBookSnapshotTableName:
+		var snapshot orderbook.Snapshot
+		if err = json.Unmarshal(record, &snapshot); err == nil {
+			s.orderBookSnapshots = append(s.orderBookSnapshots, snapshot)
+		}
+	case models.TableNames.PowData:
+		var data pow.PowData
+		if err = json.Unmarshal(record, &data); err == nil {
+			s.powData = append(s.powData, data)
+		}
+	case models.TableNames.VSP:
+		var pool vsp.VSPDto
+		if err = json.Unmarshal(record, &pool); err == nil {
+			s.vsps = append(s.vsps, pool)
+		}
+	case models.TableNames.VSPTick:
+		var tick datasync.VSPTickSyncDto
+		if err = json.Unmarshal(record, &tick); err == nil {
+			s.vspTicks = append(s.vspTicks, tick)
+		}
+	case models.TableNames.Mempool:
+		var m mempool.Mempool
+		if err = json.Unmarshal(record, &m); err == nil {
+			s.mempools = append(s.mempools, m)
+		}
+	case models.TableNames.Block:
+		var block mempool.Block
+		if err = json.Unmarshal(record, &block); err == nil {
+			s.blocks = append(s.blocks, block)
+		}
+	case models.TableNames.Vote:
+		var vote mempool.Vote
+		if err = json.Unmarshal(record, &vote); err == nil {
+			s.votes = append(s.votes, vote)
+		}
+	case voteVersionTableName:
+		var vote mempool.VoteVersion
+		if err = json.Unmarshal(record, &vote); err == nil {
+			s.voteVersions = append(s.voteVersions, vote)
+		}
+	case txConfirmationTableName:
+		var confirmation mempool.TxConfirmation
+		if err = json.Unmarshal(record, &confirmation); err == nil {
+			s.confirmations = append(s.confirmations, confirmation)
+			s.confirmationHashes[confirmation.Hash] = struct{}{}
+		}
+	case orphanedBlockTableName:
+		var block orphanedBlock
+		if err = json.Unmarshal(record, &block); err == nil {
+			s.orphanedBlocks = append(s.orphanedBlocks, block)
+		}
+	case orphanedVoteTableName:
+		var vote orphanedVote
+		if err = json.Unmarshal(record, &vote); err == nil {
+			s.orphanedVotes = append(s.orphanedVotes, vote)
+		}
+	case reorgTableName:
+		var reorg mempool.Reorg
+		if err = json.Unmarshal(record, &reorg); err == nil {
+			s.reorgs = append(s.reorgs, reorg)
+		}
+	case models.TableNames.Reddit:
+		var stat commstats.Reddit
+		if err = json.Unmarshal(record, &stat); err == nil {
+			s.reddit = append(s.reddit, stat)
+		}
+	case models.TableNames.Twitter:
+		var stat commstats.Twitter
+		if err = json.Unmarshal(record, &stat); err == nil {
+			s.twitter = append(s.twitter, stat)
+		}
+	case models.TableNames.Youtube:
+		var stat commstats.Youtube
+		if err = json.Unmarshal(record, &stat); err == nil {
+			s.youtube = append(s.youtube, stat)
+		}
+	case models.TableNames.Github:
+		var stat commstats.Github
+		if err = json.Unmarshal(record, &stat); err == nil {
+			s.github = append(s.github, stat)
+		}
+	case models.TableNames.NetworkSnapshot:
+		var snapshot netsnapshot.SnapShot
+		if err = json.Unmarshal(record, &snapshot); err == nil {
+			s.snapshots = append(s.snapshots, snapshot)
+		}
+	case models.TableNames.Heartbeat:
+		var heartbeat netsnapshot.Heartbeat
+		if err = json.Unmarshal(record, &heartbeat); err == nil {
+			s.heartbeats = append(s.heartbeats, heartbeat)
+		}
+	case models.TableNames.Node:
+		var n journalNode
+		if err = json.Unmarshal(record, &n); err == nil {
+			s.nodes[n.Address] = &node{NetworkPeer: n.NetworkPeer, failureCount: n.FailureCount}
+		}
+	case jobRunTableName:
+		var run journalJobRun
+		if err = json.Unmarshal(record, &run); err == nil {
+			s.jobRuns[run.Name] = run.LastRun
+		}
+	case apiKeyTableName:
+		var key web.APIKey
+		if err = json.Unmarshal(record, &key); err == nil {
+			s.apiKeys[key.Name] = key
+		}
+	default:
+		return fmt.Errorf("unknown table, %s", table)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot decode %s record, %s", table, err.Error())
+	}
+	return nil
+}
+
+// JournalTables returns the names of the tables written to the journal.
+func JournalTables() []string {
+	return []string{
+		models.TableNames.Exchange,
+		models.TableNames.ExchangeTick,
+		orderBookSnapshotTableName,
+		models.TableNames.PowData,
+		models.TableNames.VSP,
+		models.TableNames.VSPTick,
+		models.TableNames.Mempool,
+		models.TableNames.Block,
+		models.TableNames.Vote,
+		voteVersionTableName,
+		txConfirmationTableName,
+		orphanedBlockTableName,
+		orphanedVoteTableName,
+		reorgTableName,
+		models.TableNames.Reddit,
+		models.TableNames.Twitter,
+		models.TableNames.Youtube,
+		models.TableNames.Github,
+		models.TableNames.NetworkSnapshot,
+		models.TableNames.Heartbeat,
+		models.TableNames.Node,
+		jobRunTableName,
+		apiKeyTableName,
+	}
+}
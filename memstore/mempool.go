@@ -0,0 +1,444 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/planetdecred/dcrextdata/postgres/models"
+)
+
+func (s *Store) MempoolTableName() string {
+	return models.TableNames.Mempool
+}
+
+func (s *Store) BlockTableName() string {
+	return models.TableNames.Block
+}
+
+func (s *Store) VoteTableName() string {
+	return models.TableNames.Vote
+}
+
+func (s *Store) storeMempool(mempoolDto mempool.Mempool) (bool, error) {
+	for _, m := range s.mempools {
+		if m.Time.Equal(mempoolDto.Time) {
+			return false, nil
+		}
+	}
+	s.mempools = append(s.mempools, mempoolDto)
+	return true, s.putMempool(mempoolDto)
+}
+
+func (s *Store) StoreMempool(ctx context.Context, mempoolDto mempool.Mempool) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	stored, err := s.storeMempool(mempoolDto)
+	if stored && err == nil {
+		s.charts.Invalidate(cache.Mempool, cache.MempoolFeeRate)
+		log.Infof("Added mempool entry at %s, tx count %2d, total size: %6d B, Total Fee: %010.8f",
+			mempoolDto.Time.Format(dateTemplate), mempoolDto.NumberOfTransactions, mempoolDto.Size, mempoolDto.TotalFee)
+	}
+	return err
+}
+
+func (s *Store) StoreMempoolFromSync(ctx context.Context, mempoolDto interface{}) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	stored, err := s.storeMempool(mempoolDto.(mempool.Mempool))
+	if stored && err == nil {
+		s.charts.Invalidate(cache.Mempool, cache.MempoolFeeRate)
+	}
+	return err
+}
+
+func (s *Store) lastMempoolTime() (last time.Time) {
+	for _, m := range s.mempools {
+		if m.Time.After(last) {
+			last = m.Time
+		}
+	}
+	return
+}
+
+func (s *Store) LastMempoolTime() (time.Time, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.lastMempoolTime(), nil
+}
+
+func (s *Store) MempoolCount(ctx context.Context) (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return int64(len(s.mempools)), nil
+}
+
+func (s *Store) sortedMempools(desc bool) []mempool.Mempool {
+	mempools := append([]mempool.Mempool(nil), s.mempools...)
+	sort.SliceStable(mempools, func(i, j int) bool {
+		if desc {
+			return mempools[i].Time.After(mempools[j].Time)
+		}
+		return mempools[i].Time.Before(mempools[j].Time)
+	})
+	return mempools
+}
+
+func (s *Store) Mempools(ctx context.Context, offtset int, limit int) ([]mempool.Dto, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	mempools := s.sortedMempools(true)
+	start, end := page(len(mempools), offtset, limit)
+	var result []mempool.Dto
+	for _, m := range mempools[start:end] {
+		result = append(result, mempool.Dto{
+			TotalFee:             m.TotalFee,
+			FirstSeenTime:        m.FirstSeenTime.Format(dateTemplate),
+			Total:                m.Total,
+			Voters:               m.Voters,
+			Tickets:              m.Tickets,
+			Revocations:          m.Revocations,
+			Time:                 m.Time.Format(dateTemplate),
+			Size:                 m.Size,
+			NumberOfTransactions: m.NumberOfTransactions,
+		})
+	}
+	return result, nil
+}
+
+func (s *Store) FetchMempoolForSync(ctx context.Context, date time.Time, offtset int, limit int) ([]mempool.Mempool, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var result []mempool.Mempool
+	for _, m := range s.sortedMempools(false) {
+		if m.Time.After(date) {
+			result = append(result, m)
+		}
+	}
+	start, end := page(len(result), offtset, limit)
+	return result[start:end], int64(len(result)), nil
+}
+
+func (s *Store) blockAt(height int64) (mempool.Block, bool) {
+	for _, block := range s.blocks {
+		if int64(block.BlockHeight) == height {
+			return block, true
+		}
+	}
+	return mempool.Block{}, false
+}
+
+func (s *Store) SaveBlock(ctx context.Context, block mempool.Block) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, found := s.blockAt(int64(block.BlockHeight)); !found {
+		s.blocks = append(s.blocks, block)
+		if err := s.putBlock(block); err != nil {
+			return err
+		}
+	}
+	for i := range s.votes {
+		if s.votes[i].VotingOn == int64(block.BlockHeight) {
+			s.votes[i].BlockReceiveTime = block.BlockReceiveTime
+			s.votes[i].BlockHash = block.BlockHash
+			if err := s.putVote(s.votes[i]); err != nil {
+				return err
+			}
+		}
+	}
+	s.charts.Invalidate(cache.Propagation)
+	log.Infof("New block received at %s, PropagationHeight: %d, Hash: %s",
+		block.BlockReceiveTime.Format(dateTemplate), block.BlockHeight, block.BlockHash)
+	return nil
+}
+
+func (s *Store) SaveBlockFromSync(ctx context.Context, block interface{}) error {
+	b := block.(mempool.Block)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, found := s.blockAt(int64(b.BlockHeight)); found {
+		return nil
+	}
+	s.blocks = append(s.blocks, b)
+	s.charts.Invalidate(cache.Propagation)
+	return s.putBlock(b)
+}
+
+func (s *Store) BlockCount(ctx context.Context) (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return int64(len(s.blocks)), nil
+}
+
+// blockPage returns a page of the blocks, last received first
+func (s *Store) blockPage(offset, limit int, withVotes bool) []mempool.BlockDto {
+	blocks := append([]mempool.Block(nil), s.blocks...)
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].BlockReceiveTime.After(blocks[j].BlockReceiveTime) })
+
+	start, end := page(len(blocks), offset, limit)
+	var result []mempool.BlockDto
+	for _, block := range blocks[start:end] {
+		timeDiff := block.BlockReceiveTime.Sub(block.BlockInternalTime).Seconds()
+		dto := mempool.BlockDto{
+			BlockHash:         block.BlockHash,
+			BlockHeight:       block.BlockHeight,
+			BlockInternalTime: block.BlockInternalTime.Format(dateTemplate),
+			BlockReceiveTime:  block.BlockReceiveTime.Format(dateTemplate),
+			Delay:             fmt.Sprintf("%04.2f", timeDiff),
+		}
+		if withVotes {
+			dto.Votes = s.voteDtos(func(vote mempool.Vote) bool { return vote.VotingOn == int64(block.BlockHeight) }, false)
+		}
+		result = append(result, dto)
+	}
+	return result
+}
+
+func (s *Store) Blocks(ctx context.Context, offset int, limit int) ([]mempool.BlockDto, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.blockPage(offset, limit, true), nil
+}
+
+func (s *Store) BlocksWithoutVotes(ctx context.Context, offset int, limit int) ([]mempool.BlockDto, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.blockPage(offset, limit, false), nil
+}
+
+func (s *Store) FetchBlockForSync(ctx context.Context, blockHeight int64, offtset int, limit int) ([]mempool.Block, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var result []mempool.Block
+	for _, block := range s.blocks {
+		if int64(block.BlockHeight) > blockHeight {
+			result = append(result, block)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].BlockReceiveTime.Before(result[j].BlockReceiveTime) })
+	start, end := page(len(result), offtset, limit)
+	return result[start:end], int64(len(result)), nil
+}
+
+func (s *Store) hasVote(hash string) bool {
+	for _, vote := range s.votes {
+		if vote.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) SaveVote(ctx context.Context, vote mempool.Vote) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.hasVote(vote.Hash) {
+		return nil
+	}
+	if block, found := s.blockAt(vote.VotingOn); found {
+		vote.BlockReceiveTime = block.BlockReceiveTime
+	}
+	s.votes = append(s.votes, vote)
+	if err := s.putVote(vote); err != nil {
+		return err
+	}
+	s.charts.Invalidate(cache.Propagation)
+	log.Infof("New vote received at %s for %d, Validator Id %d, Hash %s",
+		vote.ReceiveTime.Format(dateTemplate), vote.VotingOn, vote.ValidatorId, vote.Hash)
+	return nil
+}
+
+func (s *Store) SaveVoteFromSync(ctx context.Context, voteData interface{}) error {
+	vote := voteData.(mempool.Vote)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.hasVote(vote.Hash) {
+		return nil
+	}
+	s.votes = append(s.votes, vote)
+	s.charts.Invalidate(cache.Propagation)
+	return s.putVote(vote)
+}
+
+func voteToDto(vote mempool.Vote) mempool.VoteDto {
+	timeDiff := vote.ReceiveTime.Sub(vote.TargetedBlockTime).Seconds()
+	blockReceiveTimeDiff := vote.ReceiveTime.Sub(vote.BlockReceiveTime).Seconds()
+	var shortBlockHash string
+	if len(vote.BlockHash) >= 8 {
+		shortBlockHash = vote.BlockHash[len(vote.BlockHash)-8:]
+	}
+	return mempool.VoteDto{
+		Hash:                  vote.Hash,
+		ReceiveTime:           vote.ReceiveTime.Format(dateTemplate),
+		TargetedBlockTimeDiff: fmt.Sprintf("%04.2f", timeDiff),
+		BlockReceiveTimeDiff:  fmt.Sprintf("%04.2f", blockReceiveTimeDiff),
+		VotingOn:              vote.VotingOn,
+		BlockHash:             vote.BlockHash,
+		ShortBlockHash:        shortBlockHash,
+		ValidatorId:           vote.ValidatorId,
+		Validity:              vote.Validity,
+	}
+}
+
+// voteDtos returns the votes matching keep ordered by receive time.
+func (s *Store) voteDtos(keep func(mempool.Vote) bool, desc bool) []mempool.VoteDto {
+	var votes []mempool.Vote
+	for _, vote := range s.votes {
+		if keep(vote) {
+			votes = append(votes, vote)
+		}
+	}
+	sort.SliceStable(votes, func(i, j int) bool {
+		if desc {
+			return votes[i].ReceiveTime.After(votes[j].ReceiveTime)
+		}
+		return votes[i].ReceiveTime.Before(votes[j].ReceiveTime)
+	})
+	var dtos []mempool.VoteDto
+	for _, vote := range votes {
+		dtos = append(dtos, voteToDto(vote))
+	}
+	return dtos
+}
+
+func (s *Store) Votes(ctx context.Context, offset int, limit int) ([]mempool.VoteDto, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	votes := s.voteDtos(func(mempool.Vote) bool { return true }, true)
+	start, end := page(len(votes), offset, limit)
+	return append([]mempool.VoteDto{}, votes[start:end]...), nil
+}
+
+func (s *Store) VotesByBlock(ctx context.Context, blockHash string) ([]mempool.VoteDto, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	votes := s.voteDtos(func(vote mempool.Vote) bool { return vote.BlockHash == blockHash }, true)
+	return append([]mempool.VoteDto{}, votes...), nil
+}
+
+func (s *Store) VotesCount(ctx context.Context) (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return int64(len(s.votes)), nil
+}
+
+func (s *Store) FetchVoteForSync(ctx context.Context, date time.Time, offtset int, limit int) ([]mempool.Vote, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var result []mempool.Vote
+	for _, vote := range s.votes {
+		if !vote.ReceiveTime.Before(date) {
+			result = append(result, vote)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].ReceiveTime.Before(result[j].ReceiveTime) })
+	start, end := page(len(result), offtset, limit)
+	return result[start:end], int64(len(result)), nil
+}
+
+func (s *Store) VoteVersionTableName() string {
+	return voteVersionTableName
+}
+
+// SaveVoteVersion saves the version, the bits and the agenda choices of a
+// vote. A vote that already exists is ignored.
+func (s *Store) SaveVoteVersion(ctx context.Context, vote mempool.VoteVersion) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, existing := range s.voteVersions {
+		if existing.Hash == vote.Hash {
+			return nil
+		}
+	}
+	vote.ReceiveTime = vote.ReceiveTime.UTC()
+	vote.Choices = append([]mempool.AgendaChoice(nil), vote.Choices...)
+	s.voteVersions = append(s.voteVersions, vote)
+	if err := s.putVoteVersion(vote); err != nil {
+		return err
+	}
+	s.charts.Invalidate(cache.Agenda)
+	return nil
+}
+
+func (s *Store) SaveVoteVersionFromSync(ctx context.Context, vote interface{}) error {
+	return s.SaveVoteVersion(ctx, vote.(mempool.VoteVersion))
+}
+
+// FetchVoteVersionsForSync returns the vote versions received after date
+func (s *Store) FetchVoteVersionsForSync(ctx context.Context, date time.Time, offset int, limit int) ([]mempool.VoteVersion, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var result []mempool.VoteVersion
+	for _, vote := range s.voteVersions {
+		if vote.ReceiveTime.After(date) {
+			result = append(result, vote)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].ReceiveTime.Before(result[j].ReceiveTime) })
+	start, end := page(len(result), offset, limit)
+	return result[start:end], int64(len(result)), nil
+}
+
+func (s *Store) TxConfirmationTableName() string {
+	return txConfirmationTableName
+}
+
+// SaveTxConfirmations saves the wait of the transactions mined in a block. The
+// transactions that already exist are ignored.
+func (s *Store) SaveTxConfirmations(ctx context.Context, confirmations []mempool.TxConfirmation) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	var saved int
+	for _, confirmation := range confirmations {
+		if _, found := s.confirmationHashes[confirmation.Hash]; found {
+			continue
+		}
+		confirmation.SeenTime = confirmation.SeenTime.UTC()
+		confirmation.MinedTime = confirmation.MinedTime.UTC()
+		s.confirmations = append(s.confirmations, confirmation)
+		s.confirmationHashes[confirmation.Hash] = struct{}{}
+		saved++
+		if err := s.putTxConfirmation(confirmation); err != nil {
+			return err
+		}
+	}
+	if saved > 0 {
+		s.charts.Invalidate(cache.Confirmation)
+	}
+	return nil
+}
+
+func (s *Store) SaveTxConfirmationFromSync(ctx context.Context, confirmation interface{}) error {
+	return s.SaveTxConfirmations(ctx, []mempool.TxConfirmation{confirmation.(mempool.TxConfirmation)})
+}
+
+// FetchTxConfirmationsForSync returns the transactions mined after date
+func (s *Store) FetchTxConfirmationsForSync(ctx context.Context, date time.Time, offset int, limit int) ([]mempool.TxConfirmation, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var result []mempool.TxConfirmation
+	for _, confirmation := range s.confirmations {
+		if confirmation.MinedTime.After(date) {
+			result = append(result, confirmation)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].MinedTime.Before(result[j].MinedTime) })
+	start, end := page(len(result), offset, limit)
+	return result[start:end], int64(len(result)), nil
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package memstore keeps the collected data in memory. Store implements the
+// data stores of every collector as well as web.DataQuery so that dcrextdata
+// can run without a PostgreSQL database. The hourly and daily chart bins are
+// computed by the Update methods, as the postgres store does. Changes are
+// only persisted if a Journal is set.
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/commstats"
+	"github.com/planetdecred/dcrextdata/datasync"
+	"github.com/planetdecred/dcrextdata/exchanges/orderbook"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/planetdecred/dcrextdata/netsnapshot"
+	"github.com/planetdecred/dcrextdata/postgres/models"
+	"github.com/planetdecred/dcrextdata/pow"
+	"github.com/planetdecred/dcrextdata/scheduler"
+	"github.com/planetdecred/dcrextdata/vsp"
+	"github.com/planetdecred/dcrextdata/web"
+)
+
+const (
+	dateTemplate = "2006-01-02 15:04"
+
+	orderBookSnapshotTableName = "orderbook_snapshot"
+	voteVersionTableName       = "vote_version"
+	txConfirmationTableName    = "tx_confirmation"
+	orphanedBlockTableName     = "orphaned_block"
+	orphanedVoteTableName      = "orphaned_vote"
+	reorgTableName             = "reorg"
+	jobRunTableName            = "job_run"
+	apiKeyTableName            = "api_key"
+)
+
+var (
+	_ ticks.GapStore        = (*Store)(nil)
+	_ orderbook.Store       = (*Store)(nil)
+	_ pow.PowDataStore      = (*Store)(nil)
+	_ vsp.DataStore         = (*Store)(nil)
+	_ mempool.DataStore     = (*Store)(nil)
+	_ commstats.DataStore   = (*Store)(nil)
+	_ netsnapshot.DataStore = (*Store)(nil)
+	_ datasync.Store        = (*Store)(nil)
+	_ web.DataQuery         = (*Store)(nil)
+	_ scheduler.Store       = (*Store)(nil)
+)
+
+// Store is a thread safe, in memory data store.
+type Store struct {
+	mtx sync.RWMutex
+
+	exchanges        []exchange
+	exchangeTicks    []exchangeTick
+	exchangeTickKeys map[exchangeTickKey]struct{}
+
+	orderBookSnapshots []orderbook.Snapshot
+
+	powData []pow.PowData
+
+	vsps     []vsp.VSPDto
+	vspTicks []datasync.VSPTickSyncDto
+
+	mempools []mempool.Mempool
+	blocks   []mempool.Block
+	votes    []mempool.Vote
+
+	voteVersions []mempool.VoteVersion
+
+	confirmations      []mempool.TxConfirmation
+	confirmationHashes map[string]struct{}
+
+	orphanedBlocks []orphanedBlock
+	orphanedVotes  []orphanedVote
+	reorgs         []mempool.Reorg
+
+	reddit  []commstats.Reddit
+	twitter []commstats.Twitter
+	youtube []commstats.Youtube
+	github  []commstats.Github
+
+	snapshots  []netsnapshot.SnapShot
+	heartbeats []netsnapshot.Heartbeat
+	nodes      map[string]*node
+
+	jobRuns map[string]time.Time
+	apiKeys map[string]web.APIKey
+
+	bins                 map[string]binSet
+	syncSources          []string
+	syncSourceDbProvider func(source string) (*Store, error)
+	charts               *cache.Manager
+
+	journal Journal
+}
+
+// NewStore returns an empty Store.
+func NewStore() *Store {
+	return &Store{
+		nodes:   make(map[string]*node),
+		bins:    make(map[string]binSet),
+		jobRuns: make(map[string]time.Time),
+		apiKeys: make(map[string]web.APIKey),
+
+		exchangeTickKeys:   make(map[exchangeTickKey]struct{}),
+		confirmationHashes: make(map[string]struct{}),
+	}
+}
+
+// TableNames returns the names of the tables that are shared during data sync.
+func (s *Store) TableNames() []string {
+	return []string{
+		reorgTableName,
+		models.TableNames.Vote,
+		voteVersionTableName,
+		txConfirmationTableName,
+		models.TableNames.Block,
+		models.TableNames.Mempool,
+		models.TableNames.Exchange,
+		models.TableNames.ExchangeTick,
+		orderBookSnapshotTableName,
+		models.TableNames.VSP,
+		models.TableNames.VSPTick,
+		models.TableNames.PowData,
+	}
+}
+
+// LastEntry sets receiver to the sort key of the last entry of tableName, as
+// the postgres store would read it. sql.ErrNoRows is returned for an empty
+// table.
+func (s *Store) LastEntry(ctx context.Context, tableName string, receiver interface{}) error {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var value interface{}
+	switch tableName {
+	case models.TableNames.Exchange:
+		if len(s.exchanges) > 0 {
+			value = int64(s.exchanges[len(s.exchanges)-1].ID)
+		}
+	case models.TableNames.ExchangeTick:
+		if len(s.exchangeTicks) > 0 {
+			value = int64(s.exchangeTicks[len(s.exchangeTicks)-1].ID)
+		}
+	case orderBookSnapshotTableName:
+		if t := s.lastOrderBookSnapshotTime(); !t.IsZero() {
+			value = t
+		}
+	case models.TableNames.Mempool:
+		if t := s.lastMempoolTime(); !t.IsZero() {
+			value = t
+		}
+	case models.TableNames.Block:
+		for _, block := range s.blocks {
+			if height, ok := value.(int64); !ok || int64(block.BlockHeight) > height {
+				value = int64(block.BlockHeight)
+			}
+		}
+	case models.TableNames.Vote:
+		for _, vote := range s.votes {
+			if t, ok := value.(time.Time); !ok || vote.ReceiveTime.After(t) {
+				value = vote.ReceiveTime
+			}
+		}
+	case voteVersionTableName:
+		for _, vote := range s.voteVersions {
+			if t, ok := value.(time.Time); !ok || vote.ReceiveTime.After(t) {
+				value = vote.ReceiveTime
+			}
+		}
+	case txConfirmationTableName:
+		for _, confirmation := range s.confirmations {
+			if t, ok := value.(time.Time); !ok || confirmation.MinedTime.After(t) {
+				value = confirmation.MinedTime
+			}
+		}
+	case reorgTableName:
+		for _, reorg := range s.reorgs {
+			value = latest(value, reorg.Time)
+		}
+	case models.TableNames.PowData:
+		if len(s.powData) > 0 {
+			value = s.lastPowEntryTime("")
+		}
+	case models.TableNames.VSP:
+		if len(s.vsps) > 0 {
+			value = int64(s.vsps[len(s.vsps)-1].ID)
+		}
+	case models.TableNames.VSPTick:
+		if len(s.vspTicks) > 0 {
+			value = int64(s.vspTicks[len(s.vspTicks)-1].ID)
+		}
+	case models.TableNames.Reddit:
+		for _, stat := range s.reddit {
+			value = latest(value, stat.Date)
+		}
+	case models.TableNames.Twitter:
+		for _, stat := range s.twitter {
+			value = latest(value, stat.Date)
+		}
+	case models.TableNames.Github:
+		for _, stat := range s.github {
+			value = latest(value, stat.Date)
+		}
+	case models.TableNames.Youtube:
+		for _, stat := range s.youtube {
+			value = latest(value, stat.Date)
+		}
+	case models.TableNames.NetworkSnapshot:
+		for _, snapshot := range s.snapshots {
+			if timestamp, ok := value.(int64); !ok || snapshot.Timestamp > timestamp {
+				value = snapshot.Timestamp
+			}
+		}
+	default:
+		return fmt.Errorf("unknown table, %s", tableName)
+	}
+	if value == nil {
+		return sql.ErrNoRows
+	}
+	return assign(receiver, value)
+}
+
+func latest(value interface{}, date time.Time) interface{} {
+	if t, ok := value.(time.Time); ok && !date.After(t) {
+		return t
+	}
+	return date
+}
+
+// assign stores value, an int64 or a time.Time, in receiver.
+func assign(receiver interface{}, value interface{}) error {
+	switch r := receiver.(type) {
+	case *time.Time:
+		switch v := value.(type) {
+		case time.Time:
+			*r = v
+			return nil
+		case int64:
+			*r = time.Unix(v, 0).UTC()
+			return nil
+		}
+	case *int64:
+		switch v := value.(type) {
+		case int64:
+			*r = v
+			return nil
+		case time.Time:
+			*r = v.Unix()
+			return nil
+		}
+	case *int:
+		if v, ok := value.(int64); ok {
+			*r = int(v)
+			return nil
+		}
+	case *uint32:
+		if v, ok := value.(int64); ok {
+			*r = uint32(v)
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot assign %T to %T", value, receiver)
+}
+
+// page returns the bounds of the slice of a list of total items starting at
+// offset and holding at most limit items.
+func page(total, offset, limit int) (start, end int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end = total
+	if limit >= 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return offset, end
+}
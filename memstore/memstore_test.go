@@ -0,0 +1,487 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/planetdecred/dcrextdata/postgres/models"
+	"github.com/planetdecred/dcrextdata/pow"
+	"github.com/planetdecred/dcrextdata/web"
+)
+
+func TestStoreExchangeTicks(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	if _, _, _, err := store.RegisterExchange(ctx, ticks.ExchangeData{Name: "bittrex", WebsiteURL: "https://bittrex.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := []ticks.Tick{
+		{Close: 1, Time: start},
+		{Close: 2, Time: start.Add(5 * time.Minute)},
+		{Close: 3, Time: start.Add(10 * time.Minute)},
+	}
+	last, err := store.StoreExchangeTicks(ctx, "bittrex", 5, "BTC/DCR", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !last.Equal(data[2].Time) {
+		t.Errorf("expected the last tick time %s, got %s", data[2].Time, last)
+	}
+
+	// storing the same ticks again must not duplicate them
+	if _, err = store.StoreExchangeTicks(ctx, "bittrex", 5, "BTC/DCR", data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreExchangeTicks(ctx, "unknown", 5, "BTC/DCR", data); err == nil {
+		t.Error("expected an error for an unregistered exchange")
+	}
+
+	tickDtos, total, err := store.FetchExchangeTicks(ctx, "BTC/DCR", "bittrex", 5, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 {
+		t.Fatalf("expected 3 ticks, got %d", total)
+	}
+	if len(tickDtos) != 2 || tickDtos[0].Close != 3 || tickDtos[1].Close != 2 {
+		t.Errorf("expected the two most recent ticks first, got %+v", tickDtos)
+	}
+
+	var lastID int64
+	if err = store.LastEntry(ctx, models.TableNames.ExchangeTick, &lastID); err != nil {
+		t.Fatal(err)
+	}
+	if lastID != 3 {
+		t.Errorf("expected the last exchange tick ID to be 3, got %d", lastID)
+	}
+	// the restored ticks are indexed as well, per series
+	restored := NewStore()
+	for _, xch := range store.exchanges {
+		record, _ := json.Marshal(xch)
+		if err = restored.Restore(models.TableNames.Exchange, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tick := range store.exchangeTicks {
+		record, _ := json.Marshal(tick)
+		if err = restored.Restore(models.TableNames.ExchangeTick, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data = append(data, ticks.Tick{Close: 4, Time: start.Add(15 * time.Minute)})
+	if _, err = restored.StoreExchangeTicks(ctx, "bittrex", 5, "BTC/DCR", data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = restored.StoreExchangeTicks(ctx, "bittrex", 60, "BTC/DCR", data[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.exchangeTicks) != 5 {
+		t.Errorf("expected the new 5m tick and the 1h tick to be added to the 3 restored ones, got %d ticks", len(restored.exchangeTicks))
+	}
+}
+
+func TestLastEntry(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+
+	var lastTime time.Time
+	if err := store.LastEntry(ctx, models.TableNames.PowData, &lastTime); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows for an empty table, got %v", err)
+	}
+	if err := store.LastEntry(ctx, "unknown", &lastTime); err == nil {
+		t.Fatal("expected an error for an unknown table")
+	}
+
+	powData := []pow.PowData{
+		{Time: 1577836800, Source: "f2pool", PoolHashrate: 2 * pow.Thash},
+		{Time: 1577840400, Source: "f2pool", PoolHashrate: 3 * pow.Thash},
+		{Time: 1577840400, Source: "f2pool", PoolHashrate: 3 * pow.Thash},
+	}
+	if err := store.AddPowData(ctx, powData); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := store.PowCount(ctx); count != 2 {
+		t.Errorf("expected 2 PoW entries, got %d", count)
+	}
+	if err := store.LastEntry(ctx, models.TableNames.PowData, &lastTime); err != nil {
+		t.Fatal(err)
+	}
+	if lastTime.Unix() != 1577840400 {
+		t.Errorf("expected the last PoW entry at 1577840400, got %d", lastTime.Unix())
+	}
+}
+
+func TestUpdateBins(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, size := range []int32{10, 20, 30, 40, 50} {
+		m := mempool.Mempool{Time: start.Add(time.Duration(i) * 30 * time.Minute), Size: size}
+		if err := store.StoreMempool(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.UpdateMempoolAggregateData(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the complete hours are binned
+	hours := store.binnedPoints(mempoolSeries, string(cache.HourBin))
+	if len(hours) != 2 {
+		t.Fatalf("expected 2 hour bins, got %d", len(hours))
+	}
+	if hours[0].time != uint64(start.Unix()) || hours[0].values[0] != 15 || hours[1].values[0] != 35 {
+		t.Errorf("unexpected hour bins %+v", hours)
+	}
+
+	// updating again must only add the hours completed since
+	for i, size := range []int32{60, 70} {
+		m := mempool.Mempool{Time: start.Add(time.Duration(i+3) * time.Hour), Size: size}
+		if err := store.StoreMempool(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.UpdateMempoolAggregateData(ctx); err != nil {
+		t.Fatal(err)
+	}
+	hours = store.binnedPoints(mempoolSeries, string(cache.HourBin))
+	if len(hours) != 4 || hours[2].values[0] != 50 || hours[3].values[0] != 60 {
+		t.Errorf("expected 4 hour bins, the last two averaging 50 and 60, got %+v", hours)
+	}
+}
+
+func TestMempoolFeeRateChart(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	charts := cache.NewChartData(ctx, false, nil, nil, nil, nil, nil, nil, "")
+	store.RegisterCharts(charts, nil, nil)
+
+	// the sample without a distribution predates them and is not binned
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, m := range []mempool.Mempool{
+		{Time: start, FeeRates: []mempool.FeeRateBucket{{FeeRate: 10000, Count: 2, Size: 500}},
+			Percentiles: []mempool.FeeRatePercentile{{Percentile: 50, FeeRate: 10000}}},
+		{Time: start.Add(15 * time.Minute)},
+		{Time: start.Add(30 * time.Minute), FeeRates: []mempool.FeeRateBucket{{FeeRate: 10000, Count: 4, Size: 700}},
+			Percentiles: []mempool.FeeRatePercentile{{Percentile: 50, FeeRate: 20000}}},
+		{Time: start.Add(150 * time.Minute), FeeRates: []mempool.FeeRateBucket{{FeeRate: 20000, Count: 1, Size: 300}},
+			Percentiles: []mempool.FeeRatePercentile{{Percentile: 50, FeeRate: 20000}}},
+	} {
+		if err := store.StoreMempool(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.UpdateMempoolAggregateData(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	chart := func(dataType, bin string) map[string][]float64 {
+		data, err := charts.Chart(ctx, cache.MempoolFeeRate, dataType, "", bin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var series map[string][]float64
+		if err = json.Unmarshal(data, &series); err != nil {
+			t.Fatal(err)
+		}
+		return series
+	}
+
+	samples := chart(cache.MempoolTxCount, string(cache.DefaultBin))
+	if len(samples["x"]) != 3 || samples["10000"][1] != 4 || samples["20000"][2] != 1 || samples["p50"][0] != 10000 {
+		t.Errorf("unexpected fee rate chart of the samples %v", samples)
+	}
+
+	hours := chart(cache.MempoolTxCount, string(cache.HourBin))
+	if len(hours["x"]) != 1 || hours["x"][0] != float64(start.Unix()) || hours["10000"][0] != 3 || hours["p50"][0] != 15000 {
+		t.Errorf("unexpected hourly fee rate chart %v", hours)
+	}
+	if sizes := chart(cache.MempoolSize, string(cache.HourBin)); sizes["10000"][0] != 600 {
+		t.Errorf("expected an average size of 600 bytes, got %v", sizes)
+	}
+}
+
+func TestAPIBlocks(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+
+	// blocks 10 and 9 are received at the same time, they are ordered by height
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, block := range []mempool.Block{
+		{BlockHeight: 11, BlockReceiveTime: start.Add(time.Minute)},
+		{BlockHeight: 10, BlockReceiveTime: start},
+		{BlockHeight: 9, BlockReceiveTime: start},
+		{BlockHeight: 8, BlockReceiveTime: start.Add(-time.Minute)},
+	} {
+		if err := store.SaveBlock(ctx, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page := web.APIPage{Start: start, Limit: 2}
+	blocks, err := store.APIBlocks(ctx, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 2 || blocks[0].BlockHeight != 9 || blocks[1].BlockHeight != 10 {
+		t.Fatalf("expected blocks 9 and 10, got %+v", blocks)
+	}
+
+	page.After = &web.APICursor{Time: start, Key: "10"}
+	blocks, err = store.APIBlocks(ctx, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 1 || blocks[0].BlockHeight != 11 {
+		t.Errorf("expected block 11 after the cursor, got %+v", blocks)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	store := NewStore()
+	ctx := context.Background()
+	key := web.APIKey{Name: "mirror", Hash: web.HashAPIKey("secret"), RateLimit: 60, CreatedAt: time.Now()}
+	if err := store.SaveAPIKey(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveAPIKey(ctx, key); err == nil {
+		t.Error("expected an error for a duplicate key")
+	}
+
+	found, err := store.APIKeyByHash(ctx, web.HashAPIKey("secret"))
+	if err != nil || found.Name != "mirror" || found.RateLimit != 60 {
+		t.Errorf("unexpected key %+v, %v", found, err)
+	}
+	if _, err = store.APIKeyByHash(ctx, web.HashAPIKey("other")); err != web.ErrUnknownAPIKey {
+		t.Errorf("expected ErrUnknownAPIKey, got %v", err)
+	}
+
+	if err = store.DeleteAPIKey(ctx, "mirror"); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.DeleteAPIKey(ctx, "mirror"); err != web.ErrUnknownAPIKey {
+		t.Errorf("expected ErrUnknownAPIKey, got %v", err)
+	}
+}
+
+func TestVoteVersions(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	charts := cache.NewChartData(ctx, false, nil, nil, nil, nil, nil, nil, "")
+	store.RegisterCharts(charts, nil, nil)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	votes := []mempool.VoteVersion{
+		{Hash: "a", ReceiveTime: start, VotingOn: 100, Version: 8, Bits: 5,
+			Choices: []mempool.AgendaChoice{{AgendaID: "treasury", Choice: "yes"}}},
+		{Hash: "b", ReceiveTime: start.Add(time.Second), VotingOn: 100, Version: 8, Bits: 3,
+			Choices: []mempool.AgendaChoice{{AgendaID: "treasury", Choice: "no"}}},
+		{Hash: "c", ReceiveTime: start.Add(5 * time.Minute), VotingOn: 101, Version: 8, Bits: 5,
+			Choices: []mempool.AgendaChoice{{AgendaID: "treasury", Choice: "yes"}}},
+	}
+	for _, vote := range append(votes, votes[0]) {
+		if err := store.SaveVoteVersion(ctx, vote); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	synced, total, err := store.FetchVoteVersionsForSync(ctx, start, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || synced[0].Hash != "b" || synced[1].Choices[0].Choice != "yes" {
+		t.Errorf("expected the two votes received after the first one, got %+v", synced)
+	}
+	var last time.Time
+	if err = store.LastEntry(ctx, voteVersionTableName, &last); err != nil || !last.Equal(votes[2].ReceiveTime) {
+		t.Errorf("expected the last receive time %s, got %s, %v", votes[2].ReceiveTime, last, err)
+	}
+
+	data, err := charts.Chart(ctx, cache.Agenda, "", string(cache.HeightAxis), "", "treasury")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"no":[1,0],"x":[100,101],"yes":[1,1]}`
+	if string(data) != expected {
+		t.Errorf("expected the agenda chart %s, got %s", expected, data)
+	}
+	if _, err = charts.Chart(ctx, cache.Agenda, "", "", ""); err == nil {
+		t.Error("expected an error without an agenda ID")
+	}
+}
+
+func TestTxConfirmations(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	charts := cache.NewChartData(ctx, false, nil, nil, nil, nil, nil, nil, "")
+	store.RegisterCharts(charts, nil, nil)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	confirmations := []mempool.TxConfirmation{
+		{Hash: "a", TxType: "regular", FeeRate: 15000, BlockHeight: 100, MinedTime: start, WaitTime: 60},
+		{Hash: "b", TxType: "vote", BlockHeight: 100, MinedTime: start, WaitTime: 10},
+		{Hash: "c", TxType: "regular", FeeRate: 12000, BlockHeight: 101, MinedTime: start.Add(5 * time.Minute), WaitTime: 120},
+		{Hash: "d", TxType: "regular", FeeRate: 250000, BlockHeight: 101, MinedTime: start.Add(5 * time.Minute), WaitTime: 30},
+	}
+	if err := store.SaveTxConfirmations(ctx, confirmations[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveTxConfirmations(ctx, confirmations[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	synced, total, err := store.FetchTxConfirmationsForSync(ctx, start, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || synced[0].Hash != "c" || synced[1].Hash != "d" {
+		t.Errorf("expected the two transactions mined after the first block, got %+v", synced)
+	}
+	var last time.Time
+	if err = store.LastEntry(ctx, txConfirmationTableName, &last); err != nil || !last.Equal(confirmations[2].MinedTime) {
+		t.Errorf("expected the last mined time %s, got %s, %v", confirmations[2].MinedTime, last, err)
+	}
+
+	data, err := charts.Chart(ctx, cache.Confirmation, cache.ConfirmationTxType, string(cache.HeightAxis), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"regular":[60,75],"vote":[10,null],"x":[100,101]}`
+	if string(data) != expected {
+		t.Errorf("expected the confirmation chart %s, got %s", expected, data)
+	}
+
+	data, err = charts.Chart(ctx, cache.Confirmation, cache.ConfirmationFeeRate, "", string(cache.HourBin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `{"0":[10],"10000":[90],"200000":[30],"x":[1577836800]}`
+	if string(data) != expected {
+		t.Errorf("expected the hourly confirmation chart %s, got %s", expected, data)
+	}
+	if _, err = charts.Chart(ctx, cache.Confirmation, "", "", ""); err == nil {
+		t.Error("expected an error for an unknown data type")
+	}
+}
+
+// failingJournal fails to delete the records of table.
+type failingJournal struct {
+	table string
+}
+
+func (j failingJournal) Put(string, string, []byte) error { return nil }
+func (j failingJournal) Delete(table, key string) error {
+	if table == j.table {
+		return fmt.Errorf("cannot delete %s %s", table, key)
+	}
+	return nil
+}
+
+func TestOrphanBlock(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	saveBlock := func(height uint32, hash string, at time.Time, delay, voteDelay time.Duration) {
+		block := mempool.Block{BlockHeight: height, BlockHash: hash, BlockInternalTime: at, BlockReceiveTime: at.Add(delay)}
+		if err := store.SaveBlock(ctx, block); err != nil {
+			t.Fatal(err)
+		}
+		vote := mempool.Vote{Hash: "vote-" + hash, VotingOn: int64(height), BlockHash: hash,
+			TargetedBlockTime: at, ReceiveTime: block.BlockReceiveTime.Add(voteDelay)}
+		if err := store.SaveVote(ctx, vote); err != nil {
+			t.Fatal(err)
+		}
+	}
+	updateBins := func() {
+		if err := store.UpdateBlockBinData(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.UpdateVoteTimeDeviationData(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, hash := range []string{"a", "b", "c", "d", "e"} {
+		delay, voteDelay := 10*time.Second, time.Second
+		if hash == "d" {
+			delay, voteDelay = 100*time.Second, 50*time.Second
+		}
+		saveBlock(uint32(100+i), hash, start.Add(time.Duration(i)*30*time.Minute), delay, voteDelay)
+	}
+	updateBins()
+	hours := store.binnedPoints(blockSeries, string(cache.HourBin))
+	if len(hours) != 2 || hours[1].values[0] != 55 {
+		t.Fatalf("expected the second hour to average 55s with the block to orphan, got %+v", hours)
+	}
+
+	// a journal failure leaves the records unchanged
+	store.SetJournal(failingJournal{table: models.TableNames.Vote})
+	if err := store.OrphanBlock(ctx, "d"); err == nil {
+		t.Fatal("expected the journal error")
+	}
+	if len(store.blocks) != 5 || len(store.votes) != 5 || len(store.orphanedBlocks) != 0 || len(store.orphanedVotes) != 0 {
+		t.Fatalf("expected no change after a journal error, got %d blocks and %d votes", len(store.blocks), len(store.votes))
+	}
+	for i, block := range store.blocks {
+		if block.BlockHeight != uint32(100+i) {
+			t.Fatalf("expected the blocks in order after a journal error, got %+v", store.blocks)
+		}
+	}
+	store.SetJournal(nil)
+
+	if err := store.OrphanBlock(ctx, "d"); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.blocks) != 4 || len(store.votes) != 4 || len(store.orphanedBlocks) != 1 || len(store.orphanedVotes) != 1 {
+		t.Fatalf("expected the block and its vote to be orphaned, got %d blocks and %d votes", len(store.blocks), len(store.votes))
+	}
+	if hours = store.binnedPoints(blockSeries, string(cache.HourBin)); len(hours) != 0 {
+		t.Errorf("expected the block bins of the day to be truncated, got %+v", hours)
+	}
+
+	// the block of the new chain takes the height of the orphan
+	saveBlock(103, "d2", start.Add(90*time.Minute), 10*time.Second, time.Second)
+	updateBins()
+	hours = store.binnedPoints(blockSeries, string(cache.HourBin))
+	if len(hours) != 2 || hours[0].values[0] != 10 || hours[1].values[0] != 10 {
+		t.Errorf("expected the block bins without the orphan, got %+v", hours)
+	}
+	voteHours := store.binnedPoints(voteSeries, string(cache.HourBin))
+	if len(voteHours) != 2 || voteHours[1].values[0] != 1 {
+		t.Errorf("expected the vote bins without the orphaned vote, got %+v", voteHours)
+	}
+
+	reorg := mempool.Reorg{Time: start.Add(95 * time.Minute), Depth: 1, OldTipHash: "d", OldTipHeight: 103,
+		NewTipHash: "d2", NewTipHeight: 103, OrphanedBlocks: []string{"d"}}
+	if err := store.SaveReorg(ctx, reorg); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveReorg(ctx, reorg); err != nil {
+		t.Fatal(err)
+	}
+	synced, total, err := store.FetchReorgsForSync(ctx, start, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || synced[0].NewTipHash != "d2" || len(synced[0].OrphanedBlocks) != 1 {
+		t.Errorf("expected the reorganization, got %+v", synced)
+	}
+	var last time.Time
+	if err = store.LastEntry(ctx, reorgTableName, &last); err != nil || !last.Equal(reorg.Time) {
+		t.Errorf("expected the last reorganization time %s, got %s, %v", reorg.Time, last, err)
+	}
+}
@@ -0,0 +1,576 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/netsnapshot"
+	"github.com/planetdecred/dcrextdata/postgres/models"
+)
+
+type node struct {
+	netsnapshot.NetworkPeer
+	failureCount int
+}
+
+func (s *Store) snapshotIndex(timestamp int64) int {
+	for i, snapshot := range s.snapshots {
+		if snapshot.Timestamp == timestamp {
+			return i
+		}
+	}
+	return -1
+}
+
+// heartbeatsAt returns the heartbeats recorded for the snapshot taken at
+// timestamp.
+func (s *Store) heartbeatsAt(timestamp int64) []netsnapshot.Heartbeat {
+	var heartbeats []netsnapshot.Heartbeat
+	for _, heartbeat := range s.heartbeats {
+		if heartbeat.Timestamp == timestamp {
+			heartbeats = append(heartbeats, heartbeat)
+		}
+	}
+	return heartbeats
+}
+
+func averageLatency(heartbeats []netsnapshot.Heartbeat) int {
+	var total, count int
+	for _, heartbeat := range heartbeats {
+		if heartbeat.Latency > 0 {
+			total += heartbeat.Latency
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / count
+}
+
+func (s *Store) SaveSnapshot(ctx context.Context, snapshot netsnapshot.SnapShot) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	heartbeats := s.heartbeatsAt(snapshot.Timestamp)
+	snapshot.ReachableNodeCount = len(heartbeats)
+	if snapshot.OldestNodeTimestamp == 0 {
+		for _, heartbeat := range heartbeats {
+			n, found := s.nodes[heartbeat.Address]
+			if found && (snapshot.OldestNode == "" || n.ConnectionTime > snapshot.OldestNodeTimestamp) {
+				snapshot.OldestNode = n.Address
+				snapshot.OldestNodeTimestamp = n.ConnectionTime
+			}
+		}
+	}
+	snapshot.Latency = averageLatency(heartbeats)
+	s.charts.Invalidate(cache.Snapshot)
+
+	if i := s.snapshotIndex(snapshot.Timestamp); i >= 0 {
+		s.snapshots[i] = snapshot
+		return s.putSnapshot(snapshot)
+	}
+	s.snapshots = append(s.snapshots, snapshot)
+	sort.Slice(s.snapshots, func(i, j int) bool { return s.snapshots[i].Timestamp < s.snapshots[j].Timestamp })
+	return s.putSnapshot(snapshot)
+}
+
+func (s *Store) FindNetworkSnapshot(ctx context.Context, timestamp int64) (*netsnapshot.SnapShot, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	i := s.snapshotIndex(timestamp)
+	if i < 0 {
+		return nil, sql.ErrNoRows
+	}
+	snapshot := s.snapshots[i]
+	return &snapshot, nil
+}
+
+func (s *Store) PreviousSnapshot(ctx context.Context, timestamp int64) (*netsnapshot.SnapShot, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	for i := len(s.snapshots) - 1; i >= 0; i-- {
+		if s.snapshots[i].Timestamp < timestamp {
+			snapshot := s.snapshots[i]
+			return &snapshot, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *Store) NextSnapshot(ctx context.Context, timestamp int64) (*netsnapshot.SnapShot, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	for _, snapshot := range s.snapshots {
+		if snapshot.Timestamp > timestamp {
+			return &snapshot, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *Store) SnapshotCount(ctx context.Context) (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return int64(len(s.snapshots)), nil
+}
+
+// Snapshots returns the snapshots taken at a known height, most recent first.
+// All the snapshots from offset are returned in ascending order when forChart
+// is set.
+func (s *Store) Snapshots(ctx context.Context, offset, limit int, forChart bool) ([]netsnapshot.SnapShot, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var snapshots []netsnapshot.SnapShot
+	for _, snapshot := range s.snapshots {
+		if snapshot.Height > 0 {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	total := int64(len(snapshots))
+	if forChart {
+		start, end := page(len(snapshots), offset, -1)
+		return snapshots[start:end], total, nil
+	}
+
+	for i, j := 0, len(snapshots)-1; i < j; i, j = i+1, j-1 {
+		snapshots[i], snapshots[j] = snapshots[j], snapshots[i]
+	}
+	start, end := page(len(snapshots), offset, limit)
+	return snapshots[start:end], total, nil
+}
+
+func (s *Store) DeleteSnapshot(ctx context.Context, timestamp int64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	i := s.snapshotIndex(timestamp)
+	if i < 0 {
+		return
+	}
+	s.snapshots = append(s.snapshots[:i], s.snapshots[i+1:]...)
+	if err := s.remove(models.TableNames.NetworkSnapshot, numberKey(timestamp)); err != nil {
+		log.Errorf("Unable to delete the snapshot taken at %d, %s", timestamp, err.Error())
+	}
+	heartbeats := s.heartbeats[:0]
+	for _, heartbeat := range s.heartbeats {
+		if heartbeat.Timestamp != timestamp {
+			heartbeats = append(heartbeats, heartbeat)
+			continue
+		}
+		if err := s.remove(models.TableNames.Heartbeat, heartbeatKey(heartbeat)); err != nil {
+			log.Errorf("Unable to delete the heartbeat of %s at %d, %s", heartbeat.Address, timestamp, err.Error())
+		}
+	}
+	s.heartbeats = heartbeats
+}
+
+func (s *Store) SaveHeartbeat(ctx context.Context, heartbeat netsnapshot.Heartbeat) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for i, existing := range s.heartbeats {
+		if existing.Address != heartbeat.Address || existing.Timestamp != heartbeat.Timestamp {
+			continue
+		}
+		if heartbeat.CurrentHeight > 0 {
+			s.heartbeats[i].CurrentHeight = heartbeat.CurrentHeight
+		}
+		if heartbeat.Latency > 0 {
+			s.heartbeats[i].Latency = heartbeat.Latency
+		}
+		if heartbeat.LastSeen > 0 {
+			s.heartbeats[i].LastSeen = heartbeat.LastSeen
+		}
+		return s.putHeartbeat(s.heartbeats[i])
+	}
+	s.heartbeats = append(s.heartbeats, heartbeat)
+	return s.putHeartbeat(heartbeat)
+}
+
+func (s *Store) AttemptPeer(ctx context.Context, address string, now int64) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	n, found := s.nodes[address]
+	if !found {
+		return nil
+	}
+	n.LastAttempt = now
+	return s.putNode(n)
+}
+
+// RecordNodeConnectionFailure increase the number of failure for the specified
+// node. The node is marked as dead once maxAllowedFailure is reached.
+func (s *Store) RecordNodeConnectionFailure(ctx context.Context, address string, maxAllowedFailure int) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	n, found := s.nodes[address]
+	if !found {
+		return nil
+	}
+	n.failureCount++
+	if n.failureCount >= maxAllowedFailure {
+		n.IsDead = true
+	}
+	return s.putNode(n)
+}
+
+func (s *Store) NodeExists(ctx context.Context, address string) (bool, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	_, found := s.nodes[address]
+	return found, nil
+}
+
+// SaveNode adds the node. The node is marked as alive.
+func (s *Store) SaveNode(ctx context.Context, peer netsnapshot.NetworkPeer) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	peer.LastAttempt = peer.LastSeen
+	peer.IsDead = false
+	n := &node{NetworkPeer: peer}
+	s.nodes[peer.Address] = n
+	return s.putNode(n)
+}
+
+// UpdateNode updates the node information, resetting its failure count and
+// marking it as alive.
+func (s *Store) UpdateNode(ctx context.Context, peer netsnapshot.NetworkPeer) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	n, found := s.nodes[peer.Address]
+	if !found {
+		return sql.ErrNoRows
+	}
+	n.LastAttempt = peer.LastAttempt
+	n.LastSeen = peer.LastSeen
+	n.LastSuccess = peer.LastSuccess
+	n.Services = peer.Services
+	n.StartingHeight = peer.StartingHeight
+	n.UserAgent = peer.UserAgent
+	n.CurrentHeight = peer.CurrentHeight
+	n.IsDead = false
+	n.failureCount = 0
+	if n.ConnectionTime == 0 {
+		n.ConnectionTime = peer.ConnectionTime
+	}
+	return s.putNode(n)
+}
+
+// peer returns the node as served by the postgres store.
+func (n *node) peer() netsnapshot.NetworkPeer {
+	return netsnapshot.NetworkPeer{
+		Address:         n.Address,
+		LastSeen:        n.LastSeen,
+		ConnectionTime:  n.ConnectionTime,
+		ProtocolVersion: n.ProtocolVersion,
+		UserAgent:       n.UserAgent,
+		StartingHeight:  n.StartingHeight,
+		CurrentHeight:   n.CurrentHeight,
+		Services:        n.Services,
+		IsDead:          n.IsDead,
+		IPInfo: netsnapshot.IPInfo{
+			CountryName: n.CountryName,
+			RegionName:  n.RegionName,
+			City:        n.City,
+			Zip:         n.Zip,
+		},
+	}
+}
+
+// snapshotNodes returns the nodes that sent a heartbeat at timestamp.
+func (s *Store) snapshotNodes(timestamp int64) []*node {
+	var nodes []*node
+	for _, heartbeat := range s.heartbeatsAt(timestamp) {
+		if n, found := s.nodes[heartbeat.Address]; found {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+func (s *Store) NetworkPeers(ctx context.Context, timestamp int64, q string, offset int, limit int) ([]netsnapshot.NetworkPeer, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var peers []netsnapshot.NetworkPeer
+	for _, n := range s.snapshotNodes(timestamp) {
+		if q == "" || n.Address == q || n.UserAgent == q || n.CountryName == q {
+			peers = append(peers, n.peer())
+		}
+	}
+	sort.SliceStable(peers, func(i, j int) bool { return peers[i].LastSeen > peers[j].LastSeen })
+	start, end := page(len(peers), offset, limit)
+	return peers[start:end], int64(len(peers)), nil
+}
+
+func (s *Store) GetAvailableNodes(ctx context.Context) ([]net.IP, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	peers := make([]net.IP, 0, len(s.nodes))
+	for address, n := range s.nodes {
+		if !n.IsDead {
+			peers = append(peers, net.ParseIP(address))
+		}
+	}
+	return peers, nil
+}
+
+func (s *Store) NetworkPeer(ctx context.Context, address string) (*netsnapshot.NetworkPeer, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	n, found := s.nodes[address]
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+	peer := n.peer()
+	return &peer, nil
+}
+
+func (s *Store) AverageLatency(ctx context.Context, address string) (int, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	var heartbeats []netsnapshot.Heartbeat
+	for _, heartbeat := range s.heartbeats {
+		if heartbeat.Address == address {
+			heartbeats = append(heartbeats, heartbeat)
+		}
+	}
+	return averageLatency(heartbeats), nil
+}
+
+func (s *Store) GetIPLocation(ctx context.Context, ip string) (string, int, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	n, found := s.nodes[ip]
+	if !found {
+		return "", -1, sql.ErrNoRows
+	}
+	return n.CountryName, n.IPVersion, nil
+}
+
+func (s *Store) TotalPeerCount(ctx context.Context, timestamp int64) (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return int64(len(s.heartbeatsAt(timestamp))), nil
+}
+
+func (s *Store) SeenNodesByTimestamp(ctx context.Context) ([]netsnapshot.NodeCount, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	counts := make(map[int64]int64)
+	for _, heartbeat := range s.heartbeats {
+		counts[heartbeat.Timestamp]++
+	}
+	var result []netsnapshot.NodeCount
+	for timestamp, count := range counts {
+		result = append(result, netsnapshot.NodeCount{Timestamp: timestamp, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Timestamp < result[j].Timestamp })
+	return result, nil
+}
+
+// nodeGroup is the number of nodes of a snapshot sharing a user agent or a
+// country
+type nodeGroup struct {
+	timestamp int64
+	height    int64
+	key       string
+	nodes     int64
+}
+
+// groupSnapshotNodes counts the nodes of every snapshot by the key returned by
+// keyOf, ordered by timestamp then by count. Only the keys in sources, a |
+// separated list, are counted if it is not empty.
+func (s *Store) groupSnapshotNodes(sources string, keyOf func(*node) string) []nodeGroup {
+	var wanted map[string]bool
+	if sources != "" {
+		wanted = make(map[string]bool)
+		for _, source := range strings.Split(sources, "|") {
+			if source == "Unknown" {
+				source = ""
+			}
+			wanted[source] = true
+		}
+	}
+
+	var groups []nodeGroup
+	for _, snapshot := range s.snapshots {
+		counts := make(map[string]int64)
+		for _, n := range s.snapshotNodes(snapshot.Timestamp) {
+			key := keyOf(n)
+			if wanted == nil || wanted[key] {
+				counts[key]++
+			}
+		}
+		var snapshotGroups []nodeGroup
+		for key, count := range counts {
+			if strings.TrimSpace(key) == "" {
+				key = "Unknown"
+			}
+			snapshotGroups = append(snapshotGroups, nodeGroup{
+				timestamp: snapshot.Timestamp,
+				height:    snapshot.Height,
+				key:       key,
+				nodes:     count,
+			})
+		}
+		sort.Slice(snapshotGroups, func(i, j int) bool {
+			if snapshotGroups[i].nodes != snapshotGroups[j].nodes {
+				return snapshotGroups[i].nodes > snapshotGroups[j].nodes
+			}
+			return snapshotGroups[i].key < snapshotGroups[j].key
+		})
+		groups = append(groups, snapshotGroups...)
+	}
+	return groups
+}
+
+func userAgentOf(n *node) string { return n.UserAgent }
+
+func countryOf(n *node) string { return n.CountryName }
+
+func (s *Store) PeerCountByUserAgents(ctx context.Context, sources string, offset, limit int) ([]netsnapshot.UserAgentInfo, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	groups := s.groupSnapshotNodes(sources, userAgentOf)
+	start, end := page(len(groups), offset, limit)
+	userAgents := make([]netsnapshot.UserAgentInfo, 0, end-start)
+	for _, group := range groups[start:end] {
+		userAgents = append(userAgents, netsnapshot.UserAgentInfo{
+			UserAgent: group.key,
+			Nodes:     group.nodes,
+			Timestamp: group.timestamp,
+		})
+	}
+	return userAgents, int64(len(groups)), nil
+}
+
+func (s *Store) PeerCountByCountries(ctx context.Context, sources string, offset, limit int) ([]netsnapshot.CountryInfo, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	groups := s.groupSnapshotNodes(sources, countryOf)
+	start, end := page(len(groups), offset, limit)
+	countries := make([]netsnapshot.CountryInfo, 0, end-start)
+	for _, group := range groups[start:end] {
+		countries = append(countries, netsnapshot.CountryInfo{
+			Country:   group.key,
+			Nodes:     group.nodes,
+			Timestamp: group.timestamp,
+		})
+	}
+	return countries, int64(len(groups)), nil
+}
+
+func (s *Store) PeerCountByIPVersion(ctx context.Context, timestamp int64, iPVersion int) (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	var count int64
+	for _, n := range s.snapshotNodes(timestamp) {
+		if n.IPVersion == iPVersion {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) LastSnapshotTime(ctx context.Context) (timestamp int64) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	for _, snapshot := range s.snapshots {
+		if snapshot.Height > 0 && snapshot.Timestamp > timestamp {
+			timestamp = snapshot.Timestamp
+		}
+	}
+	return
+}
+
+func (s *Store) LastSnapshot(ctx context.Context) (*netsnapshot.SnapShot, error) {
+	return s.FindNetworkSnapshot(ctx, s.LastSnapshotTime(ctx))
+}
+
+func (s *Store) distinctNodeValues(valueOf func(*node) string) []string {
+	seen := make(map[string]bool)
+	var values []string
+	for _, n := range s.nodes {
+		value := valueOf(n)
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+func (s *Store) AllNodeVersions(ctx context.Context) ([]string, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	versions := s.distinctNodeValues(userAgentOf)
+	sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+	return versions, nil
+}
+
+func (s *Store) AllNodeContries(ctx context.Context) ([]string, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.distinctNodeValues(countryOf), nil
+}
+
+// latestGroups returns the node groups with the most recent snapshot first.
+func latestGroups(groups []nodeGroup) []nodeGroup {
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].timestamp > groups[j].timestamp })
+	return groups
+}
+
+// FetchNodeLocations returns the number of nodes of each country per
+// snapshot, most recent first.
+func (s *Store) FetchNodeLocations(ctx context.Context, offset, limit int) ([]netsnapshot.CountryInfo, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	groups := latestGroups(s.groupSnapshotNodes("", countryOf))
+	start, end := page(len(groups), offset, limit)
+	result := make([]netsnapshot.CountryInfo, 0, end-start)
+	for _, group := range groups[start:end] {
+		result = append(result, netsnapshot.CountryInfo{
+			Country:   group.key,
+			Height:    group.height,
+			Timestamp: group.timestamp,
+			Nodes:     group.nodes,
+		})
+	}
+	return result, int64(len(groups)), nil
+}
+
+// FetchNodeVersion returns the number of nodes of each user agent per
+// snapshot, most recent first.
+func (s *Store) FetchNodeVersion(ctx context.Context, offset, limit int) ([]netsnapshot.UserAgentInfo, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	groups := latestGroups(s.groupSnapshotNodes("", userAgentOf))
+	start, end := page(len(groups), offset, limit)
+	result := make([]netsnapshot.UserAgentInfo, 0, end-start)
+	for _, group := range groups[start:end] {
+		result = append(result, netsnapshot.UserAgentInfo{
+			UserAgent: group.key,
+			Height:    group.height,
+			Timestamp: group.timestamp,
+			Nodes:     group.nodes,
+		})
+	}
+	return result, int64(len(groups)), nil
+}
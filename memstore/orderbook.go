@@ -0,0 +1,77 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/exchanges/orderbook"
+)
+
+func (s *Store) OrderBookSnapshotTableName() string {
+	return orderBookSnapshotTableName
+}
+
+// StoreOrderBookSnapshot saves the snapshot. A snapshot that already exists is
+// ignored.
+func (s *Store) StoreOrderBookSnapshot(ctx context.Context, snapshot orderbook.Snapshot) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	snapshot.Time = snapshot.Time.UTC()
+	for _, existing := range s.orderBookSnapshots {
+		if existing.Exchange == snapshot.Exchange && existing.CurrencyPair == snapshot.CurrencyPair &&
+			existing.Time.Equal(snapshot.Time) {
+			return nil
+		}
+	}
+	snapshot.Depths = append([]orderbook.Depth(nil), snapshot.Depths...)
+	s.orderBookSnapshots = append(s.orderBookSnapshots, snapshot)
+	if err := s.putOrderBookSnapshot(snapshot); err != nil {
+		return err
+	}
+	s.charts.Invalidate(cache.OrderBook)
+	log.Infof("%-9s %7s, stored order book depth at %s", snapshot.Exchange, snapshot.CurrencyPair,
+		snapshot.Time.Format(dateTemplate))
+	return nil
+}
+
+func (s *Store) SaveOrderBookSnapshotFromSync(ctx context.Context, snapshot interface{}) error {
+	return s.StoreOrderBookSnapshot(ctx, snapshot.(orderbook.Snapshot))
+}
+
+func (s *Store) lastOrderBookSnapshotTime() (last time.Time) {
+	for _, snapshot := range s.orderBookSnapshots {
+		if snapshot.Time.After(last) {
+			last = snapshot.Time
+		}
+	}
+	return
+}
+
+func (s *Store) LastOrderBookSnapshotTime() time.Time {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.lastOrderBookSnapshotTime()
+}
+
+// FetchOrderBookSnapshotsForSync returns the snapshots taken after date
+func (s *Store) FetchOrderBookSnapshotsForSync(ctx context.Context, date time.Time, skip, take int) ([]orderbook.Snapshot, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var snapshots []orderbook.Snapshot
+	for _, snapshot := range s.orderBookSnapshots {
+		if snapshot.Time.After(date) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+	start, end := page(len(snapshots), skip, take)
+	return snapshots[start:end], int64(len(snapshots)), nil
+}
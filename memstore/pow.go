@@ -0,0 +1,225 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/app/helpers"
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/postgres/models"
+	"github.com/planetdecred/dcrextdata/pow"
+)
+
+func (s *Store) PowTableName() string {
+	return models.TableNames.PowData
+}
+
+func (s *Store) lastPowEntryTime(source string) (last int64) {
+	for _, data := range s.powData {
+		if (source == "" || data.Source == source) && data.Time > last {
+			last = data.Time
+		}
+	}
+	return
+}
+
+func (s *Store) LastPowEntryTime(source string) int64 {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.lastPowEntryTime(source)
+}
+
+// addPowData stores data unless an entry of the same source and time exists.
+// The pool hashrate is kept in Th/s like the postgres store does.
+func (s *Store) addPowData(data pow.PowData, hashrateInTh bool) (bool, error) {
+	for _, existing := range s.powData {
+		if existing.Time == data.Time && existing.Source == data.Source {
+			return false, nil
+		}
+	}
+	if !hashrateInTh {
+		data.PoolHashrate = math.Round(data.PoolHashrate / pow.Thash)
+	}
+	s.powData = append(s.powData, data)
+	return true, s.putPowData(data)
+}
+
+func (s *Store) AddPowData(ctx context.Context, data []pow.PowData) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	added := 0
+	for _, d := range data {
+		stored, err := s.addPowData(d, false)
+		if err != nil {
+			return err
+		}
+		if stored {
+			added++
+		}
+	}
+	if added > 0 {
+		s.charts.Invalidate(cache.PowChart)
+		last := data[len(data)-1]
+		log.Infof("Added %4d PoW entries from %10s %s to %s", added, last.Source,
+			helpers.UnixTime(data[0].Time).Format(dateTemplate), helpers.UnixTime(last.Time).Format(dateTemplate))
+	}
+	return nil
+}
+
+func (s *Store) AddPowDataFromSync(ctx context.Context, data interface{}) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	stored, err := s.addPowData(data.(pow.PowData), true)
+	if stored && err == nil {
+		s.charts.Invalidate(cache.PowChart)
+	}
+	return err
+}
+
+func (s *Store) PowCount(ctx context.Context) (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return int64(len(s.powData)), nil
+}
+
+// filterPowData returns the entries of source, all sources if source is
+// empty, ordered by time.
+func (s *Store) filterPowData(source string, desc bool) []pow.PowData {
+	var result []pow.PowData
+	for _, data := range s.powData {
+		if source == "" || data.Source == source {
+			result = append(result, data)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if desc {
+			return result[i].Time > result[j].Time
+		}
+		return result[i].Time < result[j].Time
+	})
+	return result
+}
+
+func powDataToDto(data pow.PowData) pow.PowDataDto {
+	return pow.PowDataDto{
+		Time:           helpers.UnixTime(data.Time).Format(dateTemplate),
+		PoolHashrateTh: fmt.Sprintf("%.0f", data.PoolHashrate),
+		Workers:        data.Workers,
+		Source:         data.Source,
+		CoinPrice:      data.CoinPrice,
+		BtcPrice:       data.BtcPrice,
+	}
+}
+
+func (s *Store) powDtoPage(source string, offset, limit int) ([]pow.PowDataDto, int64) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	data := s.filterPowData(source, true)
+	start, end := page(len(data), offset, limit)
+	var result []pow.PowDataDto
+	for _, d := range data[start:end] {
+		result = append(result, powDataToDto(d))
+	}
+	return result, int64(len(data))
+}
+
+func (s *Store) FetchPowData(ctx context.Context, offset, limit int) ([]pow.PowDataDto, int64, error) {
+	result, total := s.powDtoPage("", offset, limit)
+	return result, total, nil
+}
+
+func (s *Store) FetchPowDataBySource(ctx context.Context, source string, offset, limit int) ([]pow.PowDataDto, int64, error) {
+	result, total := s.powDtoPage(source, offset, limit)
+	return result, total, nil
+}
+
+// FetchPowDataForSync returns PoW data for the sync operation
+func (s *Store) FetchPowDataForSync(ctx context.Context, date int64, skip, take int) ([]pow.PowData, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var result []pow.PowData
+	for _, data := range s.filterPowData("", false) {
+		if data.Time > date {
+			result = append(result, data)
+		}
+	}
+	start, end := page(len(result), skip, take)
+	return result[start:end], int64(len(result)), nil
+}
+
+func (s *Store) GetPowDistinctDates(ctx context.Context, sources []string) ([]time.Time, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	wanted := make(map[string]bool)
+	for _, source := range sources {
+		wanted[source] = true
+	}
+	seen := make(map[int64]bool)
+	var dates []time.Time
+	for _, data := range s.filterPowData("", false) {
+		if wanted[data.Source] && !seen[data.Time] {
+			seen[data.Time] = true
+			dates = append(dates, helpers.UnixTime(data.Time).UTC())
+		}
+	}
+	return dates, nil
+}
+
+// FetchPowChartData returns the values of the dataType column of the postgres
+// pow_data table for the given source.
+func (s *Store) FetchPowChartData(ctx context.Context, source string, dataType string) ([]pow.PowChartData, error) {
+	var record func(pow.PowData) string
+	switch strings.ToLower(dataType) {
+	case models.PowDatumColumns.Workers:
+		record = func(data pow.PowData) string { return fmt.Sprint(data.Workers) }
+	case models.PowDatumColumns.PoolHashrate:
+		record = func(data pow.PowData) string { return fmt.Sprintf("%.0f", data.PoolHashrate) }
+	case models.PowDatumColumns.CoinPrice:
+		record = func(data pow.PowData) string { return fmt.Sprint(data.CoinPrice) }
+	case models.PowDatumColumns.BTCPrice:
+		record = func(data pow.PowData) string { return fmt.Sprint(data.BtcPrice) }
+	default:
+		return nil, fmt.Errorf("unsupported PoW data type, %s", dataType)
+	}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	var records []pow.PowChartData
+	for _, data := range s.filterPowData(source, false) {
+		records = append(records, pow.PowChartData{
+			Date:   helpers.UnixTime(data.Time),
+			Record: record(data),
+		})
+	}
+	return records, nil
+}
+
+func (s *Store) FetchPowSourceData(ctx context.Context) ([]pow.PowDataSource, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	seen := make(map[string]bool)
+	var result []pow.PowDataSource
+	for _, data := range s.powData {
+		if !seen[data.Source] {
+			seen[data.Source] = true
+			result = append(result, pow.PowDataSource{Source: data.Source})
+		}
+	}
+	return result, nil
+}
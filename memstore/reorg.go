@@ -0,0 +1,159 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/app/helpers"
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/planetdecred/dcrextdata/postgres/models"
+)
+
+// orphanedBlock is a block that was disconnected from the main chain.
+type orphanedBlock struct {
+	mempool.Block
+	OrphanedAt time.Time
+}
+
+// orphanedVote is a vote for an orphaned block.
+type orphanedVote struct {
+	mempool.Vote
+	OrphanedAt time.Time
+}
+
+func (s *Store) ReorgTableName() string {
+	return reorgTableName
+}
+
+// OrphanBlock moves a block that was disconnected from the main chain and the
+// votes for it to the orphaned records, and truncates the propagation and vote
+// deviation bins that included them.
+func (s *Store) OrphanBlock(ctx context.Context, hash string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	now := helpers.NowUTC()
+	var since time.Time
+	earliest := func(t time.Time) {
+		if since.IsZero() || t.Before(since) {
+			since = t
+		}
+	}
+
+	// the records are replaced once every journal write succeeded, so that
+	// a failure leaves them unchanged
+	blocks := make([]mempool.Block, 0, len(s.blocks))
+	var orphanedBlocks []orphanedBlock
+	for _, block := range s.blocks {
+		if block.BlockHash != hash {
+			blocks = append(blocks, block)
+			continue
+		}
+		earliest(block.BlockInternalTime)
+		earliest(block.BlockReceiveTime)
+		orphan := orphanedBlock{Block: block, OrphanedAt: now}
+		orphanedBlocks = append(orphanedBlocks, orphan)
+		if err := s.putOrphanedBlock(orphan); err != nil {
+			return err
+		}
+		if err := s.remove(models.TableNames.Block, numberKey(int64(block.BlockHeight))); err != nil {
+			return err
+		}
+	}
+
+	votes := make([]mempool.Vote, 0, len(s.votes))
+	var orphanedVotes []orphanedVote
+	for _, vote := range s.votes {
+		if vote.BlockHash != hash {
+			votes = append(votes, vote)
+			continue
+		}
+		earliest(vote.TargetedBlockTime)
+		orphan := orphanedVote{Vote: vote, OrphanedAt: now}
+		orphanedVotes = append(orphanedVotes, orphan)
+		if err := s.putOrphanedVote(orphan); err != nil {
+			return err
+		}
+		if err := s.remove(models.TableNames.Vote, timeKey(vote.ReceiveTime, vote.Hash)); err != nil {
+			return err
+		}
+	}
+
+	s.blocks, s.votes = blocks, votes
+	s.orphanedBlocks = append(s.orphanedBlocks, orphanedBlocks...)
+	s.orphanedVotes = append(s.orphanedVotes, orphanedVotes...)
+
+	if since.IsZero() {
+		return nil
+	}
+	dayStart := uint64(since.UTC().Truncate(cache.ADay * time.Second).Unix())
+	for series := range s.bins {
+		if series == blockSeries || series == voteSeries || strings.HasPrefix(series, propagationSeries) {
+			s.truncateBins(series, dayStart)
+		}
+	}
+	s.charts.Invalidate(cache.Propagation)
+	log.Infof("Orphaned block %s", hash)
+	return nil
+}
+
+// truncateBins removes the points of series, at every bin level, from since
+// so that they are built again by the next update. The caller must hold the
+// lock.
+func (s *Store) truncateBins(series string, since uint64) {
+	for bin, points := range s.bins[series] {
+		end := sort.Search(len(points), func(i int) bool { return points[i].time >= since })
+		s.bins[series][bin] = points[:end]
+	}
+}
+
+// SaveReorg saves a chain reorganization. The reorganizations that already
+// exist are ignored.
+func (s *Store) SaveReorg(ctx context.Context, reorg mempool.Reorg) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, r := range s.reorgs {
+		if r.OldTipHash == reorg.OldTipHash {
+			return nil
+		}
+	}
+	reorg.Time = reorg.Time.UTC()
+	s.reorgs = append(s.reorgs, reorg)
+	log.Infof("Chain reorganization of depth %d from %d to %d", reorg.Depth, reorg.OldTipHeight, reorg.NewTipHeight)
+	return s.putReorg(reorg)
+}
+
+// SaveReorgFromSync orphans the blocks of a synced reorganization and saves
+// it.
+func (s *Store) SaveReorgFromSync(ctx context.Context, reorgData interface{}) error {
+	reorg := reorgData.(mempool.Reorg)
+	for _, hash := range reorg.OrphanedBlocks {
+		if err := s.OrphanBlock(ctx, hash); err != nil {
+			return err
+		}
+	}
+	return s.SaveReorg(ctx, reorg)
+}
+
+// FetchReorgsForSync returns the reorganizations that happened after date
+func (s *Store) FetchReorgsForSync(ctx context.Context, date time.Time, offset int, limit int) ([]mempool.Reorg, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var result []mempool.Reorg
+	for _, reorg := range s.reorgs {
+		if reorg.Time.After(date) {
+			result = append(result, reorg)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Time.Before(result[j].Time) })
+	start, end := page(len(result), offset, limit)
+	return result[start:end], int64(len(result)), nil
+}
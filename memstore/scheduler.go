@@ -0,0 +1,29 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"time"
+)
+
+// LastJobRuns returns the time of the last run of every scheduled job.
+func (s *Store) LastJobRuns(ctx context.Context) (map[string]time.Time, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	lastRuns := make(map[string]time.Time, len(s.jobRuns))
+	for name, lastRun := range s.jobRuns {
+		lastRuns[name] = lastRun
+	}
+	return lastRuns, nil
+}
+
+// SaveJobRun records lastRun as the time of the last run of the job name.
+func (s *Store) SaveJobRun(ctx context.Context, name string, lastRun time.Time) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.jobRuns[name] = lastRun.UTC()
+	return s.putJobRun(name, lastRun.UTC())
+}
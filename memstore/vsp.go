@@ -0,0 +1,288 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/app/helpers"
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/datasync"
+	"github.com/planetdecred/dcrextdata/postgres/models"
+	"github.com/planetdecred/dcrextdata/vsp"
+)
+
+func (s *Store) VspTableName() string {
+	return models.TableNames.VSP
+}
+
+func (s *Store) VspTickTableName() string {
+	return models.TableNames.VSPTick
+}
+
+func (s *Store) vspByName(name string) (vsp.VSPDto, bool) {
+	for _, v := range s.vsps {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return vsp.VSPDto{}, false
+}
+
+func (s *Store) vspName(id int) string {
+	for _, v := range s.vsps {
+		if v.ID == id {
+			return v.Name
+		}
+	}
+	return ""
+}
+
+func (s *Store) hasVspTick(vspID int, tickTime time.Time) bool {
+	for _, tick := range s.vspTicks {
+		if tick.VSPID == vspID && tick.Time.Equal(tickTime) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) addVspTick(tick datasync.VSPTickSyncDto) error {
+	if tick.ID == 0 {
+		tick.ID = 1
+		if n := len(s.vspTicks); n > 0 {
+			tick.ID = s.vspTicks[n-1].ID + 1
+		}
+	}
+	s.vspTicks = append(s.vspTicks, tick)
+	return s.putVspTick(tick)
+}
+
+// StoreVSPs stores a tick for each VSP of data, adding the VSPs that are not
+// known yet. It returns the number of ticks stored.
+func (s *Store) StoreVSPs(ctx context.Context, data vsp.Response) (int, []error) {
+	if ctx.Err() != nil {
+		return 0, []error{ctx.Err()}
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	completed := 0
+	var errs []error
+	for name, resp := range data {
+		pool, found := s.vspByName(name)
+		if !found {
+			id := 1
+			if n := len(s.vsps); n > 0 {
+				id = s.vsps[n-1].ID + 1
+			}
+			pool = vsp.VSPDto{
+				ID:                   id,
+				Name:                 name,
+				APIEnabled:           resp.APIEnabled,
+				APIVersionsSupported: resp.APIVersionsSupported,
+				Network:              resp.Network,
+				URL:                  resp.URL,
+				Launched:             helpers.UnixTime(resp.Launched),
+			}
+			s.vsps = append(s.vsps, pool)
+			if err := s.putVsp(pool); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		tickTime := helpers.UnixTime(resp.LastUpdated)
+		if s.hasVspTick(pool.ID, tickTime) {
+			continue
+		}
+		err := s.addVspTick(datasync.VSPTickSyncDto{
+			VSPID:            pool.ID,
+			Immature:         resp.Immature,
+			Live:             resp.Live,
+			Voted:            resp.Voted,
+			Missed:           resp.Missed,
+			PoolFees:         resp.PoolFees,
+			ProportionLive:   resp.ProportionLive,
+			ProportionMissed: resp.ProportionMissed,
+			UserCount:        resp.UserCount,
+			UsersActive:      resp.UserCountActive,
+			Time:             tickTime,
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		completed++
+	}
+	if completed == 0 {
+		log.Info("Unable to store any vsp entry")
+	} else {
+		s.charts.Invalidate(cache.VSP)
+	}
+	return completed, errs
+}
+
+func (s *Store) vspDtos(keep func(vsp.VSPDto) bool) ([]vsp.VSPDto, error) {
+	var result []vsp.VSPDto
+	for _, v := range s.vsps {
+		if !keep(v) {
+			continue
+		}
+		parsedURL, err := url.Parse(v.URL)
+		if err != nil {
+			return nil, err
+		}
+		v.Host = parsedURL.Host
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+func (s *Store) FetchVSPs(ctx context.Context) ([]vsp.VSPDto, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	result, err := s.vspDtos(func(vsp.VSPDto) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].URL != result[j].URL {
+			return result[i].URL < result[j].URL
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func (s *Store) AddVspSourceFromSync(ctx context.Context, vspData interface{}) error {
+	vspDto := vspData.(vsp.VSPDto)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, found := s.vspByName(vspDto.Name); found {
+		return nil
+	}
+	vspDto.Host = ""
+	s.vsps = append(s.vsps, vspDto)
+	sort.Slice(s.vsps, func(i, j int) bool { return s.vsps[i].ID < s.vsps[j].ID })
+	return s.putVsp(vspDto)
+}
+
+func (s *Store) FetchVspSourcesForSync(ctx context.Context, lastID int64, skip, take int) ([]vsp.VSPDto, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	result, err := s.vspDtos(func(v vsp.VSPDto) bool { return int64(v.ID) > lastID })
+	if err != nil {
+		return nil, 0, err
+	}
+	start, end := page(len(result), skip, take)
+	return result[start:end], int64(len(result)), nil
+}
+
+func (s *Store) FetchVspTicksForSync(ctx context.Context, lastID int64, skip, take int) ([]datasync.VSPTickSyncDto, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var result []datasync.VSPTickSyncDto
+	for _, tick := range s.vspTicks {
+		if int64(tick.ID) > lastID {
+			result = append(result, tick)
+		}
+	}
+	start, end := page(len(result), skip, take)
+	return result[start:end], int64(len(result)), nil
+}
+
+func (s *Store) AddVspTicksFromSync(ctx context.Context, tick datasync.VSPTickSyncDto) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.hasVspTick(tick.VSPID, tick.Time) {
+		return nil
+	}
+	err := s.addVspTick(tick)
+	sort.SliceStable(s.vspTicks, func(i, j int) bool { return s.vspTicks[i].ID < s.vspTicks[j].ID })
+	s.charts.Invalidate(cache.VSP)
+	return err
+}
+
+func (s *Store) vspTickPage(vspID int, offset, limit int) ([]vsp.VSPTickDto, int64) {
+	var tickSlice []datasync.VSPTickSyncDto
+	for _, tick := range s.vspTicks {
+		if vspID == 0 || tick.VSPID == vspID {
+			tickSlice = append(tickSlice, tick)
+		}
+	}
+	sort.SliceStable(tickSlice, func(i, j int) bool { return tickSlice[i].Time.After(tickSlice[j].Time) })
+
+	start, end := page(len(tickSlice), offset, limit)
+	var vspTicks []vsp.VSPTickDto
+	for _, tick := range tickSlice[start:end] {
+		vspTicks = append(vspTicks, vsp.VSPTickDto{
+			ID:               tick.ID,
+			VSP:              s.vspName(tick.VSPID),
+			Time:             tick.Time.Format(dateTemplate),
+			Immature:         tick.Immature,
+			Live:             tick.Live,
+			Missed:           tick.Missed,
+			PoolFees:         tick.PoolFees,
+			ProportionLive:   roundValue(tick.ProportionLive),
+			ProportionMissed: roundValue(tick.ProportionMissed),
+			UserCount:        tick.UserCount,
+			UsersActive:      tick.UsersActive,
+			Voted:            tick.Voted,
+		})
+	}
+	return vspTicks, int64(len(tickSlice))
+}
+
+func roundValue(input float64) string {
+	return strconv.FormatFloat(input*100, 'f', 3, 64)
+}
+
+func (s *Store) FilteredVSPTicks(ctx context.Context, vspName string, offset, limit int) ([]vsp.VSPTickDto, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	pool, found := s.vspByName(vspName)
+	if !found {
+		return nil, 0, fmt.Errorf("vsp %s not found", vspName)
+	}
+	vspTicks, total := s.vspTickPage(pool.ID, offset, limit)
+	return vspTicks, total, nil
+}
+
+func (s *Store) AllVSPTicks(ctx context.Context, offset, limit int) ([]vsp.VSPTickDto, int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	vspTicks, total := s.vspTickPage(0, offset, limit)
+	return vspTicks, total, nil
+}
+
+func (s *Store) LastVspTickEntryTime() (last time.Time) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	for _, tick := range s.vspTicks {
+		if tick.Time.After(last) {
+			last = tick.Time
+		}
+	}
+	return
+}
+
+func (s *Store) VspTickCount(ctx context.Context) (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return int64(len(s.vspTicks)), nil
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import "time"
+
+// collectionBuckets bound the duration, in seconds, of a collection cycle,
+// from a single API call to a full network crawl.
+var collectionBuckets = []float64{.1, .5, 1, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+var (
+	collectionCycles = NewCounterVec("dcrextdata_collection_cycles_total",
+		"Collection cycles run, by module and result.", "module", "result")
+	collectionDuration = NewHistogramVec("dcrextdata_collection_duration_seconds",
+		"Duration of the collection cycles, by module.", collectionBuckets, "module")
+)
+
+// CollectionCycle records a collection cycle of module that started at start
+// and ended with err.
+func CollectionCycle(module string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	collectionCycles.Inc(module, result)
+	collectionDuration.ObserveSince(start, module)
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("requests_total", "Requests served.", "route")
+	latency := r.NewHistogramVec("latency_seconds", "Request latency.", []float64{.1, 1}, "route")
+
+	requests.Inc("/b")
+	requests.Add(2, `/a"`)
+	latency.Observe(.05, "/a")
+	latency.Observe(.5, "/a")
+	latency.Observe(5, "/a")
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 1
+latency_seconds_bucket{route="/a",le="1"} 2
+latency_seconds_bucket{route="/a",le="+Inf"} 3
+latency_seconds_sum{route="/a"} 5.55
+latency_seconds_count{route="/a"} 3
+# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{route="/a\""} 2
+requests_total{route="/b"} 1
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected exposition, got\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("requests_total", "Requests served.")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic when registering a name twice")
+		}
+	}()
+	r.NewCounterVec("requests_total", "Requests served.")
+}
@@ -9,12 +9,12 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"sync"
 	"time"
 
 	"github.com/decred/dcrd/chaincfg/v2"
 	"github.com/planetdecred/dcrextdata/app/config"
 	"github.com/planetdecred/dcrextdata/app/helpers"
+	"github.com/planetdecred/dcrextdata/scheduler"
 )
 
 var snapshotinterval int
@@ -28,10 +28,73 @@ func NewTaker(store DataStore, cfg config.NetworkSnapshotOptions) *taker {
 	return &taker{
 		dataStore: store,
 		cfg:       cfg,
+		started:   make(chan struct{}),
 	}
 }
 
-func (t taker) Start(ctx context.Context) {
+// SetPublisher sets the publisher of the node heartbeats that are saved.
+func (t *taker) SetPublisher(publisher Publisher) {
+	t.publisher = publisher
+}
+
+// RegisterJobs adds the network snapshot to s. Each run saves the current
+// snapshot and starts the next one, the first run waits for an interval of
+// crawling.
+func (t *taker) RegisterJobs(s *scheduler.Scheduler) error {
+	interval := time.Duration(t.cfg.SnapshotInterval) * time.Minute
+	return s.AddJob(scheduler.Job{
+		Name:     "netsnapshot",
+		Interval: interval,
+		Timeout:  interval,
+		Deferred: true,
+		Run:      t.takeSnapshot,
+	})
+}
+
+// loadLiveNodes queues the known reachable nodes for connection.
+func (t *taker) loadLiveNodes(ctx context.Context) {
+	nodes, err := t.dataStore.GetAvailableNodes(ctx)
+	if err != nil {
+		log.Errorf("Error in taking network snapshot, %s", err.Error())
+	}
+	amgr.setLiveNodes(nodes)
+}
+
+func (t *taker) takeSnapshot(ctx context.Context) error {
+	select {
+	case <-t.started:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	t.mtx.Lock()
+	snapshot := SnapShot{
+		Timestamp: t.timestamp,
+		Height:    t.bestBlockHeight,
+		NodeCount: len(amgr.nodes),
+	}
+	count := t.count
+	t.count = 0
+	t.timestamp = time.Now().UTC().Unix()
+	t.mtx.Unlock()
+
+	if err := t.dataStore.SaveSnapshot(ctx, snapshot); err != nil {
+		t.dataStore.DeleteSnapshot(ctx, snapshot.Timestamp)
+		return fmt.Errorf("error in saving network snapshot, %s", err.Error())
+	}
+	if err := t.dataStore.UpdateSnapshotNodesBin(ctx); err != nil {
+		log.Errorf("Error in network snapshot bin update, %s", err.Error())
+	}
+	log.Infof("Took a new network snapshot, recorded %d discoverable nodes.", count)
+
+	// update all reachable nodes
+	t.loadLiveNodes(ctx)
+	return nil
+}
+
+// Start connects to the network nodes and records their heartbeats in the
+// current snapshot until ctx is canceled.
+func (t *taker) Start(ctx context.Context) {
 	log.Info("Triggering network snapshot taker.")
 
 	var netParams = chaincfg.MainNetParams()
@@ -49,28 +112,14 @@ func (t taker) Start(ctx context.Context) {
 		os.Exit(1)
 	}
 
-	// update all reachable nodes
-	loadLiveNodes := func() {
-		nodes, err := t.dataStore.GetAvailableNodes(ctx)
-		if err != nil {
-			log.Errorf("Error in taking network snapshot, %s", err.Error())
-		}
-		amgr.setLiveNodes(nodes)
-	}
-
 	// enqueue previous known ips
-	loadLiveNodes()
+	t.loadLiveNodes(ctx)
 
 	go runSeeder(t.cfg, netParams)
 
-	var mtx sync.Mutex
-	var bestBlockHeight int64
-
-	var count int
 	var timestamp = time.Now().UTC().Unix()
 	snapshot := SnapShot{
 		Timestamp: timestamp,
-		Height:    bestBlockHeight,
 	}
 
 	lastSnapshot, err := t.dataStore.LastSnapshot(ctx)
@@ -90,40 +139,24 @@ func (t taker) Start(ctx context.Context) {
 		}
 	}
 
-	ticker := time.NewTicker(time.Duration(t.cfg.SnapshotInterval) * time.Minute)
-	defer ticker.Stop()
+	t.mtx.Lock()
+	t.timestamp = timestamp
+	t.bestBlockHeight = snapshot.Height
+	t.mtx.Unlock()
+	close(t.started)
 
 	for {
 		// start listening for node heartbeat
 		select {
-		case <-ticker.C:
-			err := t.dataStore.SaveSnapshot(ctx, SnapShot{
-				Timestamp: timestamp,
-				Height:    bestBlockHeight,
-				NodeCount: len(amgr.nodes),
-			})
-
-			if err != nil {
-				t.dataStore.DeleteSnapshot(ctx, timestamp)
-				log.Errorf("Error in saving network snapshot, %s", err.Error())
-			}
-			if err = t.dataStore.UpdateSnapshotNodesBin(ctx); err != nil {
-				log.Errorf("Error in initial network snapshot bin update, %s", err.Error())
-			}
-
-			mtx.Lock()
-			count = 0
-			log.Infof("Took a new network snapshot, recorded %d discoverable nodes.", count)
-			timestamp = time.Now().UTC().Unix()
-			mtx.Unlock()
-			// update all reachable nodes
-			loadLiveNodes()
-
 		case node := <-amgr.peerNtfn:
 			if node.IP.String() == "127.0.0.1" { // do not add the local IP
 				break
 			}
 
+			t.mtx.Lock()
+			timestamp := t.timestamp
+			t.mtx.Unlock()
+
 			networkPeer := NetworkPeer{
 				Timestamp:       timestamp,
 				Address:         node.IP.String(),
@@ -164,24 +197,28 @@ func (t taker) Start(ctx context.Context) {
 				}
 			}
 
-			err = t.dataStore.SaveHeartbeat(ctx, Heartbeat{
+			heartbeat := Heartbeat{
 				Timestamp: timestamp,
 				Address:   node.IP.String(),
 				LastSeen:  node.LastSeen.UTC().Unix(),
 				Latency:   int(node.Latency),
-			})
+			}
+			err = t.dataStore.SaveHeartbeat(ctx, heartbeat)
 			if err != nil {
 				log.Errorf("Error in saving node info, %s.", err.Error())
 			} else {
-				mtx.Lock()
-				count++
-				if node.CurrentHeight > bestBlockHeight {
-					bestBlockHeight = node.CurrentHeight
+				if t.publisher != nil {
+					t.publisher.Publish(HeartbeatTopic, heartbeat)
+				}
+				t.mtx.Lock()
+				t.count++
+				if node.CurrentHeight > t.bestBlockHeight {
+					t.bestBlockHeight = node.CurrentHeight
 				}
 
 				snapshot := SnapShot{
 					Timestamp: timestamp,
-					Height:    bestBlockHeight,
+					Height:    t.bestBlockHeight,
 				}
 
 				snapshot.NodeCount = len(amgr.nodes)
@@ -192,7 +229,7 @@ func (t taker) Start(ctx context.Context) {
 					log.Errorf("Error in saving network snapshot, %s", err.Error())
 				}
 
-				mtx.Unlock()
+				t.mtx.Unlock()
 				log.Debugf("New heartbeat recorded for node: %s, %s, %d", node.IP.String(),
 					node.UserAgent, node.ProtocolVersion)
 			}
@@ -215,7 +252,7 @@ func (t taker) Start(ctx context.Context) {
 	}
 }
 
-func (t taker) geolocation(ctx context.Context, ip net.IP) (*IPInfo, error) {
+func (t *taker) geolocation(ctx context.Context, ip net.IP) (*IPInfo, error) {
 	// IP stack access key verification
 	if t.cfg.IpStackAccessKey == "" {
 		return nil, errors.New("IP stack access key is required")
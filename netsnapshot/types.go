@@ -3,6 +3,7 @@ package netsnapshot
 import (
 	"context"
 	"net"
+	"sync"
 
 	"github.com/planetdecred/dcrextdata/app/config"
 )
@@ -90,7 +91,24 @@ type DataStore interface {
 	NodeExists(ctx context.Context, address string) (bool, error)
 }
 
+// HeartbeatTopic is the topic of the heartbeats published by the taker.
+const HeartbeatTopic = "heartbeat"
+
+// Publisher receives the heartbeats as they are saved.
+type Publisher interface {
+	Publish(topic string, data interface{})
+}
+
 type taker struct {
 	dataStore DataStore
 	cfg       config.NetworkSnapshotOptions
+	publisher Publisher
+
+	// started is closed once Start has set the address manager up
+	started chan struct{}
+
+	mtx             sync.Mutex
+	timestamp       int64
+	bestBlockHeight int64
+	count           int
 }
@@ -0,0 +1,302 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/commstats"
+	"github.com/planetdecred/dcrextdata/datasync"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/planetdecred/dcrextdata/netsnapshot"
+	"github.com/planetdecred/dcrextdata/postgres/models"
+	"github.com/planetdecred/dcrextdata/pow"
+	"github.com/planetdecred/dcrextdata/web"
+	"github.com/volatiletech/null"
+	"github.com/volatiletech/sqlboiler/queries/qm"
+)
+
+var _ web.APIQuery = (*PgDb)(nil)
+
+// apiPageMods returns the query mods that select page from a table ordered by
+// timeColumn, then by keyColumn if the times are not distinct. timeArg
+// converts a time to the type of timeColumn.
+func apiPageMods(page web.APIPage, timeColumn, keyColumn string, timeArg func(time.Time) interface{}) []qm.QueryMod {
+	var mods []qm.QueryMod
+	if !page.Start.IsZero() {
+		mods = append(mods, qm.Where(timeColumn+" >= ?", timeArg(page.Start)))
+	}
+	if !page.End.IsZero() {
+		mods = append(mods, qm.Where(timeColumn+" < ?", timeArg(page.End)))
+	}
+	if page.After != nil {
+		after := timeArg(page.After.Time)
+		if keyColumn == "" {
+			mods = append(mods, qm.Where(timeColumn+" > ?", after))
+		} else {
+			mods = append(mods, qm.Where(fmt.Sprintf("(%[1]s > ? OR (%[1]s = ? AND %[2]s > ?))", timeColumn, keyColumn),
+				after, after, page.After.Key))
+		}
+	}
+
+	orderBy := timeColumn
+	if keyColumn != "" {
+		orderBy += ", " + keyColumn
+	}
+	return append(mods, qm.OrderBy(orderBy), qm.Limit(page.Limit))
+}
+
+func utcTime(t time.Time) interface{} {
+	return t.UTC()
+}
+
+func unixTime(t time.Time) interface{} {
+	return t.Unix()
+}
+
+func (pg *PgDb) APIExchangeTicks(ctx context.Context, filter web.APITickFilter, page web.APIPage) ([]ticks.TickSyncDto, error) {
+	query := []qm.QueryMod{qm.Load("Exchange")}
+	if filter.Exchange != "" {
+		exchange, err := models.Exchanges(models.ExchangeWhere.Name.EQ(filter.Exchange)).One(ctx, pg.db)
+		if err == sql.ErrNoRows {
+			return []ticks.TickSyncDto{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		query = append(query, models.ExchangeTickWhere.ExchangeID.EQ(exchange.ID))
+	}
+	if filter.CurrencyPair != "" {
+		query = append(query, models.ExchangeTickWhere.CurrencyPair.EQ(filter.CurrencyPair))
+	}
+	if filter.Interval > 0 {
+		query = append(query, models.ExchangeTickWhere.Interval.EQ(filter.Interval))
+	}
+	query = append(query, apiPageMods(page, models.ExchangeTickColumns.Time, models.ExchangeTickColumns.ID, utcTime)...)
+
+	tickSlice, err := models.ExchangeTicks(query...).All(ctx, pg.db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ticks.TickSyncDto, len(tickSlice))
+	for i, tick := range tickSlice {
+		result[i] = ticks.TickSyncDto{
+			ID:           tick.ID,
+			ExchangeID:   tick.ExchangeID,
+			ExchangeName: tick.R.Exchange.Name,
+			Interval:     tick.Interval,
+			CurrencyPair: tick.CurrencyPair,
+			Time:         tick.Time,
+			High:         tick.High,
+			Low:          tick.Low,
+			Open:         tick.Open,
+			Close:        tick.Close,
+			Volume:       tick.Volume,
+		}
+	}
+	return result, nil
+}
+
+func (pg *PgDb) APIVSPTicks(ctx context.Context, vspName string, page web.APIPage) ([]datasync.VSPTickSyncDto, error) {
+	query := []qm.QueryMod{qm.Load(models.VSPTickRels.VSP)}
+	if vspName != "" {
+		vspInfo, err := models.VSPS(models.VSPWhere.Name.EQ(null.StringFrom(vspName))).One(ctx, pg.db)
+		if err == sql.ErrNoRows {
+			return []datasync.VSPTickSyncDto{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		query = append(query, models.VSPTickWhere.VSPID.EQ(vspInfo.ID))
+	}
+	query = append(query, apiPageMods(page, models.VSPTickColumns.Time, models.VSPTickColumns.ID, utcTime)...)
+
+	tickSlice, err := models.VSPTicks(query...).All(ctx, pg.db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]datasync.VSPTickSyncDto, len(tickSlice))
+	for i, tick := range tickSlice {
+		result[i] = pg.vspTickModelToSyncDto(tick)
+		result[i].VSP = tick.R.VSP.Name.String
+	}
+	return result, nil
+}
+
+func (pg *PgDb) APIPowData(ctx context.Context, source string, page web.APIPage) ([]pow.PowData, error) {
+	var query []qm.QueryMod
+	if source != "" {
+		query = append(query, models.PowDatumWhere.Source.EQ(source))
+	}
+	query = append(query, apiPageMods(page, models.PowDatumColumns.Time, models.PowDatumColumns.Source, unixTime)...)
+
+	powSlice, err := models.PowData(query...).All(ctx, pg.db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]pow.PowData, len(powSlice))
+	for i, item := range powSlice {
+		if result[i], err = pg.powDataModelToDomainObj(item); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (pg *PgDb) APIMempool(ctx context.Context, page web.APIPage) ([]mempool.Mempool, error) {
+	mempoolSlice, err := models.Mempools(apiPageMods(page, models.MempoolColumns.Time, "", utcTime)...).All(ctx, pg.db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]mempool.Mempool, len(mempoolSlice))
+	for i, m := range mempoolSlice {
+		result[i] = mempool.Mempool{
+			Time:                 m.Time,
+			FirstSeenTime:        m.FirstSeenTime.Time,
+			NumberOfTransactions: m.NumberOfTransactions.Int,
+			Voters:               m.Voters.Int,
+			Tickets:              m.Tickets.Int,
+			Revocations:          m.Revocations.Int,
+			Size:                 int32(m.Size.Int),
+			TotalFee:             m.TotalFee.Float64,
+			Total:                m.Total.Float64,
+		}
+	}
+	return result, nil
+}
+
+func (pg *PgDb) APIBlocks(ctx context.Context, page web.APIPage) ([]mempool.Block, error) {
+	blockSlice, err := models.Blocks(
+		apiPageMods(page, models.BlockColumns.ReceiveTime, models.BlockColumns.Height, utcTime)...).All(ctx, pg.db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]mempool.Block, len(blockSlice))
+	for i, block := range blockSlice {
+		result[i] = mempool.Block{
+			BlockHash:         block.Hash.String,
+			BlockHeight:       uint32(block.Height),
+			BlockInternalTime: block.InternalTimestamp.Time,
+			BlockReceiveTime:  block.ReceiveTime.Time,
+		}
+	}
+	return result, nil
+}
+
+func (pg *PgDb) APIVotes(ctx context.Context, page web.APIPage) ([]mempool.Vote, error) {
+	voteSlice, err := models.Votes(
+		apiPageMods(page, models.VoteColumns.ReceiveTime, models.VoteColumns.Hash, utcTime)...).All(ctx, pg.db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]mempool.Vote, len(voteSlice))
+	for i, vote := range voteSlice {
+		result[i] = mempool.Vote{
+			Hash:              vote.Hash,
+			ReceiveTime:       vote.ReceiveTime.Time,
+			TargetedBlockTime: vote.TargetedBlockTime.Time,
+			BlockReceiveTime:  vote.BlockReceiveTime.Time,
+			VotingOn:          vote.VotingOn.Int64,
+			BlockHash:         vote.BlockHash.String,
+			ValidatorId:       vote.ValidatorID.Int,
+			Validity:          vote.Validity.String,
+		}
+	}
+	return result, nil
+}
+
+func (pg *PgDb) APIRedditStats(ctx context.Context, subreddit string, page web.APIPage) ([]commstats.Reddit, error) {
+	query := append([]qm.QueryMod{models.RedditWhere.Subreddit.EQ(subreddit)},
+		apiPageMods(page, models.RedditColumns.Date, "", utcTime)...)
+	redditSlice, err := models.Reddits(query...).All(ctx, pg.db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]commstats.Reddit, len(redditSlice))
+	for i, record := range redditSlice {
+		result[i] = commstats.Reddit{
+			Date:           record.Date,
+			Subreddit:      record.Subreddit,
+			Subscribers:    record.Subscribers,
+			AccountsActive: record.ActiveAccounts,
+		}
+	}
+	return result, nil
+}
+
+func (pg *PgDb) APITwitterStats(ctx context.Context, handle string, page web.APIPage) ([]commstats.Twitter, error) {
+	query := append([]qm.QueryMod{models.TwitterWhere.Handle.EQ(handle)},
+		apiPageMods(page, models.TwitterColumns.Date, "", utcTime)...)
+	twitterSlice, err := models.Twitters(query...).All(ctx, pg.db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]commstats.Twitter, len(twitterSlice))
+	for i, record := range twitterSlice {
+		result[i] = commstats.Twitter{
+			Date:      record.Date,
+			Handle:    record.Handle,
+			Followers: record.Followers,
+		}
+	}
+	return result, nil
+}
+
+func (pg *PgDb) APIGithubStats(ctx context.Context, repository string, page web.APIPage) ([]commstats.Github, error) {
+	query := append([]qm.QueryMod{models.GithubWhere.Repository.EQ(repository)},
+		apiPageMods(page, models.GithubColumns.Date, "", utcTime)...)
+	githubSlice, err := models.Githubs(query...).All(ctx, pg.db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]commstats.Github, len(githubSlice))
+	for i, record := range githubSlice {
+		result[i] = commstats.Github{
+			Date:       record.Date,
+			Repository: record.Repository,
+			Stars:      record.Stars,
+			Folks:      record.Folks,
+		}
+	}
+	return result, nil
+}
+
+func (pg *PgDb) APIYoutubeStats(ctx context.Context, channel string, page web.APIPage) ([]commstats.Youtube, error) {
+	query := append([]qm.QueryMod{models.YoutubeWhere.Channel.EQ(channel)},
+		apiPageMods(page, models.YoutubeColumns.Date, "", utcTime)...)
+	youtubeSlice, err := models.Youtubes(query...).All(ctx, pg.db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]commstats.Youtube, len(youtubeSlice))
+	for i, record := range youtubeSlice {
+		result[i] = commstats.Youtube{
+			Date:        record.Date,
+			Channel:     record.Channel,
+			Subscribers: record.Subscribers,
+			ViewCount:   record.ViewCount,
+		}
+	}
+	return result, nil
+}
+
+// APISnapshots returns the completed network snapshots of page.
+func (pg *PgDb) APISnapshots(ctx context.Context, page web.APIPage) ([]netsnapshot.SnapShot, error) {
+	query := append([]qm.QueryMod{models.NetworkSnapshotWhere.Height.GT(0)},
+		apiPageMods(page, models.NetworkSnapshotColumns.Timestamp, "", unixTime)...)
+	snapshotSlice, err := models.NetworkSnapshots(query...).All(ctx, pg.db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]netsnapshot.SnapShot, len(snapshotSlice))
+	for i, m := range snapshotSlice {
+		result[i] = *modelToSnapshot(m)
+	}
+	return result, nil
+}
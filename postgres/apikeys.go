@@ -0,0 +1,71 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/planetdecred/dcrextdata/web"
+)
+
+const (
+	insertAPIKey = `INSERT INTO api_key (name, key_hash, rate_limit, created_at) VALUES ($1, $2, $3, $4)`
+
+	selectAPIKeyByHash = `SELECT name, key_hash, rate_limit, created_at FROM api_key WHERE key_hash = $1`
+
+	selectAPIKeys = `SELECT name, key_hash, rate_limit, created_at FROM api_key ORDER BY name`
+
+	deleteAPIKey = `DELETE FROM api_key WHERE name = $1`
+)
+
+// SaveAPIKey stores a new API key.
+func (pg *PgDb) SaveAPIKey(ctx context.Context, key web.APIKey) error {
+	_, err := pg.db.ExecContext(ctx, insertAPIKey, key.Name, key.Hash, key.RateLimit, key.CreatedAt.UTC())
+	return err
+}
+
+// APIKeyByHash returns the API key whose hash is hash.
+func (pg *PgDb) APIKeyByHash(ctx context.Context, hash string) (web.APIKey, error) {
+	var key web.APIKey
+	err := pg.db.QueryRowContext(ctx, selectAPIKeyByHash, hash).Scan(&key.Name, &key.Hash, &key.RateLimit, &key.CreatedAt)
+	if err == sql.ErrNoRows {
+		return key, web.ErrUnknownAPIKey
+	}
+	return key, err
+}
+
+// APIKeys returns the API keys ordered by name.
+func (pg *PgDb) APIKeys(ctx context.Context) ([]web.APIKey, error) {
+	rows, err := pg.db.QueryContext(ctx, selectAPIKeys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []web.APIKey
+	for rows.Next() {
+		var key web.APIKey
+		if err = rows.Scan(&key.Name, &key.Hash, &key.RateLimit, &key.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// DeleteAPIKey removes the API key called name.
+func (pg *PgDb) DeleteAPIKey(ctx context.Context, name string) error {
+	result, err := pg.db.ExecContext(ctx, deleteAPIKey, name)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return web.ErrUnknownAPIKey
+	}
+	return nil
+}
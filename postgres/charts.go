@@ -5,6 +5,14 @@ import "github.com/planetdecred/dcrextdata/cache"
 func (pg *PgDb) RegisterCharts(charts *cache.Manager, syncSources []string, syncSourceDbProvider func(source string) (*PgDb, error)) {
 	pg.syncSourceDbProvider = syncSourceDbProvider
 	pg.syncSources = syncSources
+	pg.charts = charts
+	// the propagation chart reads the sync databases, their writes must
+	// invalidate it too
+	for _, source := range syncSources {
+		if db, err := syncSourceDbProvider(source); err == nil {
+			db.charts = charts
+		}
+	}
 
 	charts.AddRetriever(cache.Mempool, pg.fetchEncodeMempoolChart)
 
@@ -16,5 +24,12 @@ func (pg *PgDb) RegisterCharts(charts *cache.Manager, syncSources []string, sync
 
 	charts.AddRetriever(cache.Exchange, pg.fetchEncodeExchangeChart)
 
+	charts.AddRetriever(cache.OrderBook, pg.fetchEncodeOrderBookChart)
+	charts.AddRetriever(cache.ExchangeIdx, pg.fetchEncodeExchangeIndexChart)
+
 	charts.AddRetriever(cache.Snapshot, pg.fetchEncodeSnapshotChart)
+
+	charts.AddRetriever(cache.Agenda, pg.fetchEncodeAgendaChart)
+	charts.AddRetriever(cache.MempoolFeeRate, pg.fetchEncodeMempoolFeeRateChart)
+	charts.AddRetriever(cache.Confirmation, pg.fetchEncodeConfirmationChart)
 }
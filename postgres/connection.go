@@ -7,8 +7,6 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
-
-	_ "github.com/lib/pq" // Start the PostgreSQL driver
 )
 
 // Connect opens a connection to a PostgreSQL database. The caller is
@@ -33,7 +31,7 @@ func Connect(host, port, user, pass, dbname string) (*sql.DB, error) {
 		psqlInfo += fmt.Sprintf(" port=%s", port)
 	}
 
-	db, err := sql.Open("postgres", psqlInfo)
+	db, err := sql.Open(instrumentedDriverName, psqlInfo)
 	if err != nil {
 		return nil, err
 	}
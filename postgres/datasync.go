@@ -9,11 +9,15 @@ import (
 
 func (pg *PgDb) TableNames() []string {
 	return []string{
+		reorgTableName,
 		models.TableNames.Vote,
+		voteVersionTableName,
+		txConfirmationTableName,
 		models.TableNames.Block,
 		models.TableNames.Mempool,
 		models.TableNames.Exchange,
 		models.TableNames.ExchangeTick,
+		orderBookSnapshotTableName,
 		models.TableNames.VSP,
 		models.TableNames.VSPTick,
 		models.TableNames.PowData,
@@ -27,12 +31,20 @@ func (pg *PgDb) LastEntry(ctx context.Context, tableName string, receiver interf
 		columnName = models.ExchangeColumns.ID
 	case models.TableNames.ExchangeTick:
 		columnName = models.ExchangeTickColumns.ID
+	case orderBookSnapshotTableName:
+		columnName = "time"
 	case models.TableNames.Mempool:
 		columnName = models.MempoolColumns.Time
 	case models.TableNames.Block:
 		columnName = models.BlockColumns.Height
 	case models.TableNames.Vote:
 		columnName = models.VoteColumns.ReceiveTime
+	case voteVersionTableName:
+		columnName = "receive_time"
+	case txConfirmationTableName:
+		columnName = "mined_time"
+	case reorgTableName:
+		columnName = "time"
 	case models.TableNames.PowData:
 		columnName = models.PowDatumColumns.Time
 	case models.TableNames.VSP:
@@ -0,0 +1,283 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+)
+
+const (
+	lastExchangeIndexBinTime = `SELECT time FROM exchange_index_bin WHERE bin = $1 AND currency_pair = $2 ORDER BY time DESC LIMIT 1`
+
+	firstExchangeIndexTickTime = `SELECT time FROM exchange_tick WHERE currency_pair = $1 ORDER BY time LIMIT 1`
+
+	lastUSDRateBefore = `SELECT t.close FROM exchange_tick t JOIN exchange e ON e.id = t.exchange_id
+		WHERE e.name = $1 AND t.currency_pair = $2 AND t.time < $3 ORDER BY t.time DESC LIMIT 1`
+
+	selectExchangeIndexTicks = `SELECT e.name, t.currency_pair, t.interval, t.time, t.close, t.volume
+		FROM exchange_tick t JOIN exchange e ON e.id = t.exchange_id
+		WHERE t.currency_pair IN ($1, $2) AND t.interval <= $3 AND t.time >= $4 AND t.time < $5`
+
+	upsertExchangeIndexBin = `INSERT INTO exchange_index_bin (time, bin, currency_pair, price, volume, exchange_count)
+		VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (time, bin, currency_pair)
+		DO UPDATE SET price = $4, volume = $5, exchange_count = $6`
+
+	selectExchangeIndexBin = `SELECT time, price, volume, exchange_count FROM exchange_index_bin
+		WHERE bin = $1 AND currency_pair = $2 ORDER BY time`
+
+	selectExchangeIndexPage = `SELECT time, price, volume, exchange_count FROM exchange_index_bin
+		WHERE bin = $1 AND currency_pair = $2 ORDER BY time DESC OFFSET $3 LIMIT $4`
+
+	countExchangeIndex = `SELECT COUNT(*) FROM exchange_index_bin WHERE bin = $1 AND currency_pair = $2`
+)
+
+// exchangeIntervalPrice accumulates the ticks of an exchange within an index
+// interval. Only the ticks of the smallest candle interval are used so that
+// overlapping candles are not counted twice.
+type exchangeIntervalPrice struct {
+	interval int
+	weighted float64
+	volume   float64
+	sum      float64
+	count    int
+}
+
+func (p *exchangeIntervalPrice) add(interval int, close, volume float64) {
+	if p.count > 0 && interval > p.interval {
+		return
+	}
+	if p.count > 0 && interval < p.interval {
+		*p = exchangeIntervalPrice{}
+	}
+	p.interval = interval
+	p.weighted += close * volume
+	p.volume += volume
+	p.sum += close
+	p.count++
+}
+
+func (p *exchangeIntervalPrice) price() float64 {
+	if p.volume == 0 {
+		return p.sum / float64(p.count)
+	}
+	return p.weighted / p.volume
+}
+
+// UpdateExchangeIndex computes the hourly and daily cross exchange index from
+// the stored exchange ticks.
+func (pg *PgDb) UpdateExchangeIndex(ctx context.Context) error {
+	log.Info("Updating exchange index bin data")
+	if err := pg.updateExchangeIndexBin(ctx, string(cache.HourBin), cache.AnHour, 30*24*time.Hour); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err := pg.updateExchangeIndexBin(ctx, string(cache.DayBin), cache.ADay, 365*24*time.Hour); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	pg.charts.Invalidate(cache.ExchangeIdx)
+	return nil
+}
+
+func (pg *PgDb) updateExchangeIndexBin(ctx context.Context, bin string, binSize int64, step time.Duration) error {
+	var start time.Time
+	var lastBin int64
+	err := pg.db.QueryRowContext(ctx, lastExchangeIndexBinTime, bin, ticks.IndexBTCPair).Scan(&lastBin)
+	if err == nil {
+		// the last bin may have been computed before its interval ended
+		start = time.Unix(lastBin, 0).UTC()
+	} else if err == sql.ErrNoRows {
+		if err = pg.db.QueryRowContext(ctx, firstExchangeIndexTickTime, ticks.IndexBTCPair).Scan(&start); err != nil {
+			return err
+		}
+		start = time.Unix(start.Unix()-start.Unix()%binSize, 0).UTC()
+	} else {
+		return err
+	}
+
+	usdExchange, usdPair := ticks.IndexUSDSource()
+	var usdRate float64
+	err = pg.db.QueryRowContext(ctx, lastUSDRateBefore, usdExchange, usdPair, start).Scan(&usdRate)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	maxInterval := int(binSize / 60)
+	now := time.Now().UTC()
+	for start.Before(now) {
+		end := start.Add(step)
+		usdRate, err = pg.updateExchangeIndexWindow(ctx, bin, binSize, maxInterval, start, end, usdRate)
+		if err != nil {
+			return err
+		}
+		start = end
+	}
+
+	log.Infof("Exchange index %s bin updated", bin)
+	return nil
+}
+
+// updateExchangeIndexWindow stores the index of every bin between start and
+// end. It returns the last USD/BTC rate seen so that bins without a USD tick
+// can use the previous rate.
+func (pg *PgDb) updateExchangeIndexWindow(ctx context.Context, bin string, binSize int64, maxInterval int,
+	start, end time.Time, usdRate float64) (float64, error) {
+
+	usdExchange, usdPair := ticks.IndexUSDSource()
+	rows, err := pg.db.QueryContext(ctx, selectExchangeIndexTicks, ticks.IndexBTCPair, usdPair, maxInterval, start, end)
+	if err != nil {
+		return usdRate, err
+	}
+	defer rows.Close()
+
+	btcPrices := make(map[int64]map[string]*exchangeIntervalPrice)
+	usdPrices := make(map[int64]*exchangeIntervalPrice)
+	for rows.Next() {
+		var exchange, pair string
+		var interval int
+		var tickTime time.Time
+		var close, volume float64
+		if err = rows.Scan(&exchange, &pair, &interval, &tickTime, &close, &volume); err != nil {
+			return usdRate, err
+		}
+		binTime := tickTime.Unix() - tickTime.Unix()%binSize
+		if pair == usdPair {
+			if exchange != usdExchange {
+				continue
+			}
+			if usdPrices[binTime] == nil {
+				usdPrices[binTime] = new(exchangeIntervalPrice)
+			}
+			usdPrices[binTime].add(interval, close, volume)
+			continue
+		}
+		if btcPrices[binTime] == nil {
+			btcPrices[binTime] = make(map[string]*exchangeIntervalPrice)
+		}
+		if btcPrices[binTime][exchange] == nil {
+			btcPrices[binTime][exchange] = new(exchangeIntervalPrice)
+		}
+		btcPrices[binTime][exchange].add(interval, close, volume)
+	}
+	if err = rows.Err(); err != nil {
+		return usdRate, err
+	}
+
+	var binTimes []int64
+	for binTime := range btcPrices {
+		binTimes = append(binTimes, binTime)
+	}
+	sort.Slice(binTimes, func(i, j int) bool { return binTimes[i] < binTimes[j] })
+
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return usdRate, err
+	}
+	for _, binTime := range binTimes {
+		var prices []ticks.ExchangePrice
+		for exchange, p := range btcPrices[binTime] {
+			prices = append(prices, ticks.ExchangePrice{Exchange: exchange, Price: p.price(), Volume: p.volume})
+		}
+		price, volume, count, ok := ticks.VolumeWeightedIndex(prices, ticks.IndexMaxDeviation)
+		if !ok {
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, upsertExchangeIndexBin, binTime, bin, ticks.IndexBTCPair, price, volume, count); err != nil {
+			_ = tx.Rollback()
+			return usdRate, err
+		}
+
+		if p, found := usdPrices[binTime]; found {
+			usdRate = p.price()
+		}
+		if usdRate == 0 {
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, upsertExchangeIndexBin, binTime, bin, ticks.IndexUSDPair, price*usdRate, volume, count); err != nil {
+			_ = tx.Rollback()
+			return usdRate, err
+		}
+	}
+
+	return usdRate, tx.Commit()
+}
+
+func indexCurrencyPair(dataType string) string {
+	if strings.ToLower(dataType) == string(cache.IndexUSDAxis) {
+		return ticks.IndexUSDPair
+	}
+	return ticks.IndexBTCPair
+}
+
+func indexBin(binString string) string {
+	if cache.ParseBin(binString) == cache.DayBin {
+		return string(cache.DayBin)
+	}
+	return string(cache.HourBin)
+}
+
+// ExchangeIndex returns a page of the index of the given currency pair, most
+// recent first.
+func (pg *PgDb) ExchangeIndex(ctx context.Context, currencyPair, binString string, offset, limit int) ([]ticks.IndexPrice, int64, error) {
+	bin := indexBin(binString)
+	rows, err := pg.db.QueryContext(ctx, selectExchangeIndexPage, bin, currencyPair, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	prices := []ticks.IndexPrice{}
+	for rows.Next() {
+		var unixTime int64
+		price := ticks.IndexPrice{CurrencyPair: currencyPair}
+		if err = rows.Scan(&unixTime, &price.Price, &price.Volume, &price.ExchangeCount); err != nil {
+			return nil, 0, err
+		}
+		price.Time = time.Unix(unixTime, 0).UTC()
+		prices = append(prices, price)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err = pg.db.QueryRowContext(ctx, countExchangeIndex, bin, currencyPair).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	return prices, total, nil
+}
+
+func (pg *PgDb) fetchEncodeExchangeIndexChart(ctx context.Context, charts *cache.Manager, dataType, _ string, binString string, _ ...string) ([]byte, error) {
+	rows, err := pg.db.QueryContext(ctx, selectExchangeIndexBin, indexBin(binString), indexCurrencyPair(dataType))
+	if err != nil {
+		return nil, fmt.Errorf("error in fetching exchange index, %s", err.Error())
+	}
+	defer rows.Close()
+
+	var dates cache.ChartUints
+	var prices, volumes cache.ChartFloats
+	for rows.Next() {
+		var unixTime int64
+		var price, volume float64
+		var count int
+		if err = rows.Scan(&unixTime, &price, &volume, &count); err != nil {
+			return nil, err
+		}
+		dates = append(dates, uint64(unixTime))
+		prices = append(prices, price)
+		volumes = append(volumes, volume)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return charts.Encode(nil, dates, prices, volumes)
+}
@@ -122,6 +122,9 @@ func (pg *PgDb) StoreExchangeTicks(ctx context.Context, name string, interval in
 		/*log.Infof("%10s %7s, received %6v ticks %14s %s to %s",
 		name, pair, added, fmt.Sprintf("(%dm each)", interval), firstTime.Format(dateTemplate), lastTime.Format(dateTemplate))*/
 	}
+	if added > 0 {
+		pg.charts.Invalidate(cache.Exchange)
+	}
 	return lastTime, nil
 }
 
@@ -461,6 +464,9 @@ func (pg *PgDb) SaveExchangeTickFromSync(ctx context.Context, tickData interface
 	if isUniqueConstraint(err) {
 		return nil
 	}
+	if err == nil {
+		pg.charts.Invalidate(cache.Exchange)
+	}
 
 	return err
 }
@@ -493,6 +499,45 @@ func (pg *PgDb) LastExchangeEntryID() (id int64) {
 	return
 }
 
+// ExchangeTickSeries returns every stored exchange, currency pair and interval
+// combination
+func (pg *PgDb) ExchangeTickSeries(ctx context.Context) ([]ticks.TickSeries, error) {
+	rows, err := pg.db.QueryContext(ctx, selectExchangeTickSeries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []ticks.TickSeries
+	for rows.Next() {
+		var s ticks.TickSeries
+		if err = rows.Scan(&s.Exchange, &s.CurrencyPair, &s.Interval); err != nil {
+			return nil, err
+		}
+		series = append(series, s)
+	}
+	return series, rows.Err()
+}
+
+// ExchangeTickTimes returns the sorted candle times of series
+func (pg *PgDb) ExchangeTickTimes(ctx context.Context, series ticks.TickSeries) ([]time.Time, error) {
+	rows, err := pg.db.QueryContext(ctx, selectExchangeTickTimes, series.Exchange, series.CurrencyPair, series.Interval)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err = rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, rows.Err()
+}
+
 func (pg *PgDb) fetchEncodeExchangeChart(ctx context.Context, charts *cache.Manager, dataType, _ string, binString string, setKey ...string) ([]byte, error) {
 	if len(setKey) < 1 {
 		return nil, errors.New("exchange set key is required for exchange chart")
@@ -0,0 +1,70 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/planetdecred/dcrextdata/export"
+)
+
+var _ export.Source = (*PgDb)(nil)
+
+// ExportTable streams the rows of table in timeRange to rows. The rows are
+// read from a single query, one at a time.
+func (pg *PgDb) ExportTable(ctx context.Context, table export.Table, timeRange export.Range, rows export.RowWriter) error {
+	timeColumn := pq.QuoteIdentifier(table.TimeColumn)
+	timeArg := utcTime
+	if table.UnixTime {
+		timeArg = unixTime
+	}
+
+	var conditions []string
+	var args []interface{}
+	if !timeRange.Start.IsZero() {
+		args = append(args, timeArg(timeRange.Start))
+		conditions = append(conditions, fmt.Sprintf("%s >= $%d", timeColumn, len(args)))
+	}
+	if !timeRange.End.IsZero() {
+		args = append(args, timeArg(timeRange.End))
+		conditions = append(conditions, fmt.Sprintf("%s < $%d", timeColumn, len(args)))
+	}
+	query := "SELECT * FROM " + pq.QuoteIdentifier(table.Name)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + timeColumn
+
+	result, err := pg.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+
+	columns, err := result.Columns()
+	if err != nil {
+		return err
+	}
+	if err = rows.Columns(columns); err != nil {
+		return err
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for result.Next() {
+		if err = result.Scan(pointers...); err != nil {
+			return err
+		}
+		if err = rows.Row(values); err != nil {
+			return err
+		}
+	}
+	return result.Err()
+}
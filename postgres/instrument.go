@@ -0,0 +1,84 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/planetdecred/dcrextdata/metrics"
+)
+
+// instrumentedDriverName is the name of the PostgreSQL driver that records the
+// duration of the queries.
+const instrumentedDriverName = "postgres-instrumented"
+
+var queryDuration = metrics.NewHistogramVec("dcrextdata_db_query_duration_seconds",
+	"Duration of the PostgreSQL queries, by statement type.", metrics.DefBuckets, "statement")
+
+func init() {
+	sql.Register(instrumentedDriverName, instrumentedDriver{})
+}
+
+// instrumentedDriver wraps the connections of the pq driver to time the
+// queries that go through QueryContext and ExecContext, which covers the
+// queries of the models and of PgDb.
+type instrumentedDriver struct{}
+
+func (instrumentedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := pq.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn}, nil
+}
+
+type instrumentedConn struct {
+	driver.Conn
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer queryDuration.ObserveSince(time.Now(), statementType(query))
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer queryDuration.ObserveSince(time.Now(), statementType(query))
+	return execer.ExecContext(ctx, query, args)
+}
+
+// statementType returns the first keyword of query, e.g. SELECT or INSERT, to
+// label its duration without creating a series per query.
+func statementType(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	switch keyword := strings.ToUpper(fields[0]); keyword {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "WITH", "CREATE", "DROP", "ALTER", "TRUNCATE":
+		return keyword
+	default:
+		return "other"
+	}
+}
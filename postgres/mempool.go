@@ -37,6 +37,9 @@ func (pg PgDb) StoreMempool(ctx context.Context, mempoolDto mempool.Mempool) err
 			return err
 		}
 	}
+	if err = pg.storeMempoolFeeRates(ctx, mempoolDto); err != nil {
+		return err
+	}
 	//  tx count 76, total size 54205 B, fees 0.00367100
 	log.Infof("Added mempool entry at %s, tx count %2d, total size: %6d B, Total Fee: %010.8f",
 		mempoolDto.Time.Format(dateTemplate), mempoolDto.NumberOfTransactions, mempoolDto.Size, mempoolDto.TotalFee)
@@ -46,12 +49,19 @@ func (pg PgDb) StoreMempool(ctx context.Context, mempoolDto mempool.Mempool) err
 	return nil
 }
 
-func (pg PgDb) StoreMempoolFromSync(ctx context.Context, mempoolDto interface{}) error {
-	mempoolModel := mempoolDtoToModel(mempoolDto.(mempool.Mempool))
+func (pg PgDb) StoreMempoolFromSync(ctx context.Context, mempoolData interface{}) error {
+	mempoolDto := mempoolData.(mempool.Mempool)
+	mempoolModel := mempoolDtoToModel(mempoolDto)
 	err := mempoolModel.Insert(ctx, pg.db, boil.Infer())
 	if isUniqueConstraint(err) {
 		return nil
 	}
+	if err == nil {
+		err = pg.storeMempoolFeeRates(ctx, mempoolDto)
+	}
+	if err == nil {
+		pg.charts.Invalidate(cache.Mempool, cache.MempoolFeeRate)
+	}
 	return err
 }
 
@@ -131,6 +141,9 @@ func (pg *PgDb) FetchMempoolForSync(ctx context.Context, date time.Time, offtset
 			NumberOfTransactions: m.NumberOfTransactions.Int,
 		})
 	}
+	if err = pg.fetchMempoolFeeRates(ctx, result); err != nil {
+		return nil, 0, err
+	}
 	totalCount, err := models.Mempools(models.MempoolWhere.Time.GTE(date)).Count(ctx, pg.db)
 
 	return result, totalCount, err
@@ -160,6 +173,7 @@ func (pg *PgDb) SaveBlock(ctx context.Context, block mempool.Block) error {
 		}
 	}
 
+	pg.charts.Invalidate(cache.Propagation)
 	log.Infof("New block received at %s, PropagationHeight: %d, Hash: ...%s",
 		block.BlockReceiveTime.Format(dateMiliTemplate), block.BlockHeight, block.BlockHash[len(block.BlockHash)-23:])
 	return nil
@@ -173,6 +187,7 @@ func (pg *PgDb) SaveBlockFromSync(ctx context.Context, block interface{}) error
 			return err
 		}
 	}
+	pg.charts.Invalidate(cache.Propagation)
 	return nil
 }
 
@@ -299,6 +314,7 @@ func (pg *PgDb) SaveVote(ctx context.Context, vote mempool.Vote) error {
 		return err
 	}
 
+	pg.charts.Invalidate(cache.Propagation)
 	log.Infof("New vote received at %s for %d, Validator Id %d, Hash ...%s",
 		vote.ReceiveTime.Format(dateMiliTemplate), vote.VotingOn, vote.ValidatorId, vote.Hash[len(vote.Hash)-23:])
 	return nil
@@ -322,8 +338,10 @@ func (pg *PgDb) SaveVoteFromSync(ctx context.Context, voteData interface{}) erro
 		if strings.Contains(err.Error(), "unique constraint") { // Ignore duplicate entries
 			return nil
 		}
+		return err
 	}
-	return err
+	pg.charts.Invalidate(cache.Propagation)
+	return nil
 }
 
 func (pg *PgDb) Votes(ctx context.Context, offset int, limit int) ([]mempool.VoteDto, error) {
@@ -748,6 +766,11 @@ func (pg PgDb) UpdateMempoolAggregateData(ctx context.Context) error {
 		return err
 	}
 
+	if err := pg.updateMempoolFeeRateBins(ctx); err != nil {
+		return err
+	}
+
+	pg.charts.Invalidate(cache.Mempool, cache.MempoolFeeRate)
 	log.Info("Mempool bin data updated")
 	return nil
 }
@@ -958,6 +981,7 @@ func (pg PgDb) UpdatePropagationData(ctx context.Context) error {
 			return err
 		}
 	}
+	pg.charts.Invalidate(cache.Propagation)
 	log.Info("Updated propagation data")
 	return nil
 }
@@ -1258,6 +1282,7 @@ func (pg *PgDb) UpdateBlockBinData(ctx context.Context) error {
 	if err := pg.updateBlockDailyAvgData(ctx); err != nil && err != sql.ErrNoRows {
 		return err
 	}
+	pg.charts.Invalidate(cache.Propagation)
 	return nil
 }
 
@@ -1436,6 +1461,7 @@ func (pg *PgDb) UpdateVoteTimeDeviationData(ctx context.Context) error {
 	if err := pg.updateVoteTimeDeviationDailyAvgData(ctx); err != nil && err != sql.ErrNoRows {
 		return err
 	}
+	pg.charts.Invalidate(cache.Propagation)
 	return nil
 }
 
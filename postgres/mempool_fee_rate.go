@@ -0,0 +1,203 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/mempool"
+)
+
+const (
+	insertMempoolFeeRate = `INSERT INTO mempool_fee_rate (time, fee_rate, tx_count, size) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (time, fee_rate) DO NOTHING`
+
+	insertMempoolFeePercentile = `INSERT INTO mempool_fee_percentile (time, percentile, fee_rate) VALUES ($1, $2, $3)
+		ON CONFLICT (time, percentile) DO NOTHING`
+
+	selectMempoolFeeRatesBetween = `SELECT time, fee_rate, tx_count, size FROM mempool_fee_rate
+		WHERE time >= $1 AND time <= $2 ORDER BY time, fee_rate`
+
+	selectMempoolFeePercentilesBetween = `SELECT time, percentile, fee_rate FROM mempool_fee_percentile
+		WHERE time >= $1 AND time <= $2 ORDER BY time, percentile`
+
+	selectMempoolFeeRates = `SELECT EXTRACT(EPOCH FROM time)::INT8, fee_rate, tx_count, size FROM mempool_fee_rate
+		ORDER BY time, fee_rate`
+
+	selectMempoolFeePercentiles = `SELECT EXTRACT(EPOCH FROM time)::INT8, percentile, fee_rate FROM mempool_fee_percentile
+		ORDER BY time, percentile`
+
+	selectMempoolFeeRateBins = `SELECT time, fee_rate, tx_count, size FROM mempool_fee_rate_bin
+		WHERE bin = $1 ORDER BY time, fee_rate`
+
+	selectMempoolFeePercentileBins = `SELECT time, percentile, fee_rate FROM mempool_fee_percentile_bin
+		WHERE bin = $1 ORDER BY time, percentile`
+
+	// The bin statements are formatted with the bin, which is also the
+	// date_trunc field. Only the complete bins that follow the last saved one
+	// are inserted.
+	insertMempoolFeeRateBins = `INSERT INTO mempool_fee_rate_bin (time, bin, fee_rate, tx_count, size)
+		SELECT EXTRACT(EPOCH FROM date_trunc('%[1]s', time))::INT8 AS bucket, $1, fee_rate, AVG(tx_count), AVG(size)
+		FROM mempool_fee_rate
+		WHERE time >= COALESCE((SELECT to_timestamp(MAX(time)) AT TIME ZONE 'UTC' + interval '1 %[1]s'
+				FROM mempool_fee_rate_bin WHERE bin = $1), '-infinity')
+			AND time < date_trunc('%[1]s', now() AT TIME ZONE 'UTC')
+		GROUP BY bucket, fee_rate
+		ON CONFLICT (time, bin, fee_rate) DO NOTHING`
+
+	insertMempoolFeePercentileBins = `INSERT INTO mempool_fee_percentile_bin (time, bin, percentile, fee_rate)
+		SELECT EXTRACT(EPOCH FROM date_trunc('%[1]s', time))::INT8 AS bucket, $1, percentile, AVG(fee_rate)
+		FROM mempool_fee_percentile
+		WHERE time >= COALESCE((SELECT to_timestamp(MAX(time)) AT TIME ZONE 'UTC' + interval '1 %[1]s'
+				FROM mempool_fee_percentile_bin WHERE bin = $1), '-infinity')
+			AND time < date_trunc('%[1]s', now() AT TIME ZONE 'UTC')
+		GROUP BY bucket, percentile
+		ON CONFLICT (time, bin, percentile) DO NOTHING`
+)
+
+// storeMempoolFeeRates saves the fee rate buckets and percentiles of a
+// mempool sample. The sample must have been saved.
+func (pg *PgDb) storeMempoolFeeRates(ctx context.Context, mempoolDto mempool.Mempool) error {
+	if len(mempoolDto.FeeRates) == 0 && len(mempoolDto.Percentiles) == 0 {
+		return nil
+	}
+
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, bucket := range mempoolDto.FeeRates {
+		_, err = tx.ExecContext(ctx, insertMempoolFeeRate, mempoolDto.Time, bucket.FeeRate, bucket.Count, bucket.Size)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	for _, percentile := range mempoolDto.Percentiles {
+		_, err = tx.ExecContext(ctx, insertMempoolFeePercentile, mempoolDto.Time, percentile.Percentile, percentile.FeeRate)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// fetchMempoolFeeRates sets the fee rate buckets and percentiles of the
+// mempool samples, which must be ordered by time.
+func (pg *PgDb) fetchMempoolFeeRates(ctx context.Context, mempools []mempool.Mempool) error {
+	if len(mempools) == 0 {
+		return nil
+	}
+	indexes := make(map[int64]int, len(mempools))
+	for i, m := range mempools {
+		indexes[m.Time.UnixNano()] = i
+	}
+	from, to := mempools[0].Time, mempools[len(mempools)-1].Time
+
+	rows, err := pg.db.QueryContext(ctx, selectMempoolFeeRatesBetween, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t time.Time
+		var bucket mempool.FeeRateBucket
+		if err = rows.Scan(&t, &bucket.FeeRate, &bucket.Count, &bucket.Size); err != nil {
+			return err
+		}
+		if i, found := indexes[t.UnixNano()]; found {
+			mempools[i].FeeRates = append(mempools[i].FeeRates, bucket)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	percentileRows, err := pg.db.QueryContext(ctx, selectMempoolFeePercentilesBetween, from, to)
+	if err != nil {
+		return err
+	}
+	defer percentileRows.Close()
+	for percentileRows.Next() {
+		var t time.Time
+		var percentile mempool.FeeRatePercentile
+		if err = percentileRows.Scan(&t, &percentile.Percentile, &percentile.FeeRate); err != nil {
+			return err
+		}
+		if i, found := indexes[t.UnixNano()]; found {
+			mempools[i].Percentiles = append(mempools[i].Percentiles, percentile)
+		}
+	}
+	return percentileRows.Err()
+}
+
+// updateMempoolFeeRateBins averages the fee rate buckets and percentiles of
+// the samples over every complete hour and day that is not binned yet.
+func (pg *PgDb) updateMempoolFeeRateBins(ctx context.Context) error {
+	for _, bin := range []string{string(cache.HourBin), string(cache.DayBin)} {
+		if _, err := pg.db.ExecContext(ctx, fmt.Sprintf(insertMempoolFeeRateBins, bin), bin); err != nil {
+			return err
+		}
+		if _, err := pg.db.ExecContext(ctx, fmt.Sprintf(insertMempoolFeePercentileBins, bin), bin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchEncodeMempoolFeeRateChart returns the number of transactions, or their
+// size for the size data type, in each fee rate bucket over time with the fee
+// rate percentiles.
+func (pg *PgDb) fetchEncodeMempoolFeeRateChart(ctx context.Context, charts *cache.Manager, dataType,
+	_ string, binString string, _ ...string) ([]byte, error) {
+
+	rateQuery, percentileQuery := selectMempoolFeeRates, selectMempoolFeePercentiles
+	var args []interface{}
+	bin := cache.ParseBin(binString)
+	if bin != cache.DefaultBin {
+		rateQuery, percentileQuery = selectMempoolFeeRateBins, selectMempoolFeePercentileBins
+		args = append(args, string(bin))
+	}
+
+	rows, err := pg.db.QueryContext(ctx, rateQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var feeRates []cache.FeeRateRow
+	for rows.Next() {
+		var row cache.FeeRateRow
+		if err = rows.Scan(&row.Time, &row.FeeRate, &row.Count, &row.Size); err != nil {
+			return nil, err
+		}
+		feeRates = append(feeRates, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	percentileRows, err := pg.db.QueryContext(ctx, percentileQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer percentileRows.Close()
+	var percentiles []cache.FeeRatePercentileRow
+	for percentileRows.Next() {
+		var row cache.FeeRatePercentileRow
+		if err = percentileRows.Scan(&row.Time, &row.Percentile, &row.FeeRate); err != nil {
+			return nil, err
+		}
+		percentiles = append(percentiles, row)
+	}
+	if err = percentileRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return cache.MakeFeeRateChart(charts, dataType, feeRates, percentiles)
+}
@@ -0,0 +1,314 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	createSchemaVersionTable = `CREATE TABLE IF NOT EXISTS schema_version (
+		version INT NOT NULL PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL
+	);`
+
+	selectSchemaVersion    = `SELECT COALESCE(MAX(version), 0) FROM schema_version`
+	selectSchemaVersions   = `SELECT version, description, applied_at FROM schema_version ORDER BY version`
+	insertSchemaVersion    = `INSERT INTO schema_version (version, description, applied_at) VALUES ($1, $2, $3)`
+	deleteSchemaVersion    = `DELETE FROM schema_version WHERE version = $1`
+	dropSchemaVersionTable = `DROP TABLE IF EXISTS schema_version;`
+)
+
+// migration changes the schema from the previous version to version. down
+// reverts the changes of up.
+type migration struct {
+	version     int
+	description string
+	up          []string
+	down        []string
+}
+
+// migrations holds every schema change, ordered by version. The versions
+// start at 1 and have no gaps. A released migration must never be edited,
+// changes to the schema are made by appending a new one.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create the initial tables",
+		// The statements do not fail on existing tables so that the
+		// databases created before the migrations were introduced are
+		// adopted as they are.
+		up: []string{
+			createMempoolTable,
+			createMempoolDayBinTable,
+			createPropagationTable,
+			createBlockTable,
+			createBlockBinTable,
+			createVoteTable,
+			createVoteReceiveTimeDeviationTable,
+			createVSPInfoTable,
+			createVSPTickTable,
+			createVSPTickIndex,
+			createVSPTickBinTable,
+			createExchangeTable,
+			createExchangeTickTable,
+			createExchangeTickIndex,
+			createOrderBookSnapshotTable,
+			createOrderBookSnapshotIndex,
+			createOrderBookDepthTable,
+			createExchangeIndexBinTable,
+			createPowDataTable,
+			createPowBInTable,
+			createRedditTable,
+			createTwitterTable,
+			createYoutubeTable,
+			createGithubTable,
+			createNetworkSnapshotTable,
+			createNetworkSnapshotBinTable,
+			createNodeVersionTable,
+			createNodeLocationTable,
+			createNodeTable,
+			createHeartbeatTable,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS heartbeat;`,
+			`DROP TABLE IF EXISTS node;`,
+			`DROP TABLE IF EXISTS node_location;`,
+			`DROP TABLE IF EXISTS node_version;`,
+			`DROP TABLE IF EXISTS network_snapshot_bin;`,
+			`DROP TABLE IF EXISTS network_snapshot;`,
+			`DROP TABLE IF EXISTS github;`,
+			`DROP TABLE IF EXISTS youtube;`,
+			`DROP TABLE IF EXISTS twitter;`,
+			`DROP TABLE IF EXISTS reddit;`,
+			`DROP TABLE IF EXISTS pow_bin;`,
+			`DROP TABLE IF EXISTS pow_data;`,
+			`DROP TABLE IF EXISTS exchange_index_bin;`,
+			`DROP TABLE IF EXISTS orderbook_depth;`,
+			`DROP TABLE IF EXISTS orderbook_snapshot;`,
+			`DROP TABLE IF EXISTS exchange_tick;`,
+			`DROP TABLE IF EXISTS exchange;`,
+			`DROP TABLE IF EXISTS vsp_tick_bin;`,
+			`DROP TABLE IF EXISTS vsp_tick;`,
+			`DROP TABLE IF EXISTS vsp;`,
+			`DROP TABLE IF EXISTS vote_receive_time_deviation;`,
+			`DROP TABLE IF EXISTS vote;`,
+			`DROP TABLE IF EXISTS block_bin;`,
+			`DROP TABLE IF EXISTS block;`,
+			`DROP TABLE IF EXISTS propagation;`,
+			`DROP TABLE IF EXISTS mempool_bin;`,
+			`DROP TABLE IF EXISTS mempool;`,
+		},
+	},
+	{
+		version:     2,
+		description: "add the last run of the scheduled jobs",
+		up:          []string{createJobRunTable},
+		down:        []string{`DROP TABLE IF EXISTS job_run;`},
+	},
+	{
+		version:     3,
+		description: "add the API keys",
+		up:          []string{createAPIKeyTable},
+		down:        []string{`DROP TABLE IF EXISTS api_key;`},
+	},
+	{
+		version:     4,
+		description: "add the vote versions and agenda choices",
+		up:          []string{createVoteVersionTable, createVoteVersionIndex, createAgendaVoteTable, createAgendaVoteIndex},
+		down:        []string{`DROP TABLE IF EXISTS agenda_vote;`, `DROP TABLE IF EXISTS vote_version;`},
+	},
+	{
+		version:     5,
+		description: "add the fee rate distribution of the mempool",
+		up: []string{
+			createMempoolFeeRateTable,
+			createMempoolFeePercentileTable,
+			createMempoolFeeRateBinTable,
+			createMempoolFeePercentileBinTable,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS mempool_fee_percentile_bin;`,
+			`DROP TABLE IF EXISTS mempool_fee_rate_bin;`,
+			`DROP TABLE IF EXISTS mempool_fee_percentile;`,
+			`DROP TABLE IF EXISTS mempool_fee_rate;`,
+		},
+	},
+	{
+		version:     6,
+		description: "add the transaction confirmations",
+		up:          []string{createTxConfirmationTable, createTxConfirmationIndex},
+		down:        []string{`DROP TABLE IF EXISTS tx_confirmation;`},
+	},
+	{
+		version:     7,
+		description: "add the orphaned blocks and votes and the chain reorganizations",
+		up:          []string{createOrphanedBlockTable, createOrphanedVoteTable, createReorgTable, createReorgIndex},
+		down: []string{
+			`DROP TABLE IF EXISTS reorg;`,
+			`DROP TABLE IF EXISTS orphaned_vote;`,
+			`DROP TABLE IF EXISTS orphaned_block;`,
+		},
+	},
+}
+
+// MigrationStatus describes a schema version and whether it is applied.
+type MigrationStatus struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+	// Unknown is set for the applied versions that this build has no
+	// migration for.
+	Unknown bool
+}
+
+// LatestSchemaVersion returns the schema version that this build uses.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion returns the version of the schema of the database, 0 if no
+// migration has been applied.
+func (pg *PgDb) SchemaVersion(ctx context.Context) (int, error) {
+	if _, err := pg.db.ExecContext(ctx, createSchemaVersionTable); err != nil {
+		return 0, err
+	}
+	var version int
+	err := pg.db.QueryRowContext(ctx, selectSchemaVersion).Scan(&version)
+	return version, err
+}
+
+// CheckSchemaVersion returns an error if the database has been migrated to a
+// schema version that is newer than the one of this build.
+func (pg *PgDb) CheckSchemaVersion(ctx context.Context) error {
+	version, err := pg.SchemaVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot read the schema version, %s", err.Error())
+	}
+	if version > LatestSchemaVersion() {
+		return fmt.Errorf("the database schema version %d is newer than the latest known version %d, "+
+			"please upgrade dcrextdata", version, LatestSchemaVersion())
+	}
+	return nil
+}
+
+// MigrationStatuses returns the status of every known schema version followed
+// by the applied versions that are not known.
+func (pg *PgDb) MigrationStatuses(ctx context.Context) ([]MigrationStatus, error) {
+	if _, err := pg.db.ExecContext(ctx, createSchemaVersionTable); err != nil {
+		return nil, err
+	}
+	rows, err := pg.db.QueryContext(ctx, selectSchemaVersions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]MigrationStatus)
+	var versions []int
+	for rows.Next() {
+		status := MigrationStatus{Applied: true}
+		if err = rows.Scan(&status.Version, &status.Description, &status.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[status.Version] = status
+		versions = append(versions, status.Version)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		status, found := applied[m.version]
+		if !found {
+			status = MigrationStatus{Version: m.version, Description: m.description}
+		}
+		statuses = append(statuses, status)
+	}
+	for _, version := range versions {
+		if version > LatestSchemaVersion() {
+			status := applied[version]
+			status.Unknown = true
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
+// MigrateUp applies every pending migration.
+func (pg *PgDb) MigrateUp(ctx context.Context) error {
+	return pg.MigrateTo(ctx, LatestSchemaVersion())
+}
+
+// MigrateDown reverts the last applied migration.
+func (pg *PgDb) MigrateDown(ctx context.Context) error {
+	version, err := pg.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		return fmt.Errorf("no migration has been applied")
+	}
+	return pg.MigrateTo(ctx, version-1)
+}
+
+// MigrateTo applies or reverts the migrations needed to bring the schema to
+// target. Each migration runs in its own transaction.
+func (pg *PgDb) MigrateTo(ctx context.Context, target int) error {
+	if target < 0 || target > LatestSchemaVersion() {
+		return fmt.Errorf("unknown schema version %d, the latest version is %d", target, LatestSchemaVersion())
+	}
+	if err := pg.CheckSchemaVersion(ctx); err != nil {
+		return err
+	}
+	version, err := pg.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for version < target {
+		m := migrations[version]
+		log.Infof("Migrating the database schema to version %d, %s", m.version, m.description)
+		if err = pg.runMigration(ctx, m.up, insertSchemaVersion, m.version, m.description, time.Now().UTC()); err != nil {
+			return fmt.Errorf("migration to version %d failed, %s", m.version, err.Error())
+		}
+		version = m.version
+	}
+	for version > target {
+		m := migrations[version-1]
+		log.Infof("Reverting the database schema version %d, %s", m.version, m.description)
+		if err = pg.runMigration(ctx, m.down, deleteSchemaVersion, m.version); err != nil {
+			return fmt.Errorf("reverting version %d failed, %s", m.version, err.Error())
+		}
+		version = m.version - 1
+	}
+	return nil
+}
+
+// runMigration executes statements and records the change of version with
+// versionQuery in a single transaction.
+func (pg *PgDb) runMigration(ctx context.Context, statements []string, versionQuery string, args ...interface{}) error {
+	tx, err := pg.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err = tx.ExecContext(ctx, statement); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if _, err = tx.ExecContext(ctx, versionQuery, args...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("expected migration %d to have the version %d, got %d", i, i+1, m.version)
+		}
+		if m.description == "" {
+			t.Errorf("migration %d has no description", m.version)
+		}
+		if len(m.up) == 0 || len(m.down) == 0 {
+			t.Errorf("migration %d must have up and down statements", m.version)
+		}
+	}
+	if LatestSchemaVersion() != len(migrations) {
+		t.Errorf("expected the latest schema version %d, got %d", len(migrations), LatestSchemaVersion())
+	}
+}
+
+var (
+	createTablePattern = regexp.MustCompile(`(?i)^\s*CREATE TABLE (?:IF NOT EXISTS )?(\w+)`)
+	createIndexPattern = regexp.MustCompile(`(?i)^\s*CREATE (?:UNIQUE )?INDEX (?:IF NOT EXISTS )?(\w+) ON (\w+)`)
+	dropTablePattern   = regexp.MustCompile(`(?i)^\s*DROP TABLE (?:IF EXISTS )?(\w+)`)
+	dropIndexPattern   = regexp.MustCompile(`(?i)^\s*DROP INDEX (?:IF EXISTS )?(\w+)`)
+)
+
+// TestMigrationsDown checks that the down statements of each migration drop
+// the tables created by its up statements, in the reverse order so that the
+// referencing tables go first, and the indexes that are not dropped with
+// their table.
+func TestMigrationsDown(t *testing.T) {
+	for _, m := range migrations {
+		var created []string
+		indexes := make(map[string]string)
+		for _, statement := range m.up {
+			if match := createTablePattern.FindStringSubmatch(statement); match != nil {
+				created = append(created, strings.ToLower(match[1]))
+			} else if match := createIndexPattern.FindStringSubmatch(statement); match != nil {
+				indexes[strings.ToLower(match[1])] = strings.ToLower(match[2])
+			}
+		}
+
+		var dropped []string
+		droppedTables := make(map[string]bool)
+		droppedIndexes := make(map[string]bool)
+		for _, statement := range m.down {
+			if match := dropTablePattern.FindStringSubmatch(statement); match != nil {
+				dropped = append(dropped, strings.ToLower(match[1]))
+				droppedTables[strings.ToLower(match[1])] = true
+			} else if match := dropIndexPattern.FindStringSubmatch(statement); match != nil {
+				droppedIndexes[strings.ToLower(match[1])] = true
+			}
+		}
+
+		expected := make([]string, len(created))
+		for i, table := range created {
+			expected[len(created)-1-i] = table
+		}
+		if !reflect.DeepEqual(dropped, expected) {
+			t.Errorf("migration %d: expected the tables %v to be dropped, got %v", m.version, expected, dropped)
+		}
+		for index, table := range indexes {
+			if !droppedIndexes[index] && !droppedTables[table] {
+				t.Errorf("migration %d: the index %s on %s is not dropped", m.version, index, table)
+			}
+		}
+	}
+}
@@ -48,8 +48,11 @@ func (pg PgDb) SaveSnapshot(ctx context.Context, snapshot netsnapshot.SnapShot)
 		existingSnapshot.OldestNode = snapshot.OldestNode
 		existingSnapshot.OldestNodeTimestamp = snapshot.OldestNodeTimestamp
 		existingSnapshot.Latency = snapshot.Latency
-		_, err = existingSnapshot.Update(ctx, pg.db, boil.Infer())
-		return err
+		if _, err = existingSnapshot.Update(ctx, pg.db, boil.Infer()); err != nil {
+			return err
+		}
+		pg.charts.Invalidate(cache.Snapshot)
+		return nil
 	}
 
 	snapshotModel := modelFromSnapshot(snapshot)
@@ -60,6 +63,7 @@ func (pg PgDb) SaveSnapshot(ctx context.Context, snapshot netsnapshot.SnapShot)
 		}
 	}
 
+	pg.charts.Invalidate(cache.Snapshot)
 	return nil
 }
 
@@ -776,6 +780,8 @@ func (pg *PgDb) fetchEncodeSnapshotNodesChart(ctx context.Context, charts *cache
 
 func (pg *PgDb) UpdateSnapshotNodesBin(ctx context.Context) error {
 	log.Info("Updating snapshot node bin data")
+	// the bins may be partially updated when an error is returned
+	defer pg.charts.Invalidate(cache.Snapshot)
 	// hour bin
 	lastHourEntry, err := models.NetworkSnapshotBins(
 		models.NetworkSnapshotBinWhere.Bin.EQ(string(cache.HourBin)),
@@ -0,0 +1,219 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/exchanges/orderbook"
+	"github.com/volatiletech/null"
+)
+
+const (
+	orderBookSnapshotTableName = "orderbook_snapshot"
+
+	insertOrderBookSnapshot = `INSERT INTO orderbook_snapshot (exchange, currency_pair, time, best_bid, best_ask, mid_price)
+		VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (exchange, currency_pair, time) DO NOTHING RETURNING id`
+
+	insertOrderBookDepth = `INSERT INTO orderbook_depth (snapshot_id, band, bid_depth, ask_depth) VALUES ($1, $2, $3, $4)`
+
+	selectOrderBookSnapshotsForSync = `SELECT id, exchange, currency_pair, time, best_bid, best_ask, mid_price
+		FROM orderbook_snapshot WHERE time > $1 ORDER BY time OFFSET $2 LIMIT $3`
+
+	countOrderBookSnapshotsForSync = `SELECT COUNT(*) FROM orderbook_snapshot WHERE time > $1`
+
+	selectOrderBookDepths = `SELECT snapshot_id, band, bid_depth, ask_depth FROM orderbook_depth
+		WHERE snapshot_id = ANY($1) ORDER BY band`
+
+	// selectOrderBookDepthChart is formatted with the time bucket expression
+	selectOrderBookDepthChart = `SELECT %s AS bucket, d.band, AVG(d.bid_depth), AVG(d.ask_depth)
+		FROM orderbook_snapshot s JOIN orderbook_depth d ON d.snapshot_id = s.id
+		WHERE s.exchange = $1 AND s.currency_pair = $2
+		GROUP BY bucket, d.band ORDER BY bucket, d.band`
+)
+
+func (pg *PgDb) OrderBookSnapshotTableName() string {
+	return orderBookSnapshotTableName
+}
+
+// StoreOrderBookSnapshot saves the snapshot and its depth bands. A snapshot
+// that already exists is ignored.
+func (pg *PgDb) StoreOrderBookSnapshot(ctx context.Context, snapshot orderbook.Snapshot) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var snapshotID int
+	err = tx.QueryRowContext(ctx, insertOrderBookSnapshot, snapshot.Exchange, snapshot.CurrencyPair,
+		snapshot.Time.UTC(), snapshot.BestBid, snapshot.BestAsk, snapshot.MidPrice).Scan(&snapshotID)
+	if err == sql.ErrNoRows {
+		_ = tx.Rollback()
+		return nil
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	for _, depth := range snapshot.Depths {
+		_, err = tx.ExecContext(ctx, insertOrderBookDepth, snapshotID, depth.Band, depth.BidDepth, depth.AskDepth)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	pg.charts.Invalidate(cache.OrderBook)
+	log.Infof("%-9s %7s, stored order book depth at %s", snapshot.Exchange, snapshot.CurrencyPair,
+		snapshot.Time.Format(dateTemplate))
+	return nil
+}
+
+func (pg *PgDb) SaveOrderBookSnapshotFromSync(ctx context.Context, snapshot interface{}) error {
+	return pg.StoreOrderBookSnapshot(ctx, snapshot.(orderbook.Snapshot))
+}
+
+// LastOrderBookSnapshotTime
+func (pg *PgDb) LastOrderBookSnapshotTime() (time time.Time) {
+	rows := pg.db.QueryRow(lastOrderBookSnapshotTime)
+	_ = rows.Scan(&time)
+	return
+}
+
+// FetchOrderBookSnapshotsForSync returns the snapshots taken after date with their depth bands
+func (pg *PgDb) FetchOrderBookSnapshotsForSync(ctx context.Context, date time.Time, skip, take int) ([]orderbook.Snapshot, int64, error) {
+	rows, err := pg.db.QueryContext(ctx, selectOrderBookSnapshotsForSync, date, skip, take)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var snapshots []orderbook.Snapshot
+	var ids []int64
+	indexes := make(map[int64]int)
+	for rows.Next() {
+		var id int64
+		var snapshot orderbook.Snapshot
+		err = rows.Scan(&id, &snapshot.Exchange, &snapshot.CurrencyPair, &snapshot.Time,
+			&snapshot.BestBid, &snapshot.BestAsk, &snapshot.MidPrice)
+		if err != nil {
+			return nil, 0, err
+		}
+		indexes[id] = len(snapshots)
+		ids = append(ids, id)
+		snapshots = append(snapshots, snapshot)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	depthRows, err := pg.db.QueryContext(ctx, selectOrderBookDepths, pq.Array(ids))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer depthRows.Close()
+	for depthRows.Next() {
+		var id int64
+		var depth orderbook.Depth
+		if err = depthRows.Scan(&id, &depth.Band, &depth.BidDepth, &depth.AskDepth); err != nil {
+			return nil, 0, err
+		}
+		i := indexes[id]
+		snapshots[i].Depths = append(snapshots[i].Depths, depth)
+	}
+	if err = depthRows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var totalCount int64
+	if err = pg.db.QueryRowContext(ctx, countOrderBookSnapshotsForSync, date).Scan(&totalCount); err != nil {
+		return nil, 0, err
+	}
+
+	return snapshots, totalCount, nil
+}
+
+// fetchEncodeOrderBookChart returns the bid or ask depth of each band over
+// time. The order book key of the exchange and pair is passed as the first
+// extra.
+func (pg *PgDb) fetchEncodeOrderBookChart(ctx context.Context, charts *cache.Manager, dataType, _ string, binString string, setKey ...string) ([]byte, error) {
+	if len(setKey) < 1 || setKey[0] == "" {
+		return nil, errors.New("order book key is required for order book chart")
+	}
+	exchangeName, currencyPair := cache.ExtractOrderBookKey(setKey[0])
+
+	bucket := "s.time"
+	switch cache.ParseBin(binString) {
+	case cache.HourBin:
+		bucket = "date_trunc('hour', s.time)"
+	case cache.DayBin:
+		bucket = "date_trunc('day', s.time)"
+	}
+
+	rows, err := pg.db.QueryContext(ctx, fmt.Sprintf(selectOrderBookDepthChart, bucket), exchangeName, currencyPair)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dates cache.ChartUints
+	var bands []float64
+	bandDepths := make(map[float64]map[uint64]float64)
+	for rows.Next() {
+		var t time.Time
+		var band, bidDepth, askDepth float64
+		if err = rows.Scan(&t, &band, &bidDepth, &askDepth); err != nil {
+			return nil, err
+		}
+		date := uint64(t.Unix())
+		if len(dates) == 0 || dates[len(dates)-1] != date {
+			dates = append(dates, date)
+		}
+		if _, found := bandDepths[band]; !found {
+			bandDepths[band] = make(map[uint64]float64)
+			bands = append(bands, band)
+		}
+		if strings.ToLower(dataType) == string(cache.OrderBookAskAxis) {
+			bandDepths[band][date] = askDepth
+		} else {
+			bandDepths[band][date] = bidDepth
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Float64s(bands)
+	var keys = []string{"x"}
+	var sets = []cache.Lengther{dates}
+	for _, band := range bands {
+		var series cache.ChartNullFloats
+		for _, date := range dates {
+			if depth, found := bandDepths[band][date]; found {
+				series = append(series, &null.Float64{Float64: depth, Valid: true})
+			} else {
+				series = append(series, &null.Float64{})
+			}
+		}
+		keys = append(keys, strconv.FormatFloat(band, 'f', -1, 64))
+		sets = append(sets, series)
+	}
+
+	return charts.Encode(keys, sets...)
+}
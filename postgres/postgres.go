@@ -7,9 +7,11 @@ package postgres
 //go:generate sqlboiler --wipe psql --no-hooks --no-auto-timestamps
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
+	"github.com/planetdecred/dcrextdata/cache"
 	"github.com/volatiletech/sqlboiler/boil"
 )
 
@@ -18,6 +20,7 @@ type PgDb struct {
 	queryTimeout         time.Duration
 	syncSourceDbProvider func(source string) (*PgDb, error)
 	syncSources          []string
+	charts               *cache.Manager
 }
 type logWriter struct{}
 
@@ -42,6 +45,11 @@ func NewPgDb(host, port, user, pass, dbname string, debug bool) (*PgDb, error) {
 	}, nil
 }
 
+// Ping checks that the database can be reached.
+func (pg *PgDb) Ping(ctx context.Context) error {
+	return pg.db.PingContext(ctx)
+}
+
 func (pg *PgDb) Close() error {
 	log.Trace("Closing postgresql connection")
 	return pg.db.Close()
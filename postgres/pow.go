@@ -66,6 +66,7 @@ func (pg *PgDb) AddPowData(ctx context.Context, data []pow.PowData) error {
 			added, last.Source, UnixTimeToString(data[0].Time), UnixTimeToString(last.Time))
 	}
 
+	pg.charts.Invalidate(cache.PowChart)
 	return nil
 }
 
@@ -76,6 +77,9 @@ func (pg *PgDb) AddPowDataFromSync(ctx context.Context, data interface{}) error
 	if isUniqueConstraint(err) {
 		return nil
 	}
+	if err == nil {
+		pg.charts.Invalidate(cache.PowChart)
+	}
 
 	return err
 }
@@ -453,6 +457,7 @@ func (pg *PgDb) UpdatePowChart(ctx context.Context) error {
 		return err
 	}
 
+	pg.charts.Invalidate(cache.PowChart)
 	return nil
 }
 
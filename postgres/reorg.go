@@ -0,0 +1,162 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/planetdecred/dcrextdata/app/helpers"
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/volatiletech/null"
+)
+
+const (
+	reorgTableName = "reorg"
+
+	// selectOrphanTime is the earliest time of a block and of the votes for
+	// it, from which the bins are outdated when it is orphaned.
+	selectOrphanTime = `SELECT MIN(t) FROM (
+			SELECT internal_timestamp AS t FROM block WHERE hash = $1
+			UNION ALL SELECT receive_time FROM block WHERE hash = $1
+			UNION ALL SELECT targeted_block_time FROM vote WHERE block_hash = $1
+		) times`
+
+	orphanBlock = `WITH orphaned AS (DELETE FROM block WHERE hash = $1
+			RETURNING hash, height, receive_time, internal_timestamp)
+		INSERT INTO orphaned_block (hash, height, receive_time, internal_timestamp, orphaned_at)
+			SELECT hash, height, receive_time, internal_timestamp, $2 FROM orphaned
+		ON CONFLICT (hash) DO NOTHING`
+
+	orphanVotes = `WITH orphaned AS (DELETE FROM vote WHERE block_hash = $1
+			RETURNING hash, voting_on, block_hash, receive_time, block_receive_time, targeted_block_time,
+				validator_id, validity)
+		INSERT INTO orphaned_vote (hash, voting_on, block_hash, receive_time, block_receive_time,
+				targeted_block_time, validator_id, validity, orphaned_at)
+			SELECT hash, voting_on, block_hash, receive_time, block_receive_time, targeted_block_time,
+				validator_id, validity, $2 FROM orphaned
+		ON CONFLICT (hash) DO NOTHING`
+
+	// The bins are deleted from the start of the day of an orphaned block and
+	// built again without it by their updaters.
+	deleteBlockBinsSince       = `DELETE FROM block_bin WHERE internal_timestamp >= $1`
+	deleteVoteDeviationsSince  = `DELETE FROM vote_receive_time_deviation WHERE block_time >= $1`
+	deletePropagationBinsSince = `DELETE FROM propagation WHERE time >= $1`
+
+	insertReorg = `INSERT INTO reorg (old_tip_hash, old_tip_height, new_tip_hash, new_tip_height, depth,
+			orphaned_blocks, time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (old_tip_hash) DO NOTHING`
+
+	selectReorgsForSync = `SELECT old_tip_hash, old_tip_height, new_tip_hash, new_tip_height, depth, orphaned_blocks, time
+		FROM reorg WHERE time > $1 ORDER BY time, old_tip_hash OFFSET $2 LIMIT $3`
+
+	countReorgsForSync = `SELECT COUNT(*) FROM reorg WHERE time > $1`
+)
+
+func (pg *PgDb) ReorgTableName() string {
+	return reorgTableName
+}
+
+// OrphanBlock moves a block that was disconnected from the main chain and the
+// votes for it to the orphaned tables, and deletes the propagation and vote
+// deviation bins that included them.
+func (pg *PgDb) OrphanBlock(ctx context.Context, hash string) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var since null.Time
+	if err = tx.QueryRowContext(ctx, selectOrphanTime, hash).Scan(&since); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if !since.Valid {
+		// neither the block nor votes for it were saved
+		_ = tx.Rollback()
+		return nil
+	}
+
+	now := helpers.NowUTC()
+	if _, err = tx.ExecContext(ctx, orphanBlock, hash, now); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, orphanVotes, hash, now); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	dayStart := since.Time.UTC().Truncate(cache.ADay * time.Second).Unix()
+	for _, statement := range []string{deleteBlockBinsSince, deleteVoteDeviationsSince, deletePropagationBinsSince} {
+		if _, err = tx.ExecContext(ctx, statement, dayStart); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	pg.charts.Invalidate(cache.Propagation)
+	log.Infof("Orphaned block %s", hash)
+	return nil
+}
+
+// SaveReorg saves a chain reorganization. The reorganizations that already
+// exist are ignored.
+func (pg *PgDb) SaveReorg(ctx context.Context, reorg mempool.Reorg) error {
+	_, err := pg.db.ExecContext(ctx, insertReorg, reorg.OldTipHash, reorg.OldTipHeight, reorg.NewTipHash,
+		reorg.NewTipHeight, reorg.Depth, pq.Array(reorg.OrphanedBlocks), reorg.Time.UTC())
+	if err != nil {
+		return err
+	}
+	log.Infof("Chain reorganization of depth %d from %d to %d", reorg.Depth, reorg.OldTipHeight, reorg.NewTipHeight)
+	return nil
+}
+
+// SaveReorgFromSync orphans the blocks of a synced reorganization and saves
+// it.
+func (pg *PgDb) SaveReorgFromSync(ctx context.Context, reorgData interface{}) error {
+	reorg := reorgData.(mempool.Reorg)
+	for _, hash := range reorg.OrphanedBlocks {
+		if err := pg.OrphanBlock(ctx, hash); err != nil {
+			return err
+		}
+	}
+	return pg.SaveReorg(ctx, reorg)
+}
+
+// FetchReorgsForSync returns the reorganizations that happened after date
+func (pg *PgDb) FetchReorgsForSync(ctx context.Context, date time.Time, skip, take int) ([]mempool.Reorg, int64, error) {
+	rows, err := pg.db.QueryContext(ctx, selectReorgsForSync, date, skip, take)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var reorgs []mempool.Reorg
+	for rows.Next() {
+		var r mempool.Reorg
+		err = rows.Scan(&r.OldTipHash, &r.OldTipHeight, &r.NewTipHash, &r.NewTipHeight, &r.Depth,
+			pq.Array(&r.OrphanedBlocks), &r.Time)
+		if err != nil {
+			return nil, 0, err
+		}
+		reorgs = append(reorgs, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var totalCount int64
+	if err = pg.db.QueryRowContext(ctx, countReorgsForSync, date).Scan(&totalCount); err != nil {
+		return nil, 0, err
+	}
+
+	return reorgs, totalCount, nil
+}
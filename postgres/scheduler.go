@@ -0,0 +1,43 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	selectJobRuns = `SELECT name, last_run FROM job_run`
+
+	upsertJobRun = `INSERT INTO job_run (name, last_run) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET last_run = EXCLUDED.last_run`
+)
+
+// LastJobRuns returns the time of the last run of every scheduled job.
+func (pg *PgDb) LastJobRuns(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := pg.db.QueryContext(ctx, selectJobRuns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lastRuns := make(map[string]time.Time)
+	for rows.Next() {
+		var name string
+		var lastRun time.Time
+		if err = rows.Scan(&name, &lastRun); err != nil {
+			return nil, err
+		}
+		lastRuns[name] = lastRun
+	}
+	return lastRuns, rows.Err()
+}
+
+// SaveJobRun records lastRun as the time of the last run of the job name.
+func (pg *PgDb) SaveJobRun(ctx context.Context, name string, lastRun time.Time) error {
+	_, err := pg.db.ExecContext(ctx, upsertJobRun, name, lastRun.UTC())
+	return err
+}
@@ -27,6 +27,44 @@ const (
 
 	lastExchangeEntryID = `SELECT id FROM exchange ORDER BY id DESC LIMIT 1`
 
+	selectExchangeTickSeries = `SELECT DISTINCT e.name, t.currency_pair, t.interval
+		FROM exchange_tick t JOIN exchange e ON e.id = t.exchange_id ORDER BY e.name, t.currency_pair, t.interval`
+
+	selectExchangeTickTimes = `SELECT t.time FROM exchange_tick t JOIN exchange e ON e.id = t.exchange_id
+		WHERE e.name = $1 AND t.currency_pair = $2 AND t.interval = $3 ORDER BY t.time`
+
+	createOrderBookSnapshotTable = `CREATE TABLE IF NOT EXISTS orderbook_snapshot (
+		id SERIAL PRIMARY KEY,
+		exchange TEXT NOT NULL,
+		currency_pair TEXT NOT NULL,
+		time TIMESTAMPTZ NOT NULL,
+		best_bid FLOAT NOT NULL,
+		best_ask FLOAT NOT NULL,
+		mid_price FLOAT NOT NULL
+	);`
+
+	createOrderBookSnapshotIndex = `CREATE UNIQUE INDEX IF NOT EXISTS orderbook_snapshot_idx ON orderbook_snapshot (exchange, currency_pair, time);`
+
+	createOrderBookDepthTable = `CREATE TABLE IF NOT EXISTS orderbook_depth (
+		snapshot_id INT REFERENCES orderbook_snapshot(id) ON DELETE CASCADE NOT NULL,
+		band FLOAT NOT NULL,
+		bid_depth FLOAT NOT NULL,
+		ask_depth FLOAT NOT NULL,
+		PRIMARY KEY (snapshot_id, band)
+	);`
+
+	lastOrderBookSnapshotTime = `SELECT time FROM orderbook_snapshot ORDER BY time DESC LIMIT 1`
+
+	createExchangeIndexBinTable = `CREATE TABLE IF NOT EXISTS exchange_index_bin (
+		time INT8 NOT NULL,
+		bin VARCHAR(25) NOT NULL,
+		currency_pair TEXT NOT NULL,
+		price FLOAT8 NOT NULL,
+		volume FLOAT8 NOT NULL,
+		exchange_count INT NOT NULL,
+		PRIMARY KEY (time, bin, currency_pair)
+	);`
+
 	createVSPInfoTable = `CREATE TABLE IF NOT EXISTS vsp (
 		id SERIAL PRIMARY KEY,
 		name TEXT,
@@ -264,287 +302,116 @@ const (
 		current_height INT8 NOT NULL,
 		PRIMARY KEY (timestamp, node_id)
 	);`
-)
-
-func (pg *PgDb) CreateExchangeTable() error {
-	log.Trace("Creating exchange tick table")
-	_, err := pg.db.Exec(createExchangeTable)
-	return err
-}
-
-func (pg *PgDb) ExchangeTableExits() bool {
-	exists, _ := pg.tableExists("exchange")
-	return exists
-}
-
-func (pg *PgDb) CreateExchangeTickTable() error {
-	log.Trace("Creating exchange tick table")
-	_, err := pg.db.Exec(createExchangeTickTable)
-	return err
-}
-
-func (pg *PgDb) CreateExchangeTickIndex() error {
-	_, err := pg.db.Exec(createExchangeTickIndex)
-	return err
-}
-
-func (pg *PgDb) ExchangeTickTableExits() bool {
-	exists, _ := pg.tableExists("exchange_tick")
-	return exists
-}
 
-func (pg *PgDb) CreateVSPInfoTables() error {
-	_, err := pg.db.Exec(createVSPInfoTable)
-	return err
-}
-
-func (pg *PgDb) VSPInfoTableExits() bool {
-	exists, _ := pg.tableExists("vsp")
-	return exists
-}
-
-func (pg *PgDb) CreateVSPTickTables() error {
-	_, err := pg.db.Exec(createVSPTickTable)
-	return err
-}
-
-func (pg *PgDb) CreateVSPTickBinTable() error {
-	_, err := pg.db.Exec(createVSPTickBinTable)
-	return err
-}
-
-func (pg *PgDb) VSPTickBinTableExits() bool {
-	exists, _ := pg.tableExists("vsp_tick_bin")
-	return exists
-}
-
-func (pg *PgDb) CreateVSPTickIndex() error {
-	_, err := pg.db.Exec(createVSPTickIndex)
-
-	return err
-}
-
-func (pg *PgDb) VSPTickTableExits() bool {
-	exists, _ := pg.tableExists("vsp_tick")
-	return exists
-}
-
-func (pg *PgDb) CreatePowDataTable() error {
-	_, err := pg.db.Exec(createPowDataTable)
-	return err
-}
-
-func (pg *PgDb) PowDataTableExits() bool {
-	exists, _ := pg.tableExists("pow_data")
-	return exists
-}
-
-func (pg *PgDb) CreatePowBinTable() error {
-	_, err := pg.db.Exec(createPowBInTable)
-	return err
-}
-
-func (pg *PgDb) PowBInTableExits() bool {
-	exists, _ := pg.tableExists("pow_bin")
-	return exists
-}
-
-func (pg *PgDb) CreateMempoolDataTable() error {
-	_, err := pg.db.Exec(createMempoolTable)
-	return err
-}
-
-func (pg *PgDb) CreateMempoolDayBinTable() error {
-	_, err := pg.db.Exec(createMempoolDayBinTable)
-	return err
-}
-
-func (pg *PgDb) MempoolDataTableExits() bool {
-	exists, _ := pg.tableExists("mempool")
-	return exists
-}
-
-func (pg *PgDb) MempoolBinDataTableExits() bool {
-	exists, _ := pg.tableExists("mempool_bin")
-	return exists
-}
-
-func (pg *PgDb) CreatePropagationTable() error {
-	_, err := pg.db.Exec(createPropagationTable)
-	return err
-}
-
-func (pg *PgDb) PropagationTableExists() bool {
-	exists, _ := pg.tableExists("propagation")
-	return exists
-}
-
-// block table
-func (pg *PgDb) CreateBlockTable() error {
-	_, err := pg.db.Exec(createBlockTable)
-	return err
-}
-
-func (pg *PgDb) BlockTableExits() bool {
-	exists, _ := pg.tableExists("block")
-	return exists
-}
-
-// createBlockBinTable
-func (pg *PgDb) CreateBlockBinTable() error {
-	_, err := pg.db.Exec(createBlockBinTable)
-	return err
-}
-
-func (pg *PgDb) BlockBinTableExits() bool {
-	exists, _ := pg.tableExists("block_bin")
-	return exists
-}
-
-// vote table
-func (pg *PgDb) CreateVoteTable() error {
-	_, err := pg.db.Exec(createVoteTable)
-	return err
-}
-
-func (pg *PgDb) VoteTableExits() bool {
-	exists, _ := pg.tableExists("vote")
-	return exists
-}
-
-// vote_receive_time_deviation table
-func (pg *PgDb) CreateVoteReceiveTimeDeviationTable() error {
-	_, err := pg.db.Exec(createVoteReceiveTimeDeviationTable)
-	return err
-}
-
-func (pg *PgDb) VoteReceiveTimeDeviationTableExits() bool {
-	exists, _ := pg.tableExists("vote_receive_time_deviation")
-	return exists
-}
-
-// reddit table
-func (pg *PgDb) CreateRedditTable() error {
-	_, err := pg.db.Exec(createRedditTable)
-	return err
-}
-
-func (pg *PgDb) RedditTableExits() bool {
-	exists, _ := pg.tableExists("reddit")
-	return exists
-}
-
-// twitter table
-func (pg *PgDb) CreateTwitterTable() error {
-	_, err := pg.db.Exec(createTwitterTable)
-	return err
-}
-
-func (pg *PgDb) TwitterTableExits() bool {
-	exists, _ := pg.tableExists("twitter")
-	return exists
-}
-
-// youtube table
-func (pg *PgDb) CreateGithubTable() error {
-	_, err := pg.db.Exec(createGithubTable)
-	return err
-}
-
-func (pg *PgDb) GithubTableExits() bool {
-	exists, _ := pg.tableExists("github")
-	return exists
-}
+	createJobRunTable = `CREATE TABLE IF NOT EXISTS job_run (
+		name TEXT NOT NULL PRIMARY KEY,
+		last_run TIMESTAMPTZ NOT NULL
+	);`
 
-// youtube table
-func (pg *PgDb) CreateYoutubeTable() error {
-	_, err := pg.db.Exec(createYoutubeTable)
-	return err
-}
+	createAPIKeyTable = `CREATE TABLE IF NOT EXISTS api_key (
+		name TEXT NOT NULL PRIMARY KEY,
+		key_hash TEXT NOT NULL UNIQUE,
+		rate_limit INT NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL
+	);`
 
-func (pg *PgDb) YoutubeTableExits() bool {
-	exists, _ := pg.tableExists("youtube")
-	return exists
-}
+	createVoteVersionTable = `CREATE TABLE IF NOT EXISTS vote_version (
+		hash VARCHAR(128) NOT NULL PRIMARY KEY,
+		receive_time TIMESTAMPTZ NOT NULL,
+		voting_on INT8 NOT NULL,
+		version INT8 NOT NULL,
+		bits INT NOT NULL
+	);`
 
-// network snapshot
-func (pg *PgDb) CreateNetworkSnapshotTable() error {
-	_, err := pg.db.Exec(createNetworkSnapshotTable)
-	return err
-}
+	createVoteVersionIndex = `CREATE INDEX IF NOT EXISTS vote_version_receive_time_idx ON vote_version (receive_time);`
 
-func (pg *PgDb) NetworkSnapshotTableExists() bool {
-	exists, _ := pg.tableExists("network_snapshot")
-	return exists
-}
+	createAgendaVoteTable = `CREATE TABLE IF NOT EXISTS agenda_vote (
+		vote_hash VARCHAR(128) REFERENCES vote_version(hash) ON DELETE CASCADE NOT NULL,
+		agenda_id TEXT NOT NULL,
+		choice TEXT NOT NULL,
+		PRIMARY KEY (vote_hash, agenda_id)
+	);`
 
-// network_snapshot_bin
-func (pg *PgDb) CreateNetworkSnapshotBinTable() error {
-	_, err := pg.db.Exec(createNetworkSnapshotBinTable)
-	return err
-}
+	createAgendaVoteIndex = `CREATE INDEX IF NOT EXISTS agenda_vote_agenda_idx ON agenda_vote (agenda_id);`
 
-func (pg *PgDb) NetworkSnapshotBinTableExists() bool {
-	exists, _ := pg.tableExists("network_snapshot_bin")
-	return exists
-}
+	createMempoolFeeRateTable = `CREATE TABLE IF NOT EXISTS mempool_fee_rate (
+		time timestamp REFERENCES mempool(time) ON DELETE CASCADE NOT NULL,
+		fee_rate INT8 NOT NULL,
+		tx_count INT NOT NULL,
+		size INT8 NOT NULL,
+		PRIMARY KEY (time, fee_rate)
+	);`
 
-// node_version
-func (pg *PgDb) CreateNodeVersoinTable() error {
-	_, err := pg.db.Exec(createNodeVersionTable)
-	return err
-}
+	createMempoolFeePercentileTable = `CREATE TABLE IF NOT EXISTS mempool_fee_percentile (
+		time timestamp REFERENCES mempool(time) ON DELETE CASCADE NOT NULL,
+		percentile INT NOT NULL,
+		fee_rate INT8 NOT NULL,
+		PRIMARY KEY (time, percentile)
+	);`
 
-func (pg *PgDb) NodeVersionTableExists() bool {
-	exists, _ := pg.tableExists("node_version")
-	return exists
-}
+	createMempoolFeeRateBinTable = `CREATE TABLE IF NOT EXISTS mempool_fee_rate_bin (
+		time INT8 NOT NULL,
+		bin VARCHAR(25) NOT NULL,
+		fee_rate INT8 NOT NULL,
+		tx_count FLOAT8 NOT NULL,
+		size FLOAT8 NOT NULL,
+		PRIMARY KEY (time, bin, fee_rate)
+	);`
 
-// node_location
-func (pg *PgDb) CreateNodeLocationTable() error {
-	_, err := pg.db.Exec(createNodeLocationTable)
-	return err
-}
+	createMempoolFeePercentileBinTable = `CREATE TABLE IF NOT EXISTS mempool_fee_percentile_bin (
+		time INT8 NOT NULL,
+		bin VARCHAR(25) NOT NULL,
+		percentile INT NOT NULL,
+		fee_rate FLOAT8 NOT NULL,
+		PRIMARY KEY (time, bin, percentile)
+	);`
 
-func (pg *PgDb) NodeLocationTableExists() bool {
-	exists, _ := pg.tableExists("node_location")
-	return exists
-}
+	createTxConfirmationTable = `CREATE TABLE IF NOT EXISTS tx_confirmation (
+		hash VARCHAR(128) NOT NULL PRIMARY KEY,
+		tx_type VARCHAR(16) NOT NULL,
+		fee_rate INT8 NOT NULL,
+		size INT NOT NULL,
+		seen_time TIMESTAMPTZ NOT NULL,
+		block_height INT8 NOT NULL,
+		block_hash VARCHAR(128) NOT NULL,
+		mined_time TIMESTAMPTZ NOT NULL,
+		wait_time FLOAT8 NOT NULL
+	);`
 
-// network node
-func (pg *PgDb) CreateNetworkNodeTable() error {
-	_, err := pg.db.Exec(createNodeTable)
-	return err
-}
+	createTxConfirmationIndex = `CREATE INDEX IF NOT EXISTS tx_confirmation_mined_time_idx ON tx_confirmation (mined_time);`
 
-func (pg *PgDb) NetworkNodeTableExists() bool {
-	exists, _ := pg.tableExists("node")
-	return exists
-}
+	createOrphanedBlockTable = `CREATE TABLE IF NOT EXISTS orphaned_block (
+		hash VARCHAR(512) NOT NULL PRIMARY KEY,
+		height INT NOT NULL,
+		receive_time timestamp,
+		internal_timestamp timestamp,
+		orphaned_at TIMESTAMPTZ NOT NULL
+	);`
 
-// network peer
-func (pg *PgDb) CreateHeartbeatTable() error {
-	_, err := pg.db.Exec(createHeartbeatTable)
-	return err
-}
+	createOrphanedVoteTable = `CREATE TABLE IF NOT EXISTS orphaned_vote (
+		hash VARCHAR(128) NOT NULL PRIMARY KEY,
+		voting_on INT8,
+		block_hash VARCHAR(128),
+		receive_time timestamp,
+		block_receive_time timestamp,
+		targeted_block_time timestamp,
+		validator_id INT,
+		validity VARCHAR(128),
+		orphaned_at TIMESTAMPTZ NOT NULL
+	);`
 
-func (pg *PgDb) HeartbeatTableExists() bool {
-	exists, _ := pg.tableExists("heartbeat")
-	return exists
-}
+	createReorgTable = `CREATE TABLE IF NOT EXISTS reorg (
+		old_tip_hash VARCHAR(512) NOT NULL PRIMARY KEY,
+		old_tip_height INT NOT NULL,
+		new_tip_hash VARCHAR(512) NOT NULL,
+		new_tip_height INT NOT NULL,
+		depth INT NOT NULL,
+		orphaned_blocks TEXT[] NOT NULL,
+		time TIMESTAMPTZ NOT NULL
+	);`
 
-func (pg *PgDb) tableExists(name string) (bool, error) {
-	rows, err := pg.db.Query(`SELECT relname FROM pg_class WHERE relname = $1`, name)
-	if err == nil {
-		defer func() {
-			if e := rows.Close(); e != nil {
-				log.Error("Close of Query failed: ", e)
-			}
-		}()
-		return rows.Next(), nil
-	}
-	return false, err
-}
+	createReorgIndex = `CREATE INDEX IF NOT EXISTS reorg_time_idx ON reorg (time);`
+)
 
 func (pg *PgDb) DropAllTables() error {
 	// vsp_tick
@@ -579,6 +446,25 @@ func (pg *PgDb) DropAllTables() error {
 		return err
 	}
 
+	// exchange_index_bin
+	if err := pg.dropTable("exchange_index_bin"); err != nil {
+		return err
+	}
+
+	// orderbook_depth
+	if err := pg.dropTable("orderbook_depth"); err != nil {
+		return err
+	}
+
+	// orderbook_snapshot
+	if err := pg.dropIndex("orderbook_snapshot_idx"); err != nil {
+		return err
+	}
+
+	if err := pg.dropTable("orderbook_snapshot"); err != nil {
+		return err
+	}
+
 	// pow_data
 	if err := pg.dropTable("pow_data"); err != nil {
 		return err
@@ -589,6 +475,26 @@ func (pg *PgDb) DropAllTables() error {
 		return err
 	}
 
+	// mempool_fee_rate
+	if err := pg.dropTable("mempool_fee_rate"); err != nil {
+		return err
+	}
+
+	// mempool_fee_rate_bin
+	if err := pg.dropTable("mempool_fee_rate_bin"); err != nil {
+		return err
+	}
+
+	// mempool_fee_percentile
+	if err := pg.dropTable("mempool_fee_percentile"); err != nil {
+		return err
+	}
+
+	// mempool_fee_percentile_bin
+	if err := pg.dropTable("mempool_fee_percentile_bin"); err != nil {
+		return err
+	}
+
 	// mempool
 	if err := pg.dropTable("mempool"); err != nil {
 		return err
@@ -619,6 +525,36 @@ func (pg *PgDb) DropAllTables() error {
 		return err
 	}
 
+	// agenda_vote
+	if err := pg.dropTable("agenda_vote"); err != nil {
+		return err
+	}
+
+	// vote_version
+	if err := pg.dropTable("vote_version"); err != nil {
+		return err
+	}
+
+	// tx_confirmation
+	if err := pg.dropTable("tx_confirmation"); err != nil {
+		return err
+	}
+
+	// orphaned_block
+	if err := pg.dropTable("orphaned_block"); err != nil {
+		return err
+	}
+
+	// orphaned_vote
+	if err := pg.dropTable("orphaned_vote"); err != nil {
+		return err
+	}
+
+	// reorg
+	if err := pg.dropTable("reorg"); err != nil {
+		return err
+	}
+
 	// reddit
 	if err := pg.dropTable("reddit"); err != nil {
 		return err
@@ -664,37 +600,70 @@ func (pg *PgDb) DropAllTables() error {
 		return err
 	}
 
+	// job_run
+	if err := pg.dropTable("job_run"); err != nil {
+		return err
+	}
+
+	// api_key
+	if err := pg.dropTable("api_key"); err != nil {
+		return err
+	}
+
+	// schema_version, so that the tables are created again by the migrations
+	if _, err := pg.db.Exec(dropSchemaVersionTable); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// DropCacheTables empties the tables that hold the chart data computed from
+// the collected data. The tables are kept, their schema being managed by the
+// migrations.
 func (pg *PgDb) DropCacheTables() error {
+	// exchange_index_bin
+	if err := pg.truncateTable("exchange_index_bin"); err != nil {
+		return err
+	}
+
 	// vsp_tick
-	if err := pg.dropTable("vsp_tick_bin"); err != nil {
+	if err := pg.truncateTable("vsp_tick_bin"); err != nil {
 		return err
 	}
 
 	// pow_bin
-	if err := pg.dropTable("pow_bin"); err != nil {
+	if err := pg.truncateTable("pow_bin"); err != nil {
 		return err
 	}
 
 	// mempool_bin
-	if err := pg.dropTable("mempool_bin"); err != nil {
+	if err := pg.truncateTable("mempool_bin"); err != nil {
+		return err
+	}
+
+	// mempool_fee_rate_bin
+	if err := pg.truncateTable("mempool_fee_rate_bin"); err != nil {
+		return err
+	}
+
+	// mempool_fee_percentile_bin
+	if err := pg.truncateTable("mempool_fee_percentile_bin"); err != nil {
 		return err
 	}
 
 	// propagation
-	if err := pg.dropTable("propagation"); err != nil {
+	if err := pg.truncateTable("propagation"); err != nil {
 		return err
 	}
 
 	// vote_receive_time_deviation
-	if err := pg.dropTable("vote_receive_time_deviation"); err != nil {
+	if err := pg.truncateTable("vote_receive_time_deviation"); err != nil {
 		return err
 	}
 
 	//network_snapshot_bin
-	if err := pg.dropTable("network_snapshot_bin"); err != nil {
+	if err := pg.truncateTable("network_snapshot_bin"); err != nil {
 		return err
 	}
 
@@ -707,6 +676,12 @@ func (pg *PgDb) dropTable(name string) error {
 	return err
 }
 
+func (pg *PgDb) truncateTable(name string) error {
+	log.Tracef("Emptying table %s", name)
+	_, err := pg.db.Exec(fmt.Sprintf(`TRUNCATE TABLE %s;`, name))
+	return err
+}
+
 func (pg *PgDb) dropIndex(name string) error {
 	log.Tracef("Dropping table %s", name)
 	_, err := pg.db.Exec(fmt.Sprintf(`DROP INDEX IF EXISTS %s;`, name))
@@ -0,0 +1,158 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/mempool"
+)
+
+const (
+	txConfirmationTableName = "tx_confirmation"
+
+	insertTxConfirmation = `INSERT INTO tx_confirmation (hash, tx_type, fee_rate, size, seen_time, block_height,
+			block_hash, mined_time, wait_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (hash) DO NOTHING`
+
+	selectTxConfirmationsForSync = `SELECT hash, tx_type, fee_rate, size, seen_time, block_height, block_hash,
+			mined_time, wait_time
+		FROM tx_confirmation WHERE mined_time > $1 ORDER BY mined_time, hash OFFSET $2 LIMIT $3`
+
+	countTxConfirmationsForSync = `SELECT COUNT(*) FROM tx_confirmation WHERE mined_time > $1`
+
+	// selectConfirmationLatencies is formatted with the bucket and the group
+	// expressions.
+	selectConfirmationLatencies = `SELECT %s AS bucket, MIN(block_height), MIN(mined_time), %s AS grp, AVG(wait_time)
+		FROM tx_confirmation
+		GROUP BY bucket, grp ORDER BY bucket`
+
+	// feeRateBucketGroup is the lower fee rate of the bucket of a transaction,
+	// the buckets being passed as the first argument.
+	feeRateBucketGroup = `(($1::INT8[])[width_bucket(fee_rate, $1::INT8[])])::TEXT`
+)
+
+func (pg *PgDb) TxConfirmationTableName() string {
+	return txConfirmationTableName
+}
+
+// SaveTxConfirmations saves the wait of the transactions mined in a block. The
+// transactions that already exist are ignored.
+func (pg *PgDb) SaveTxConfirmations(ctx context.Context, confirmations []mempool.TxConfirmation) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, c := range confirmations {
+		_, err = tx.ExecContext(ctx, insertTxConfirmation, c.Hash, c.TxType, c.FeeRate, c.Size, c.SeenTime.UTC(),
+			c.BlockHeight, c.BlockHash, c.MinedTime.UTC(), c.WaitTime)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	pg.charts.Invalidate(cache.Confirmation)
+	log.Infof("Saved the confirmation time of %d transactions", len(confirmations))
+	return nil
+}
+
+func (pg *PgDb) SaveTxConfirmationFromSync(ctx context.Context, confirmation interface{}) error {
+	return pg.SaveTxConfirmations(ctx, []mempool.TxConfirmation{confirmation.(mempool.TxConfirmation)})
+}
+
+// FetchTxConfirmationsForSync returns the transactions mined after date
+func (pg *PgDb) FetchTxConfirmationsForSync(ctx context.Context, date time.Time, skip, take int) ([]mempool.TxConfirmation, int64, error) {
+	rows, err := pg.db.QueryContext(ctx, selectTxConfirmationsForSync, date, skip, take)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var confirmations []mempool.TxConfirmation
+	for rows.Next() {
+		var c mempool.TxConfirmation
+		err = rows.Scan(&c.Hash, &c.TxType, &c.FeeRate, &c.Size, &c.SeenTime, &c.BlockHeight, &c.BlockHash,
+			&c.MinedTime, &c.WaitTime)
+		if err != nil {
+			return nil, 0, err
+		}
+		confirmations = append(confirmations, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var totalCount int64
+	if err = pg.db.QueryRowContext(ctx, countTxConfirmationsForSync, date).Scan(&totalCount); err != nil {
+		return nil, 0, err
+	}
+
+	return confirmations, totalCount, nil
+}
+
+// fetchEncodeConfirmationChart returns the average wait in the mempool of the
+// transactions of each fee rate bucket or transaction type, per block for the
+// default bin.
+func (pg *PgDb) fetchEncodeConfirmationChart(ctx context.Context, charts *cache.Manager, dataType, axisString string, binString string, _ ...string) ([]byte, error) {
+	var group string
+	var groups []string
+	var args []interface{}
+	switch dataType {
+	case cache.ConfirmationFeeRate:
+		group = feeRateBucketGroup
+		for _, feeRate := range mempool.FeeRateBuckets {
+			groups = append(groups, strconv.FormatInt(feeRate, 10))
+		}
+		args = append(args, pq.Array(mempool.FeeRateBuckets))
+	case cache.ConfirmationTxType:
+		group = "tx_type"
+		groups = cache.ConfirmationTxTypes
+	default:
+		return nil, cache.UnknownChartErr
+	}
+
+	bin := cache.ParseBin(binString)
+	bucket := "block_height"
+	switch bin {
+	case cache.HourBin:
+		bucket = "EXTRACT(EPOCH FROM date_trunc('hour', mined_time))::INT8"
+	case cache.DayBin:
+		bucket = "EXTRACT(EPOCH FROM date_trunc('day', mined_time))::INT8"
+	}
+
+	rows, err := pg.db.QueryContext(ctx, fmt.Sprintf(selectConfirmationLatencies, bucket, group), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var latencies []cache.ConfirmationLatency
+	for rows.Next() {
+		var latency cache.ConfirmationLatency
+		var minedTime time.Time
+		if err = rows.Scan(&latency.Bucket, &latency.Height, &minedTime, &latency.Group, &latency.Wait); err != nil {
+			return nil, err
+		}
+		latency.Time = uint64(minedTime.Unix())
+		if bin != cache.DefaultBin {
+			latency.Time = latency.Bucket
+		}
+		latencies = append(latencies, latency)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return cache.MakeConfirmationChart(charts, groups, latencies, cache.ParseAxis(axisString))
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/mempool"
+)
+
+const (
+	voteVersionTableName = "vote_version"
+
+	insertVoteVersion = `INSERT INTO vote_version (hash, receive_time, voting_on, version, bits)
+		VALUES ($1, $2, $3, $4, $5) ON CONFLICT (hash) DO NOTHING`
+
+	insertAgendaVote = `INSERT INTO agenda_vote (vote_hash, agenda_id, choice) VALUES ($1, $2, $3)
+		ON CONFLICT (vote_hash, agenda_id) DO NOTHING`
+
+	selectVoteVersionsForSync = `SELECT hash, receive_time, voting_on, version, bits
+		FROM vote_version WHERE receive_time > $1 ORDER BY receive_time OFFSET $2 LIMIT $3`
+
+	countVoteVersionsForSync = `SELECT COUNT(*) FROM vote_version WHERE receive_time > $1`
+
+	selectAgendaVotes = `SELECT vote_hash, agenda_id, choice FROM agenda_vote
+		WHERE vote_hash = ANY($1) ORDER BY agenda_id`
+
+	// selectAgendaBlockTallies counts the choices of the votes on each block
+	selectAgendaBlockTallies = `SELECT v.voting_on, MIN(v.receive_time), c.choice, COUNT(*)
+		FROM vote_version v JOIN agenda_vote c ON c.vote_hash = v.hash
+		WHERE c.agenda_id = $1
+		GROUP BY v.voting_on, c.choice ORDER BY v.voting_on`
+
+	// selectAgendaBinTallies is formatted with the time bucket expression
+	selectAgendaBinTallies = `SELECT %s AS bucket, MIN(v.voting_on), c.choice, COUNT(*)
+		FROM vote_version v JOIN agenda_vote c ON c.vote_hash = v.hash
+		WHERE c.agenda_id = $1
+		GROUP BY bucket, c.choice ORDER BY bucket`
+)
+
+func (pg *PgDb) VoteVersionTableName() string {
+	return voteVersionTableName
+}
+
+// SaveVoteVersion saves the version, the bits and the agenda choices of a
+// vote. A vote that already exists is ignored.
+func (pg *PgDb) SaveVoteVersion(ctx context.Context, vote mempool.VoteVersion) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, insertVoteVersion, vote.Hash, vote.ReceiveTime.UTC(), vote.VotingOn,
+		vote.Version, vote.Bits)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if inserted, err := result.RowsAffected(); err != nil || inserted == 0 {
+		_ = tx.Rollback()
+		return err
+	}
+
+	for _, choice := range vote.Choices {
+		if _, err = tx.ExecContext(ctx, insertAgendaVote, vote.Hash, choice.AgendaID, choice.Choice); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	pg.charts.Invalidate(cache.Agenda)
+	return nil
+}
+
+func (pg *PgDb) SaveVoteVersionFromSync(ctx context.Context, vote interface{}) error {
+	return pg.SaveVoteVersion(ctx, vote.(mempool.VoteVersion))
+}
+
+// FetchVoteVersionsForSync returns the vote versions received after date with
+// their agenda choices
+func (pg *PgDb) FetchVoteVersionsForSync(ctx context.Context, date time.Time, skip, take int) ([]mempool.VoteVersion, int64, error) {
+	rows, err := pg.db.QueryContext(ctx, selectVoteVersionsForSync, date, skip, take)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var votes []mempool.VoteVersion
+	var hashes []string
+	indexes := make(map[string]int)
+	for rows.Next() {
+		var vote mempool.VoteVersion
+		err = rows.Scan(&vote.Hash, &vote.ReceiveTime, &vote.VotingOn, &vote.Version, &vote.Bits)
+		if err != nil {
+			return nil, 0, err
+		}
+		indexes[vote.Hash] = len(votes)
+		hashes = append(hashes, vote.Hash)
+		votes = append(votes, vote)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	choiceRows, err := pg.db.QueryContext(ctx, selectAgendaVotes, pq.Array(hashes))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer choiceRows.Close()
+	for choiceRows.Next() {
+		var hash string
+		var choice mempool.AgendaChoice
+		if err = choiceRows.Scan(&hash, &choice.AgendaID, &choice.Choice); err != nil {
+			return nil, 0, err
+		}
+		i := indexes[hash]
+		votes[i].Choices = append(votes[i].Choices, choice)
+	}
+	if err = choiceRows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var totalCount int64
+	if err = pg.db.QueryRowContext(ctx, countVoteVersionsForSync, date).Scan(&totalCount); err != nil {
+		return nil, 0, err
+	}
+
+	return votes, totalCount, nil
+}
+
+// fetchEncodeAgendaChart returns the number of votes for each choice of an
+// agenda, per block for the default bin. The agenda ID is passed as the
+// first extra.
+func (pg *PgDb) fetchEncodeAgendaChart(ctx context.Context, charts *cache.Manager, _, axisString string, binString string, agendaID ...string) ([]byte, error) {
+	if len(agendaID) < 1 || agendaID[0] == "" {
+		return nil, errors.New("agenda ID is required for agenda chart")
+	}
+
+	var tallies []cache.AgendaTally
+	bin := cache.ParseBin(binString)
+	if bin == cache.DefaultBin {
+		rows, err := pg.db.QueryContext(ctx, selectAgendaBlockTallies, agendaID[0])
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var tally cache.AgendaTally
+			var t time.Time
+			if err = rows.Scan(&tally.Height, &t, &tally.Choice, &tally.Count); err != nil {
+				return nil, err
+			}
+			tally.Time = uint64(t.Unix())
+			tally.Bucket = tally.Height
+			tallies = append(tallies, tally)
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		bucket := "date_trunc('hour', v.receive_time)"
+		if bin == cache.DayBin {
+			bucket = "date_trunc('day', v.receive_time)"
+		}
+		rows, err := pg.db.QueryContext(ctx, fmt.Sprintf(selectAgendaBinTallies, bucket), agendaID[0])
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var tally cache.AgendaTally
+			var t time.Time
+			if err = rows.Scan(&t, &tally.Height, &tally.Choice, &tally.Count); err != nil {
+				return nil, err
+			}
+			tally.Time = uint64(t.Unix())
+			tally.Bucket = tally.Time
+			tallies = append(tallies, tally)
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return cache.MakeAgendaChart(charts, tallies, cache.ParseAxis(axisString))
+}
@@ -58,6 +58,8 @@ func (pg *PgDb) StoreVSPs(ctx context.Context, data vsp.Response) (int, []error)
 	}
 	if completed == 0 {
 		log.Info("Unable to store any vsp entry")
+	} else {
+		pg.charts.Invalidate(cache.VSP)
 	}
 	return completed, errs
 }
@@ -249,7 +251,11 @@ func (pg *PgDb) AddVspTicksFromSync(ctx context.Context, tick datasync.VSPTickSy
 		Time:             tick.Time,
 	}
 
-	return tickModel.Insert(ctx, pg.db, boil.Infer())
+	if err := tickModel.Insert(ctx, pg.db, boil.Infer()); err != nil {
+		return err
+	}
+	pg.charts.Invalidate(cache.VSP)
+	return nil
 }
 
 func (pg *PgDb) FilteredVSPTicks(ctx context.Context, vspName string, offset, limit int) ([]vsp.VSPTickDto, int64, error) {
@@ -868,6 +874,7 @@ func (pg *PgDb) UpdateVspChart(ctx context.Context) error {
 	if err := pg.UpdateVspDailyChart(ctx); err != nil {
 		return err
 	}
+	pg.charts.Invalidate(cache.VSP)
 	return nil
 }
 
@@ -6,11 +6,12 @@ package pow
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
-	"github.com/planetdecred/dcrextdata/app"
-	"github.com/planetdecred/dcrextdata/app/helpers"
+	"github.com/planetdecred/dcrextdata/scheduler"
 )
 
 var (
@@ -65,79 +66,41 @@ func NewCollector(disabledPows []string, period int64, store PowDataStore) (*Col
 	}, nil
 }
 
-func (pc *Collector) Run(ctx context.Context) {
-	app.MarkBusyIfFree()
-	log.Info("Triggering PoW collectors.")
-
-	lastCollectionDateUnix := pc.store.LastPowEntryTime("")
-	lastCollectionDate := helpers.UnixTime(lastCollectionDateUnix)
-	secondsPassed := time.Since(lastCollectionDate)
+// RegisterJobs adds the PoW data collection to s.
+func (pc *Collector) RegisterJobs(s *scheduler.Scheduler) error {
 	period := time.Duration(pc.period) * time.Second
-
-	if lastCollectionDateUnix > 0 && secondsPassed < period {
-		timeLeft := period - secondsPassed
-		log.Infof("Fetching PoW data every %dm, collected %s ago, will fetch in %s.", pc.period/60,
-			helpers.DurationToString(secondsPassed), helpers.DurationToString(timeLeft))
-
-		app.ReleaseForNewModule()
-		time.Sleep(timeLeft)
-	}
-
-	if lastCollectionDateUnix > 0 && secondsPassed < period {
-		// continually check the state of the app until its free to run this module
-		app.MarkBusyIfFree()
-	}
-	pc.Collect(ctx)
-	app.ReleaseForNewModule()
-	go pc.CollectAsync(ctx)
+	return s.AddJob(scheduler.Job{
+		Name:     "pow",
+		Interval: period,
+		Timeout:  period,
+		Run:      pc.Collect,
+	})
 }
 
-func (pc *Collector) CollectAsync(ctx context.Context) {
-	if ctx.Err() != nil {
-		return
-	}
-
-	ticker := time.NewTicker(time.Duration(pc.period) * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			log.Infof("Stopping PoW collectors")
-			return
-		case <-ticker.C:
-			// continually check the state of the app until its free to run this module
-			app.MarkBusyIfFree()
-			completeCollectionCycle := pc.store.LastPowEntryTime("")
-			collectionCycleDate := helpers.UnixTime(completeCollectionCycle)
-			timeInterval := time.Since(collectionCycleDate)
-			log.Info("The next collection cycle begins in", timeInterval)
-
-			log.Info("Starting a new PoW collection cycle")
-			pc.Collect(ctx)
-			app.ReleaseForNewModule()
-		}
-	}
-}
-
-func (pc *Collector) Collect(ctx context.Context) {
+// Collect fetches and stores the data of every PoW source. The sources that
+// fail do not stop the others, their errors are returned together.
+func (pc *Collector) Collect(ctx context.Context) error {
 	log.Info("Fetching PoW data.")
+	var failures []string
 	for _, powInfo := range pc.pows {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			data, err := powInfo.Collect(ctx)
-			if err != nil {
-				log.Error(err, powInfo.Name())
-			}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		data, err := powInfo.Collect(ctx)
+		if err == nil {
 			err = pc.store.AddPowData(ctx, data)
-			if err != nil {
-				log.Error(err)
-			}
+		}
+		if err != nil {
+			log.Error(err, powInfo.Name())
+			failures = append(failures, fmt.Sprintf("%s: %v", powInfo.Name(), err))
 		}
 	}
 	if err := pc.store.UpdateMempoolAggregateData(ctx); err != nil {
 		log.Error(err)
+		failures = append(failures, err.Error())
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("unable to collect the PoW data, %s", strings.Join(failures, "; "))
 	}
+	return nil
 }
@@ -7,6 +7,7 @@ package pow
 import (
 	"context"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
@@ -95,7 +96,9 @@ func (in *LuxorPow) Collect(ctx context.Context) ([]PowData, error) {
 	}
 
 	result := in.fetch(res, in.lastUpdate)
-	in.lastUpdate = result[len(result)-1].Time
+	if len(result) > 0 {
+		in.lastUpdate = result[len(result)-1].Time
+	}
 
 	return result, nil
 }
@@ -158,7 +161,9 @@ func (in *F2poolPow) Collect(ctx context.Context) ([]PowData, error) {
 	}
 
 	result := in.fetch(res, in.lastUpdate)
-	in.lastUpdate = result[len(result)-1].Time
+	if len(result) > 0 {
+		in.lastUpdate = result[len(result)-1].Time
+	}
 
 	return result, nil
 }
@@ -181,6 +186,8 @@ func (F2poolPow) fetch(res *f2poolAPIResponse, start int64) []PowData {
 			Source:       "f2pool",
 		})
 	}
+	// the history is a map, order it by time so that the last entry is the latest
+	sort.Slice(data, func(i, j int) bool { return data[i].Time < data[j].Time })
 	return data
 }
 
@@ -0,0 +1,137 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pow
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/planetdecred/dcrextdata/app/helpers"
+	"github.com/planetdecred/dcrextdata/testutil"
+)
+
+// powTestStore records the data passed to AddPowData, or fails with err.
+type powTestStore struct {
+	data []PowData
+	err  error
+}
+
+func (s *powTestStore) PowTableName() string { return "pow_data" }
+func (s *powTestStore) AddPowData(_ context.Context, data []PowData) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.data = append(s.data, data...)
+	return nil
+}
+func (s *powTestStore) LastPowEntryTime(string) int64 { return 0 }
+func (s *powTestStore) FetchPowDataForSync(context.Context, int64, int, int) ([]PowData, int64, error) {
+	return nil, 0, nil
+}
+func (s *powTestStore) UpdateMempoolAggregateData(context.Context) error { return nil }
+
+func TestCollect(t *testing.T) {
+	server := testutil.NewFixtureServer(t,
+		testutil.Route{Host: "master-api.luxor.tech", Path: "/dcr/api/pool_stats", Fixture: "luxor.json"},
+		testutil.Route{Host: "api.f2pool.com", Path: "/decred/", Fixture: "f2pool.json"},
+		testutil.Route{Host: "www2.coinmine.pl", Path: "/dcr/index.php", Fixture: "coinmine.json"},
+		testutil.Route{Host: "uupool.cn", Path: "/api/getPoolInfo.php", Fixture: "uupool.json"},
+	)
+
+	var pows []Pow
+	for _, name := range availablePows {
+		pow, err := PowConstructors[name](server.Client(), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pows = append(pows, pow)
+	}
+	store := new(powTestStore)
+	collector := &Collector{pows: pows, store: store}
+
+	start := helpers.NowUTC().Unix()
+	if err := collector.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	end := helpers.NowUTC().Unix()
+
+	// coinmine and uupool only report the current state, dated with the
+	// collection time
+	for i := range store.data {
+		if source := store.data[i].Source; source != Coinmine && source != Uupool {
+			continue
+		}
+		if store.data[i].Time < start || store.data[i].Time > end {
+			t.Errorf("expected %s data between %d and %d, got %d", store.data[i].Source, start, end, store.data[i].Time)
+		}
+		store.data[i].Time = 0
+	}
+
+	expected := []PowData{
+		{Time: 0, PoolHashrate: 1.82e+15, Workers: 41, Source: Coinmine},
+		{Time: 1577836800, PoolHashrate: 4.521e+16, Workers: 312, CoinPrice: 19.23, BtcPrice: 0.002648, Source: Luxor},
+		{Time: 1577837100, PoolHashrate: 4.612e+16, Workers: 318, CoinPrice: 19.27, BtcPrice: 0.002651, Source: Luxor},
+		{Time: 1577836800, PoolHashrate: 4.25e+16, Source: F2pool},
+		{Time: 1577837100, PoolHashrate: 4.32e+16, Source: F2pool},
+		{Time: 1577837400, PoolHashrate: 4.41e+16, Source: F2pool},
+		{Time: 0, PoolHashrate: 2.64e+15, Workers: 57, Source: Uupool},
+	}
+	if !reflect.DeepEqual(store.data, expected) {
+		t.Errorf("expected %+v, got %+v", expected, store.data)
+	}
+
+	// the next collection starts after the latest stored entry
+	if last := pows[1].LastUpdateTime(); last != 1577837100 {
+		t.Errorf("expected the luxor last update at 1577837100, got %d", last)
+	}
+	if last := pows[2].LastUpdateTime(); last != 1577837400 {
+		t.Errorf("expected the f2pool last update at 1577837400, got %d", last)
+	}
+
+	requests := server.Requests()
+	if len(requests) != len(pows) {
+		t.Fatalf("expected %d requests, got %d", len(pows), len(requests))
+	}
+	if query := requests[0].Query(); query.Get("page") != "api" || query.Get("action") != "public" {
+		t.Errorf("unexpected coinmine request %s", requests[0])
+	}
+	if coin := requests[3].Query().Get("coin"); coin != "dcr" {
+		t.Errorf("expected the dcr uupool stats to be requested, got %s", coin)
+	}
+}
+
+func TestCollectReturnsErrors(t *testing.T) {
+	server := testutil.NewFixtureServer(t,
+		testutil.Route{Host: "master-api.luxor.tech", Path: "/dcr/api/pool_stats", Fixture: "luxor.json"},
+		testutil.Route{Host: "api.f2pool.com", Path: "/decred/", Fixture: "f2pool.json"},
+	)
+	var pows []Pow
+	for _, name := range []string{Luxor, F2pool} {
+		pow, err := PowConstructors[name](server.Client(), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pows = append(pows, pow)
+	}
+	store := &powTestStore{err: errors.New("disk full")}
+	collector := &Collector{pows: pows, store: store}
+
+	// a failing source does not stop the others
+	err := collector.Collect(context.Background())
+	if err == nil {
+		t.Fatal("expected the store errors to be returned")
+	}
+	for _, name := range []string{Luxor, F2pool} {
+		if !strings.Contains(err.Error(), name+": disk full") {
+			t.Errorf("expected the %s error in %q", name, err)
+		}
+	}
+	if requests := server.Requests(); len(requests) != len(pows) {
+		t.Errorf("expected %d requests, got %d", len(pows), len(requests))
+	}
+}
@@ -0,0 +1,414 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package scheduler runs the periodic collection jobs. The jobs run
+// concurrently, except for the jobs that declare the same group, which run one
+// at a time in order of priority.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/app/helpers"
+	"github.com/planetdecred/dcrextdata/metrics"
+)
+
+// minRetryDelay is the delay before the retry of a failed run. It doubles
+// with every consecutive failure, up to the interval of the job.
+const minRetryDelay = 30 * time.Second
+
+// Job is a task that is run periodically by a Scheduler.
+type Job struct {
+	// Name identifies the job. The time of its last run is persisted under
+	// it.
+	Name string
+	// Module labels the metrics of the job's collection cycles. The jobs of a
+	// collector share it, it defaults to the name of the job.
+	Module string
+	// Interval is the time between the start of two runs.
+	Interval time.Duration
+	// Jitter bounds a random delay added to each run, to spread the requests
+	// of the jobs that share an interval.
+	Jitter time.Duration
+	// Timeout cancels the context of a run that lasts longer. Zero means no
+	// timeout.
+	Timeout time.Duration
+	// Group names the jobs that must not run concurrently. The jobs without a
+	// group run whenever they are due.
+	Group string
+	// Priority orders the jobs of a group that are due at the same time,
+	// the highest first.
+	Priority int
+	// StaleAfter is the time without a successful run after which the job is
+	// reported as stale. It defaults to three intervals.
+	StaleAfter time.Duration
+	// Deferred delays the first run after a start by an interval, whatever
+	// the persisted last run, for the jobs whose runs cover the time since
+	// the previous one.
+	Deferred bool
+	// Run executes a run of the job.
+	Run func(ctx context.Context) error
+}
+
+// Store persists the time of the last successful run of every job, so that a
+// restart does not run the jobs before they are due.
+type Store interface {
+	LastJobRuns(ctx context.Context) (map[string]time.Time, error)
+	SaveJobRun(ctx context.Context, name string, lastRun time.Time) error
+}
+
+// JobStatus describes the state of a job.
+type JobStatus struct {
+	Name         string
+	Group        string
+	Interval     time.Duration
+	Priority     int
+	StaleAfter   time.Duration
+	Running      bool
+	Paused       bool
+	Stale        bool
+	LastRun      time.Time
+	LastSuccess  time.Time
+	LastDuration time.Duration
+	LastError    string
+	NextRun      time.Time
+	Runs         int
+	Failures     int
+}
+
+type job struct {
+	Job
+	status JobStatus
+	// triggered requests a run, even if the job is paused
+	triggered bool
+	// retries is the number of consecutive failed runs
+	retries int
+}
+
+// Scheduler runs the jobs added to it when they are due.
+type Scheduler struct {
+	store Store
+
+	mtx        sync.Mutex
+	started    time.Time
+	jobs       []*job
+	busyGroups map[string]bool
+	wake       chan struct{}
+}
+
+func NewScheduler(store Store) *Scheduler {
+	return &Scheduler{
+		store:      store,
+		busyGroups: make(map[string]bool),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// AddJob adds a job to the scheduler. The jobs must be added before Start is
+// called.
+func (s *Scheduler) AddJob(j Job) error {
+	if j.Name == "" || j.Run == nil {
+		return fmt.Errorf("a job must have a name and a run function")
+	}
+	if j.Interval <= 0 {
+		return fmt.Errorf("invalid interval for the %s job, %s", j.Name, j.Interval)
+	}
+	if j.Module == "" {
+		j.Module = j.Name
+	}
+	if j.StaleAfter <= 0 {
+		j.StaleAfter = 3 * j.Interval
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, existing := range s.jobs {
+		if existing.Name == j.Name {
+			return fmt.Errorf("a job named %s already exists", j.Name)
+		}
+	}
+	s.jobs = append(s.jobs, &job{
+		Job: j,
+		status: JobStatus{
+			Name:       j.Name,
+			Group:      j.Group,
+			Interval:   j.Interval,
+			Priority:   j.Priority,
+			StaleAfter: j.StaleAfter,
+		},
+	})
+	return nil
+}
+
+// Statuses returns the status of every job, ordered by name.
+func (s *Scheduler) Statuses() []JobStatus {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	now := time.Now()
+	statuses := make([]JobStatus, len(s.jobs))
+	for i, j := range s.jobs {
+		statuses[i] = j.status
+		statuses[i].Stale = s.stale(j, now)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Start runs the jobs until ctx is canceled. The first run of each job is due
+// an interval after its persisted last run, or within its jitter if it never
+// ran. The deferred jobs are due an interval after the start.
+func (s *Scheduler) Start(ctx context.Context) {
+	lastRuns, err := s.store.LastJobRuns(ctx)
+	if err != nil {
+		log.Errorf("Cannot read the last job runs, %s", err.Error())
+	}
+
+	s.mtx.Lock()
+	now := time.Now()
+	s.started = now
+	for _, j := range s.jobs {
+		lastRun, found := lastRuns[j.Name]
+		if found {
+			j.status.LastRun = lastRun
+		}
+		switch {
+		case j.Deferred:
+			j.status.NextRun = now.Add(j.Interval + jitter(j))
+		case found:
+			s.plan(j, now)
+		default:
+			j.status.NextRun = now.Add(jitter(j))
+		}
+		if found && j.status.NextRun.After(now) {
+			log.Infof("Running %s every %s, ran %s ago, will run in %s.", j.Name, helpers.DurationToString(j.Interval),
+				helpers.DurationToString(now.Sub(lastRun)), helpers.DurationToString(j.status.NextRun.Sub(now)))
+		}
+	}
+	s.mtx.Unlock()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		wait := s.dispatch(ctx)
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			log.Info("Stopping the scheduler")
+			return
+		case <-timer.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// plan sets the next run of j, an interval after its last run or, after a
+// failure, the retry delay from now. The caller must hold the lock.
+func (s *Scheduler) plan(j *job, now time.Time) {
+	if j.retries > 0 {
+		j.status.NextRun = now.Add(retryDelay(j.Interval, j.retries))
+		return
+	}
+	j.status.NextRun = j.status.LastRun.Add(j.Interval + jitter(j))
+}
+
+// retryDelay returns the delay before the next run of a job of interval after
+// failures consecutive failed runs.
+func retryDelay(interval time.Duration, failures int) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < failures && delay < interval; i++ {
+		delay *= 2
+	}
+	if delay > interval {
+		return interval
+	}
+	return delay
+}
+
+// jitter returns a random delay within the jitter of j.
+func jitter(j *job) time.Duration {
+	if j.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(j.Jitter)))
+}
+
+// stale tells whether j went without a successful run for longer than its
+// StaleAfter. The jobs that did not succeed since the scheduler started are
+// measured from its start. Paused jobs are never stale. The caller must hold
+// the lock.
+func (s *Scheduler) stale(j *job, now time.Time) bool {
+	if s.started.IsZero() || j.status.Paused {
+		return false
+	}
+	since := j.status.LastSuccess
+	if since.IsZero() {
+		since = s.started
+	}
+	return now.Sub(since) > j.StaleAfter
+}
+
+// dispatch starts the due jobs whose group is free and returns the time until
+// the next job is due.
+func (s *Scheduler) dispatch(ctx context.Context) time.Duration {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	now := time.Now()
+	var due []*job
+	for _, j := range s.jobs {
+		if j.status.Running {
+			continue
+		}
+		if j.triggered || (!j.status.Paused && !j.status.NextRun.After(now)) {
+			due = append(due, j)
+		}
+	}
+	sort.SliceStable(due, func(a, b int) bool {
+		if due[a].Priority != due[b].Priority {
+			return due[a].Priority > due[b].Priority
+		}
+		return due[a].status.NextRun.Before(due[b].status.NextRun)
+	})
+	for _, j := range due {
+		if j.Group != "" {
+			if s.busyGroups[j.Group] {
+				continue
+			}
+			s.busyGroups[j.Group] = true
+		}
+		j.status.Running = true
+		j.triggered = false
+		go s.run(ctx, j)
+	}
+
+	// the due jobs waiting for their group are started when it is released
+	wait := time.Hour
+	for _, j := range s.jobs {
+		if j.status.Running || j.status.Paused || !j.status.NextRun.After(now) {
+			continue
+		}
+		if untilNext := j.status.NextRun.Sub(now); untilNext < wait {
+			wait = untilNext
+		}
+	}
+	return wait
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job) {
+	log.Debugf("Running %s", j.Name)
+	start := time.Now()
+	runCtx, cancel := ctx, context.CancelFunc(func() {})
+	if j.Timeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, j.Timeout)
+	}
+	err := j.Run(runCtx)
+	cancel()
+
+	s.mtx.Lock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastRun = start
+	j.status.LastDuration = time.Since(start)
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+		j.retries++
+	} else {
+		j.status.LastSuccess = start
+		j.status.LastError = ""
+		j.retries = 0
+	}
+	s.plan(j, time.Now())
+	if j.Group != "" {
+		s.busyGroups[j.Group] = false
+	}
+	s.mtx.Unlock()
+
+	if err != nil && ctx.Err() == nil {
+		log.Errorf("The %s job failed, %s", j.Name, err.Error())
+	}
+	if ctx.Err() == nil {
+		metrics.CollectionCycle(j.Module, start, err)
+	}
+	// a failed run is retried after a restart
+	if err == nil && ctx.Err() == nil {
+		if err = s.store.SaveJobRun(ctx, j.Name, start); err != nil {
+			log.Errorf("Cannot save the last run of %s, %s", j.Name, err.Error())
+		}
+	}
+
+	s.signal()
+}
+
+// signal wakes the dispatch loop up.
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// job returns the job named name. The caller must hold the lock.
+func (s *Scheduler) job(name string) (*job, error) {
+	for _, j := range s.jobs {
+		if j.Name == name {
+			return j, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown job, %s", name)
+}
+
+// Trigger runs the job named name as soon as its group is free, whether it is
+// paused or not. The following runs are due an interval after it.
+func (s *Scheduler) Trigger(name string) error {
+	s.mtx.Lock()
+	j, err := s.job(name)
+	if err == nil {
+		j.triggered = true
+	}
+	s.mtx.Unlock()
+	if err == nil {
+		s.signal()
+	}
+	return err
+}
+
+// Pause stops the job named name from running until Resume is called. A run
+// in progress is not interrupted.
+func (s *Scheduler) Pause(name string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	j, err := s.job(name)
+	if err == nil {
+		j.status.Paused = true
+	}
+	return err
+}
+
+// Resume lets the job named name run again. A job that became due while paused
+// runs right away.
+func (s *Scheduler) Resume(name string) error {
+	s.mtx.Lock()
+	j, err := s.job(name)
+	if err == nil {
+		j.status.Paused = false
+	}
+	s.mtx.Unlock()
+	if err == nil {
+		s.signal()
+	}
+	return err
+}
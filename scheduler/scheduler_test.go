@@ -0,0 +1,330 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mapStore struct {
+	mtx      sync.Mutex
+	lastRuns map[string]time.Time
+}
+
+func (m *mapStore) LastJobRuns(ctx context.Context) (map[string]time.Time, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	lastRuns := make(map[string]time.Time)
+	for name, lastRun := range m.lastRuns {
+		lastRuns[name] = lastRun
+	}
+	return lastRuns, nil
+}
+
+func (m *mapStore) SaveJobRun(ctx context.Context, name string, lastRun time.Time) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.lastRuns[name] = lastRun
+	return nil
+}
+
+func TestGroups(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &mapStore{lastRuns: map[string]time.Time{
+		// ran recently, must not run again
+		"recent": time.Now(),
+	}}
+	s := NewScheduler(store)
+
+	var mtx sync.Mutex
+	var order []string
+	running := make(map[string]int)
+	done := make(chan string, 10)
+	addJob := func(name, group string, priority int) {
+		err := s.AddJob(Job{
+			Name:     name,
+			Interval: time.Hour,
+			Group:    group,
+			Priority: priority,
+			Run: func(ctx context.Context) error {
+				mtx.Lock()
+				order = append(order, name)
+				running[group]++
+				if group != "" && running[group] > 1 {
+					t.Errorf("%s ran concurrently with another job of the %s group", name, group)
+				}
+				mtx.Unlock()
+				time.Sleep(20 * time.Millisecond)
+				mtx.Lock()
+				running[group]--
+				mtx.Unlock()
+				done <- name
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	addJob("low", "exchange", 1)
+	addJob("high", "exchange", 3)
+	addJob("medium", "exchange", 2)
+	addJob("free", "", 0)
+	addJob("recent", "", 0)
+	if err := s.AddJob(Job{Name: "free", Interval: time.Hour, Run: func(context.Context) error { return nil }}); err == nil {
+		t.Error("expected an error for a duplicate job name")
+	}
+
+	go s.Start(ctx)
+	for i := 0; i < 4; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the jobs to run")
+		}
+	}
+
+	// the run is recorded after the job returns
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		lastRuns, _ := store.LastJobRuns(ctx)
+		if len(lastRuns) == 5 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the last run of the 5 jobs to be saved, got %v", lastRuns)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mtx.Lock()
+	var grouped []string
+	for _, name := range order {
+		if name != "free" {
+			grouped = append(grouped, name)
+		}
+	}
+	mtx.Unlock()
+	if len(grouped) != 3 || grouped[0] != "high" || grouped[1] != "medium" || grouped[2] != "low" {
+		t.Errorf("expected the grouped jobs to run by priority, got %v", grouped)
+	}
+
+	for _, status := range s.Statuses() {
+		if status.Name == "recent" {
+			if status.Runs != 0 || !status.NextRun.After(time.Now()) {
+				t.Errorf("the recent job must wait for its interval, got %+v", status)
+			}
+			continue
+		}
+		if status.Runs != 1 || status.LastSuccess.IsZero() {
+			t.Errorf("expected %s to have run once, got %+v", status.Name, status)
+		}
+	}
+}
+
+func TestPauseTrigger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewScheduler(&mapStore{lastRuns: map[string]time.Time{}})
+
+	runs := make(chan struct{}, 10)
+	err := s.AddJob(Job{
+		Name:     "job",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			runs <- struct{}{}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Pause("job"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Pause("unknown"); err == nil {
+		t.Error("expected an error for an unknown job")
+	}
+
+	go s.Start(ctx)
+	select {
+	case <-runs:
+		t.Fatal("a paused job must not run")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// a triggered job runs even if paused
+	if err = s.Trigger("job"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the triggered run")
+	}
+	if status := s.Statuses()[0]; !status.Paused {
+		t.Errorf("expected the job to stay paused, got %+v", status)
+	}
+}
+
+func TestStale(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewScheduler(&mapStore{lastRuns: map[string]time.Time{}})
+
+	done := make(chan struct{}, 10)
+	addJob := func(name string, staleAfter time.Duration, err error) {
+		if err := s.AddJob(Job{
+			Name:       name,
+			Interval:   time.Hour,
+			StaleAfter: staleAfter,
+			Run: func(ctx context.Context) error {
+				done <- struct{}{}
+				return err
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addJob("failing", 50*time.Millisecond, fmt.Errorf("no response"))
+	addJob("succeeding", 50*time.Millisecond, nil)
+	addJob("recent", time.Hour, nil)
+	if status := s.Statuses()[0]; status.Stale || status.StaleAfter != 50*time.Millisecond {
+		t.Errorf("a job cannot be stale before the scheduler starts, got %+v", status)
+	}
+
+	go s.Start(ctx)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the jobs to run")
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// a success only keeps a job fresh for StaleAfter
+	for _, status := range s.Statuses() {
+		if status.Stale != (status.Name != "recent") {
+			t.Errorf("unexpected staleness of %s, got %+v", status.Name, status)
+		}
+	}
+	if err := s.Pause("failing"); err != nil {
+		t.Fatal(err)
+	}
+	if status := s.Statuses()[0]; status.Stale {
+		t.Errorf("a paused job cannot be stale, got %+v", status)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		failures int
+		expected time.Duration
+	}{
+		{time.Hour, 1, minRetryDelay},
+		{time.Hour, 2, 2 * minRetryDelay},
+		{time.Hour, 4, 8 * minRetryDelay},
+		{time.Hour, 7, 64 * minRetryDelay},
+		{time.Hour, 8, time.Hour},
+		{time.Hour, 1000, time.Hour},
+		{10 * time.Second, 1, 10 * time.Second},
+	}
+	for _, test := range tests {
+		if delay := retryDelay(test.interval, test.failures); delay != test.expected {
+			t.Errorf("retryDelay(%s, %d): expected %s, got %s", test.interval, test.failures, test.expected, delay)
+		}
+	}
+}
+
+func TestFailedRunRetry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &mapStore{lastRuns: map[string]time.Time{}}
+	s := NewScheduler(store)
+
+	done := make(chan struct{}, 10)
+	if err := s.AddJob(Job{
+		Name:     "failing",
+		Interval: 24 * time.Hour,
+		Run: func(ctx context.Context) error {
+			done <- struct{}{}
+			return fmt.Errorf("no response")
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Start(ctx)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the job to run")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Statuses()[0].Failures == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the failure to be recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the failure is retried shortly and not persisted
+	status := s.Statuses()[0]
+	if untilNext := time.Until(status.NextRun); untilNext > minRetryDelay || untilNext <= 0 {
+		t.Errorf("expected a retry within %s, got %s", minRetryDelay, untilNext)
+	}
+	if lastRuns, _ := store.LastJobRuns(ctx); len(lastRuns) != 0 {
+		t.Errorf("expected the failed run not to be saved, got %v", lastRuns)
+	}
+}
+
+func TestFirstRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ranAt := time.Now().Add(-2 * time.Hour)
+	s := NewScheduler(&mapStore{lastRuns: map[string]time.Time{"deferred": ranAt}})
+
+	run := func(context.Context) error { return nil }
+	jobs := []Job{
+		{Name: "jittered", Interval: time.Hour, Jitter: time.Hour, Run: run},
+		{Name: "deferred", Interval: time.Hour, Deferred: true, Run: run},
+	}
+	for _, job := range jobs {
+		if err := s.AddJob(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	go s.Start(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Statuses()[0].NextRun.IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the scheduler to start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, status := range s.Statuses() {
+		switch status.Name {
+		case "jittered":
+			// the first run is spread over the jitter
+			if status.NextRun.Before(start) || status.NextRun.After(start.Add(time.Hour+time.Second)) {
+				t.Errorf("expected the first run within the jitter, got %s", status.NextRun)
+			}
+		case "deferred":
+			// the first run waits an interval, even if the last one is older
+			if status.Runs != 0 || status.NextRun.Before(start.Add(time.Hour)) {
+				t.Errorf("expected the deferred job to wait an interval, got %+v", status)
+			}
+		}
+	}
+}
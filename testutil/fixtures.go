@@ -0,0 +1,120 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package testutil replays recorded HTTP responses to the data collectors so
+// that their tests do not depend on the live APIs.
+package testutil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// originalHostHeader carries the host a collector requested to the fixture
+// server.
+const originalHostHeader = "X-Fixture-Host"
+
+// Route maps the requests for a host and path to a recorded response in the
+// testdata directory of the package under test. An empty Host or Path matches
+// any value. Status defaults to 200.
+type Route struct {
+	Host    string
+	Path    string
+	Fixture string
+	Status  int
+}
+
+// FixtureServer is an httptest.Server that answers with recorded responses.
+// Requests sent through its Client are redirected to the server whatever the
+// URL they were made for, so collectors keep their production URLs.
+type FixtureServer struct {
+	*httptest.Server
+	t        testing.TB
+	routes   []Route
+	mtx      sync.Mutex
+	requests []*url.URL
+}
+
+// NewFixtureServer starts a FixtureServer for routes. The server is closed
+// when the test ends.
+func NewFixtureServer(t testing.TB, routes ...Route) *FixtureServer {
+	s := &FixtureServer{t: t, routes: routes}
+	for _, route := range routes {
+		if _, err := ioutil.ReadFile(filepath.Join("testdata", route.Fixture)); err != nil {
+			t.Fatalf("fixture %s: %v", route.Fixture, err)
+		}
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	if c, ok := t.(interface{ Cleanup(func()) }); ok {
+		c.Cleanup(s.Close)
+	}
+	return s
+}
+
+func (s *FixtureServer) serve(w http.ResponseWriter, r *http.Request) {
+	requested := *r.URL
+	requested.Scheme = "https"
+	requested.Host = r.Header.Get(originalHostHeader)
+	if requested.Host == "" {
+		requested.Host = r.Host
+	}
+	s.mtx.Lock()
+	s.requests = append(s.requests, &requested)
+	s.mtx.Unlock()
+
+	for _, route := range s.routes {
+		if (route.Host != "" && route.Host != requested.Host) || (route.Path != "" && route.Path != requested.Path) {
+			continue
+		}
+		body, err := ioutil.ReadFile(filepath.Join("testdata", route.Fixture))
+		if err != nil {
+			s.t.Errorf("fixture %s: %v", route.Fixture, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+		return
+	}
+
+	s.t.Errorf("no fixture for %s", requested.String())
+	http.NotFound(w, r)
+}
+
+// Client returns an http.Client that sends every request to the server.
+func (s *FixtureServer) Client() *http.Client {
+	target, _ := url.Parse(s.URL)
+	return &http.Client{Transport: &redirectTransport{target: target}}
+}
+
+// Requests returns the URLs requested so far, with the host the collector
+// asked for.
+func (s *FixtureServer) Requests() []*url.URL {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]*url.URL(nil), s.requests...)
+}
+
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	redirected := req.Clone(req.Context())
+	redirected.Header.Set(originalHostHeader, req.URL.Host)
+	redirected.URL.Scheme = t.target.Scheme
+	redirected.URL.Host = t.target.Host
+	redirected.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(redirected)
+}
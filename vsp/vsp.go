@@ -11,9 +11,7 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/planetdecred/dcrextdata/app"
-	"github.com/planetdecred/dcrextdata/app/helpers"
-	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/scheduler"
 )
 
 const (
@@ -35,6 +33,11 @@ func NewVspCollector(period int64, store DataStore) (*Collector, error) {
 	}, nil
 }
 
+// SetHTTPClient replaces the client used to request the VSP data.
+func (vsp *Collector) SetHTTPClient(client *http.Client) {
+	vsp.client = *client
+}
+
 func (vsp *Collector) fetch(ctx context.Context, response interface{}) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
@@ -53,55 +56,14 @@ func (vsp *Collector) fetch(ctx context.Context, response interface{}) error {
 	return nil
 }
 
-func (vsp *Collector) Run(ctx context.Context, cacheManager *cache.Manager) {
-	if ctx.Err() != nil {
-		return
-	}
-
-	lastCollectionDate := vsp.dataStore.LastVspTickEntryTime()
-	secondsPassed := time.Since(lastCollectionDate)
-	period := vsp.period * time.Second
-
-	log.Info("Starting VSP collection cycle.")
-
-	if secondsPassed < period {
-		timeLeft := period - secondsPassed
-		log.Infof("Fetching VSPs every %dm, collected %s ago, will fetch in %s.", vsp.period/60, helpers.DurationToString(secondsPassed),
-			helpers.DurationToString(timeLeft))
-
-		time.Sleep(timeLeft)
-	}
-
-	// continually check the state of the app until its free to run this module
-	app.MarkBusyIfFree()
-
-	err := vsp.collectAndStore(ctx)
-	app.ReleaseForNewModule()
-	if err != nil {
-		log.Errorf("Could not start collection: %s", err.Error())
-		return
-	}
-
-	go func() {
-		ticker := time.NewTicker(vsp.period * time.Second)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				log.Infof("Shutting down VSP collector")
-				return
-			case <-ticker.C:
-				// continually check the state of the app until its free to run this module
-				app.MarkBusyIfFree()
-				err := vsp.collectAndStore(ctx)
-				app.ReleaseForNewModule()
-				if err != nil {
-					return
-				}
-			}
-		}
-	}()
+// RegisterJobs adds the VSP data collection to s.
+func (vsp *Collector) RegisterJobs(s *scheduler.Scheduler) error {
+	return s.AddJob(scheduler.Job{
+		Name:     "vsp",
+		Interval: vsp.period * time.Second,
+		Timeout:  vsp.period * time.Second,
+		Run:      vsp.collectAndStore,
+	})
 }
 
 func (vsp *Collector) collectAndStore(ctx context.Context) error {
@@ -0,0 +1,104 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package vsp
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/datasync"
+	"github.com/planetdecred/dcrextdata/testutil"
+)
+
+// vspTestStore records the responses passed to StoreVSPs.
+type vspTestStore struct {
+	stored        []Response
+	chartsUpdated int
+}
+
+func (s *vspTestStore) VspTableName() string     { return "vsp" }
+func (s *vspTestStore) VspTickTableName() string { return "vsp_tick" }
+func (s *vspTestStore) StoreVSPs(_ context.Context, resp Response) (int, []error) {
+	s.stored = append(s.stored, resp)
+	return len(resp), nil
+}
+func (s *vspTestStore) LastVspTickEntryTime() time.Time { return time.Time{} }
+func (s *vspTestStore) UpdateVspChart(context.Context) error {
+	s.chartsUpdated++
+	return nil
+}
+func (s *vspTestStore) FetchVspSourcesForSync(context.Context, int64, int, int) ([]VSPDto, int64, error) {
+	return nil, 0, nil
+}
+func (s *vspTestStore) FetchVspTicksForSync(context.Context, int64, int, int) ([]datasync.VSPTickSyncDto, int64, error) {
+	return nil, 0, nil
+}
+
+func TestCollectAndStore(t *testing.T) {
+	server := testutil.NewFixtureServer(t, testutil.Route{Host: "api.decred.org", Path: "/", Fixture: "gsd.json"})
+
+	store := new(vspTestStore)
+	collector, err := NewVspCollector(300, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.SetHTTPClient(server.Client())
+	if err = collector.collectAndStore(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Response{
+		"Alpha": {
+			APIEnabled:           true,
+			APIVersionsSupported: []int64{1, 2},
+			Network:              "mainnet",
+			URL:                  "https://dcr.stakeminer.com",
+			Launched:             1464566400,
+			LastUpdated:          1577836800,
+			Immature:             12,
+			Live:                 1520,
+			Voted:                48231,
+			Missed:               129,
+			PoolFees:             7.5,
+			ProportionLive:       0.0374,
+			ProportionMissed:     0.0027,
+			UserCount:            1240,
+			UserCountActive:      532,
+		},
+		"Bravo": {
+			APIEnabled:           true,
+			APIVersionsSupported: []int64{2},
+			Network:              "mainnet",
+			URL:                  "https://stakepool.dcrstats.com",
+			Launched:             1479859200,
+			LastUpdated:          1577836500,
+			Immature:             3,
+			Live:                 411,
+			Voted:                10562,
+			Missed:               17,
+			PoolFees:             2,
+			ProportionLive:       0.0101,
+			ProportionMissed:     0.0016,
+			UserCount:            318,
+			UserCountActive:      97,
+		},
+	}
+	if len(store.stored) != 1 {
+		t.Fatalf("expected a single stored response, got %d", len(store.stored))
+	}
+	if !reflect.DeepEqual(store.stored[0], expected) {
+		t.Errorf("expected %+v, got %+v", expected, store.stored[0])
+	}
+	if store.chartsUpdated != 1 {
+		t.Errorf("expected the VSP chart to be updated once, got %d", store.chartsUpdated)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].Query().Get("c") != "gsd" {
+		t.Errorf("expected a single gsd request, got %v", requests)
+	}
+}
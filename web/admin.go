@@ -0,0 +1,158 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/planetdecred/dcrextdata/commstats"
+	"github.com/planetdecred/dcrextdata/scheduler"
+)
+
+// JobController gives access to the scheduled collection jobs.
+type JobController interface {
+	Statuses() []scheduler.JobStatus
+	Trigger(name string) error
+	Pause(name string) error
+	Resume(name string) error
+}
+
+// adminJob is a collection job as listed by the admin API.
+type adminJob struct {
+	Name         string  `json:"name"`
+	Group        string  `json:"group,omitempty"`
+	Interval     float64 `json:"interval"`
+	Running      bool    `json:"running"`
+	Paused       bool    `json:"paused"`
+	LastRun      string  `json:"last_run,omitempty"`
+	LastSuccess  string  `json:"last_success,omitempty"`
+	LastDuration float64 `json:"last_duration"`
+	LastError    string  `json:"last_error,omitempty"`
+	NextRun      string  `json:"next_run,omitempty"`
+	Runs         int     `json:"runs"`
+	Failures     int     `json:"failures"`
+	Records      *int64  `json:"records,omitempty"`
+}
+
+func (s *Server) registerAdminHandlers(r *chi.Mux) {
+	if s.jobs == nil || s.adminKey == "" {
+		return
+	}
+	r.Route("/admin/api", func(r chi.Router) {
+		r.Use(s.requireAdminKey)
+		r.Get("/jobs", s.adminJobs)
+		r.With(addJobNameToCtx).Post("/jobs/{name}/trigger", s.adminJobAction(s.jobs.Trigger))
+		r.With(addJobNameToCtx).Post("/jobs/{name}/pause", s.adminJobAction(s.jobs.Pause))
+		r.With(addJobNameToCtx).Post("/jobs/{name}/resume", s.adminJobAction(s.jobs.Resume))
+		r.Get("/keys", s.adminAPIKeys)
+		r.Post("/keys", s.adminCreateAPIKey)
+		r.Delete("/keys/{name}", s.adminDeleteAPIKey)
+	})
+}
+
+// requireAdminKey rejects the requests that do not carry the admin key as a
+// bearer token.
+func (s *Server) requireAdminKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(key), []byte(s.adminKey)) != 1 {
+			s.renderAdminError(http.StatusUnauthorized, "invalid admin key", w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// /admin/api/jobs
+func (s *Server) adminJobs(w http.ResponseWriter, r *http.Request) {
+	statuses := s.jobs.Statuses()
+	jobs := make([]adminJob, len(statuses))
+	for i, status := range statuses {
+		jobs[i] = adminJob{
+			Name:         status.Name,
+			Group:        status.Group,
+			Interval:     status.Interval.Seconds(),
+			Running:      status.Running,
+			Paused:       status.Paused,
+			LastRun:      formatAdminTime(status.LastRun),
+			LastSuccess:  formatAdminTime(status.LastSuccess),
+			LastDuration: status.LastDuration.Seconds(),
+			LastError:    status.LastError,
+			NextRun:      formatAdminTime(status.NextRun),
+			Runs:         status.Runs,
+			Failures:     status.Failures,
+		}
+		if count, found := jobRecordCounters[status.Name]; found {
+			records, err := count(r.Context(), s.db)
+			if err != nil {
+				log.Errorf("Cannot count the records of the %s job, %s", status.Name, err.Error())
+				continue
+			}
+			jobs[i].Records = &records
+		}
+	}
+	s.renderJSON(jobs, w)
+}
+
+// adminJobAction returns a handler that applies action to the job named in the
+// URL.
+func (s *Server) adminJobAction(action func(name string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := action(getJobNameCtx(r)); err != nil {
+			s.renderAdminError(http.StatusNotFound, err.Error(), w)
+			return
+		}
+		s.renderJSON(map[string]interface{}{"success": true}, w)
+	}
+}
+
+func (s *Server) renderAdminError(status int, errorMessage string, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	s.renderErrorJSON(errorMessage, w)
+}
+
+func formatAdminTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// jobRecordCounters counts the records stored by each collection job.
+var jobRecordCounters = map[string]func(ctx context.Context, db DataQuery) (int64, error){
+	"vsp":                     func(ctx context.Context, db DataQuery) (int64, error) { return db.VspTickCount(ctx) },
+	"pow":                     func(ctx context.Context, db DataQuery) (int64, error) { return db.PowCount(ctx) },
+	"exchange-ticks-short":    func(ctx context.Context, db DataQuery) (int64, error) { return db.ExchangeTickCount(ctx) },
+	"exchange-ticks-long":     func(ctx context.Context, db DataQuery) (int64, error) { return db.ExchangeTickCount(ctx) },
+	"exchange-ticks-historic": func(ctx context.Context, db DataQuery) (int64, error) { return db.ExchangeTickCount(ctx) },
+	"mempool":                 func(ctx context.Context, db DataQuery) (int64, error) { return db.MempoolCount(ctx) },
+	"netsnapshot":             func(ctx context.Context, db DataQuery) (int64, error) { return db.SnapshotCount(ctx) },
+	"commstats-reddit": func(ctx context.Context, db DataQuery) (int64, error) {
+		return sumCounts(ctx, commstats.Subreddits(), db.CountRedditStat)
+	},
+	"commstats-twitter": func(ctx context.Context, db DataQuery) (int64, error) {
+		return sumCounts(ctx, commstats.TwitterHandles(), db.CountTwitterStat)
+	},
+	"commstats-github": func(ctx context.Context, db DataQuery) (int64, error) {
+		return sumCounts(ctx, commstats.Repositories(), db.CountGithubStat)
+	},
+	"commstats-youtube": func(ctx context.Context, db DataQuery) (int64, error) {
+		return sumCounts(ctx, commstats.YoutubeChannels(), db.CountYoutubeStat)
+	},
+}
+
+func sumCounts(ctx context.Context, keys []string, count func(context.Context, string) (int64, error)) (int64, error) {
+	var total int64
+	for _, key := range keys {
+		n, err := count(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/planetdecred/dcrextdata/scheduler"
+)
+
+func TestAdminJobActions(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		code          int
+	}{
+		{"missing key", "", http.StatusUnauthorized},
+		{"wrong key", "Bearer guess", http.StatusUnauthorized},
+		{"key prefix", "Bearer secre", http.StatusUnauthorized},
+		{"valid key", "Bearer secret", http.StatusOK},
+	}
+	for _, action := range []string{"trigger", "pause", "resume"} {
+		for _, test := range tests {
+			jobs := &testJobs{statuses: []scheduler.JobStatus{{Name: "test-job"}}}
+			s := &Server{jobs: jobs, adminKey: "secret"}
+			router := chi.NewRouter()
+			s.registerAdminHandlers(router)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/api/jobs/test-job/"+action, nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != test.code {
+				t.Errorf("%s, %s: expected a %d, got %d", action, test.name, test.code, rec.Code)
+			}
+			var expected []string
+			if test.code == http.StatusOK {
+				expected = []string{action + " test-job"}
+			}
+			if !reflect.DeepEqual(jobs.calls, expected) {
+				t.Errorf("%s, %s: expected the calls %v, got %v", action, test.name, expected, jobs.calls)
+			}
+		}
+	}
+}
+
+func TestAdminHandlers(t *testing.T) {
+	jobs := &testJobs{statuses: []scheduler.JobStatus{{Name: "test-job"}}}
+	tests := []struct {
+		name     string
+		adminKey string
+		method   string
+		path     string
+		code     int
+	}{
+		{"job list", "secret", http.MethodGet, "/admin/api/jobs", http.StatusOK},
+		{"unknown job", "secret", http.MethodPost, "/admin/api/jobs/unknown/trigger", http.StatusNotFound},
+		{"admin API disabled", "", http.MethodPost, "/admin/api/jobs/test-job/trigger", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		s := &Server{jobs: jobs, adminKey: test.adminKey}
+		router := chi.NewRouter()
+		s.registerAdminHandlers(router)
+
+		req := httptest.NewRequest(test.method, test.path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != test.code {
+			t.Errorf("%s: expected a %d, got %d", test.name, test.code, rec.Code)
+		}
+	}
+	if len(jobs.calls) != 0 {
+		t.Errorf("expected no job call, got %v", jobs.calls)
+	}
+}
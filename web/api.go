@@ -0,0 +1,755 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/planetdecred/dcrextdata/commstats"
+	"github.com/planetdecred/dcrextdata/datasync"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/export"
+	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/planetdecred/dcrextdata/netsnapshot"
+	"github.com/planetdecred/dcrextdata/pow"
+)
+
+const (
+	apiDefaultLimit = 100
+	apiMaxLimit     = 1000
+)
+
+// APIPage selects a page of the records of a v1 API resource. The records are
+// ordered by time, then by a key that is unique among the records of the same
+// time.
+type APIPage struct {
+	// Start and End bound the time of the records, End excluded. The zero
+	// time leaves the range open.
+	Start, End time.Time
+	// After is the position of the last record of the previous page, nil for
+	// the first page.
+	After *APICursor
+	Limit int
+}
+
+// APICursor is the position of a record in the order of a v1 API resource.
+// Key is empty for the resources whose records have distinct times.
+type APICursor struct {
+	Time time.Time `json:"t"`
+	Key  string    `json:"k,omitempty"`
+}
+
+// apiCursorKey is the type of the cursor keys of a v1 API resource. The key of
+// a cursor is checked against it before the cursor is given to the store.
+type apiCursorKey int
+
+const (
+	// apiNoKey is used by the resources whose records have distinct times.
+	apiNoKey apiCursorKey = iota
+	// apiIntKey is used by the resources keyed by an integer column.
+	apiIntKey
+	// apiStringKey is used by the resources keyed by a text column.
+	apiStringKey
+)
+
+func (kind apiCursorKey) valid(key string) bool {
+	switch kind {
+	case apiNoKey:
+		return key == ""
+	case apiIntKey:
+		// the integer keys are 32 bit columns
+		_, err := strconv.ParseInt(key, 10, 32)
+		return err == nil
+	}
+	return true
+}
+
+// APITickFilter selects the exchange ticks of the v1 API. Empty fields match
+// every tick.
+type APITickFilter struct {
+	Exchange     string
+	CurrencyPair string
+	Interval     int
+}
+
+// APIQuery is implemented by the stores that back the v1 API. Each method
+// returns the records of page, ordered by time then by key.
+type APIQuery interface {
+	APIExchangeTicks(ctx context.Context, filter APITickFilter, page APIPage) ([]ticks.TickSyncDto, error)
+	APIVSPTicks(ctx context.Context, vspName string, page APIPage) ([]datasync.VSPTickSyncDto, error)
+	APIPowData(ctx context.Context, source string, page APIPage) ([]pow.PowData, error)
+	APIMempool(ctx context.Context, page APIPage) ([]mempool.Mempool, error)
+	APIBlocks(ctx context.Context, page APIPage) ([]mempool.Block, error)
+	APIVotes(ctx context.Context, page APIPage) ([]mempool.Vote, error)
+	APIRedditStats(ctx context.Context, subreddit string, page APIPage) ([]commstats.Reddit, error)
+	APITwitterStats(ctx context.Context, handle string, page APIPage) ([]commstats.Twitter, error)
+	APIGithubStats(ctx context.Context, repository string, page APIPage) ([]commstats.Github, error)
+	APIYoutubeStats(ctx context.Context, channel string, page APIPage) ([]commstats.Youtube, error)
+	APISnapshots(ctx context.Context, page APIPage) ([]netsnapshot.SnapShot, error)
+}
+
+// apiError is the error envelope of the v1 API.
+type apiError struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiList is the envelope of the lists returned by the v1 API. NextCursor is
+// set when more records may follow.
+type apiList struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+func (s *Server) registerAPIHandlers(r *chi.Mux) {
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(s.requireAPIKey)
+		r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+			s.renderAPIError(w, http.StatusNotFound, "not_found", "unknown resource")
+		})
+		r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+			s.renderAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		})
+		for _, endpoint := range s.apiEndpoints() {
+			r.Get(endpoint.path, endpoint.handler)
+		}
+		r.Get("/openapi.json", s.openAPIDocument)
+	})
+}
+
+func (s *Server) renderAPIError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	s.renderJSON(apiError{Error: apiErrorDetail{Status: status, Code: code, Message: message}}, w)
+}
+
+// renderAPIList writes the records of a page. The cursor of the last record,
+// given by position, is returned for full pages only.
+func (s *Server) renderAPIList(w http.ResponseWriter, data interface{}, count int, page APIPage, position func(last int) APICursor) {
+	list := apiList{Data: data}
+	if count > 0 && count == page.Limit {
+		list.NextCursor = encodeAPICursor(position(count - 1))
+	}
+	s.renderJSON(list, w)
+}
+
+// apiPageRequest reads the time range, the cursor and the limit of a list
+// request. The key of the cursor must be of the type used by the resource. An
+// error is rendered if they are invalid.
+func (s *Server) apiPageRequest(w http.ResponseWriter, r *http.Request, cursorKey apiCursorKey) (page APIPage, ok bool) {
+	query := r.URL.Query()
+	var err error
+	if page.Start, err = export.ParseTime(query.Get("start")); err != nil {
+		s.renderAPIError(w, http.StatusBadRequest, "invalid_parameter", fmt.Sprintf("invalid start, %s", err.Error()))
+		return page, false
+	}
+	if page.End, err = export.ParseTime(query.Get("end")); err != nil {
+		s.renderAPIError(w, http.StatusBadRequest, "invalid_parameter", fmt.Sprintf("invalid end, %s", err.Error()))
+		return page, false
+	}
+	if !page.Start.IsZero() && !page.End.IsZero() && !page.End.After(page.Start) {
+		s.renderAPIError(w, http.StatusBadRequest, "invalid_parameter", "end must be after start")
+		return page, false
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		if page.After, err = decodeAPICursor(cursor); err != nil || !cursorKey.valid(page.After.Key) {
+			s.renderAPIError(w, http.StatusBadRequest, "invalid_parameter", "invalid cursor")
+			return page, false
+		}
+	}
+
+	page.Limit = apiDefaultLimit
+	if limit := query.Get("limit"); limit != "" {
+		page.Limit, err = strconv.Atoi(limit)
+		if err != nil || page.Limit < 1 || page.Limit > apiMaxLimit {
+			s.renderAPIError(w, http.StatusBadRequest, "invalid_parameter",
+				fmt.Sprintf("limit must be between 1 and %d", apiMaxLimit))
+			return page, false
+		}
+	}
+	return page, true
+}
+
+func encodeAPICursor(cursor APICursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeAPICursor(value string) (*APICursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor APICursor
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+func (s *Server) renderAPIStoreError(w http.ResponseWriter, resource string, err error) {
+	log.Errorf("Error in fetching the %s of the v1 API, %s", resource, err.Error())
+	s.renderAPIError(w, http.StatusInternalServerError, "internal_error", fmt.Sprintf("cannot fetch the %s", resource))
+}
+
+// The records of the v1 API.
+
+type apiExchange struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type apiTick struct {
+	ID           int       `json:"id"`
+	Exchange     string    `json:"exchange"`
+	CurrencyPair string    `json:"currency_pair"`
+	Interval     int       `json:"interval"`
+	Time         time.Time `json:"time"`
+	Open         float64   `json:"open"`
+	High         float64   `json:"high"`
+	Low          float64   `json:"low"`
+	Close        float64   `json:"close"`
+	Volume       float64   `json:"volume"`
+}
+
+func newAPITick(tick ticks.TickSyncDto) apiTick {
+	return apiTick{
+		ID:           tick.ID,
+		Exchange:     tick.ExchangeName,
+		CurrencyPair: tick.CurrencyPair,
+		Interval:     tick.Interval,
+		Time:         tick.Time.UTC(),
+		Open:         tick.Open,
+		High:         tick.High,
+		Low:          tick.Low,
+		Close:        tick.Close,
+		Volume:       tick.Volume,
+	}
+}
+
+type apiVSP struct {
+	ID                   int       `json:"id"`
+	Name                 string    `json:"name"`
+	URL                  string    `json:"url"`
+	Network              string    `json:"network"`
+	APIEnabled           bool      `json:"api_enabled"`
+	APIVersionsSupported []int64   `json:"api_versions_supported"`
+	Launched             time.Time `json:"launched"`
+}
+
+type apiVSPTick struct {
+	ID               int       `json:"id"`
+	VSP              string    `json:"vsp"`
+	Time             time.Time `json:"time"`
+	Immature         int       `json:"immature"`
+	Live             int       `json:"live"`
+	Voted            int       `json:"voted"`
+	Missed           int       `json:"missed"`
+	PoolFees         float64   `json:"pool_fees"`
+	ProportionLive   float64   `json:"proportion_live"`
+	ProportionMissed float64   `json:"proportion_missed"`
+	UserCount        int       `json:"user_count"`
+	UsersActive      int       `json:"users_active"`
+}
+
+type apiPow struct {
+	Source       string    `json:"source"`
+	Time         time.Time `json:"time"`
+	PoolHashrate float64   `json:"pool_hashrate"`
+	Workers      int64     `json:"workers"`
+	CoinPrice    float64   `json:"coin_price"`
+	BtcPrice     float64   `json:"btc_price"`
+}
+
+type apiMempool struct {
+	Time                 time.Time `json:"time"`
+	FirstSeenTime        time.Time `json:"first_seen_time"`
+	NumberOfTransactions int       `json:"number_of_transactions"`
+	Voters               int       `json:"voters"`
+	Tickets              int       `json:"tickets"`
+	Revocations          int       `json:"revocations"`
+	Size                 int32     `json:"size"`
+	TotalFee             float64   `json:"total_fee"`
+	Total                float64   `json:"total"`
+}
+
+func newAPIMempool(m mempool.Mempool) apiMempool {
+	return apiMempool{
+		Time:                 m.Time.UTC(),
+		FirstSeenTime:        m.FirstSeenTime.UTC(),
+		NumberOfTransactions: m.NumberOfTransactions,
+		Voters:               m.Voters,
+		Tickets:              m.Tickets,
+		Revocations:          m.Revocations,
+		Size:                 m.Size,
+		TotalFee:             m.TotalFee,
+		Total:                m.Total,
+	}
+}
+
+type apiBlock struct {
+	Height       uint32    `json:"height"`
+	Hash         string    `json:"hash"`
+	ReceiveTime  time.Time `json:"receive_time"`
+	InternalTime time.Time `json:"internal_time"`
+}
+
+func newAPIBlock(block mempool.Block) apiBlock {
+	return apiBlock{
+		Height:       block.BlockHeight,
+		Hash:         block.BlockHash,
+		ReceiveTime:  block.BlockReceiveTime.UTC(),
+		InternalTime: block.BlockInternalTime.UTC(),
+	}
+}
+
+type apiVote struct {
+	Hash              string    `json:"hash"`
+	ReceiveTime       time.Time `json:"receive_time"`
+	VotingOn          int64     `json:"voting_on"`
+	BlockHash         string    `json:"block_hash"`
+	TargetedBlockTime time.Time `json:"targeted_block_time"`
+	BlockReceiveTime  time.Time `json:"block_receive_time"`
+	ValidatorID       int       `json:"validator_id"`
+	Validity          string    `json:"validity"`
+}
+
+func newAPIVote(vote mempool.Vote) apiVote {
+	return apiVote{
+		Hash:              vote.Hash,
+		ReceiveTime:       vote.ReceiveTime.UTC(),
+		VotingOn:          vote.VotingOn,
+		BlockHash:         vote.BlockHash,
+		TargetedBlockTime: vote.TargetedBlockTime.UTC(),
+		BlockReceiveTime:  vote.BlockReceiveTime.UTC(),
+		ValidatorID:       vote.ValidatorId,
+		Validity:          vote.Validity,
+	}
+}
+
+type apiCommunityStat struct {
+	Platform       string    `json:"platform"`
+	Account        string    `json:"account"`
+	Time           time.Time `json:"time"`
+	Subscribers    *int      `json:"subscribers,omitempty"`
+	ActiveAccounts *int      `json:"active_accounts,omitempty"`
+	Followers      *int      `json:"followers,omitempty"`
+	Stars          *int      `json:"stars,omitempty"`
+	Forks          *int      `json:"forks,omitempty"`
+	ViewCount      *int      `json:"view_count,omitempty"`
+}
+
+type apiSnapshot struct {
+	Time                time.Time `json:"time"`
+	Height              int64     `json:"height"`
+	NodeCount           int       `json:"node_count"`
+	ReachableNodeCount  int       `json:"reachable_node_count"`
+	OldestNode          string    `json:"oldest_node"`
+	OldestNodeTimestamp time.Time `json:"oldest_node_time"`
+	Latency             int       `json:"latency"`
+}
+
+// apiParam is a query parameter of an endpoint of the v1 API.
+type apiParam struct {
+	name        string
+	in          string
+	description string
+	schemaType  string
+	enum        []string
+	required    bool
+}
+
+// apiEndpoint is a GET endpoint of the v1 API. The OpenAPI document is
+// generated from the endpoints.
+type apiEndpoint struct {
+	path    string
+	summary string
+	params  []apiParam
+	// record is a value of the type of the returned records
+	record interface{}
+	// paginated endpoints accept the time range, cursor and limit
+	// parameters
+	paginated bool
+	handler   http.HandlerFunc
+}
+
+var communityPlatforms = []string{"reddit", "twitter", "github", "youtube"}
+
+func (s *Server) apiEndpoints() []apiEndpoint {
+	return []apiEndpoint{
+		{
+			path:    "/exchanges",
+			summary: "List the exchanges whose ticks are collected",
+			record:  apiExchange{},
+			handler: s.apiExchanges,
+		},
+		{
+			path:    "/ticks",
+			summary: "List the exchange ticks",
+			params: []apiParam{
+				{name: "exchange", in: "query", schemaType: "string", description: "Name of the exchange"},
+				{name: "pair", in: "query", schemaType: "string", description: "Currency pair, e.g. BTC/DCR"},
+				{name: "interval", in: "query", schemaType: "integer", description: "Tick interval in minutes"},
+			},
+			record:    apiTick{},
+			paginated: true,
+			handler:   s.apiTicks,
+		},
+		{
+			path:    "/vsps",
+			summary: "List the voting service providers",
+			record:  apiVSP{},
+			handler: s.apiVSPs,
+		},
+		{
+			path:    "/vsp-ticks",
+			summary: "List the voting service provider ticks",
+			params: []apiParam{
+				{name: "vsp", in: "query", schemaType: "string", description: "Name of the voting service provider"},
+			},
+			record:    apiVSPTick{},
+			paginated: true,
+			handler:   s.apiVSPTicks,
+		},
+		{
+			path:    "/pow",
+			summary: "List the mining pool data",
+			params: []apiParam{
+				{name: "source", in: "query", schemaType: "string", description: "Name of the mining pool"},
+			},
+			record:    apiPow{},
+			paginated: true,
+			handler:   s.apiPow,
+		},
+		{
+			path:      "/mempool",
+			summary:   "List the mempool samples",
+			record:    apiMempool{},
+			paginated: true,
+			handler:   s.apiMempool,
+		},
+		{
+			path:      "/blocks",
+			summary:   "List the blocks, by receive time",
+			record:    apiBlock{},
+			paginated: true,
+			handler:   s.apiBlocks,
+		},
+		{
+			path:      "/votes",
+			summary:   "List the votes, by receive time",
+			record:    apiVote{},
+			paginated: true,
+			handler:   s.apiVotes,
+		},
+		{
+			path:    "/community/{platform}",
+			summary: "List the community statistics of a platform",
+			params: []apiParam{
+				{name: "platform", in: "path", schemaType: "string", enum: communityPlatforms, required: true},
+				{name: "account", in: "query", schemaType: "string", required: true,
+					description: "Subreddit, Twitter handle, GitHub repository or YouTube channel"},
+			},
+			record:    apiCommunityStat{},
+			paginated: true,
+			handler:   s.apiCommunityStats,
+		},
+		{
+			path:      "/snapshots",
+			summary:   "List the network snapshots",
+			record:    apiSnapshot{},
+			paginated: true,
+			handler:   s.apiSnapshots,
+		},
+	}
+}
+
+// /api/v1/exchanges
+func (s *Server) apiExchanges(w http.ResponseWriter, r *http.Request) {
+	exchanges, err := s.db.AllExchange(r.Context())
+	if err != nil {
+		s.renderAPIStoreError(w, "exchanges", err)
+		return
+	}
+	records := make([]apiExchange, len(exchanges))
+	for i, exchange := range exchanges {
+		records[i] = apiExchange{ID: exchange.ID, Name: exchange.Name, URL: exchange.URL}
+	}
+	s.renderJSON(apiList{Data: records}, w)
+}
+
+// /api/v1/ticks
+func (s *Server) apiTicks(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.apiPageRequest(w, r, apiIntKey)
+	if !ok {
+		return
+	}
+	filter := APITickFilter{
+		Exchange:     r.URL.Query().Get("exchange"),
+		CurrencyPair: r.URL.Query().Get("pair"),
+	}
+	if interval := r.URL.Query().Get("interval"); interval != "" {
+		var err error
+		if filter.Interval, err = strconv.Atoi(interval); err != nil || filter.Interval <= 0 {
+			s.renderAPIError(w, http.StatusBadRequest, "invalid_parameter", "invalid interval")
+			return
+		}
+	}
+
+	tickSlice, err := s.db.APIExchangeTicks(r.Context(), filter, page)
+	if err != nil {
+		s.renderAPIStoreError(w, "exchange ticks", err)
+		return
+	}
+	records := make([]apiTick, len(tickSlice))
+	for i, tick := range tickSlice {
+		records[i] = newAPITick(tick)
+	}
+	s.renderAPIList(w, records, len(records), page, func(last int) APICursor {
+		return APICursor{Time: records[last].Time, Key: strconv.Itoa(records[last].ID)}
+	})
+}
+
+// /api/v1/vsps
+func (s *Server) apiVSPs(w http.ResponseWriter, r *http.Request) {
+	vsps, err := s.db.FetchVSPs(r.Context())
+	if err != nil {
+		s.renderAPIStoreError(w, "VSPs", err)
+		return
+	}
+	records := make([]apiVSP, len(vsps))
+	for i, vsp := range vsps {
+		records[i] = apiVSP{
+			ID:                   vsp.ID,
+			Name:                 vsp.Name,
+			URL:                  vsp.URL,
+			Network:              vsp.Network,
+			APIEnabled:           vsp.APIEnabled,
+			APIVersionsSupported: vsp.APIVersionsSupported,
+			Launched:             vsp.Launched.UTC(),
+		}
+	}
+	s.renderJSON(apiList{Data: records}, w)
+}
+
+// /api/v1/vsp-ticks
+func (s *Server) apiVSPTicks(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.apiPageRequest(w, r, apiIntKey)
+	if !ok {
+		return
+	}
+	tickSlice, err := s.db.APIVSPTicks(r.Context(), r.URL.Query().Get("vsp"), page)
+	if err != nil {
+		s.renderAPIStoreError(w, "VSP ticks", err)
+		return
+	}
+	records := make([]apiVSPTick, len(tickSlice))
+	for i, tick := range tickSlice {
+		records[i] = apiVSPTick{
+			ID:               tick.ID,
+			VSP:              tick.VSP,
+			Time:             tick.Time.UTC(),
+			Immature:         tick.Immature,
+			Live:             tick.Live,
+			Voted:            tick.Voted,
+			Missed:           tick.Missed,
+			PoolFees:         tick.PoolFees,
+			ProportionLive:   tick.ProportionLive,
+			ProportionMissed: tick.ProportionMissed,
+			UserCount:        tick.UserCount,
+			UsersActive:      tick.UsersActive,
+		}
+	}
+	s.renderAPIList(w, records, len(records), page, func(last int) APICursor {
+		return APICursor{Time: records[last].Time, Key: strconv.Itoa(records[last].ID)}
+	})
+}
+
+// /api/v1/pow
+func (s *Server) apiPow(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.apiPageRequest(w, r, apiStringKey)
+	if !ok {
+		return
+	}
+	powSlice, err := s.db.APIPowData(r.Context(), r.URL.Query().Get("source"), page)
+	if err != nil {
+		s.renderAPIStoreError(w, "PoW data", err)
+		return
+	}
+	records := make([]apiPow, len(powSlice))
+	for i, data := range powSlice {
+		records[i] = apiPow{
+			Source:       data.Source,
+			Time:         time.Unix(data.Time, 0).UTC(),
+			PoolHashrate: data.PoolHashrate,
+			Workers:      data.Workers,
+			CoinPrice:    data.CoinPrice,
+			BtcPrice:     data.BtcPrice,
+		}
+	}
+	s.renderAPIList(w, records, len(records), page, func(last int) APICursor {
+		return APICursor{Time: records[last].Time, Key: records[last].Source}
+	})
+}
+
+// /api/v1/mempool
+func (s *Server) apiMempool(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.apiPageRequest(w, r, apiNoKey)
+	if !ok {
+		return
+	}
+	mempools, err := s.db.APIMempool(r.Context(), page)
+	if err != nil {
+		s.renderAPIStoreError(w, "mempool samples", err)
+		return
+	}
+	records := make([]apiMempool, len(mempools))
+	for i, m := range mempools {
+		records[i] = newAPIMempool(m)
+	}
+	s.renderAPIList(w, records, len(records), page, func(last int) APICursor {
+		return APICursor{Time: records[last].Time}
+	})
+}
+
+// /api/v1/blocks
+func (s *Server) apiBlocks(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.apiPageRequest(w, r, apiIntKey)
+	if !ok {
+		return
+	}
+	blocks, err := s.db.APIBlocks(r.Context(), page)
+	if err != nil {
+		s.renderAPIStoreError(w, "blocks", err)
+		return
+	}
+	records := make([]apiBlock, len(blocks))
+	for i, block := range blocks {
+		records[i] = newAPIBlock(block)
+	}
+	s.renderAPIList(w, records, len(records), page, func(last int) APICursor {
+		return APICursor{Time: records[last].ReceiveTime, Key: strconv.FormatUint(uint64(records[last].Height), 10)}
+	})
+}
+
+// /api/v1/votes
+func (s *Server) apiVotes(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.apiPageRequest(w, r, apiStringKey)
+	if !ok {
+		return
+	}
+	votes, err := s.db.APIVotes(r.Context(), page)
+	if err != nil {
+		s.renderAPIStoreError(w, "votes", err)
+		return
+	}
+	records := make([]apiVote, len(votes))
+	for i, vote := range votes {
+		records[i] = newAPIVote(vote)
+	}
+	s.renderAPIList(w, records, len(records), page, func(last int) APICursor {
+		return APICursor{Time: records[last].ReceiveTime, Key: records[last].Hash}
+	})
+}
+
+// /api/v1/community/{platform}
+func (s *Server) apiCommunityStats(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.apiPageRequest(w, r, apiNoKey)
+	if !ok {
+		return
+	}
+	platform := chi.URLParam(r, "platform")
+	account := r.URL.Query().Get("account")
+	if account == "" {
+		s.renderAPIError(w, http.StatusBadRequest, "invalid_parameter", "account is required")
+		return
+	}
+
+	ctx := r.Context()
+	var records []apiCommunityStat
+	var err error
+	switch platform {
+	case "reddit":
+		var stats []commstats.Reddit
+		stats, err = s.db.APIRedditStats(ctx, account, page)
+		for i := range stats {
+			records = append(records, apiCommunityStat{Platform: platform, Account: stats[i].Subreddit, Time: stats[i].Date.UTC(),
+				Subscribers: &stats[i].Subscribers, ActiveAccounts: &stats[i].AccountsActive})
+		}
+	case "twitter":
+		var stats []commstats.Twitter
+		stats, err = s.db.APITwitterStats(ctx, account, page)
+		for i := range stats {
+			records = append(records, apiCommunityStat{Platform: platform, Account: stats[i].Handle, Time: stats[i].Date.UTC(),
+				Followers: &stats[i].Followers})
+		}
+	case "github":
+		var stats []commstats.Github
+		stats, err = s.db.APIGithubStats(ctx, account, page)
+		for i := range stats {
+			records = append(records, apiCommunityStat{Platform: platform, Account: stats[i].Repository, Time: stats[i].Date.UTC(),
+				Stars: &stats[i].Stars, Forks: &stats[i].Folks})
+		}
+	case "youtube":
+		var stats []commstats.Youtube
+		stats, err = s.db.APIYoutubeStats(ctx, account, page)
+		for i := range stats {
+			records = append(records, apiCommunityStat{Platform: platform, Account: stats[i].Channel, Time: stats[i].Date.UTC(),
+				Subscribers: &stats[i].Subscribers, ViewCount: &stats[i].ViewCount})
+		}
+	default:
+		s.renderAPIError(w, http.StatusNotFound, "not_found",
+			fmt.Sprintf("unknown platform, expected one of %s", strings.Join(communityPlatforms, ", ")))
+		return
+	}
+	if err != nil {
+		s.renderAPIStoreError(w, platform+" statistics", err)
+		return
+	}
+	if records == nil {
+		records = []apiCommunityStat{}
+	}
+	s.renderAPIList(w, records, len(records), page, func(last int) APICursor {
+		return APICursor{Time: records[last].Time}
+	})
+}
+
+// /api/v1/snapshots
+func (s *Server) apiSnapshots(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.apiPageRequest(w, r, apiNoKey)
+	if !ok {
+		return
+	}
+	snapshots, err := s.db.APISnapshots(r.Context(), page)
+	if err != nil {
+		s.renderAPIStoreError(w, "network snapshots", err)
+		return
+	}
+	records := make([]apiSnapshot, len(snapshots))
+	for i, snapshot := range snapshots {
+		records[i] = apiSnapshot{
+			Time:                time.Unix(snapshot.Timestamp, 0).UTC(),
+			Height:              snapshot.Height,
+			NodeCount:           snapshot.NodeCount,
+			ReachableNodeCount:  snapshot.ReachableNodeCount,
+			OldestNode:          snapshot.OldestNode,
+			OldestNodeTimestamp: time.Unix(snapshot.OldestNodeTimestamp, 0).UTC(),
+			Latency:             snapshot.Latency,
+		}
+	}
+	s.renderAPIList(w, records, len(records), page, func(last int) APICursor {
+		return APICursor{Time: records[last].Time}
+	})
+}
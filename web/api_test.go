@@ -0,0 +1,240 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/mempool"
+)
+
+// apiTestStore serves ticks and mempool samples from memory, one a minute
+// from start, and records the pages requested.
+type apiTestStore struct {
+	DataQuery
+	start    time.Time
+	count    int
+	err      error
+	requests []APIPage
+}
+
+func (s *apiTestStore) APIExchangeTicks(_ context.Context, _ APITickFilter, page APIPage) ([]ticks.TickSyncDto, error) {
+	s.requests = append(s.requests, page)
+	if s.err != nil {
+		return nil, s.err
+	}
+	result := []ticks.TickSyncDto{}
+	for id := 1; id <= s.count && len(result) < page.Limit; id++ {
+		tick := ticks.TickSyncDto{ID: id, ExchangeName: "binance", Time: s.start.Add(time.Duration(id) * time.Minute)}
+		if page.After != nil {
+			after, _ := strconv.Atoi(page.After.Key)
+			if tick.Time.Before(page.After.Time) || tick.Time.Equal(page.After.Time) && id <= after {
+				continue
+			}
+		}
+		result = append(result, tick)
+	}
+	return result, nil
+}
+
+func (s *apiTestStore) APIMempool(_ context.Context, page APIPage) ([]mempool.Mempool, error) {
+	s.requests = append(s.requests, page)
+	return []mempool.Mempool{}, s.err
+}
+
+// apiTestResponse is a list or an error response of the v1 API.
+type apiTestResponse struct {
+	Data       []apiTick      `json:"data"`
+	NextCursor string         `json:"next_cursor"`
+	Error      apiErrorDetail `json:"error"`
+}
+
+func apiTestRequest(t *testing.T, router http.Handler, target string) (int, apiTestResponse) {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	var resp apiTestResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s: %v, %s", target, err, rec.Body.String())
+	}
+	return rec.Code, resp
+}
+
+func TestAPICursorPaging(t *testing.T) {
+	store := &apiTestStore{start: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), count: 5}
+	s := &Server{db: store}
+	router := chi.NewRouter()
+	s.registerAPIHandlers(router)
+
+	var ids []int
+	target := "/api/v1/ticks?limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("the paging does not end, got the ticks %v", ids)
+		}
+		code, resp := apiTestRequest(t, router, target)
+		if code != http.StatusOK {
+			t.Fatalf("%s: expected a 200, got %d, %+v", target, code, resp.Error)
+		}
+		for _, tick := range resp.Data {
+			ids = append(ids, tick.ID)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		target = "/api/v1/ticks?limit=2&cursor=" + resp.NextCursor
+	}
+	if len(ids) != 5 {
+		t.Fatalf("expected the 5 ticks, got %v", ids)
+	}
+	for i, id := range ids {
+		if id != i+1 {
+			t.Fatalf("expected the ticks in order, got %v", ids)
+		}
+	}
+
+	// a short page ends the paging
+	if len(store.requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(store.requests))
+	}
+	if store.requests[0].After != nil {
+		t.Errorf("expected no cursor for the first page, got %+v", store.requests[0].After)
+	}
+	if after := store.requests[2].After; after == nil || after.Key != "4" || !after.Time.Equal(store.start.Add(4*time.Minute)) {
+		t.Errorf("expected the last page after the tick 4, got %+v", after)
+	}
+	for _, page := range store.requests {
+		if page.Limit != 2 {
+			t.Errorf("expected a limit of 2, got %d", page.Limit)
+		}
+	}
+
+	store.requests = nil
+	if code, _ := apiTestRequest(t, router, "/api/v1/ticks"); code != http.StatusOK {
+		t.Fatalf("expected a 200, got %d", code)
+	}
+	if store.requests[0].Limit != apiDefaultLimit {
+		t.Errorf("expected the default limit, got %d", store.requests[0].Limit)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	store := &apiTestStore{start: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := &Server{db: store}
+	router := chi.NewRouter()
+	s.registerAPIHandlers(router)
+
+	cursor := func(key string) string {
+		return encodeAPICursor(APICursor{Time: store.start, Key: key})
+	}
+	tests := []struct {
+		name   string
+		target string
+		code   int
+		err    string
+	}{
+		{"zero limit", "/api/v1/ticks?limit=0", http.StatusBadRequest, "invalid_parameter"},
+		{"limit over the maximum", "/api/v1/ticks?limit=1001", http.StatusBadRequest, "invalid_parameter"},
+		{"limit not a number", "/api/v1/ticks?limit=ten", http.StatusBadRequest, "invalid_parameter"},
+		{"unreadable cursor", "/api/v1/ticks?cursor=%21%21", http.StatusBadRequest, "invalid_parameter"},
+		{"text key for an integer column", "/api/v1/ticks?cursor=" + cursor("1 OR 1=1"), http.StatusBadRequest,
+			"invalid_parameter"},
+		{"integer key overflow", "/api/v1/ticks?cursor=" + cursor("4294967296"), http.StatusBadRequest,
+			"invalid_parameter"},
+		{"integer key", "/api/v1/ticks?cursor=" + cursor("12"), http.StatusOK, ""},
+		{"missing integer key", "/api/v1/ticks?cursor=" + cursor(""), http.StatusBadRequest, "invalid_parameter"},
+		{"key for a resource without key", "/api/v1/mempool?cursor=" + cursor("12"), http.StatusBadRequest,
+			"invalid_parameter"},
+		{"cursor without key", "/api/v1/mempool?cursor=" + cursor(""), http.StatusOK, ""},
+		{"end before start", "/api/v1/mempool?start=2020-01-02&end=2020-01-01", http.StatusBadRequest,
+			"invalid_parameter"},
+		{"invalid interval", "/api/v1/ticks?interval=-5", http.StatusBadRequest, "invalid_parameter"},
+		{"unknown resource", "/api/v1/unknown", http.StatusNotFound, "not_found"},
+		{"unknown platform", "/api/v1/community/myspace?account=decred", http.StatusNotFound, "not_found"},
+	}
+	for _, test := range tests {
+		code, resp := apiTestRequest(t, router, test.target)
+		if code != test.code {
+			t.Errorf("%s: expected a %d, got %d", test.name, test.code, code)
+		}
+		if resp.Error.Code != test.err {
+			t.Errorf("%s: expected the %q error, got %q", test.name, test.err, resp.Error.Code)
+		}
+		if test.err != "" && (resp.Error.Status != code || resp.Error.Message == "") {
+			t.Errorf("%s: incomplete error envelope %+v", test.name, resp.Error)
+		}
+	}
+
+	store.err = errors.New("connection refused")
+	code, resp := apiTestRequest(t, router, "/api/v1/ticks")
+	if code != http.StatusInternalServerError || resp.Error.Code != "internal_error" {
+		t.Errorf("expected an internal error, got %d, %+v", code, resp.Error)
+	}
+	if resp.Error.Message != "cannot fetch the exchange ticks" {
+		t.Errorf("the store error must not be returned, got %q", resp.Error.Message)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	s := &Server{db: &apiTestStore{}}
+	router := chi.NewRouter()
+	s.registerAPIHandlers(router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a 200, got %d", rec.Code)
+	}
+	var document struct {
+		Paths map[string]struct {
+			Get struct {
+				Parameters []struct {
+					Name string `json:"name"`
+				} `json:"parameters"`
+				Responses map[string]struct {
+					Content map[string]struct {
+						Schema struct {
+							Properties map[string]struct {
+								Items struct {
+									Properties map[string]map[string]interface{} `json:"properties"`
+								} `json:"items"`
+							} `json:"properties"`
+						} `json:"schema"`
+					} `json:"content"`
+				} `json:"responses"`
+			} `json:"get"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	for _, endpoint := range s.apiEndpoints() {
+		path, found := document.Paths["/api/v1"+endpoint.path]
+		if !found {
+			t.Errorf("%s is not documented", endpoint.path)
+			continue
+		}
+		params := make(map[string]bool)
+		for _, param := range path.Get.Parameters {
+			params[param.Name] = true
+		}
+		if endpoint.paginated != (params["cursor"] && params["limit"]) {
+			t.Errorf("%s: expected the page parameters to be documented for the paginated endpoints only", endpoint.path)
+		}
+	}
+	tick := document.Paths["/api/v1/ticks"].Get.Responses["200"].Content["application/json"].Schema.
+		Properties["data"].Items.Properties
+	if tick["id"]["type"] != "integer" || tick["time"]["format"] != "date-time" {
+		t.Errorf("unexpected tick schema %v", tick)
+	}
+}
@@ -0,0 +1,213 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// apiKeyCacheTTL is how long the result of an API key lookup is reused, so
+// that a key added or removed by another instance sharing the database is
+// picked up without querying it on every request.
+const apiKeyCacheTTL = time.Minute
+
+// ErrUnknownAPIKey is returned for the API keys that are not stored.
+var ErrUnknownAPIKey = errors.New("unknown API key")
+
+// APIKey identifies a client of the API. Only the SHA-256 hash of the key is
+// stored, the key itself is shown once when it is created.
+type APIKey struct {
+	Name string
+	Hash string
+	// RateLimit is the number of requests per minute allowed for the key,
+	// 0 for the default of the server.
+	RateLimit int
+	CreatedAt time.Time
+}
+
+// APIKeyStore stores the API keys.
+type APIKeyStore interface {
+	SaveAPIKey(ctx context.Context, key APIKey) error
+	// APIKeyByHash returns ErrUnknownAPIKey if no key has the hash.
+	APIKeyByHash(ctx context.Context, hash string) (APIKey, error)
+	APIKeys(ctx context.Context) ([]APIKey, error)
+	// DeleteAPIKey returns ErrUnknownAPIKey if no key is called name.
+	DeleteAPIKey(ctx context.Context, name string) error
+}
+
+// AccessPolicy restricts the access to the HTTP server.
+type AccessPolicy struct {
+	// RequireAPIKey rejects the requests to the data APIs, /api/v1,
+	// /api/export and /api/events, that do not carry a valid API key.
+	RequireAPIKey bool
+	// IPRateLimit is the number of requests per minute allowed per IP
+	// address for the requests without an API key, 0 for no limit.
+	IPRateLimit int
+	// KeyRateLimit is the number of requests per minute allowed per API
+	// key that has no limit of its own, 0 for no limit.
+	KeyRateLimit int
+	// CORSOrigins are the origins allowed to call the server from a
+	// browser, "*" allowing any origin.
+	CORSOrigins []string
+	// SyncKeys are the names of the API keys allowed to call the sync
+	// endpoints. The sync endpoints follow RequireAPIKey if it is empty.
+	SyncKeys []string
+}
+
+// HashAPIKey returns the hash under which key is stored.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// NewAPIKey returns a random API key.
+func NewAPIKey() (string, error) {
+	key := make([]byte, 24)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+type cachedAPIKey struct {
+	key     *APIKey
+	expires time.Time
+}
+
+// apiKeyCache keeps the recent lookups of API keys by hash, unknown keys
+// included.
+type apiKeyCache struct {
+	mtx  sync.Mutex
+	keys map[string]cachedAPIKey
+}
+
+// lookup returns the key with hash, nil if it is unknown.
+func (c *apiKeyCache) lookup(ctx context.Context, store APIKeyStore, hash string) (*APIKey, error) {
+	now := time.Now()
+	c.mtx.Lock()
+	cached, found := c.keys[hash]
+	c.mtx.Unlock()
+	if found && now.Before(cached.expires) {
+		return cached.key, nil
+	}
+
+	var key *APIKey
+	stored, err := store.APIKeyByHash(ctx, hash)
+	if err == nil {
+		key = &stored
+	} else if err != ErrUnknownAPIKey {
+		return nil, err
+	}
+	c.mtx.Lock()
+	if c.keys == nil || len(c.keys) > 10000 {
+		c.keys = make(map[string]cachedAPIKey)
+	}
+	c.keys[hash] = cachedAPIKey{key: key, expires: now.Add(apiKeyCacheTTL)}
+	c.mtx.Unlock()
+	return key, nil
+}
+
+func (c *apiKeyCache) clear() {
+	c.mtx.Lock()
+	c.keys = nil
+	c.mtx.Unlock()
+}
+
+// adminAPIKey is an API key as listed by the admin API.
+type adminAPIKey struct {
+	Name      string `json:"name"`
+	Key       string `json:"key,omitempty"`
+	RateLimit int    `json:"rate_limit"`
+	Sync      bool   `json:"sync"`
+	CreatedAt string `json:"created_at"`
+}
+
+func (s *Server) newAdminAPIKey(key APIKey) adminAPIKey {
+	return adminAPIKey{
+		Name:      key.Name,
+		RateLimit: key.RateLimit,
+		Sync:      s.maySync(&key),
+		CreatedAt: formatAdminTime(key.CreatedAt),
+	}
+}
+
+// /admin/api/keys
+func (s *Server) adminAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.db.APIKeys(r.Context())
+	if err != nil {
+		log.Errorf("Cannot read the API keys, %s", err.Error())
+		s.renderAdminError(http.StatusInternalServerError, "cannot read the API keys", w)
+		return
+	}
+	records := make([]adminAPIKey, len(keys))
+	for i, key := range keys {
+		records[i] = s.newAdminAPIKey(key)
+	}
+	s.renderJSON(records, w)
+}
+
+// /admin/api/keys creates a key from a JSON object with its name and its
+// optional rate_limit. The response holds the key, which is not stored.
+func (s *Server) adminCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name      string `json:"name"`
+		RateLimit int    `json:"rate_limit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.renderAdminError(http.StatusBadRequest, fmt.Sprintf("invalid request, %s", err.Error()), w)
+		return
+	}
+	if request.Name == "" || request.RateLimit < 0 {
+		s.renderAdminError(http.StatusBadRequest, "a name and a positive rate_limit are required", w)
+		return
+	}
+
+	secret, err := NewAPIKey()
+	if err != nil {
+		log.Errorf("Cannot generate an API key, %s", err.Error())
+		s.renderAdminError(http.StatusInternalServerError, "cannot generate an API key", w)
+		return
+	}
+	key := APIKey{
+		Name:      request.Name,
+		Hash:      HashAPIKey(secret),
+		RateLimit: request.RateLimit,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err = s.db.SaveAPIKey(r.Context(), key); err != nil {
+		s.renderAdminError(http.StatusConflict, fmt.Sprintf("cannot save the API key, %s", err.Error()), w)
+		return
+	}
+	s.apiKeys.clear()
+
+	record := s.newAdminAPIKey(key)
+	record.Key = secret
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	s.renderJSON(record, w)
+}
+
+// /admin/api/keys/{name}
+func (s *Server) adminDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	err := s.db.DeleteAPIKey(r.Context(), chi.URLParam(r, "name"))
+	if err == ErrUnknownAPIKey {
+		s.renderAdminError(http.StatusNotFound, err.Error(), w)
+		return
+	}
+	if err != nil {
+		log.Errorf("Cannot delete the API key, %s", err.Error())
+		s.renderAdminError(http.StatusInternalServerError, "cannot delete the API key", w)
+		return
+	}
+	s.apiKeys.clear()
+	s.renderJSON(map[string]interface{}{"success": true}, w)
+}
@@ -0,0 +1,197 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/planetdecred/dcrextdata/metrics"
+	"github.com/planetdecred/dcrextdata/netsnapshot"
+)
+
+const (
+	// subscriberBuffer is the number of events a subscriber may lag behind
+	// before the next events are dropped.
+	subscriberBuffer = 64
+
+	// eventKeepAlive is the interval of the comments sent to keep idle
+	// connections open through proxies.
+	eventKeepAlive = 30 * time.Second
+)
+
+// eventTopics are the topics published by the collectors.
+var eventTopics = []string{
+	mempool.BlockTopic,
+	mempool.VoteTopic,
+	mempool.MempoolTopic,
+	ticks.TickTopic,
+	netsnapshot.HeartbeatTopic,
+}
+
+var droppedEvents = metrics.NewCounterVec("dcrextdata_dropped_events_total",
+	"Live update events dropped because a subscriber lagged behind, by topic.", "topic")
+
+// Event is a record published on a topic.
+type Event struct {
+	Topic string
+	Data  interface{}
+}
+
+type subscriber struct {
+	topics map[string]bool
+	events chan Event
+}
+
+// Hub passes the records published by the collectors to the subscribed web
+// clients. Publishing never blocks, the events are dropped for the
+// subscribers that lag behind.
+type Hub struct {
+	mtx         sync.RWMutex
+	subscribers map[*subscriber]struct{}
+}
+
+// NewHub returns a Hub without subscribers.
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*subscriber]struct{})}
+}
+
+// Publish sends data to the subscribers of topic.
+func (h *Hub) Publish(topic string, data interface{}) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	for sub := range h.subscribers {
+		if !sub.topics[topic] {
+			continue
+		}
+		select {
+		case sub.events <- Event{Topic: topic, Data: data}:
+		default:
+			droppedEvents.Inc(topic)
+		}
+	}
+}
+
+func (h *Hub) subscribe(topics []string) *subscriber {
+	sub := &subscriber{
+		topics: make(map[string]bool),
+		events: make(chan Event, subscriberBuffer),
+	}
+	for _, topic := range topics {
+		sub.topics[topic] = true
+	}
+	h.mtx.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mtx.Unlock()
+	return sub
+}
+
+func (h *Hub) unsubscribe(sub *subscriber) {
+	h.mtx.Lock()
+	delete(h.subscribers, sub)
+	h.mtx.Unlock()
+}
+
+func (s *Server) registerEventHandlers(r *chi.Mux) {
+	r.With(s.requireAPIKey).Get("/api/events", s.events)
+}
+
+// /api/events streams the events of the topics listed, separated by commas,
+// in the topics parameter as Server-Sent Events. Every topic is streamed if
+// the parameter is missing.
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	topics := eventTopics
+	if param := r.URL.Query().Get("topics"); param != "" {
+		topics = strings.Split(param, ",")
+		for _, topic := range topics {
+			if !isEventTopic(topic) {
+				s.renderAPIError(w, http.StatusBadRequest, "invalid_parameter",
+					fmt.Sprintf("unknown topic %s, expected one of %s", topic, strings.Join(eventTopics, ", ")))
+				return
+			}
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.renderAPIError(w, http.StatusInternalServerError, "internal_error", "streaming is not supported")
+		return
+	}
+
+	sub := s.hub.subscribe(topics)
+	defer s.hub.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event := <-sub.events:
+			data, err := json.Marshal(eventRecord(event.Data))
+			if err != nil {
+				log.Errorf("Cannot encode the %s event, %s", event.Topic, err.Error())
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Topic, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func isEventTopic(topic string) bool {
+	for _, known := range eventTopics {
+		if topic == known {
+			return true
+		}
+	}
+	return false
+}
+
+// eventRecord returns the record of the v1 API for the collected data, so
+// that the events and the API share their encoding.
+func eventRecord(data interface{}) interface{} {
+	switch record := data.(type) {
+	case ticks.TickSyncDto:
+		return newAPITick(record)
+	case mempool.Mempool:
+		return newAPIMempool(record)
+	case mempool.Block:
+		return newAPIBlock(record)
+	case mempool.Vote:
+		return newAPIVote(record)
+	case netsnapshot.Heartbeat:
+		return apiHeartbeat{
+			Time:          time.Unix(record.Timestamp, 0).UTC(),
+			Address:       record.Address,
+			LastSeen:      time.Unix(record.LastSeen, 0).UTC(),
+			Latency:       record.Latency,
+			CurrentHeight: record.CurrentHeight,
+		}
+	}
+	return data
+}
+
+type apiHeartbeat struct {
+	Time          time.Time `json:"time"`
+	Address       string    `json:"address"`
+	LastSeen      time.Time `json:"last_seen"`
+	Latency       int       `json:"latency"`
+	CurrentHeight int64     `json:"current_height"`
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/mempool"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	blocks := hub.subscribe([]string{mempool.BlockTopic})
+	all := hub.subscribe(eventTopics)
+
+	hub.Publish(mempool.BlockTopic, 1)
+	hub.Publish(ticks.TickTopic, 2)
+	if len(blocks.events) != 1 || len(all.events) != 2 {
+		t.Fatalf("expected 1 and 2 events, got %d and %d", len(blocks.events), len(all.events))
+	}
+	if event := <-blocks.events; event.Topic != mempool.BlockTopic || event.Data != 1 {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	// the events are dropped for the subscribers that do not read them,
+	// without blocking the publisher nor the other subscribers
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*subscriberBuffer; i++ {
+			hub.Publish(mempool.BlockTopic, i)
+			<-blocks.events
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow subscriber blocks the publisher")
+	}
+	if len(all.events) != subscriberBuffer {
+		t.Errorf("expected the %d buffered events of the slow subscriber, got %d", subscriberBuffer, len(all.events))
+	}
+	if event := <-all.events; event.Topic != mempool.BlockTopic || event.Data != 1 {
+		t.Errorf("expected the oldest events to be kept, got %+v", event)
+	}
+
+	hub.unsubscribe(blocks)
+	hub.Publish(mempool.BlockTopic, 3)
+	if len(blocks.events) != 0 {
+		t.Error("an unsubscribed client received an event")
+	}
+}
+
+// subscriberCount returns the number of subscribers of hub.
+func (h *Hub) subscriberCount() int {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return len(h.subscribers)
+}
+
+// waitFor polls condition until it holds or a second has passed.
+func waitFor(condition func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+	return condition()
+}
+
+func TestEventStream(t *testing.T) {
+	s := &Server{hub: NewHub()}
+	router := chi.NewRouter()
+	s.useMiddlewares(router)
+	s.registerEventHandlers(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/events?topics="+mempool.BlockTopic, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// asking for a compressed response explicitly keeps the transport from
+	// decompressing it, the stream must be sent as is
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d, %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		t.Fatalf("expected an uncompressed stream, got %s", encoding)
+	}
+	if !waitFor(func() bool { return s.hub.subscriberCount() == 1 }) {
+		t.Fatal("the client is not subscribed")
+	}
+
+	lines := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	readLine := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("the event is not flushed")
+		}
+		return ""
+	}
+
+	receiveTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.hub.Publish(mempool.VoteTopic, mempool.Vote{Hash: "vote"})
+	s.hub.Publish(mempool.BlockTopic, mempool.Block{BlockHeight: 5, BlockHash: "hash", BlockReceiveTime: receiveTime})
+	if line := readLine(); line != "event: "+mempool.BlockTopic {
+		t.Fatalf("expected the block event, got %q", line)
+	}
+	line := readLine()
+	if !strings.HasPrefix(line, "data: ") {
+		t.Fatalf("expected the event data, got %q", line)
+	}
+	var block apiBlock
+	if err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &block); err != nil {
+		t.Fatal(err)
+	}
+	if block.Height != 5 || block.Hash != "hash" || !block.ReceiveTime.Equal(receiveTime) {
+		t.Errorf("unexpected block %+v", block)
+	}
+
+	cancel()
+	if !waitFor(func() bool { return s.hub.subscriberCount() == 0 }) {
+		t.Error("the client is still subscribed after disconnecting")
+	}
+}
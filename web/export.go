@@ -0,0 +1,116 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/planetdecred/dcrextdata/cache"
+	"github.com/planetdecred/dcrextdata/export"
+)
+
+func (s *Server) registerExportHandlers(r *chi.Mux) {
+	r.With(s.requireAPIKey).Get("/api/export/tables/{table}", s.exportTable)
+	r.With(s.requireAPIKey, chartTypeCtx, chartDataTypeCtx).Get("/api/export/charts/{chartType}/{chartDataType}", s.exportChart)
+	r.With(s.requireAPIKey, chartTypeCtx).Get("/api/export/charts/{chartType}", s.exportChart)
+}
+
+// exportRequest reads the format and the time range of an export. The format
+// defaults to CSV.
+func (s *Server) exportRequest(w http.ResponseWriter, r *http.Request) (format string, timeRange export.Range, ok bool) {
+	query := r.URL.Query()
+	format = query.Get("format")
+	if format == "" {
+		format = export.CSV
+	}
+	if format != export.CSV && format != export.NDJSON {
+		s.renderAPIError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("format must be %s or %s", export.CSV, export.NDJSON))
+		return format, timeRange, false
+	}
+
+	var err error
+	if timeRange.Start, err = export.ParseTime(query.Get("start")); err != nil {
+		s.renderAPIError(w, http.StatusBadRequest, "invalid_parameter", fmt.Sprintf("invalid start, %s", err.Error()))
+		return format, timeRange, false
+	}
+	if timeRange.End, err = export.ParseTime(query.Get("end")); err != nil {
+		s.renderAPIError(w, http.StatusBadRequest, "invalid_parameter", fmt.Sprintf("invalid end, %s", err.Error()))
+		return format, timeRange, false
+	}
+	return format, timeRange, true
+}
+
+// exportWriter returns a writer of format to w, named filename for the
+// browsers that save it.
+func exportWriter(w http.ResponseWriter, format, filename string) export.Writer {
+	writer, _ := export.NewWriter(format, w)
+	w.Header().Set("Content-Type", writer.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+	return writer
+}
+
+// /api/export/tables/{table} streams the rows of a table.
+func (s *Server) exportTable(w http.ResponseWriter, r *http.Request) {
+	table, err := export.TableByName(chi.URLParam(r, "table"))
+	if err != nil {
+		s.renderAPIError(w, http.StatusNotFound, "not_found", err.Error())
+		return
+	}
+	format, timeRange, ok := s.exportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// the status is sent with the first row, a failure past it can only cut
+	// the response short
+	writer := exportWriter(w, format, table.Name)
+	if err = s.db.ExportTable(r.Context(), table, timeRange, writer); err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		log.Errorf("Error in exporting the %s table, %s", table.Name, err.Error())
+	}
+}
+
+// /api/export/charts/{chartType}/{chartDataType} writes the points of a chart,
+// requested with the parameters of /api/charts.
+func (s *Server) exportChart(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	chartType := getChartTypeCtx(r)
+	dataType := getChartDataTypeCtx(r)
+	axis := r.URL.Query().Get("axis")
+	format, timeRange, ok := s.exportRequest(w, r)
+	if !ok {
+		return
+	}
+	extras, err := chartExtras(r, chartType)
+	if err != nil {
+		s.renderAPIError(w, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return
+	}
+
+	chartData, err := s.charts.Chart(r.Context(), chartType, dataType, axis, r.URL.Query().Get("bin"), extras...)
+	if err == cache.UnknownChartErr {
+		s.renderAPIError(w, http.StatusNotFound, "not_found", err.Error())
+		return
+	}
+	if err != nil {
+		log.Warnf("Error fetching %s chart: %v", chartType, err)
+		s.renderAPIError(w, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return
+	}
+
+	filename := chartType
+	if dataType != "" {
+		filename += "-" + dataType
+	}
+	writer := exportWriter(w, format, filename)
+	timeAxis := cache.ParseAxis(axis) != cache.HeightAxis
+	if err = export.WriteChart(chartData, timeAxis, timeRange, writer); err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		log.Errorf("Error in exporting the %s chart, %s", chartType, err.Error())
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/planetdecred/dcrextdata/cache"
 	"github.com/planetdecred/dcrextdata/commstats"
 	"github.com/planetdecred/dcrextdata/datasync"
+	"github.com/planetdecred/dcrextdata/exchanges/ticks"
 	"github.com/planetdecred/dcrextdata/netsnapshot"
 	"github.com/planetdecred/dcrextdata/postgres/models"
 	"github.com/planetdecred/dcrextdata/pow"
@@ -109,12 +110,13 @@ func (s *Server) homePage(res http.ResponseWriter, req *http.Request) {
 	}
 
 	data := map[string]interface{}{
-		"mempoolCount": mempoolCount,
-		"blocksCount":  blocksCount,
-		"votesCount":   votesCount,
-		"powCount":     powCount,
-		"vspCount":     vspCount,
-		"exchangeTick": exchangeCount,
+		"mempoolCount":   mempoolCount,
+		"blocksCount":    blocksCount,
+		"votesCount":     votesCount,
+		"powCount":       powCount,
+		"vspCount":       vspCount,
+		"exchangeTick":   exchangeCount,
+		"exchangeHealth": ticks.ExchangeStatuses(),
 	}
 
 	s.render("home.html", data, res)
@@ -346,6 +348,48 @@ func (s *Server) currencyPairByExchange(res http.ResponseWriter, req *http.Reque
 	s.renderJSON(result, res)
 }
 
+// /api/exchanges/index returns the volume weighted cross exchange price of
+// DCR in BTC or, with currency=usd, in USD
+func (s *Server) exchangeIndex(res http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	page, _ := strconv.Atoi(req.FormValue("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(req.FormValue("page-size"))
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	} else if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	currencyPair := ticks.IndexBTCPair
+	if strings.ToLower(req.FormValue("currency")) == "usd" {
+		currencyPair = ticks.IndexUSDPair
+	}
+
+	bin := req.FormValue("bin")
+	if bin == "" {
+		bin = string(cache.HourBin)
+	}
+
+	prices, total, err := s.db.ExchangeIndex(req.Context(), currencyPair, bin, (page-1)*pageSize, pageSize)
+	if err != nil {
+		s.renderErrorJSON("error in loading exchange index, "+err.Error(), res)
+		return
+	}
+
+	s.renderJSON(map[string]interface{}{
+		"currencyPair": currencyPair,
+		"bin":          bin,
+		"records":      prices,
+		"currentPage":  page,
+		"pageSize":     pageSize,
+		"totalCount":   total,
+	}, res)
+}
+
 // /vsps
 func (s *Server) getVspTicks(res http.ResponseWriter, req *http.Request) {
 	vsps, err := s.fetchVSPData(req)
@@ -1740,30 +1784,38 @@ func (s *Server) sync(res http.ResponseWriter, req *http.Request) {
 }
 
 // api/charts/{chartType}/{dataType}
+// chartExtras returns the extras of the chartType chart requested by r, the
+// sources separated by | or, for the exchange chart, the exchange set key.
+// r.ParseForm must have been called.
+func chartExtras(r *http.Request, chartType string) ([]string, error) {
+	if chartType != cache.Exchange {
+		return strings.Split(r.URL.Query().Get("extras"), "|"), nil
+	}
+
+	selectedCurrencyPair := r.FormValue("selected-currency-pair")
+	selectedInterval := r.FormValue("selected-interval")
+	selectedExchange := r.FormValue("selected-exchange")
+
+	interval, err := strconv.Atoi(selectedInterval)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid interval, %s", err.Error())
+	}
+	return []string{cache.BuildExchangeKey(selectedExchange, selectedCurrencyPair, interval)}, nil
+}
+
 func (s *Server) chartTypeData(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
 	chartType := getChartTypeCtx(r)
 	dataType := getChartDataTypeCtx(r)
 	bin := r.URL.Query().Get("bin")
 	axis := r.URL.Query().Get("axis")
-	extras := r.URL.Query().Get("extras")
-
-	// the extra data passed for exchange chart is the exchange set key
-	if chartType == cache.Exchange {
-		selectedCurrencyPair := r.FormValue("selected-currency-pair")
-		selectedInterval := r.FormValue("selected-interval")
-		selectedExchange := r.FormValue("selected-exchange")
-
-		interval, err := strconv.Atoi(selectedInterval)
-		if err != nil {
-			s.renderErrorJSON(fmt.Sprintf("Invalid interval, %s", err.Error()), w)
-			return
-		}
-
-		extras = cache.BuildExchangeKey(selectedExchange, selectedCurrencyPair, interval)
+	extras, err := chartExtras(r, chartType)
+	if err != nil {
+		s.renderErrorJSON(err.Error(), w)
+		return
 	}
 
-	chartData, err := s.charts.Chart(r.Context(), chartType, dataType, axis, bin, strings.Split(extras, "|")...)
+	chartData, err := s.charts.Chart(r.Context(), chartType, dataType, axis, bin, extras...)
 	if err != nil {
 		s.renderErrorJSON(err.Error(), w)
 		log.Warnf(`Error fetching %s chart: %v`, chartType, err)
@@ -1810,12 +1862,13 @@ func (s *Server) statsPage(res http.ResponseWriter, req *http.Request) {
 	}
 
 	data := map[string]interface{}{
-		"mempoolCount": mempoolCount,
-		"blocksCount":  blocksCount,
-		"votesCount":   votesCount,
-		"powCount":     powCount,
-		"vspCount":     vspCount,
-		"exchangeTick": exchangeCount,
+		"mempoolCount":   mempoolCount,
+		"blocksCount":    blocksCount,
+		"votesCount":     votesCount,
+		"powCount":       powCount,
+		"vspCount":       vspCount,
+		"exchangeTick":   exchangeCount,
+		"exchangeHealth": ticks.ExchangeStatuses(),
 	}
 
 	s.render("stats.html", data, res)
@@ -0,0 +1,184 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// healthCheckTimeout bounds the time spent checking a dependency.
+const healthCheckTimeout = 5 * time.Second
+
+// DcrdConnection reports the state of the RPC connection to dcrd.
+type DcrdConnection interface {
+	Connected() bool
+}
+
+// Health holds the dependencies checked by /healthz and /readyz. Some of them
+// become available after the server started, they are set as the startup
+// progresses.
+type Health struct {
+	mtx         sync.RWMutex
+	database    func(ctx context.Context) error
+	dcrd        DcrdConnection
+	chartsReady bool
+}
+
+// NewHealth returns the health of an instance whose database is checked with
+// pingDatabase. A nil pingDatabase skips the database check.
+func NewHealth(pingDatabase func(ctx context.Context) error) *Health {
+	return &Health{database: pingDatabase}
+}
+
+// SetDcrd adds the RPC connection to dcrd to the checked dependencies.
+func (h *Health) SetDcrd(dcrd DcrdConnection) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.dcrd = dcrd
+}
+
+// SetChartsReady records that the initial update of the chart bins finished.
+func (h *Health) SetChartsReady() {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.chartsReady = true
+}
+
+// healthCheck is the state of a dependency as reported by the health
+// endpoints.
+type healthCheck struct {
+	Name        string  `json:"name"`
+	Type        string  `json:"type"`
+	Healthy     bool    `json:"healthy"`
+	Message     string  `json:"message,omitempty"`
+	LastSuccess string  `json:"last_success,omitempty"`
+	StaleAfter  float64 `json:"stale_after,omitempty"`
+	// degrades tells that the failure of the check degrades the service
+	// without making the instance unavailable
+	degrades bool
+}
+
+type healthReport struct {
+	Status string        `json:"status"`
+	Checks []healthCheck `json:"checks"`
+}
+
+func (s *Server) registerHealthHandlers(r *chi.Mux) {
+	r.Get("/healthz", s.healthz)
+	r.Get("/readyz", s.readyz)
+}
+
+// /healthz reports whether the database and dcrd can be reached and whether
+// every collection job succeeded recently. A stale job, that depends on a
+// third party, degrades the instance but does not fail the check, so that a
+// liveness probe does not restart it.
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	checks := s.dependencyChecks(r.Context())
+	if s.jobs != nil {
+		for _, status := range s.jobs.Statuses() {
+			check := healthCheck{
+				Name:        status.Name,
+				Type:        "job",
+				Healthy:     !status.Stale,
+				degrades:    true,
+				LastSuccess: formatAdminTime(status.LastSuccess),
+				StaleAfter:  status.StaleAfter.Seconds(),
+			}
+			switch {
+			case status.Paused:
+				check.Message = "paused"
+			case status.Stale:
+				check.Message = "no successful run within the staleness threshold"
+				if status.LastError != "" {
+					check.Message += ", last error: " + status.LastError
+				}
+			}
+			checks = append(checks, check)
+		}
+	}
+	s.renderHealth(checks, w)
+}
+
+// /readyz reports whether the instance can serve requests, i.e. the database
+// can be reached and the initial update of the chart bins finished.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	var checks []healthCheck
+	if check, found := s.databaseCheck(r.Context()); found {
+		checks = append(checks, check)
+	}
+
+	s.health.mtx.RLock()
+	chartsReady := s.health.chartsReady
+	s.health.mtx.RUnlock()
+	check := healthCheck{Name: "charts", Type: "charts", Healthy: chartsReady}
+	if !chartsReady {
+		check.Message = "the initial chart bin update is in progress"
+	}
+	checks = append(checks, check)
+
+	s.renderHealth(checks, w)
+}
+
+// dependencyChecks checks the database and the connection to dcrd.
+func (s *Server) dependencyChecks(ctx context.Context) []healthCheck {
+	var checks []healthCheck
+	if check, found := s.databaseCheck(ctx); found {
+		checks = append(checks, check)
+	}
+
+	s.health.mtx.RLock()
+	dcrd := s.health.dcrd
+	s.health.mtx.RUnlock()
+	if dcrd != nil {
+		check := healthCheck{Name: "dcrd", Type: "rpc", Healthy: dcrd.Connected()}
+		if !check.Healthy {
+			check.Message = "the RPC connection to dcrd is down"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// databaseCheck pings the database. found is false if there is no database to
+// check.
+func (s *Server) databaseCheck(ctx context.Context) (check healthCheck, found bool) {
+	if s.health.database == nil {
+		return check, false
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	check = healthCheck{Name: "postgresql", Type: "database", Healthy: true}
+	if err := s.health.database(ctx); err != nil {
+		check.Healthy = false
+		check.Message = err.Error()
+	}
+	return check, true
+}
+
+// renderHealth writes the report of checks, with a 503 status if one of them
+// failed. The failed checks that only degrade the service set the degraded
+// status with a 200.
+func (s *Server) renderHealth(checks []healthCheck, w http.ResponseWriter) {
+	report := healthReport{Status: "ok", Checks: checks}
+	status := http.StatusOK
+	for _, check := range checks {
+		if check.Healthy {
+			continue
+		}
+		if !check.degrades {
+			report.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			break
+		}
+		report.Status = "degraded"
+	}
+	if report.Checks == nil {
+		report.Checks = []healthCheck{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	s.renderJSON(report, w)
+}
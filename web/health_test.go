@@ -0,0 +1,138 @@
+// Copyright (c) 2018-2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/planetdecred/dcrextdata/scheduler"
+)
+
+// testJobs is a JobController of fixed jobs that records the calls made to
+// it.
+type testJobs struct {
+	statuses []scheduler.JobStatus
+	calls    []string
+}
+
+func (j *testJobs) Statuses() []scheduler.JobStatus { return j.statuses }
+
+func (j *testJobs) call(action, name string) error {
+	for _, status := range j.statuses {
+		if status.Name == name {
+			j.calls = append(j.calls, action+" "+name)
+			return nil
+		}
+	}
+	return errors.New("unknown job, " + name)
+}
+
+func (j *testJobs) Trigger(name string) error { return j.call("trigger", name) }
+func (j *testJobs) Pause(name string) error   { return j.call("pause", name) }
+func (j *testJobs) Resume(name string) error  { return j.call("resume", name) }
+
+type testDcrd bool
+
+func (d testDcrd) Connected() bool { return bool(d) }
+
+func TestHealthHandlers(t *testing.T) {
+	var databaseErr error
+	ping := func(context.Context) error { return databaseErr }
+	fresh := scheduler.JobStatus{Name: "pow", LastSuccess: time.Now(), StaleAfter: time.Hour}
+	stale := scheduler.JobStatus{Name: "commstats-twitter", Stale: true, LastError: "rate limited", StaleAfter: time.Hour}
+
+	tests := []struct {
+		name        string
+		path        string
+		database    error
+		dcrd        *testDcrd
+		jobs        []scheduler.JobStatus
+		chartsReady bool
+		code        int
+		status      string
+		failed      []string
+	}{
+		{name: "healthy", path: "/healthz", dcrd: newTestDcrd(true), jobs: []scheduler.JobStatus{fresh},
+			code: http.StatusOK, status: "ok"},
+		{name: "database down", path: "/healthz", database: errors.New("connection refused"), jobs: []scheduler.JobStatus{fresh},
+			code: http.StatusServiceUnavailable, status: "unavailable", failed: []string{"postgresql"}},
+		{name: "dcrd down", path: "/healthz", dcrd: newTestDcrd(false),
+			code: http.StatusServiceUnavailable, status: "unavailable", failed: []string{"dcrd"}},
+		{name: "stale job", path: "/healthz", jobs: []scheduler.JobStatus{fresh, stale},
+			code: http.StatusOK, status: "degraded", failed: []string{"commstats-twitter"}},
+		{name: "stale job and dcrd down", path: "/healthz", dcrd: newTestDcrd(false), jobs: []scheduler.JobStatus{stale},
+			code: http.StatusServiceUnavailable, status: "unavailable", failed: []string{"dcrd", "commstats-twitter"}},
+		{name: "charts not ready", path: "/readyz",
+			code: http.StatusServiceUnavailable, status: "unavailable", failed: []string{"charts"}},
+		{name: "ready", path: "/readyz", jobs: []scheduler.JobStatus{stale}, chartsReady: true,
+			code: http.StatusOK, status: "ok"},
+		{name: "ready without database", path: "/readyz", database: errors.New("connection refused"), chartsReady: true,
+			code: http.StatusServiceUnavailable, status: "unavailable", failed: []string{"postgresql"}},
+	}
+	for _, test := range tests {
+		databaseErr = test.database
+		health := NewHealth(ping)
+		if test.dcrd != nil {
+			health.SetDcrd(*test.dcrd)
+		}
+		if test.chartsReady {
+			health.SetChartsReady()
+		}
+		s := &Server{health: health, jobs: &testJobs{statuses: test.jobs}}
+		router := chi.NewRouter()
+		s.registerHealthHandlers(router)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Code != test.code {
+			t.Errorf("%s: expected a %d, got %d", test.name, test.code, rec.Code)
+		}
+		var report struct {
+			Status string `json:"status"`
+			Checks []struct {
+				Name    string `json:"name"`
+				Healthy bool   `json:"healthy"`
+				Message string `json:"message"`
+			} `json:"checks"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if report.Status != test.status {
+			t.Errorf("%s: expected the %s status, got %s", test.name, test.status, report.Status)
+		}
+		var failed []string
+		for _, check := range report.Checks {
+			if !check.Healthy {
+				failed = append(failed, check.Name)
+				if check.Message == "" {
+					t.Errorf("%s: the failed %s check has no message", test.name, check.Name)
+				}
+			}
+		}
+		if len(failed) != len(test.failed) {
+			t.Errorf("%s: expected the failed checks %v, got %v", test.name, test.failed, failed)
+			continue
+		}
+		for i := range failed {
+			if failed[i] != test.failed[i] {
+				t.Errorf("%s: expected the failed checks %v, got %v", test.name, test.failed, failed)
+				break
+			}
+		}
+	}
+}
+
+func newTestDcrd(connected bool) *testDcrd {
+	dcrd := testDcrd(connected)
+	return &dcrd
+}
@@ -2,10 +2,19 @@ package web
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/planetdecred/dcrextdata/datasync"
+	"github.com/planetdecred/dcrextdata/metrics"
 )
 
 type contextKey int
@@ -16,8 +25,40 @@ const (
 	ctxNodeIp
 	ctxChartType
 	ctxChartDataType
+	ctxJobName
+	ctxAPIKey
 )
 
+var requestDuration = metrics.NewHistogramVec("dcrextdata_http_request_duration_seconds",
+	"Latency of the HTTP handlers, by route, method and status code.", metrics.DefBuckets, "route", "method", "code")
+
+var rateLimitedRequests = metrics.NewCounterVec("dcrextdata_http_rate_limited_total",
+	"Requests rejected by the rate limits, by kind of client.", "client")
+
+// unlimitedPaths are the path prefixes that are not rate limited, the static
+// files loaded with every page and the endpoints of the monitoring.
+var unlimitedPaths = []string{"/static/", "/healthz", "/readyz", "/metrics"}
+
+// instrumentHandler records the latency of the requests under their route
+// pattern, so that the URL parameters do not create a series per value.
+func instrumentHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		requestDuration.ObserveSince(start, route, r.Method, strconv.Itoa(status))
+	})
+}
+
 func syncDataType(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := context.WithValue(r.Context(), ctxSyncDataType,
@@ -111,3 +152,226 @@ func getChartDataTypeCtx(r *http.Request) string {
 	}
 	return chartAxisType
 }
+
+func addJobNameToCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), ctxJobName,
+			chi.URLParam(r, "name"))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getJobNameCtx(r *http.Request) string {
+	name, _ := r.Context().Value(ctxJobName).(string)
+	return name
+}
+
+// cors answers the preflight requests and allows the origins of the access
+// policy to read the responses.
+func (s *Server) cors(next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(s.access.CORSOrigins))
+	for _, origin := range s.access.CORSOrigins {
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !(allowed["*"] || allowed[origin]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if allowed["*"] {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-API-Key")
+		w.Header().Set("Access-Control-Max-Age", "600")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// identifyClient adds the API key of the request, sent in the X-API-Key
+// header or the api_key parameter, to the request context. The requests with
+// an unknown key are rejected.
+func (s *Server) identifyClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := r.Header.Get("X-API-Key")
+		if secret == "" {
+			secret = r.URL.Query().Get("api_key")
+		}
+		if secret == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := s.apiKeys.lookup(r.Context(), s.db, HashAPIKey(secret))
+		if err != nil {
+			log.Errorf("Cannot look the API key up, %s", err.Error())
+			s.renderAPIError(w, http.StatusServiceUnavailable, "unavailable", "the API key cannot be checked")
+			return
+		}
+		if key == nil {
+			s.renderAPIError(w, http.StatusUnauthorized, "unauthorized", "invalid API key")
+			return
+		}
+		ctx := context.WithValue(r.Context(), ctxAPIKey, key)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// getAPIKeyCtx retrieves the API key of the request from the request
+// context. If not set, the return value is nil.
+func getAPIKeyCtx(r *http.Request) *APIKey {
+	key, _ := r.Context().Value(ctxAPIKey).(*APIKey)
+	return key
+}
+
+// limitRate rejects the requests of the clients that exceed their rate
+// limit. The clients with an API key are limited per key, the others per IP
+// address.
+func (s *Server) limitRate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range unlimitedPaths {
+			if strings.HasPrefix(r.URL.Path, path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		client, limit, kind := "ip:"+clientIP(r), s.access.IPRateLimit, "ip"
+		if key := getAPIKeyCtx(r); key != nil {
+			client, limit, kind = "key:"+key.Name, s.access.KeyRateLimit, "key"
+			if key.RateLimit > 0 {
+				limit = key.RateLimit
+			}
+		}
+		if limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if wait := s.limiter.reserve(client, limit, time.Now()); wait > 0 {
+			rateLimitedRequests.Inc(kind)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			s.renderAPIError(w, http.StatusTooManyRequests, "rate_limited",
+				fmt.Sprintf("rate limit of %d requests per minute exceeded", limit))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requireAPIKey rejects the requests without an API key if the access policy
+// requires one.
+func (s *Server) requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.access.RequireAPIKey && getAPIKeyCtx(r) == nil {
+			s.renderAPIError(w, http.StatusUnauthorized, "unauthorized", "an API key is required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireSyncKey rejects the sync requests of the clients that may not sync.
+// The reason is given as a failed sync result, as expected by the instances
+// syncing from this one.
+func (s *Server) requireSyncKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := getAPIKeyCtx(r)
+		if s.maySync(key) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		status, message := http.StatusForbidden, "the API key is not allowed to sync"
+		if key == nil {
+			status, message = http.StatusUnauthorized, "an API key is required to sync"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		s.renderJSON(datasync.Result{Message: message}, w)
+	})
+}
+
+// maySync tells whether the client with key, nil for none, may call the sync
+// endpoints.
+func (s *Server) maySync(key *APIKey) bool {
+	if len(s.access.SyncKeys) == 0 {
+		return key != nil || !s.access.RequireAPIKey
+	}
+	if key == nil {
+		return false
+	}
+	for _, name := range s.access.SyncKeys {
+		if name == key.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// rateLimiter keeps a token bucket per client, holding up to a minute of
+// requests.
+type rateLimiter struct {
+	mtx       sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// reserve takes a token from the bucket of client, refilled at perMinute
+// tokens per minute. It returns 0 if a token was taken and otherwise how long
+// until the next token.
+func (l *rateLimiter) reserve(client string, perMinute int, now time.Time) time.Duration {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	// a bucket untouched for a minute is full, dropping it changes nothing
+	if now.Sub(l.lastPrune) > time.Minute {
+		for name, bucket := range l.buckets {
+			if now.Sub(bucket.lastRefill) > time.Minute {
+				delete(l.buckets, name)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	capacity := float64(perMinute)
+	bucket, found := l.buckets[client]
+	if !found {
+		bucket = &tokenBucket{tokens: capacity, lastRefill: now}
+		l.buckets[client] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.lastRefill).Minutes() * capacity
+	if bucket.tokens > capacity {
+		bucket.tokens = capacity
+	}
+	bucket.lastRefill = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0
+	}
+	return time.Duration((1 - bucket.tokens) / capacity * float64(time.Minute))
+}
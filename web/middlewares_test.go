@@ -0,0 +1,49 @@
+package web
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	var limiter rateLimiter
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if wait := limiter.reserve("a", 3, now); wait != 0 {
+			t.Fatalf("request %d was limited", i)
+		}
+	}
+	if wait := limiter.reserve("a", 3, now); wait != 20*time.Second {
+		t.Errorf("expected to wait 20s, got %v", wait)
+	}
+	if wait := limiter.reserve("b", 3, now); wait != 0 {
+		t.Error("the clients must have their own limit")
+	}
+	if wait := limiter.reserve("a", 3, now.Add(20*time.Second)); wait != 0 {
+		t.Error("a token must be added every 20s")
+	}
+}
+
+func TestMaySync(t *testing.T) {
+	key := &APIKey{Name: "mirror"}
+	other := &APIKey{Name: "other"}
+	tests := []struct {
+		access AccessPolicy
+		key    *APIKey
+		allow  bool
+	}{
+		{AccessPolicy{}, nil, true},
+		{AccessPolicy{RequireAPIKey: true}, nil, false},
+		{AccessPolicy{RequireAPIKey: true}, other, true},
+		{AccessPolicy{SyncKeys: []string{"mirror"}}, nil, false},
+		{AccessPolicy{SyncKeys: []string{"mirror"}}, other, false},
+		{AccessPolicy{SyncKeys: []string{"mirror"}}, key, true},
+	}
+	for i, test := range tests {
+		s := &Server{access: test.access}
+		if allow := s.maySync(test.key); allow != test.allow {
+			t.Errorf("%d: expected %v, got %v", i, test.allow, allow)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/planetdecred/dcrextdata/app"
+)
+
+// pageParams are the parameters of the paginated endpoints of the v1 API.
+var pageParams = []apiParam{
+	{name: "start", in: "query", schemaType: "string",
+		description: "Only return the records at or after this time, RFC 3339 or UNIX timestamp"},
+	{name: "end", in: "query", schemaType: "string",
+		description: "Only return the records before this time, RFC 3339 or UNIX timestamp"},
+	{name: "cursor", in: "query", schemaType: "string",
+		description: "The next_cursor of the previous page"},
+	{name: "limit", in: "query", schemaType: "integer",
+		description: "Maximum number of records, 100 by default, at most 1000"},
+}
+
+// /api/v1/openapi.json
+func (s *Server) openAPIDocument(w http.ResponseWriter, r *http.Request) {
+	s.renderJSON(openAPIDocument(s.apiEndpoints()), w)
+}
+
+// openAPIDocument describes endpoints in an OpenAPI 3 document. The schemas of
+// the records are generated from the JSON encoding of their types.
+func openAPIDocument(endpoints []apiEndpoint) map[string]interface{} {
+	paths := make(map[string]interface{})
+	for _, endpoint := range endpoints {
+		params := endpoint.params
+		if endpoint.paginated {
+			params = append(append([]apiParam(nil), params...), pageParams...)
+		}
+		var parameters []interface{}
+		for _, param := range params {
+			schema := map[string]interface{}{"type": param.schemaType}
+			if len(param.enum) > 0 {
+				schema["enum"] = param.enum
+			}
+			parameter := map[string]interface{}{
+				"name":     param.name,
+				"in":       param.in,
+				"required": param.required || param.in == "path",
+				"schema":   schema,
+			}
+			if param.description != "" {
+				parameter["description"] = param.description
+			}
+			parameters = append(parameters, parameter)
+		}
+
+		list := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type":  "array",
+				"items": jsonSchema(reflect.TypeOf(endpoint.record)),
+			},
+		}
+		if endpoint.paginated {
+			list["next_cursor"] = map[string]interface{}{
+				"type":        "string",
+				"description": "Cursor of the next page, absent on the last page",
+			}
+		}
+		errorResponse := map[string]interface{}{
+			"description": "Error",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"},
+				},
+			},
+		}
+
+		operation := map[string]interface{}{
+			"summary": endpoint.summary,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type":       "object",
+								"properties": list,
+							},
+						},
+					},
+				},
+				"default": errorResponse,
+			},
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		paths["/api/v1"+endpoint.path] = map[string]interface{}{"get": operation}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "dcrextdata API",
+			"version": app.Version(),
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Error": jsonSchema(reflect.TypeOf(apiError{})),
+			},
+		},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonSchema returns the schema of the JSON encoding of t.
+func jsonSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" || field.PkgPath != "" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = jsonSchema(field.Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	return map[string]interface{}{}
+}
@@ -19,7 +19,9 @@ import (
 	"github.com/planetdecred/dcrextdata/cache"
 	"github.com/planetdecred/dcrextdata/commstats"
 	"github.com/planetdecred/dcrextdata/exchanges/ticks"
+	"github.com/planetdecred/dcrextdata/export"
 	"github.com/planetdecred/dcrextdata/mempool"
+	"github.com/planetdecred/dcrextdata/metrics"
 	"github.com/planetdecred/dcrextdata/netsnapshot"
 	"github.com/planetdecred/dcrextdata/postgres/models"
 	"github.com/planetdecred/dcrextdata/pow"
@@ -27,6 +29,10 @@ import (
 )
 
 type DataQuery interface {
+	APIQuery
+	APIKeyStore
+	export.Source
+
 	ExchangeTickCount(ctx context.Context) (int64, error)
 	AllExchangeTicks(ctx context.Context, currencyPair string, defaultInterval, offset, limit int) ([]ticks.TickDto, int64, error)
 	AllExchange(ctx context.Context) (models.ExchangeSlice, error)
@@ -36,6 +42,7 @@ type DataQuery interface {
 	ExchangeTicksChartData(ctx context.Context, filter string, currencyPair string, selectedInterval int, exchanges string) ([]ticks.TickChartData, error)
 	AllExchangeTicksInterval(ctx context.Context) ([]ticks.TickDtoInterval, error)
 	TickIntervalsByExchangeAndPair(ctx context.Context, exchange string, currencyPair string) ([]ticks.TickDtoInterval, error)
+	ExchangeIndex(ctx context.Context, currencyPair, bin string, offset, limit int) ([]ticks.IndexPrice, int64, error)
 
 	VspTickCount(ctx context.Context) (int64, error)
 	FetchVSPs(ctx context.Context) ([]vsp.VSPDto, error)
@@ -98,10 +105,23 @@ type Server struct {
 	activeChain  *chaincfg.Params
 	extDbFactory func(name string) (DataQuery, error)
 	charts       *cache.Manager
+	jobs         JobController
+	adminKey     string
+	health       *Health
+	hub          *Hub
+	access       AccessPolicy
+	apiKeys      apiKeyCache
+	limiter      rateLimiter
 }
 
+// StartHttpServer serves the web interface and the API. The admin API, that
+// controls the collection jobs, is only served if adminKey is set. The health
+// endpoints report the state of the dependencies held by health. The events
+// published on hub are pushed to the clients of /api/events. The API keys,
+// rate limits and CORS origins are set by access.
 func StartHttpServer(httpHost, httpPort string, charts *cache.Manager, db DataQuery,
-	activeChain *chaincfg.Params, extDbFactory func(name string) (DataQuery, error)) {
+	activeChain *chaincfg.Params, extDbFactory func(name string) (DataQuery, error), jobs JobController, adminKey string,
+	health *Health, hub *Hub, access AccessPolicy) {
 
 	server := &Server{
 		templates:    map[string]*template.Template{},
@@ -109,15 +129,26 @@ func StartHttpServer(httpHost, httpPort string, charts *cache.Manager, db DataQu
 		activeChain:  activeChain,
 		extDbFactory: extDbFactory,
 		charts:       charts,
+		jobs:         jobs,
+		adminKey:     adminKey,
+		health:       health,
+		hub:          hub,
+		access:       access,
 	}
 
 	router := chi.NewRouter()
-	router.Use(middleware.DefaultCompress)
+	server.useMiddlewares(router)
 	workDir, _ := os.Getwd()
 
 	filesDir := filepath.Join(workDir, "web/public/dist")
 	FileServer(router, "/static", http.Dir(filesDir))
 	server.registerHandlers(router)
+	server.registerAdminHandlers(router)
+	server.registerHealthHandlers(router)
+	server.registerAPIHandlers(router)
+	server.registerExportHandlers(router)
+	server.registerEventHandlers(router)
+	router.Handle("/metrics", metrics.Handler())
 
 	// load templates
 	server.loadTemplates()
@@ -133,6 +164,15 @@ func StartHttpServer(httpHost, httpPort string, charts *cache.Manager, db DataQu
 	}
 }
 
+// useMiddlewares installs the middlewares shared by every route of router.
+func (s *Server) useMiddlewares(router *chi.Mux) {
+	router.Use(instrumentHandler)
+	router.Use(s.cors)
+	router.Use(middleware.DefaultCompress)
+	router.Use(s.identifyClient)
+	router.Use(s.limitRate)
+}
+
 func FileServer(r chi.Router, path string, root http.FileSystem) {
 	if strings.ContainsAny(path, "{}*") {
 		panic("FileServer does not permit URL parameters.")
@@ -158,6 +198,7 @@ func (s *Server) registerHandlers(r *chi.Mux) {
 	r.Get("/exchangechart", s.getExchangeChartData)
 	r.Get("/api/exchanges/intervals", s.tickIntervalsByExchangeAndPair)
 	r.Get("/api/exchanges/currency-pairs", s.currencyPairByExchange)
+	r.Get("/api/exchanges/index", s.exchangeIndex)
 	r.Get("/vsp", s.getVspTicks)
 	r.Get("/vsps", s.getFilteredVspTicks)
 	r.Get("/pow", s.powPage)
@@ -192,7 +233,7 @@ func (s *Server) registerHandlers(r *chi.Mux) {
 	r.Get("/api/snapshot/node-versions", s.nodeVersions)
 	r.Get("/api/snapshot/node-countries", s.nodeCountries)
 
-	r.With(syncDataType).Get("/api/sync/{dataType}", s.sync)
+	r.With(s.requireSyncKey).With(syncDataType).Get("/api/sync/{dataType}", s.sync)
 	r.With(chartTypeCtx).With(chartDataTypeCtx).Get("/api/charts/{chartType}/{chartDataType}", s.chartTypeData)
 	r.With(chartTypeCtx).Get("/api/charts/{chartType}", s.chartTypeData)
 }